@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+	"github.com/julienschmidt/httprouter"
+)
+
+// signupsDays is how many days of signups the admin dashboard shows.
+const signupsDays = 30
+
+// signupDay is how many users signed up on a day, for the admin dashboard.
+type signupDay struct {
+	Day   time.Time
+	Count int
+}
+
+// adminStats is what the admin dashboard shows about the whole site.
+type adminStats struct {
+	Users         int
+	DisabledUsers int
+	Snippets      *models.SnippetStats
+	Signups       []*signupDay
+	MaxSignups    int
+}
+
+// idParam returns the ID in the path of r, zero when it isn't one.
+func idParam(r *http.Request) int {
+	id, err := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("id"))
+	if err != nil || id < 1 {
+		return 0
+	}
+
+	return id
+}
+
+func (app *App) adminDashboard(w http.ResponseWriter, r *http.Request) {
+	users, disabledUsers, err := app.users.Count(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	snippetStats, err := app.snippets.Stats(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, 1-signupsDays)
+	signups, err := app.users.Signups(r.Context(), since)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	snippets, err := app.snippets.Latest(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	stats := &adminStats{Users: users, DisabledUsers: disabledUsers, Snippets: snippetStats}
+
+	// the most recent day first, like the snippets.
+	stats.Signups = make([]*signupDay, signupsDays)
+	for i := range stats.Signups {
+		stats.Signups[i] = &signupDay{Day: today.AddDate(0, 0, -i)}
+	}
+	for _, created := range signups {
+		i := int(today.Sub(created.UTC().Truncate(24*time.Hour)) / (24 * time.Hour))
+		if i < 0 || i >= signupsDays {
+			continue
+		}
+
+		stats.Signups[i].Count++
+		stats.MaxSignups = max(stats.MaxSignups, stats.Signups[i].Count)
+	}
+
+	data := app.newTemplateData(r)
+	data.AdminStats = stats
+	data.Snippets = snippets
+	app.render(w, r, http.StatusOK, "admin.go.html", data)
+}
+
+func (app *App) adminUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := app.users.List(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Users = users
+	data.Roles = models.Roles
+	app.render(w, r, http.StatusOK, "admin_users.go.html", data)
+}
+
+// adminUserAction runs action on the user in the path of r, refusing when it
+// is the admin themselves so they can't lock themselves out, and redirects
+// back to the users with flash.
+func (app *App) adminUserAction(w http.ResponseWriter, r *http.Request, flash string, action func(ctx context.Context, id int) error) {
+	id := idParam(r)
+	if id == 0 {
+		app.notFound(w)
+		return
+	}
+
+	if id == app.sessionManager.GetInt(r.Context(), "authenticatedUserID") {
+		app.sessionManager.Put(r.Context(), "flash", "You can't change your own account from here.")
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	err := action(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, models.ErrInvalidRole):
+			app.clientError(w, http.StatusBadRequest)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", flash)
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (app *App) adminUserRolePost(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	role := models.Role(r.PostForm.Get("role"))
+
+	app.adminUserAction(w, r, "The role has been changed.", func(ctx context.Context, id int) error {
+		err := app.users.SetRole(ctx, id, role)
+		if err != nil {
+			return err
+		}
+
+		app.auditUser(r, models.EventUserRole, id, string(role))
+		return nil
+	})
+}
+
+func (app *App) adminUserDisablePost(w http.ResponseWriter, r *http.Request) {
+	app.adminUserAction(w, r, "The account has been disabled.", func(ctx context.Context, id int) error {
+		err := app.users.SetDisabled(ctx, id, true)
+		if err != nil {
+			return err
+		}
+
+		// the user is logged out everywhere too.
+		err = app.destroyUserSessions(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		app.auditUser(r, models.EventUserDisable, id, "")
+		return nil
+	})
+}
+
+func (app *App) adminUserEnablePost(w http.ResponseWriter, r *http.Request) {
+	app.adminUserAction(w, r, "The account has been enabled.", func(ctx context.Context, id int) error {
+		err := app.users.SetDisabled(ctx, id, false)
+		if err != nil {
+			return err
+		}
+
+		app.auditUser(r, models.EventUserEnable, id, "")
+		return nil
+	})
+}
+
+func (app *App) adminUserUnlockPost(w http.ResponseWriter, r *http.Request) {
+	app.adminUserAction(w, r, "The account has been unlocked.", func(ctx context.Context, id int) error {
+		err := app.users.Unlock(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		app.auditUser(r, models.EventUserUnlock, id, "")
+		return nil
+	})
+}
+
+func (app *App) adminSnippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	id := idParam(r)
+	if id == 0 {
+		app.notFound(w)
+		return
+	}
+
+	err := app.snippets.Delete(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, &models.AuditEvent{Type: models.EventSnippetDelete, TargetType: models.TargetSnippet, TargetID: id})
+
+	app.sessionManager.Put(r.Context(), "flash", "The snippet has been deleted.")
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// setRole gives an account a role, for -set-role. value is email=role.
+func setRole(ctx context.Context, users models.UserModelInterface, value string) (string, models.Role, error) {
+	i := strings.LastIndex(value, "=")
+	if i < 0 {
+		return "", "", fmt.Errorf("set-role must be email=role, got %q", value)
+	}
+	email, role := value[:i], models.Role(value[i+1:])
+
+	if !role.Valid() {
+		return "", "", fmt.Errorf("set-role: the role must be user, moderator or admin, got %q", role)
+	}
+
+	user, err := users.GetByEmail(ctx, email)
+	if err != nil {
+		return "", "", fmt.Errorf("setting the role of %s: %w", email, err)
+	}
+
+	return email, role, users.SetRole(ctx, user.ID, role)
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/memory"
+	"github.com/ahmadyogi543/snippetbox/internal/mocks"
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name          string
+		email         string
+		wantDashboard int
+		wantUsers     int
+	}{
+		{
+			name:          "User",
+			email:         "ayogi@snippetbox.sh",
+			wantDashboard: http.StatusForbidden,
+			wantUsers:     http.StatusForbidden,
+		},
+		{
+			name:          "Moderator",
+			email:         "moderator@snippetbox.sh",
+			wantDashboard: http.StatusOK,
+			wantUsers:     http.StatusForbidden,
+		},
+		{
+			name:          "Admin",
+			email:         "admin@snippetbox.sh",
+			wantDashboard: http.StatusOK,
+			wantUsers:     http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestServer(t, newTestApp(t).routes())
+			defer server.Close()
+
+			loginWithPassword(t, server, test.email)
+
+			code, _, _ := server.get(t, "/admin")
+			assert.Equal(t, code, test.wantDashboard)
+
+			code, _, _ = server.get(t, "/admin/users")
+			assert.Equal(t, code, test.wantUsers)
+		})
+	}
+
+	t.Run("Anonymous", func(t *testing.T) {
+		server := newTestServer(t, newTestApp(t).routes())
+		defer server.Close()
+
+		code, header, _ := server.get(t, "/admin")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+}
+
+func TestAdminDashboard(t *testing.T) {
+	server := newTestServer(t, newTestApp(t).routes())
+	defer server.Close()
+
+	loginWithPassword(t, server, "moderator@snippetbox.sh")
+
+	code, _, body := server.get(t, "/admin")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "A Title")
+	assert.StringContains(t, body, `<progress max="1" value="1">`)
+	assert.Equal(t, strings.Count(body, "<progress"), signupsDays)
+	// the users are managed by the admins only.
+	if strings.Contains(body, "Manage users") {
+		t.Error("the dashboard of a moderator links to the users")
+	}
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, header, _ := server.postForm(t, "/admin/snippets/1/delete", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/admin")
+
+	code, _, _ = server.postForm(t, "/admin/snippets/2/delete", form)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestAdminUserActions(t *testing.T) {
+	tests := []struct {
+		name      string
+		urlPath   string
+		role      string
+		wantCode  int
+		wantFlash string
+	}{
+		{
+			name:      "Disable",
+			urlPath:   "/admin/users/1/disable",
+			wantCode:  http.StatusSeeOther,
+			wantFlash: "The account has been disabled.",
+		},
+		{
+			name:      "Enable",
+			urlPath:   "/admin/users/1/enable",
+			wantCode:  http.StatusSeeOther,
+			wantFlash: "The account has been enabled.",
+		},
+		{
+			name:      "Unlock",
+			urlPath:   "/admin/users/1/unlock",
+			wantCode:  http.StatusSeeOther,
+			wantFlash: "The account has been unlocked.",
+		},
+		{
+			name:      "Role",
+			urlPath:   "/admin/users/1/role",
+			role:      "moderator",
+			wantCode:  http.StatusSeeOther,
+			wantFlash: "The role has been changed.",
+		},
+		{
+			name:     "Invalid role",
+			urlPath:  "/admin/users/1/role",
+			role:     "root",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:      "Themselves",
+			urlPath:   "/admin/users/5/disable",
+			wantCode:  http.StatusSeeOther,
+			wantFlash: "You can&#39;t change your own account from here.",
+		},
+		{
+			name:     "Non-existent user",
+			urlPath:  "/admin/users/100/disable",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			urlPath:  "/admin/users/foo/disable",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestServer(t, newTestApp(t).routes())
+			defer server.Close()
+
+			loginWithPassword(t, server, "admin@snippetbox.sh")
+			_, _, body := server.get(t, "/admin/users")
+
+			form := url.Values{}
+			form.Add("role", test.role)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, header, _ := server.postForm(t, test.urlPath, form)
+			assert.Equal(t, code, test.wantCode)
+			if test.wantFlash == "" {
+				return
+			}
+			assert.Equal(t, header.Get("Location"), "/admin/users")
+
+			_, _, body = server.get(t, "/admin/users")
+			assert.StringContains(t, body, test.wantFlash)
+		})
+	}
+}
+
+func TestAdminUserDisable(t *testing.T) {
+	users := &memory.UserModel{BcryptCost: bcrypt.MinCost}
+	auditEvents := &memory.AuditEventModel{}
+	app := newTestApp(t)
+	app.users = users
+	app.auditEvents = auditEvents
+
+	ctx := context.Background()
+	for i, email := range []string{"admin@snippetbox.sh", "jane@snippetbox.sh"} {
+		assert.NilError(t, users.Insert(ctx, "Jane Doe", email, "12345678"))
+		assert.NilError(t, users.VerifyEmail(ctx, i+1))
+	}
+	assert.NilError(t, users.SetRole(ctx, 1, models.RoleAdmin))
+
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	admin := server.newDevice(t)
+	loginWithPassword(t, admin, "admin@snippetbox.sh")
+	jane := server.newDevice(t)
+	loginWithPassword(t, jane, "jane@snippetbox.sh")
+
+	_, _, body := admin.get(t, "/admin/users")
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := admin.postForm(t, "/admin/users/2/disable", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	events, err := auditEvents.List(ctx, models.AuditFilter{Type: models.EventUserDisable})
+	assert.NilError(t, err)
+	assert.Equal(t, len(events), 1)
+	assert.Equal(t, events[0].ActorID, 1)
+	assert.Equal(t, events[0].TargetType, models.TargetUser)
+	assert.Equal(t, events[0].TargetID, 2)
+
+	// the user is logged out and can't log in again.
+	code, _, _ = jane.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = jane.get(t, "/user/login")
+	form = url.Values{}
+	form.Add("email", "jane@snippetbox.sh")
+	form.Add("password", "12345678")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body = jane.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusForbidden)
+	assert.StringContains(t, body, "Your account has been disabled.")
+
+	_, _, body = admin.get(t, "/admin/users")
+	assert.StringContains(t, body, "Disabled")
+	assert.StringContains(t, body, `action="/admin/users/2/enable"`)
+}
+
+func TestSetRole(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{
+			name:  "Valid",
+			value: "ayogi@snippetbox.sh=admin",
+		},
+		{
+			name:    "No role",
+			value:   "ayogi@snippetbox.sh",
+			wantErr: "set-role must be email=role",
+		},
+		{
+			name:    "Invalid role",
+			value:   "ayogi@snippetbox.sh=root",
+			wantErr: `the role must be user, moderator or admin, got "root"`,
+		},
+		{
+			name:    "Non-existent user",
+			value:   "nobody@snippetbox.sh=admin",
+			wantErr: "setting the role of nobody@snippetbox.sh",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := setRole(context.Background(), &mocks.UserModel{}, test.value)
+			if test.wantErr == "" {
+				assert.NilError(t, err)
+				return
+			}
+
+			if err == nil {
+				t.Fatal("expected an error; got nil instead")
+			}
+			assert.StringContains(t, err.Error(), test.wantErr)
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+// accountEventsLimit is how many of their events the users see on their
+// account page.
+const accountEventsLimit = 20
+
+// adminEventsLimit is how many events the admins see on the audit page, the
+// export has them all.
+const adminEventsLimit = 100
+
+// auditExportBatchSize is how many events the export loads at once, so the
+// whole table is never held in memory.
+const auditExportBatchSize = 500
+
+// auditFilterDateLayout is the layout of the dates in the audit filters.
+const auditFilterDateLayout = "2006-01-02"
+
+// auditFilterForm holds the filters of the audit page, to fill them in again.
+type auditFilterForm struct {
+	Type  string
+	User  string
+	IP    string
+	Since string
+	Until string
+}
+
+// audit records event, done by the user logged in to the session of r
+// unless its actor is set already. It only logs the errors, a failure to
+// record the event mustn't fail the request.
+func (app *App) audit(r *http.Request, event *models.AuditEvent) {
+	if event.ActorID == 0 {
+		event.ActorID = app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	}
+	event.IP = clientIP(r)
+	event.UserAgent = truncate(r.UserAgent(), maxUserAgentLength)
+	event.Detail = truncate(event.Detail, 255)
+
+	err := app.auditEvents.Insert(r.Context(), event)
+	if err != nil {
+		app.logger.Error(
+			"recording an audit event: "+err.Error(),
+			"request_id", requestInfoFrom(r).id,
+			"type", event.Type,
+			"actor_id", event.ActorID,
+			"target_type", event.TargetType,
+			"target_id", event.TargetID,
+			"ip", event.IP,
+			"detail", event.Detail,
+		)
+	}
+}
+
+// auditUser records event done to the user id.
+func (app *App) auditUser(r *http.Request, eventType models.AuditEventType, id int, detail string) {
+	app.audit(r, &models.AuditEvent{
+		Type:       eventType,
+		TargetType: models.TargetUser,
+		TargetID:   id,
+		Detail:     detail,
+	})
+}
+
+// auditLoginFailure records a failed login to the account of email, why
+// being the reason. When there's no such account the email is kept in the
+// detail instead.
+func (app *App) auditLoginFailure(r *http.Request, email string, why string) {
+	event := &models.AuditEvent{Type: models.EventLoginFailed, Detail: why}
+
+	user, err := app.users.GetByEmail(r.Context(), email)
+	if err == nil {
+		event.TargetType = models.TargetUser
+		event.TargetID = user.ID
+	} else {
+		event.Detail += ": " + email
+	}
+
+	app.audit(r, event)
+}
+
+// parseAuditFilter returns the filter in the query of r, and false when it
+// selects a user that doesn't exist so there can't be any events.
+func (app *App) parseAuditFilter(r *http.Request) (models.AuditFilter, auditFilterForm, bool, error) {
+	query := r.URL.Query()
+	form := auditFilterForm{
+		Type:  query.Get("type"),
+		User:  strings.TrimSpace(query.Get("user")),
+		IP:    strings.TrimSpace(query.Get("ip")),
+		Since: query.Get("since"),
+		Until: query.Get("until"),
+	}
+
+	filter := models.AuditFilter{Type: models.AuditEventType(form.Type), IP: form.IP}
+	if filter.Type != "" && !filter.Type.Valid() {
+		return filter, form, false, errInvalidAuditFilter
+	}
+
+	var err error
+	if form.Since != "" {
+		filter.Since, err = time.Parse(auditFilterDateLayout, form.Since)
+		if err != nil {
+			return filter, form, false, errInvalidAuditFilter
+		}
+	}
+	if form.Until != "" {
+		// the until day is included.
+		filter.Until, err = time.Parse(auditFilterDateLayout, form.Until)
+		if err != nil {
+			return filter, form, false, errInvalidAuditFilter
+		}
+		filter.Until = filter.Until.AddDate(0, 0, 1)
+	}
+
+	if form.User != "" {
+		user, err := app.users.GetByEmail(r.Context(), form.User)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				return filter, form, false, nil
+			}
+			return filter, form, false, err
+		}
+		filter.UserID = user.ID
+	}
+
+	return filter, form, true, nil
+}
+
+// errInvalidAuditFilter is a filter of the audit page that can't be parsed.
+var errInvalidAuditFilter = errors.New("invalid audit filter")
+
+// listAuditEvents returns the events selected by the query of r, at most
+// limit of them, with the emails of the users by their IDs. When it fails it
+// writes the error to w and returns false.
+func (app *App) listAuditEvents(w http.ResponseWriter, r *http.Request, limit int) ([]*models.AuditEvent, map[int]string, auditFilterForm, bool) {
+	filter, form, found, err := app.parseAuditFilter(r)
+	if err != nil {
+		if errors.Is(err, errInvalidAuditFilter) {
+			app.clientError(w, http.StatusBadRequest)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, nil, form, false
+	}
+
+	events := []*models.AuditEvent{}
+	if found {
+		filter.Limit = limit
+		events, err = app.auditEvents.List(r.Context(), filter)
+		if err != nil {
+			app.serverError(w, r, err)
+			return nil, nil, form, false
+		}
+	}
+
+	emails, err := app.users.Emails(r.Context(), auditUserIDs(events))
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, nil, form, false
+	}
+
+	return events, emails, form, true
+}
+
+func (app *App) adminAudit(w http.ResponseWriter, r *http.Request) {
+	events, emails, form, ok := app.listAuditEvents(w, r, adminEventsLimit)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.AuditEvents = events
+	data.AuditEventTypes = models.AuditEventTypes
+	data.UserEmails = emails
+	app.render(w, r, http.StatusOK, "admin_audit.go.html", data)
+}
+
+func (app *App) adminAuditExport(w http.ResponseWriter, r *http.Request) {
+	filter, _, found, err := app.parseAuditFilter(r)
+	if err != nil {
+		if errors.Is(err, errInvalidAuditFilter) {
+			app.clientError(w, http.StatusBadRequest)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// the events are paged by ID, the most recent first, and each batch is
+	// sent before the next one is loaded.
+	filter.Limit = auditExportBatchSize
+	more := found
+	next := func() ([]*models.AuditEvent, map[int]string, error) {
+		if !more {
+			return nil, nil, nil
+		}
+
+		events, err := app.auditEvents.List(r.Context(), filter)
+		if err != nil || len(events) == 0 {
+			return nil, nil, err
+		}
+		filter.BeforeID = events[len(events)-1].ID
+		more = len(events) == filter.Limit
+
+		emails, err := app.users.Emails(r.Context(), auditUserIDs(events))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return events, emails, nil
+	}
+
+	// the errors of the first batch can still be reported with a status.
+	events, emails, err := next()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-events.csv"`)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"id", "created", "type", "actor_id", "actor_email", "target_type", "target_id", "target_email", "ip", "user_agent", "detail"})
+	for len(events) > 0 {
+		for _, event := range events {
+			targetEmail := ""
+			if event.TargetType == models.TargetUser {
+				targetEmail = emails[event.TargetID]
+			}
+
+			writer.Write([]string{
+				strconv.Itoa(event.ID),
+				event.Created.UTC().Format(time.RFC3339),
+				string(event.Type),
+				formatID(event.ActorID),
+				csvSafe(emails[event.ActorID]),
+				event.TargetType,
+				formatID(event.TargetID),
+				csvSafe(targetEmail),
+				csvSafe(event.IP),
+				csvSafe(event.UserAgent),
+				csvSafe(event.Detail),
+			})
+		}
+
+		writer.Flush()
+		if err = writer.Error(); err != nil {
+			break
+		}
+
+		events, emails, err = next()
+	}
+
+	writer.Flush()
+	if err == nil {
+		err = writer.Error()
+	}
+	if err != nil {
+		// the response has started, the export is left truncated.
+		app.logger.Error(err.Error(), "request_id", requestInfoFrom(r).id)
+	}
+}
+
+// auditUserIDs returns the IDs of the users in events, once each.
+func auditUserIDs(events []*models.AuditEvent) []int {
+	seen := map[int]bool{}
+	ids := []int{}
+	for _, event := range events {
+		userIDs := []int{event.ActorID}
+		if event.TargetType == models.TargetUser {
+			userIDs = append(userIDs, event.TargetID)
+		}
+
+		for _, id := range userIDs {
+			if id != 0 && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	return ids
+}
+
+// formatID formats the IDs of the export, leaving the zero ones empty.
+func formatID(id int) string {
+	if id == 0 {
+		return ""
+	}
+
+	return strconv.Itoa(id)
+}
+
+// csvSafe keeps the spreadsheets opening the export from running a value as a
+// formula, the clients choose their user agent and the emails they fail to
+// log in with.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/memory"
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+func TestAuditEvents(t *testing.T) {
+	auditEvents := &memory.AuditEventModel{}
+	app := newTestApp(t)
+	app.auditEvents = auditEvents
+
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	ctx := context.Background()
+
+	// a failed login to an account, then to one that doesn't exist.
+	for _, email := range []string{"ayogi@snippetbox.sh", "nobody@snippetbox.sh"} {
+		_, _, body := server.get(t, "/user/login")
+		form := url.Values{}
+		form.Add("email", email)
+		form.Add("password", "wrong password")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		server.postForm(t, "/user/login", form)
+	}
+
+	loginWithPassword(t, server, "ayogi@snippetbox.sh")
+
+	_, _, body := server.get(t, "/snippet/create")
+	form := url.Values{}
+	form.Add("title", "A Title")
+	form.Add("content", "A content")
+	form.Add("expires", "7")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	server.postForm(t, "/snippet/create", form)
+
+	form = url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	server.postForm(t, "/user/logout", form)
+
+	events, err := auditEvents.List(ctx, models.AuditFilter{})
+	assert.NilError(t, err)
+
+	types := []string{}
+	for _, event := range events {
+		types = append(types, string(event.Type))
+	}
+	assert.Equal(t, strings.Join(types, " "), "logout snippet.create login login.failed login.failed")
+
+	logout, create, login, unknown, failed := events[0], events[1], events[2], events[3], events[4]
+	assert.Equal(t, logout.ActorID, 1)
+	assert.Equal(t, create.ActorID, 1)
+	assert.Equal(t, create.TargetType, models.TargetSnippet)
+	assert.Equal(t, create.TargetID, 2)
+	assert.Equal(t, login.ActorID, 1)
+	assert.Equal(t, login.IP, "127.0.0.1")
+	assert.Equal(t, login.UserAgent, "Go-http-client/1.1")
+	assert.Equal(t, failed.ActorID, 0)
+	assert.Equal(t, failed.TargetID, 1)
+	assert.Equal(t, failed.Detail, "invalid credentials")
+	assert.Equal(t, unknown.TargetID, 0)
+	assert.Equal(t, unknown.Detail, "invalid credentials: nobody@snippetbox.sh")
+}
+
+func TestAdminAudit(t *testing.T) {
+	auditEvents := &memory.AuditEventModel{}
+	app := newTestApp(t)
+	app.auditEvents = auditEvents
+
+	ctx := context.Background()
+	events := []*models.AuditEvent{
+		{Type: models.EventSignup, ActorID: 1, TargetType: models.TargetUser, TargetID: 1, IP: "192.0.2.1"},
+		{Type: models.EventLoginFailed, TargetType: models.TargetUser, TargetID: 3, IP: "192.0.2.2", UserAgent: "=HYPERLINK(\"https://example.com\")"},
+	}
+	for _, event := range events {
+		assert.NilError(t, auditEvents.Insert(ctx, event))
+	}
+
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	loginWithPassword(t, server, "admin@snippetbox.sh")
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantBody []string
+		wantRows int
+	}{
+		{
+			name:     "All",
+			query:    "",
+			wantCode: http.StatusOK,
+			wantBody: []string{"signup", "login.failed", "ayogi@snippetbox.sh", "twofactor@snippetbox.sh", "Anonymous"},
+			wantRows: 3,
+		},
+		{
+			name:     "Type",
+			query:    "?type=signup",
+			wantCode: http.StatusOK,
+			wantBody: []string{"<td>signup</td>"},
+			wantRows: 1,
+		},
+		{
+			name:     "User",
+			query:    "?user=twofactor%40snippetbox.sh",
+			wantCode: http.StatusOK,
+			wantBody: []string{"<td>login.failed</td>"},
+			wantRows: 1,
+		},
+		{
+			name:     "Non-existent user",
+			query:    "?user=nobody%40snippetbox.sh",
+			wantCode: http.StatusOK,
+			wantBody: []string{"There are no events matching the filters."},
+		},
+		{
+			name:     "Future",
+			query:    "?since=2999-01-01",
+			wantCode: http.StatusOK,
+			wantBody: []string{"There are no events matching the filters."},
+		},
+		{
+			name:     "Invalid type",
+			query:    "?type=snippet.view",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid date",
+			query:    "?until=yesterday",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _, body := server.get(t, "/admin/audit"+test.query)
+			assert.Equal(t, code, test.wantCode)
+			for _, want := range test.wantBody {
+				assert.StringContains(t, body, want)
+			}
+
+			if test.wantCode != http.StatusOK {
+				return
+			}
+
+			code, header, body := server.get(t, "/admin/audit.csv"+test.query)
+			assert.Equal(t, code, http.StatusOK)
+			assert.Equal(t, header.Get("Content-Type"), "text/csv; charset=utf-8")
+
+			records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+			assert.NilError(t, err)
+			// the login of the admin is recorded too, in the unfiltered rows.
+			assert.Equal(t, len(records)-1, test.wantRows)
+		})
+	}
+
+	t.Run("Export", func(t *testing.T) {
+		_, _, body := server.get(t, "/admin/audit.csv?type=login.failed")
+
+		records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+		assert.NilError(t, err)
+		assert.Equal(t, len(records), 2)
+		assert.Equal(t, strings.Join(records[0], ","), "id,created,type,actor_id,actor_email,target_type,target_id,target_email,ip,user_agent,detail")
+		assert.Equal(t, records[1][3], "")
+		assert.Equal(t, records[1][7], "twofactor@snippetbox.sh")
+		assert.Equal(t, records[1][9], `'=HYPERLINK("https://example.com")`)
+	})
+
+	t.Run("Moderator", func(t *testing.T) {
+		server := newTestServer(t, app.routes())
+		defer server.Close()
+
+		loginWithPassword(t, server, "moderator@snippetbox.sh")
+
+		code, _, _ := server.get(t, "/admin/audit.csv")
+		assert.Equal(t, code, http.StatusForbidden)
+	})
+}
+
+func TestAuditExportBatches(t *testing.T) {
+	auditEvents := &memory.AuditEventModel{}
+	app := newTestApp(t)
+	app.auditEvents = auditEvents
+
+	ctx := context.Background()
+	for i := 0; i < 2*auditExportBatchSize+1; i++ {
+		assert.NilError(t, auditEvents.Insert(ctx, &models.AuditEvent{Type: models.EventLoginFailed, IP: "192.0.2.1"}))
+	}
+
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+	loginWithPassword(t, server, "admin@snippetbox.sh")
+
+	code, _, body := server.get(t, "/admin/audit.csv?type=login.failed")
+	assert.Equal(t, code, http.StatusOK)
+
+	records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	assert.NilError(t, err)
+	assert.Equal(t, len(records)-1, 2*auditExportBatchSize+1)
+
+	// the batches follow each other, the most recent event first.
+	for i, record := range records[1:] {
+		assert.Equal(t, record[0], strconv.Itoa(2*auditExportBatchSize+1-i))
+	}
+}
+
+func TestCSVSafe(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "", want: ""},
+		{value: "Mozilla/5.0", want: "Mozilla/5.0"},
+		{value: "=1+1", want: "'=1+1"},
+		{value: "+1", want: "'+1"},
+		{value: "-1", want: "'-1"},
+		{value: "@SUM(A1)", want: "'@SUM(A1)"},
+		{value: "\tcmd", want: "'\tcmd"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			assert.Equal(t, csvSafe(test.value), test.want)
+		})
+	}
+}
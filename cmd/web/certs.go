@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// certReloader serves the TLS certificate through tls.Config.GetCertificate
+// and reloads it when the certificate or key file changes, so a renewed
+// certificate is used without restarting the server.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+
+	mu       sync.Mutex
+	modTimes [2]time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	cr := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	_, err := cr.reloadIfChanged()
+	if err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return cr.cert.Load(), nil
+}
+
+// leaf returns the parsed certificate currently being served.
+func (cr *certReloader) leaf() *x509.Certificate {
+	return cr.cert.Load().Leaf
+}
+
+// daysToExpiry returns how many days the certificate being served has left,
+// negative once it expired.
+func (cr *certReloader) daysToExpiry() float64 {
+	return time.Until(cr.leaf().NotAfter).Hours() / 24
+}
+
+// reloadIfChanged loads the certificate again if the modification time of
+// either file changed since the last successful load, and reports whether it
+// did. A failed load keeps the current certificate and is retried next time,
+// because the files may be caught halfway through a renewal.
+func (cr *certReloader) reloadIfChanged() (bool, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	var modTimes [2]time.Time
+	for i, file := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return false, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	if modTimes == cr.modTimes {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return false, err
+	}
+
+	cr.cert.Store(&cert)
+	cr.modTimes = modTimes
+
+	return true, nil
+}
+
+// watch checks the files every interval until ctx is cancelled.
+func (cr *certReloader) watch(ctx context.Context, interval time.Duration, onReload func(*x509.Certificate), onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := cr.reloadIfChanged()
+			if err != nil {
+				onError(err)
+			} else if reloaded {
+				onReload(cr.leaf())
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+// writeTestCertificate writes a self-signed certificate for commonName that
+// expires after validFor, and sets the modification time of both files to
+// modTime.
+func writeTestCertificate(t *testing.T, certFile, keyFile, commonName string, validFor time.Duration, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validFor),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	for file, content := range map[string][]byte{certFile: certPEM, keyFile: keyPEM} {
+		if err := os.WriteFile(file, content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	modTime := time.Now().Add(-time.Hour)
+
+	writeTestCertificate(t, certFile, keyFile, "old.localhost", 24*time.Hour, modTime)
+
+	cr, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := cr.GetCertificate(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, cert.Leaf.Subject.CommonName, "old.localhost")
+
+	reloaded, err := cr.reloadIfChanged()
+	assert.NilError(t, err)
+	assert.Equal(t, reloaded, false)
+
+	t.Run("Renewed", func(t *testing.T) {
+		writeTestCertificate(t, certFile, keyFile, "new.localhost", 90*24*time.Hour, modTime.Add(time.Minute))
+
+		reloaded, err := cr.reloadIfChanged()
+		assert.NilError(t, err)
+		assert.Equal(t, reloaded, true)
+
+		cert, err := cr.GetCertificate(nil)
+		assert.NilError(t, err)
+		assert.Equal(t, cert.Leaf.Subject.CommonName, "new.localhost")
+		assert.Equal(t, int(cr.daysToExpiry()+0.5), 90)
+	})
+
+	t.Run("Half-written Renewal", func(t *testing.T) {
+		err := os.WriteFile(keyFile, []byte("not a key"), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = cr.reloadIfChanged()
+		if err == nil {
+			t.Fatal("expected an error; got nil instead")
+		}
+
+		cert, err := cr.GetCertificate(nil)
+		assert.NilError(t, err)
+		assert.Equal(t, cert.Leaf.Subject.CommonName, "new.localhost")
+	})
+}
@@ -0,0 +1,641 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/ldap"
+	"github.com/ahmadyogi543/snippetbox/internal/mailer"
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+	"github.com/ahmadyogi543/snippetbox/internal/ratelimit"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// envPrefix is prepended to the upper-cased flag name (with dashes replaced by
+// underscores) to get the environment variable of a setting, for example
+// SNIPPETBOX_SESSION_LIFETIME for -session-lifetime.
+const envPrefix = "SNIPPETBOX_"
+
+// commandFlags are flags that control what the program does rather than how
+// the server is configured, so they can't be set in the file or environment.
+var commandFlags = map[string]bool{
+	"config":       true,
+	"print-config": true,
+	"gen-dev-cert": true,
+	"unlock-user":  true,
+	"set-role":     true,
+}
+
+// secretSettings are redacted by -print-config.
+var secretSettings = map[string]bool{
+	"dsn":                true,
+	"smtp-password":      true,
+	"oidc-client-secret": true,
+	"ldap-bind-password": true,
+}
+
+type config struct {
+	addr  string
+	debug bool
+	db    string
+	dsn   string
+	store string
+	query struct {
+		timeout       time.Duration
+		slowThreshold time.Duration
+	}
+	session sessionPolicy
+	server  struct {
+		idleTimeout     time.Duration
+		readTimeout     time.Duration
+		writeTimeout    time.Duration
+		shutdownTimeout time.Duration
+		drainDelay      time.Duration
+	}
+	tls struct {
+		enabled        bool
+		certFile       string
+		keyFile        string
+		reloadInterval time.Duration
+	}
+	httpRedirectAddr string
+	adminAddr        string
+	baseURL          string
+	traceExporter    string
+	hsts             struct {
+		maxAge            time.Duration
+		includeSubDomains bool
+		preload           bool
+	}
+	trustedProxies   prefixList
+	rateLimits       rateLimits
+	lockout          models.LockoutPolicy
+	loginThrottle    loginThrottle
+	bcryptCost       int
+	verificationTTL  time.Duration
+	passwordResetTTL time.Duration
+	mail             struct {
+		transport string
+		from      string
+		dir       string
+		smtp      struct {
+			host     string
+			port     int
+			username string
+			password string
+		}
+		outboxInterval    time.Duration
+		outboxMaxAttempts int
+	}
+	oidc struct {
+		issuer         string
+		clientID       string
+		clientSecret   string
+		name           string
+		allowedDomains domainList
+	}
+	ldap struct {
+		url            string
+		bindDN         string
+		bindPassword   string
+		userDN         string
+		baseDN         string
+		filter         string
+		nameAttribute  string
+		emailAttribute string
+		timeout        time.Duration
+	}
+	log struct {
+		format string
+		level  slog.Level
+	}
+
+	configFile  string
+	printConfig bool
+	genDevCert  bool
+	unlockUser  string
+	setRole     string
+	flags       *flag.FlagSet
+}
+
+// newFlagSet binds every setting to a flag. The flags are the single list of
+// settings, the config file and the environment are applied through them too.
+func (cfg *config) newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("web", flag.ContinueOnError)
+
+	fs.StringVar(&cfg.configFile, "config", "", "Path to a JSON config file, TOML and YAML aren't supported (also "+envPrefix+"CONFIG)")
+	fs.BoolVar(&cfg.printConfig, "print-config", false, "Print the effective config with secrets redacted and exit")
+	fs.BoolVar(&cfg.genDevCert, "gen-dev-cert", false, "Write a localhost certificate signed by a local CA to -tls-cert and -tls-key and exit")
+	fs.StringVar(&cfg.unlockUser, "unlock-user", "", "Unlock the account with this email after too many failed logins and exit")
+	fs.StringVar(&cfg.setRole, "set-role", "", "Give an account a role, as email=role with a role of user, moderator or admin, and exit")
+
+	fs.StringVar(&cfg.addr, "addr", ":3000", "HTTP network address")
+	fs.BoolVar(&cfg.debug, "debug", true, "Enable debug mode")
+	fs.StringVar(&cfg.log.format, "log-format", "text", "Log format, either text or json")
+	fs.TextVar(&cfg.log.level, "log-level", slog.LevelInfo, "Minimum log level, one of debug, info, warn or error")
+	fs.StringVar(&cfg.dsn, "dsn", "web:12345678@/snippetbox?parseTime=true", "MySQL data source name")
+	fs.StringVar(&cfg.db, "db", "mysql", "Database backend, either mysql (uses -dsn) or sqlite:<path>")
+	fs.StringVar(&cfg.store, "store", "sql", "Storage for snippets, users and sessions, either sql (uses -db) or memory")
+	fs.DurationVar(&cfg.query.timeout, "query-timeout", 3*time.Second, "Maximum duration of a database query")
+	fs.DurationVar(&cfg.query.slowThreshold, "slow-query-threshold", 200*time.Millisecond, "Log the database queries taking longer than this, disabled when zero")
+	fs.DurationVar(&cfg.session.lifetime, "session-lifetime", 12*time.Hour, "How long a login lasts at most, unless the user asks to be remembered")
+	fs.DurationVar(&cfg.session.idleTimeout, "session-idle-timeout", 30*time.Minute, "Log out the users not remembered after this long without a request")
+	fs.DurationVar(&cfg.session.rememberLifetime, "session-remember-lifetime", 30*24*time.Hour, "How long the login of the users who ask to be remembered lasts")
+	fs.DurationVar(&cfg.server.idleTimeout, "idle-timeout", time.Minute, "Server idle timeout")
+	fs.DurationVar(&cfg.server.readTimeout, "read-timeout", 5*time.Second, "Server read timeout")
+	fs.DurationVar(&cfg.server.writeTimeout, "write-timeout", 10*time.Second, "Server write timeout")
+	fs.DurationVar(&cfg.server.shutdownTimeout, "shutdown-timeout", 30*time.Second, "How long to wait for requests in flight when shutting down")
+	fs.DurationVar(&cfg.server.drainDelay, "drain-delay", 0, "How long /readyz reports draining before the server stops accepting connections on shutdown")
+	fs.BoolVar(&cfg.tls.enabled, "tls", true, "Serve HTTPS, disable it only behind a TLS-terminating proxy")
+	fs.StringVar(&cfg.tls.certFile, "tls-cert", "./tls/cert.pem", "TLS certificate file")
+	fs.StringVar(&cfg.tls.keyFile, "tls-key", "./tls/key.pem", "TLS private key file")
+	fs.DurationVar(&cfg.tls.reloadInterval, "tls-reload-interval", time.Minute, "How often to check the TLS files for a renewed certificate")
+	fs.StringVar(&cfg.httpRedirectAddr, "http-redirect-addr", "", "HTTP network address that redirects to HTTPS, disabled when empty")
+	fs.StringVar(&cfg.baseURL, "base-url", "https://localhost:3000", "URL the users reach the site at, used in the links sent by email")
+	fs.StringVar(&cfg.adminAddr, "admin-addr", "", "HTTP network address serving /metrics, disabled when empty, keep it private (e.g. localhost:3001)")
+	fs.StringVar(&cfg.traceExporter, "trace-exporter", "", "Where to export the traces, either stdout or file:<path>, disabled when empty")
+	fs.DurationVar(&cfg.hsts.maxAge, "hsts-max-age", 0, "Max-age of the Strict-Transport-Security header, disabled when zero")
+	fs.BoolVar(&cfg.hsts.includeSubDomains, "hsts-include-subdomains", false, "Apply Strict-Transport-Security to subdomains")
+	fs.BoolVar(&cfg.hsts.preload, "hsts-preload", false, "Allow the domain to be added to the browsers' HSTS preload lists")
+	fs.Var(&cfg.trustedProxies, "trusted-proxies", "Comma-separated IPs or CIDRs of proxies whose X-Forwarded-For and X-Forwarded-Proto headers are trusted")
+	fs.TextVar(&cfg.rateLimits.global, "rate-limit", ratelimit.Limit{Burst: 300, Period: time.Minute}, "Requests to the pages allowed per client IP and per user, as <burst>/<period> or off")
+	fs.TextVar(&cfg.rateLimits.auth, "rate-limit-auth", ratelimit.Limit{Burst: 10, Period: time.Minute}, "Login, signup and password change attempts allowed per client IP and per user, as <burst>/<period> or off")
+	fs.TextVar(&cfg.rateLimits.snippets, "rate-limit-snippets", ratelimit.Limit{Burst: 20, Period: time.Hour}, "Snippets created allowed per client IP and per user, as <burst>/<period> or off")
+	fs.IntVar(&cfg.lockout.Threshold, "lockout-threshold", 5, "Failed logins in a row that lock an account, disabled when zero")
+	fs.DurationVar(&cfg.lockout.Duration, "lockout-duration", 15*time.Minute, "How long an account is locked, doubled on every failed login after the lock")
+	fs.DurationVar(&cfg.lockout.MaxDuration, "lockout-max-duration", 24*time.Hour, "Maximum duration of an account lock")
+	fs.IntVar(&cfg.loginThrottle.threshold, "login-ip-threshold", 20, "Failed logins of a client IP within -login-ip-window after which its logins are refused, disabled when zero")
+	fs.DurationVar(&cfg.loginThrottle.window, "login-ip-window", 15*time.Minute, "Window over which the failed logins of a client IP are counted")
+	fs.IntVar(&cfg.bcryptCost, "bcrypt-cost", models.DefaultBcryptCost, "Bcrypt cost of password hashes")
+	fs.DurationVar(&cfg.verificationTTL, "verification-ttl", 48*time.Hour, "How long the email verification links work")
+	fs.DurationVar(&cfg.passwordResetTTL, "password-reset-ttl", 30*time.Minute, "How long the password reset links work")
+	fs.StringVar(&cfg.mail.transport, "mailer", "log", "How to send the emails, one of log, smtp (uses -smtp-*) or file (a maildir in -mail-dir)")
+	fs.StringVar(&cfg.mail.from, "mail-from", "Snippetbox <no-reply@snippetbox.sh>", "Sender address of the emails")
+	fs.StringVar(&cfg.mail.dir, "mail-dir", "./mail", "Maildir the file mailer writes to")
+	fs.StringVar(&cfg.mail.smtp.host, "smtp-host", "localhost", "SMTP server host")
+	fs.IntVar(&cfg.mail.smtp.port, "smtp-port", 587, "SMTP server port")
+	fs.StringVar(&cfg.mail.smtp.username, "smtp-username", "", "SMTP username, no authentication when empty")
+	fs.StringVar(&cfg.mail.smtp.password, "smtp-password", "", "SMTP password")
+	fs.DurationVar(&cfg.mail.outboxInterval, "outbox-interval", 30*time.Second, "How often the emails that failed to send are tried again")
+	fs.IntVar(&cfg.mail.outboxMaxAttempts, "outbox-max-attempts", mailer.DefaultMaxAttempts, "How many times an email is tried before giving up on it")
+	fs.StringVar(&cfg.oidc.issuer, "oidc-issuer", "", "Issuer URL of the OpenID Connect provider for single sign-on, disabled when empty")
+	fs.StringVar(&cfg.oidc.clientID, "oidc-client-id", "", "Client ID registered with the OpenID Connect provider")
+	fs.StringVar(&cfg.oidc.clientSecret, "oidc-client-secret", "", "Client secret registered with the OpenID Connect provider")
+	fs.StringVar(&cfg.oidc.name, "oidc-name", "single sign-on", "Name of the OpenID Connect provider on the login page")
+	fs.Var(&cfg.oidc.allowedDomains, "oidc-allowed-domains", "Comma-separated email domains allowed to log in with single sign-on, any when empty")
+	fs.StringVar(&cfg.ldap.url, "ldap-url", "", "URL of the LDAP directory the users log in with, ldaps:// or ldap:// upgraded with StartTLS, disabled when empty")
+	fs.StringVar(&cfg.ldap.userDN, "ldap-user-dn", "", "DN of the users' entries, {username} and {email} are replaced with what they typed, e.g. uid={username},ou=people,dc=example,dc=com")
+	fs.StringVar(&cfg.ldap.baseDN, "ldap-base-dn", "", "Where to search for the users' entries with -ldap-filter when -ldap-user-dn is empty")
+	fs.StringVar(&cfg.ldap.filter, "ldap-filter", "(mail={email})", "Filter finding the entry of a user under -ldap-base-dn")
+	fs.StringVar(&cfg.ldap.bindDN, "ldap-bind-dn", "", "DN to bind as before searching, anonymous when empty")
+	fs.StringVar(&cfg.ldap.bindPassword, "ldap-bind-password", "", "Password of -ldap-bind-dn")
+	fs.StringVar(&cfg.ldap.nameAttribute, "ldap-name-attribute", "cn", "Attribute holding the users' names")
+	fs.StringVar(&cfg.ldap.emailAttribute, "ldap-email-attribute", "mail", "Attribute holding the users' email addresses")
+	fs.DurationVar(&cfg.ldap.timeout, "ldap-timeout", 5*time.Second, "Maximum duration of a login against the LDAP directory")
+
+	return fs
+}
+
+// loadConfig merges the defaults, the config file, the environment and the
+// command line flags, each one overriding the ones before it.
+func loadConfig(args []string, lookupEnv func(string) (string, bool)) (*config, error) {
+	cfg := &config{}
+	cfg.flags = cfg.newFlagSet()
+
+	err := cfg.flags.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// the flags have to be parsed first to find the config file, so remember
+	// them and set them again once the file and the environment are applied.
+	given := map[string]string{}
+	cfg.flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+
+	if cfg.configFile == "" {
+		cfg.configFile, _ = lookupEnv(envPrefix + "CONFIG")
+	}
+
+	if cfg.configFile != "" {
+		settings, err := readConfigFile(cfg.configFile)
+		if err != nil {
+			return nil, err
+		}
+
+		for name, value := range settings {
+			err = cfg.set(name, value)
+			if err != nil {
+				return nil, fmt.Errorf("config file %s: %w", cfg.configFile, err)
+			}
+		}
+	}
+
+	cfg.flags.VisitAll(func(f *flag.Flag) {
+		if commandFlags[f.Name] || err != nil {
+			return
+		}
+
+		name := envName(f.Name)
+		if value, ok := lookupEnv(name); ok {
+			if setErr := f.Value.Set(value); setErr != nil {
+				err = fmt.Errorf("environment variable %s: %w", name, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range given {
+		cfg.flags.Set(name, value)
+	}
+
+	return cfg, nil
+}
+
+func (cfg *config) set(name, value string) error {
+	f := cfg.flags.Lookup(name)
+	if f == nil || commandFlags[name] {
+		return fmt.Errorf("unknown setting %q", name)
+	}
+
+	err := f.Value.Set(value)
+	if err != nil {
+		return fmt.Errorf("invalid value %q for %s: %w", value, name, err)
+	}
+
+	return nil
+}
+
+// readConfigFile reads a flat JSON object whose keys are flag names. The other
+// formats are refused by their extension rather than failing to parse as JSON.
+func readConfigFile(path string) (map[string]string, error) {
+	if ext := strings.ToLower(filepath.Ext(path)); ext != ".json" {
+		return nil, fmt.Errorf("config file %s: only JSON config files ending in .json are supported, got %q", path, ext)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]any
+	err = json.Unmarshal(content, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	settings := make(map[string]string, len(raw))
+	for name, value := range raw {
+		switch v := value.(type) {
+		case string:
+			settings[name] = v
+		case bool:
+			settings[name] = strconv.FormatBool(v)
+		case float64:
+			settings[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return nil, fmt.Errorf("config file %s: %s must be a string, number or boolean", path, name)
+		}
+	}
+
+	return settings, nil
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+func (cfg *config) validate() error {
+	var errs []error
+
+	if cfg.addr == "" {
+		errs = append(errs, errors.New("addr must not be empty"))
+	}
+
+	if cfg.log.format != "text" && cfg.log.format != "json" {
+		errs = append(errs, fmt.Errorf("log-format must be text or json, got %q", cfg.log.format))
+	}
+
+	if u, err := url.Parse(cfg.baseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("base-url must be an absolute http or https URL, got %q", cfg.baseURL))
+	}
+
+	switch cfg.mail.transport {
+	case "log", "file":
+	case "smtp":
+		if cfg.mail.smtp.port < 1 || cfg.mail.smtp.port > 65535 {
+			errs = append(errs, fmt.Errorf("smtp-port must be between 1 and 65535, got %d", cfg.mail.smtp.port))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("mailer must be log, smtp or file, got %q", cfg.mail.transport))
+	}
+
+	if _, err := mail.ParseAddress(cfg.mail.from); err != nil {
+		errs = append(errs, fmt.Errorf("mail-from: %w", err))
+	}
+
+	if cfg.mail.outboxMaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("outbox-max-attempts must be positive, got %d", cfg.mail.outboxMaxAttempts))
+	}
+
+	if cfg.traceExporter != "" && cfg.traceExporter != "stdout" && !strings.HasPrefix(cfg.traceExporter, "file:") {
+		errs = append(errs, fmt.Errorf("trace-exporter must be stdout or file:<path>, got %q", cfg.traceExporter))
+	}
+
+	switch cfg.store {
+	case "memory":
+	case "sql":
+		if _, _, err := parseDatabase(cfg.db, cfg.dsn); err != nil {
+			errs = append(errs, fmt.Errorf("db: %w", err))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("store must be sql or memory, got %q", cfg.store))
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"query-timeout", cfg.query.timeout},
+		{"session-lifetime", cfg.session.lifetime},
+		{"session-idle-timeout", cfg.session.idleTimeout},
+		{"session-remember-lifetime", cfg.session.rememberLifetime},
+		{"idle-timeout", cfg.server.idleTimeout},
+		{"read-timeout", cfg.server.readTimeout},
+		{"write-timeout", cfg.server.writeTimeout},
+		{"shutdown-timeout", cfg.server.shutdownTimeout},
+		{"tls-reload-interval", cfg.tls.reloadInterval},
+		{"lockout-duration", cfg.lockout.Duration},
+		{"lockout-max-duration", cfg.lockout.MaxDuration},
+		{"login-ip-window", cfg.loginThrottle.window},
+		{"verification-ttl", cfg.verificationTTL},
+		{"password-reset-ttl", cfg.passwordResetTTL},
+		{"ldap-timeout", cfg.ldap.timeout},
+		{"outbox-interval", cfg.mail.outboxInterval},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", d.name, d.value))
+		}
+	}
+
+	if cfg.server.drainDelay < 0 {
+		errs = append(errs, fmt.Errorf("drain-delay must not be negative, got %s", cfg.server.drainDelay))
+	}
+
+	// the last request of a session is only recorded every
+	// sessionTouchInterval.
+	if cfg.session.idleTimeout > 0 && cfg.session.idleTimeout < 5*sessionTouchInterval {
+		errs = append(errs, fmt.Errorf("session-idle-timeout must be at least %s, got %s", 5*sessionTouchInterval, cfg.session.idleTimeout))
+	}
+
+	if cfg.session.rememberLifetime < cfg.session.lifetime {
+		errs = append(errs, errors.New("session-remember-lifetime must not be less than session-lifetime"))
+	}
+
+	if cfg.query.slowThreshold < 0 {
+		errs = append(errs, fmt.Errorf("slow-query-threshold must not be negative, got %s", cfg.query.slowThreshold))
+	}
+
+	if cfg.lockout.Threshold < 0 {
+		errs = append(errs, fmt.Errorf("lockout-threshold must not be negative, got %d", cfg.lockout.Threshold))
+	}
+
+	if cfg.lockout.MaxDuration < cfg.lockout.Duration {
+		errs = append(errs, errors.New("lockout-max-duration must not be less than lockout-duration"))
+	}
+
+	if cfg.loginThrottle.threshold < 0 {
+		errs = append(errs, fmt.Errorf("login-ip-threshold must not be negative, got %d", cfg.loginThrottle.threshold))
+	}
+
+	if cfg.httpRedirectAddr != "" && !cfg.tls.enabled {
+		errs = append(errs, errors.New("http-redirect-addr needs tls to be enabled"))
+	}
+
+	if cfg.hsts.maxAge < 0 {
+		errs = append(errs, fmt.Errorf("hsts-max-age must not be negative, got %s", cfg.hsts.maxAge))
+	}
+
+	// the requirements of https://hstspreload.org.
+	if cfg.hsts.preload && (!cfg.hsts.includeSubDomains || cfg.hsts.maxAge < 365*24*time.Hour) {
+		errs = append(errs, errors.New("hsts-preload needs hsts-include-subdomains and an hsts-max-age of at least 8760h"))
+	}
+
+	if cfg.oidc.issuer != "" {
+		if u, err := url.Parse(cfg.oidc.issuer); err != nil || u.Scheme != "https" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("oidc-issuer must be an absolute https URL, got %q", cfg.oidc.issuer))
+		}
+		if cfg.oidc.clientID == "" {
+			errs = append(errs, errors.New("oidc-client-id must not be empty with oidc-issuer"))
+		}
+	}
+
+	if cfg.ldap.url != "" {
+		if u, err := url.Parse(cfg.ldap.url); err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("ldap-url must be an ldap:// or ldaps:// URL, got %q", cfg.ldap.url))
+		}
+		if cfg.ldap.userDN == "" {
+			if cfg.ldap.baseDN == "" {
+				errs = append(errs, errors.New("ldap-url needs either ldap-user-dn or ldap-base-dn"))
+			}
+			placeholders := strings.NewReplacer("{email}", "x", "{username}", "x")
+			if _, err := ldap.ParseFilter(placeholders.Replace(cfg.ldap.filter)); err != nil {
+				errs = append(errs, fmt.Errorf("ldap-filter: %w", err))
+			}
+		}
+	}
+
+	if cfg.bcryptCost < bcrypt.MinCost || cfg.bcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Errorf("bcrypt-cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cfg.bcryptCost))
+	}
+
+	if cfg.tls.enabled {
+		for _, file := range []string{cfg.tls.certFile, cfg.tls.keyFile} {
+			if _, err := os.Stat(file); err != nil {
+				errs = append(errs, fmt.Errorf("tls: %w (run with -gen-dev-cert to create a development certificate)", err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// print writes the effective config as a JSON object that can be used as a
+// config file, with the secrets redacted.
+func (cfg *config) print(w io.Writer) error {
+	settings := map[string]any{}
+	cfg.flags.VisitAll(func(f *flag.Flag) {
+		if commandFlags[f.Name] {
+			return
+		}
+
+		value := f.Value.(flag.Getter).Get()
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+
+		settings[f.Name] = redact(f.Name, value)
+	})
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(settings)
+}
+
+func redact(name string, value any) any {
+	if secretSettings[name] && value != "" {
+		return "[redacted]"
+	}
+
+	// the mysql backend accepts a DSN after the colon.
+	if s, ok := value.(string); ok && name == "db" {
+		if backend, dsn, found := strings.Cut(s, ":"); found && backend == "mysql" && dsn != "" {
+			return "mysql:[redacted]"
+		}
+	}
+
+	return value
+}
+
+// prefixList is a flag.Value holding a comma-separated list of IP addresses
+// and CIDR ranges.
+type prefixList []netip.Prefix
+
+func (pl *prefixList) String() string {
+	prefixes := make([]string, len(*pl))
+	for i, prefix := range *pl {
+		prefixes[i] = prefix.String()
+	}
+
+	return strings.Join(prefixes, ",")
+}
+
+func (pl *prefixList) Set(value string) error {
+	prefixes := prefixList{}
+
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if !strings.Contains(field, "/") {
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return err
+			}
+			field = netip.PrefixFrom(addr, addr.BitLen()).String()
+		}
+
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	*pl = prefixes
+	return nil
+}
+
+func (pl *prefixList) Get() any {
+	return pl.String()
+}
+
+func (pl prefixList) contains(addr netip.Addr) bool {
+	for _, prefix := range pl {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// domainList is a flag.Value holding a comma-separated list of email
+// domains, lower-cased.
+type domainList []string
+
+func (dl *domainList) String() string {
+	return strings.Join(*dl, ",")
+}
+
+func (dl *domainList) Set(value string) error {
+	domains := domainList{}
+
+	for _, field := range strings.Split(value, ",") {
+		field = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(field), "@"))
+		if field == "" {
+			continue
+		}
+
+		if strings.ContainsAny(field, "@ /") {
+			return fmt.Errorf("invalid domain %q", field)
+		}
+		domains = append(domains, field)
+	}
+
+	*dl = domains
+	return nil
+}
+
+func (dl *domainList) Get() any {
+	return dl.String()
+}
+
+// allows reports whether the domain of the email address is in the list.
+// An empty list allows every domain.
+func (dl domainList) allows(email string) bool {
+	if len(dl) == 0 {
+		return true
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+
+	for _, allowed := range dl {
+		if domain == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hstsHeader returns the value of the Strict-Transport-Security header, or an
+// empty string when it's disabled.
+func (cfg *config) hstsHeader() string {
+	if cfg.hsts.maxAge <= 0 {
+		return ""
+	}
+
+	header := fmt.Sprintf("max-age=%d", int64(cfg.hsts.maxAge.Seconds()))
+	if cfg.hsts.includeSubDomains {
+		header += "; includeSubDomains"
+	}
+	if cfg.hsts.preload {
+		header += "; preload"
+	}
+
+	return header
+}
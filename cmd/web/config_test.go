@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	return writeConfigFileNamed(t, "config.json", content)
+}
+
+func writeConfigFileNamed(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func lookupEnvFrom(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfigFile(t, `{"addr": ":4000", "read-timeout": "3s", "write-timeout": "20s", "bcrypt-cost": 10, "debug": false}`)
+
+	env := map[string]string{
+		"SNIPPETBOX_CONFIG":        path,
+		"SNIPPETBOX_READ_TIMEOUT":  "4s",
+		"SNIPPETBOX_WRITE_TIMEOUT": "30s",
+	}
+
+	cfg, err := loadConfig([]string{"-write-timeout", "40s"}, lookupEnvFrom(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, cfg.configFile, path)
+	assert.Equal(t, cfg.addr, ":4000")
+	assert.Equal(t, cfg.debug, false)
+	assert.Equal(t, cfg.bcryptCost, 10)
+	assert.Equal(t, cfg.server.readTimeout, 4*time.Second)
+	assert.Equal(t, cfg.server.writeTimeout, 40*time.Second)
+	assert.Equal(t, cfg.server.idleTimeout, time.Minute)
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		file     string
+		env      map[string]string
+		expected string
+	}{
+		{
+			name:     "YAML File",
+			fileName: "config.yaml",
+			file:     "addr: :4000",
+			expected: `only JSON config files ending in .json are supported, got ".yaml"`,
+		},
+		{
+			name:     "TOML File",
+			fileName: "config.toml",
+			file:     `addr = ":4000"`,
+			expected: `got ".toml"`,
+		},
+		{
+			name:     "Unknown Setting",
+			file:     `{"adr": ":4000"}`,
+			expected: `unknown setting "adr"`,
+		},
+		{
+			name:     "Command In File",
+			file:     `{"print-config": true}`,
+			expected: `unknown setting "print-config"`,
+		},
+		{
+			name:     "Invalid File Value",
+			file:     `{"read-timeout": "soon"}`,
+			expected: `invalid value "soon" for read-timeout`,
+		},
+		{
+			name:     "Nested Object",
+			file:     `{"tls": {"cert": "cert.pem"}}`,
+			expected: "tls must be a string, number or boolean",
+		},
+		{
+			name:     "Invalid Environment Value",
+			env:      map[string]string{"SNIPPETBOX_BCRYPT_COST": "high"},
+			expected: "environment variable SNIPPETBOX_BCRYPT_COST",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var args []string
+			if test.file != "" {
+				fileName := test.fileName
+				if fileName == "" {
+					fileName = "config.json"
+				}
+				args = append(args, "-config", writeConfigFileNamed(t, fileName, test.file))
+			}
+
+			_, err := loadConfig(args, lookupEnvFrom(test.env))
+			if err == nil {
+				t.Fatal("expected an error; got nil instead")
+			}
+			assert.StringContains(t, err.Error(), test.expected)
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.WriteFile(file, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := loadConfig([]string{"-tls-cert", certFile, "-tls-key", keyFile}, lookupEnvFrom(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NilError(t, cfg.validate())
+
+	cfg, err = loadConfig([]string{"-store", "redis", "-session-lifetime", "-1h", "-session-idle-timeout", "1m", "-session-remember-lifetime", "1h", "-bcrypt-cost", "3", "-lockout-max-duration", "1m", "-tls-cert", "missing.pem", "-oidc-issuer", "http://sso.example.com", "-ldap-url", "ldap://ldap.example.com", "-ldap-filter", "(mail={email}"}, lookupEnvFrom(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cfg.validate()
+	if err == nil {
+		t.Fatal("expected an error; got nil instead")
+	}
+	assert.StringContains(t, err.Error(), `store must be sql or memory, got "redis"`)
+	assert.StringContains(t, err.Error(), "session-lifetime must be positive")
+	assert.StringContains(t, err.Error(), "session-idle-timeout must be at least 5m0s, got 1m0s")
+	assert.StringContains(t, err.Error(), "bcrypt-cost must be between 4 and 31")
+	assert.StringContains(t, err.Error(), "lockout-max-duration must not be less than lockout-duration")
+	assert.StringContains(t, err.Error(), "missing.pem")
+	assert.StringContains(t, err.Error(), `oidc-issuer must be an absolute https URL, got "http://sso.example.com"`)
+	assert.StringContains(t, err.Error(), "oidc-client-id must not be empty")
+	assert.StringContains(t, err.Error(), "ldap-url needs either ldap-user-dn or ldap-base-dn")
+	assert.StringContains(t, err.Error(), "ldap-filter: ldap: invalid filter")
+
+	cfg, err = loadConfig([]string{"-tls=false", "-http-redirect-addr", ":80", "-hsts-max-age", "24h", "-hsts-preload"}, lookupEnvFrom(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cfg.validate()
+	if err == nil {
+		t.Fatal("expected an error; got nil instead")
+	}
+	assert.StringContains(t, err.Error(), "http-redirect-addr needs tls to be enabled")
+	assert.StringContains(t, err.Error(), "hsts-preload needs hsts-include-subdomains")
+}
+
+func TestDomainList(t *testing.T) {
+	var domains domainList
+	assert.Equal(t, domains.allows("jane@example.com"), true)
+
+	assert.NilError(t, domains.Set(" Snippetbox.sh, @example.com ,"))
+	assert.Equal(t, domains.String(), "snippetbox.sh,example.com")
+	assert.Equal(t, domains.allows("jane@SNIPPETBOX.sh"), true)
+	assert.Equal(t, domains.allows("jane@example.com"), true)
+	assert.Equal(t, domains.allows("jane@sub.example.com"), false)
+	assert.Equal(t, domains.allows("jane@example.com.evil.com"), false)
+	assert.Equal(t, domains.allows("example.com"), false)
+
+	err := domains.Set("jane@example.com")
+	assert.Equal(t, err != nil, true)
+}
+
+func TestConfigHSTSHeader(t *testing.T) {
+	cfg, err := loadConfig(nil, lookupEnvFrom(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, cfg.hstsHeader(), "")
+
+	cfg, err = loadConfig([]string{"-hsts-max-age", "8760h", "-hsts-include-subdomains", "-hsts-preload"}, lookupEnvFrom(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, cfg.hstsHeader(), "max-age=31536000; includeSubDomains; preload")
+}
+
+func TestConfigPrint(t *testing.T) {
+	cfg, err := loadConfig([]string{"-db", "mysql:web:hunter2@/snippetbox", "-dsn", "web:hunter2@/snippetbox", "-oidc-client-secret", "hunter2", "-ldap-bind-password", "hunter2"}, lookupEnvFrom(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = cfg.print(&buf)
+	assert.NilError(t, err)
+
+	output := buf.String()
+	assert.Equal(t, bytes.Contains(buf.Bytes(), []byte("hunter2")), false)
+	assert.StringContains(t, output, `"db": "mysql:[redacted]"`)
+	assert.StringContains(t, output, `"dsn": "[redacted]"`)
+	assert.StringContains(t, output, `"oidc-client-secret": "[redacted]"`)
+	assert.StringContains(t, output, `"ldap-bind-password": "[redacted]"`)
+	assert.StringContains(t, output, `"session-lifetime": "12h0m0s"`)
+	assert.StringContains(t, output, `"bcrypt-cost": 12`)
+}
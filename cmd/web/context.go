@@ -3,3 +3,17 @@ package main
 type contextKey string
 
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
+
+const authenticatedUserContextKey = contextKey("authenticatedUser")
+
+const forwardedProtoContextKey = contextKey("forwardedProto")
+
+const requestInfoContextKey = contextKey("requestInfo")
+
+// requestInfo is stored by pointer in the request context, so logRequest can
+// log what the middlewares and handlers after it find out about the request.
+type requestInfo struct {
+	id     string
+	route  string
+	userID int
+}
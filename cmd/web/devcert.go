@@ -0,0 +1,165 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/fs"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// devCertificateHosts are the names the development certificate is valid for.
+var devCertificateHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// generateDevCertificate writes a certificate for localhost to certFile and
+// keyFile, signed by a local CA stored next to them as ca.pem and ca-key.pem.
+// An existing CA is reused, so it only has to be trusted once. It returns the
+// path of the CA certificate.
+func generateDevCertificate(certFile, keyFile string) (string, error) {
+	dir := filepath.Dir(certFile)
+	caCertFile := filepath.Join(dir, "ca.pem")
+	caKeyFile := filepath.Join(dir, "ca-key.pem")
+
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return "", err
+	}
+
+	ca, caKey, err := loadDevCA(caCertFile, caKeyFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		ca, caKey, err = createDevCA(caCertFile, caKeyFile)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: randomSerialNumber(),
+		Subject:      pkix.Name{Organization: []string{"Snippetbox development"}, CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		// browsers reject leaf certificates valid for longer than 825 days.
+		NotAfter:    time.Now().AddDate(0, 0, 825),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range devCertificateHosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return "", err
+	}
+
+	err = writePEM(certFile, "CERTIFICATE", der, 0o644)
+	if err != nil {
+		return "", err
+	}
+
+	err = writePrivateKey(keyFile, key)
+	if err != nil {
+		return "", err
+	}
+
+	return caCertFile, nil
+}
+
+func createDevCA(certFile, keyFile string) (*x509.Certificate, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          randomSerialNumber(),
+		Subject:               pkix.Name{Organization: []string{"Snippetbox development"}, CommonName: "Snippetbox development CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = writePEM(certFile, "CERTIFICATE", der, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = writePrivateKey(keyFile, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ca, key, nil
+}
+
+func loadDevCA(certFile, keyFile string) (*x509.Certificate, crypto.Signer, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ca, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok || !ca.IsCA {
+		return nil, nil, errors.New(certFile + " is not a CA certificate")
+	}
+
+	return ca, key, nil
+}
+
+func writePrivateKey(path string, key *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	return writePEM(path, "PRIVATE KEY", der, 0o600)
+}
+
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), perm)
+}
+
+func randomSerialNumber() *big.Int {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		panic(err)
+	}
+
+	return serialNumber
+}
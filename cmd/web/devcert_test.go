@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestGenerateDevCertificate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tls")
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	caFile, err := generateDevCertificate(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, caFile, filepath.Join(dir, "ca.pem"))
+
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func(t *testing.T) {
+		pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		leaf, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		roots := x509.NewCertPool()
+		roots.AppendCertsFromPEM(caPEM)
+
+		for _, host := range devCertificateHosts {
+			_, err = leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots})
+			assert.NilError(t, err)
+		}
+	}
+
+	t.Run("New CA", verify)
+
+	t.Run("Reused CA", func(t *testing.T) {
+		_, err := generateDevCertificate(certFile, keyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		verify(t)
+	})
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 
@@ -11,6 +12,18 @@ import (
 	"github.com/julienschmidt/httprouter"
 )
 
+type userPasswordForgotForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+type userPasswordResetForm struct {
+	Token               string `form:"-"`
+	NewPassword         string `form:"new_password"`
+	ConfirmNewPassword  string `form:"confirm_new_password"`
+	validator.Validator `form:"-"`
+}
+
 type snippetCreateForm struct {
 	Title   string
 	Content string
@@ -28,6 +41,7 @@ type userSignupForm struct {
 type userLoginForm struct {
 	Email               string `form:"email"`
 	Password            string `form:"password"`
+	RememberMe          bool   `form:"remember_me"`
 	validator.Validator `form:"-"`
 }
 
@@ -39,46 +53,60 @@ type accountPasswordUpdateForm struct {
 }
 
 func (app *App) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.snippets.Latest()
+	snippets, err := app.snippets.Latest(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 	}
 
 	data := app.newTemplateData(r)
 	data.Snippets = snippets
 
-	app.render(w, http.StatusOK, "home.go.html", data)
+	app.render(w, r, http.StatusOK, "home.go.html", data)
 }
 
 func (app *App) about(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 
-	app.render(w, http.StatusOK, "about.go.html", data)
+	app.render(w, r, http.StatusOK, "about.go.html", data)
 }
 
 func (app *App) accountView(w http.ResponseWriter, r *http.Request) {
 	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
 
-	user, err := app.users.Get(userID)
+	user, err := app.users.Get(r.Context(), userID)
 	if err != nil {
 		if err == models.ErrNoRecord {
 			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	sessions, err := app.userSessions(r, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	events, err := app.auditEvents.List(r.Context(), models.AuditFilter{UserID: userID, Limit: accountEventsLimit})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	data := app.newTemplateData(r)
 	data.User = user
-	app.render(w, http.StatusOK, "account.go.html", data)
+	data.Sessions = sessions
+	data.AuditEvents = events
+	app.render(w, r, http.StatusOK, "account.go.html", data)
 }
 
 func (app *App) accountPasswordUpdate(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = accountPasswordUpdateForm{}
 
-	app.render(w, http.StatusOK, "change_password.go.html", data)
+	app.render(w, r, http.StatusOK, "change_password.go.html", data)
 }
 
 func (app *App) accountPasswordUpdatePost(w http.ResponseWriter, r *http.Request) {
@@ -107,12 +135,12 @@ func (app *App) accountPasswordUpdatePost(w http.ResponseWriter, r *http.Request
 		data := app.newTemplateData(r)
 		data.Form = form
 
-		app.render(w, http.StatusUnprocessableEntity, "change_password.go.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "change_password.go.html", data)
 		return
 	}
 
 	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
-	err = app.users.UpdatePassword(userID, form.CurrentPassword, form.NewPassword)
+	err = app.users.UpdatePassword(r.Context(), userID, form.CurrentPassword, form.NewPassword)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
 			form.AddFieldError("currentPassword", "Current password is incorrect")
@@ -120,13 +148,22 @@ func (app *App) accountPasswordUpdatePost(w http.ResponseWriter, r *http.Request
 			data := app.newTemplateData(r)
 			data.Form = form
 
-			app.render(w, http.StatusUnprocessableEntity, "change_password.go.html", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "change_password.go.html", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	// whoever else knew the old password is logged out.
+	err = app.destroyOtherSessions(r, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.auditUser(r, models.EventPasswordChange, userID, "")
+
 	app.sessionManager.Put(r.Context(), "flash", "Your password has been updated!")
 
 	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
@@ -141,12 +178,12 @@ func (app *App) snippetView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	snippet, err := app.snippets.Get(id)
+	snippet, err := app.snippets.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
@@ -154,7 +191,7 @@ func (app *App) snippetView(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
 
-	app.render(w, http.StatusOK, "view.go.html", data)
+	app.render(w, r, http.StatusOK, "view.go.html", data)
 }
 
 func (app *App) snippetCreateForm(w http.ResponseWriter, r *http.Request) {
@@ -163,7 +200,7 @@ func (app *App) snippetCreateForm(w http.ResponseWriter, r *http.Request) {
 		Expires: 365,
 	}
 
-	app.render(w, http.StatusOK, "create.go.html", data)
+	app.render(w, r, http.StatusOK, "create.go.html", data)
 }
 
 func (app *App) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
@@ -196,15 +233,17 @@ func (app *App) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
 		data := app.newTemplateData(r)
 		data.Form = form
 
-		app.render(w, http.StatusUnprocessableEntity, "create.go.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "create.go.html", data)
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	id, err := app.snippets.Insert(r.Context(), form.Title, form.Content, form.Expires)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
+	app.metrics.snippetsCreated.Inc()
+	app.audit(r, &models.AuditEvent{Type: models.EventSnippetCreate, TargetType: models.TargetSnippet, TargetID: id})
 
 	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
 
@@ -215,7 +254,7 @@ func (app *App) userSignup(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userSignupForm{}
 
-	app.render(w, http.StatusOK, "signup.go.html", data)
+	app.render(w, r, http.StatusOK, "signup.go.html", data)
 }
 
 func (app *App) userSignupPost(w http.ResponseWriter, r *http.Request) {
@@ -244,34 +283,109 @@ func (app *App) userSignupPost(w http.ResponseWriter, r *http.Request) {
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "signup.go.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.go.html", data)
 		return
 	}
 
-	err = app.users.Insert(name, email, password)
+	err = app.users.Insert(r.Context(), name, email, password)
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.AddFieldError("email", "Email address is already in use")
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "signup.go.html", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "signup.go.html", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
+	user, err := app.users.GetByEmail(r.Context(), email)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.audit(r, &models.AuditEvent{Type: models.EventSignup, ActorID: user.ID, TargetType: models.TargetUser, TargetID: user.ID})
+
+	err = app.sendVerificationEmail(r, user)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please check your email to verify your address, then log in.")
 
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 }
 
+func (app *App) userVerify(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	redirectPath := "/user/login"
+	if app.isAuthenticated(r) {
+		redirectPath = "/account/view"
+	}
+
+	userID, err := app.tokens.Use(r.Context(), params.ByName("token"), models.ScopeEmailVerification)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.sessionManager.Put(r.Context(), "flash", "This verification link is invalid or has expired.")
+			http.Redirect(w, r, redirectPath, http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.users.VerifyEmail(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your email address has been verified!")
+	http.Redirect(w, r, redirectPath, http.StatusSeeOther)
+}
+
+func (app *App) userVerifyResendPost(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	user, err := app.users.Get(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if user.EmailVerified() {
+		app.sessionManager.Put(r.Context(), "flash", "Your email address is already verified!")
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+
+	// only the latest link works, so the ones in the older emails can't be
+	// used by whoever got hold of them.
+	err = app.tokens.DeleteAllForUser(r.Context(), user.ID, models.ScopeEmailVerification)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.sendVerificationEmail(r, user)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "A new verification email is on its way!")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
 func (app *App) userLogin(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userLoginForm{}
 
-	app.render(w, http.StatusOK, "login.go.html", data)
+	app.render(w, r, http.StatusOK, "login.go.html", data)
 }
 
 func (app *App) userLoginPost(w http.ResponseWriter, r *http.Request) {
@@ -285,8 +399,9 @@ func (app *App) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	password := r.PostForm.Get("password")
 
 	form := userLoginForm{
-		Email:    email,
-		Password: password,
+		Email:      email,
+		Password:   password,
+		RememberMe: r.PostForm.Get("remember_me") != "",
 	}
 
 	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
@@ -296,53 +411,258 @@ func (app *App) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "login.go.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "login.go.html", data)
 		return
 	}
 
-	id, err := app.users.Authenticate(email, password)
+	throttled, err := app.loginThrottled(r)
 	if err != nil {
-		if errors.Is(err, models.ErrInvalidCredentials) {
-			form.AddNonFieldError("Email or password is incorrect")
+		app.serverError(w, r, err)
+		return
+	}
+	if throttled {
+		app.metrics.logins.Inc("throttled")
+		app.auditLoginFailure(r, email, "throttled")
+		app.loginFailed(w, r, form)
+		return
+	}
+
+	id, err := app.users.Authenticate(r.Context(), email, password)
+	if err != nil {
+		var lockout *models.LockoutError
+
+		switch {
+		case errors.As(err, &lockout):
+			app.metrics.logins.Inc("locked")
+			app.recordLoginFailure(r)
+			app.auditLoginFailure(r, email, "locked")
+			if lockout.Started {
+				app.notifyLockout(r, lockout)
+			}
+			app.loginFailed(w, r, form)
+		case errors.Is(err, models.ErrInvalidCredentials):
+			app.metrics.logins.Inc("failure")
+			app.recordLoginFailure(r)
+			app.auditLoginFailure(r, email, "invalid credentials")
+			app.loginFailed(w, r, form)
+		case errors.Is(err, models.ErrUnverifiedAccount):
+			app.metrics.logins.Inc("failure")
+			app.auditLoginFailure(r, email, "unverified account")
+			form.AddNonFieldError("An account with your email address already exists. Log in with its password and verify your email address to log in with your directory account.")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusForbidden, "login.go.html", data)
+		case errors.Is(err, models.ErrAccountDisabled):
+			app.metrics.logins.Inc("disabled")
+			app.auditLoginFailure(r, email, "disabled")
+			form.AddNonFieldError("Your account has been disabled.")
 
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "login.go.html", data)
+			app.render(w, r, http.StatusForbidden, "login.go.html", data)
+		default:
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	// the users with two-factor authentication aren't logged in until they
+	// type their code too.
+	tf, err := app.twoFactor.Get(r.Context(), id)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+	if tf != nil && tf.Enabled() {
+		app.startTwoFactorLogin(w, r, id, form.RememberMe)
+		return
+	}
+
+	path, err := app.logIn(r, id, form.RememberMe)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, path, http.StatusSeeOther)
+}
+
+func (app *App) userPasswordForgot(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userPasswordForgotForm{}
+
+	app.render(w, r, http.StatusOK, "forgot_password.go.html", data)
+}
+
+func (app *App) userPasswordForgotPost(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := userPasswordForgotForm{
+		Email: r.PostForm.Get("email"),
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRegexPattern), "email", "This field must be a valid email address")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "forgot_password.go.html", data)
+		return
+	}
+
+	user, err := app.users.GetByEmail(r.Context(), form.Email)
+	switch {
+	case err == nil:
+		err = app.sendPasswordResetEmail(r, user)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	case !errors.Is(err, models.ErrNoRecord):
+		app.serverError(w, r, err)
+		return
+	}
+
+	// the same answer whether there's an account or not, so the form can't be
+	// used to find out who signed up.
+	app.sessionManager.Put(r.Context(), "flash", "If there's an account with that email address, a link to reset its password is on its way.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *App) userPasswordReset(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	// the token is only used once the form is sent, so opening the link, which
+	// some email clients do to preview it, doesn't spend it.
+	data := app.newTemplateData(r)
+	data.Form = userPasswordResetForm{Token: params.ByName("token")}
+
+	app.render(w, r, http.StatusOK, "reset_password.go.html", data)
+}
+
+func (app *App) userPasswordResetPost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := userPasswordResetForm{
+		Token:              params.ByName("token"),
+		NewPassword:        r.PostForm.Get("new_password"),
+		ConfirmNewPassword: r.PostForm.Get("confirm_new_password"),
+	}
+
+	form.CheckField(validator.NotBlank(form.NewPassword), "newPassword", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.NewPassword, 8), "newPassword", "This field must be at least 8 characters long")
+	form.CheckField(validator.NotBlank(form.ConfirmNewPassword), "confirmNewPassword", "This field cannot be blank")
+	form.CheckField(validator.Equal(form.NewPassword, form.ConfirmNewPassword), "confirmNewPassword", "Password do not match")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "reset_password.go.html", data)
+		return
+	}
+
+	userID, err := app.tokens.Use(r.Context(), form.Token, models.ScopePasswordReset)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.sessionManager.Put(r.Context(), "flash", "This password reset link is invalid or has expired.")
+			http.Redirect(w, r, "/user/password/forgot", http.StatusSeeOther)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
+		return
+	}
+
+	err = app.users.SetPassword(r.Context(), userID, form.NewPassword)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
+	err = app.tokens.DeleteAllForUser(r.Context(), userID, models.ScopePasswordReset)
+	if err != nil {
+		app.serverError(w, r, err)
 		return
 	}
 
-	err = app.sessionManager.RenewToken(r.Context())
+	// whoever knew the old password may still be logged in.
+	err = app.destroyUserSessions(r.Context(), userID)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
-	path := app.sessionManager.PopString(r.Context(), "redirectPathAfterLogin")
-	if path != "" {
-		http.Redirect(w, r, path, http.StatusSeeOther)
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	err = app.renewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
 		return
 	}
 
-	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+	app.audit(r, &models.AuditEvent{Type: models.EventPasswordReset, ActorID: userID, TargetType: models.TargetUser, TargetID: userID})
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been reset. Please log in.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// loginFailed shows the login form again with the same message whether the
+// credentials are wrong, the account is locked or the client is throttled,
+// so the response doesn't tell whether the account exists.
+func (app *App) loginFailed(w http.ResponseWriter, r *http.Request, form userLoginForm) {
+	form.AddNonFieldError("Email or password is incorrect, or there were too many failed attempts. Please try again later.")
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, r, http.StatusUnprocessableEntity, "login.go.html", data)
 }
 
 func (app *App) userLogoutPost(w http.ResponseWriter, r *http.Request) {
-	err := app.sessionManager.RenewToken(r.Context())
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err := app.logOut(r)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
+	app.audit(r, &models.AuditEvent{Type: models.EventLogout, ActorID: userID, TargetType: models.TargetUser, TargetID: userID})
 
-	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
 	app.sessionManager.Put(r.Context(), "flash", "You've been logout successfully!")
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// redirectToHTTPS redirects every request to the same URL over HTTPS on
+// httpsPort. It uses 308 so that the method and body of a POST are kept.
+func redirectToHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if host == "" {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
 func ping(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
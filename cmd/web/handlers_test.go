@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
+	"io"
 	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/memory"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestPing(t *testing.T) {
@@ -18,6 +25,40 @@ func TestPing(t *testing.T) {
 	assert.Equal(t, body, "OK")
 }
 
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		name             string
+		httpsPort        string
+		target           string
+		expectedLocation string
+	}{
+		{
+			name:             "Default Port",
+			httpsPort:        "443",
+			target:           "http://snippetbox.sh/snippet/view/1?page=2",
+			expectedLocation: "https://snippetbox.sh/snippet/view/1?page=2",
+		},
+		{
+			name:             "Custom Port",
+			httpsPort:        "4000",
+			target:           "http://localhost:8080/user/login",
+			expectedLocation: "https://localhost:4000/user/login",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, test.target, nil)
+
+			redirectToHTTPS(test.httpsPort).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, http.StatusPermanentRedirect)
+			assert.Equal(t, rr.Header().Get("Location"), test.expectedLocation)
+		})
+	}
+}
+
 func TestSnippetView(t *testing.T) {
 	app := newTestApp(t)
 	server := newTestServer(t, app.routes())
@@ -290,8 +331,10 @@ func TestAccountView(t *testing.T) {
 
 	server.postForm(t, "/user/login", form)
 
-	code, _, _ := server.get(t, "/account/view")
+	code, _, body := server.get(t, "/account/view")
 	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Recent Activity")
+	assert.StringContains(t, body, "<td>192.0.2.1</td>")
 }
 
 func TestPasswordUpdate(t *testing.T) {
@@ -380,3 +423,319 @@ func TestPasswordUpdatePost(t *testing.T) {
 		})
 	}
 }
+
+func TestSnippetCreateAndView(t *testing.T) {
+	app := newTestApp(t)
+	app.snippets = &memory.SnippetModel{}
+	app.users = &memory.UserModel{BcryptCost: bcrypt.MinCost}
+	app.tokens = &memory.TokenModel{}
+	sender := &recordingSender{}
+	app.mailer = sender
+
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+	app.baseURL = server.URL
+
+	_, _, body := server.get(t, "/user/signup")
+	form := url.Values{}
+	form.Add("name", "Jane Doe")
+	form.Add("email", "jane@snippetbox.sh")
+	form.Add("password", "pa55word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ := server.postForm(t, "/user/signup", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = server.get(t, "/user/login")
+	form = url.Values{}
+	form.Add("email", "jane@snippetbox.sh")
+	form.Add("password", "pa55word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ = server.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// the snippets can only be created once the email address is verified.
+	code, headers, _ := server.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/view")
+
+	app.wg.Wait()
+	assert.Equal(t, len(sender.messages), 1)
+	link := verificationLinkRX.FindString(sender.messages[0].Text)
+	code, headers, _ = server.get(t, strings.TrimPrefix(link, server.URL))
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/view")
+
+	// the link only works once.
+	server.get(t, strings.TrimPrefix(link, server.URL))
+	_, _, body = server.get(t, "/account/view")
+	assert.StringContains(t, body, "This verification link is invalid or has expired.")
+
+	user, err := app.users.GetByEmail(context.Background(), "jane@snippetbox.sh")
+	assert.NilError(t, err)
+	assert.Equal(t, user.EmailVerified(), true)
+
+	_, _, body = server.get(t, "/snippet/create")
+	form = url.Values{}
+	form.Add("title", "An old silent pond")
+	form.Add("content", "An old silent pond...")
+	form.Add("expires", "7")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, headers, _ = server.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/snippet/view/1")
+
+	code, _, body = server.get(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "An old silent pond...")
+
+	_, _, body = server.get(t, "/")
+	assert.StringContains(t, body, "An old silent pond")
+}
+
+func TestUserVerify(t *testing.T) {
+	app := newTestApp(t)
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		urlPath       string
+		expectedFlash string
+	}{
+		{
+			name:          "Valid Token",
+			urlPath:       "/user/verify/valid-token",
+			expectedFlash: "Your email address has been verified!",
+		},
+		{
+			name:          "Invalid Token",
+			urlPath:       "/user/verify/invalid-token",
+			expectedFlash: "This verification link is invalid or has expired.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, headers, _ := server.get(t, test.urlPath)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, headers.Get("Location"), "/user/login")
+
+			_, _, body := server.get(t, "/user/login")
+			assert.StringContains(t, body, test.expectedFlash)
+		})
+	}
+}
+
+func TestUserVerifyResendPost(t *testing.T) {
+	app := newTestApp(t)
+	sender := &recordingSender{}
+	app.mailer = sender
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	_, _, body := server.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "unverified@snippetbox.sh")
+	form.Add("password", "12345678")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	server.postForm(t, "/user/login", form)
+
+	code, headers, _ := server.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/view")
+
+	_, _, body = server.get(t, "/account/view")
+	assert.StringContains(t, body, "You have to verify your email address to access this page!")
+	assert.StringContains(t, body, `<form action="/user/verify/resend" method="POST">`)
+
+	form = url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, headers, _ = server.postForm(t, "/user/verify/resend", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/view")
+
+	app.wg.Wait()
+	assert.Equal(t, len(sender.messages), 1)
+	assert.Equal(t, sender.messages[0].To, "unverified@snippetbox.sh")
+	assert.StringContains(t, sender.messages[0].Text, "/user/verify/valid-token")
+}
+
+func TestUserPasswordForgotPost(t *testing.T) {
+	app := newTestApp(t)
+	sender := &recordingSender{}
+	app.mailer = sender
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	_, _, body := server.get(t, "/user/password/forgot")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		email        string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Known email",
+			email:        "ayogi@snippetbox.sh",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/login",
+		},
+		{
+			name:         "Unknown email",
+			email:        "nobody@snippetbox.sh",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/login",
+		},
+		{
+			name:     "Invalid email",
+			email:    "ayogi@",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be a valid email address",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := server.postForm(t, "/user/password/forgot", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	// only the known email gets the link.
+	app.wg.Wait()
+	assert.Equal(t, len(sender.messages), 1)
+	assert.Equal(t, sender.messages[0].To, "ayogi@snippetbox.sh")
+	assert.StringContains(t, sender.messages[0].Text, "/user/password/reset/valid-token")
+}
+
+func TestUserPasswordResetPost(t *testing.T) {
+	app := newTestApp(t)
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	tests := []struct {
+		name               string
+		token              string
+		newPassword        string
+		confirmNewPassword string
+		wantCode           int
+		wantLocation       string
+		wantBody           string
+	}{
+		{
+			name:               "Valid token",
+			token:              "valid-token",
+			newPassword:        "n3wpa55word",
+			confirmNewPassword: "n3wpa55word",
+			wantCode:           http.StatusSeeOther,
+			wantLocation:       "/user/login",
+		},
+		{
+			name:               "Invalid token",
+			token:              "invalid-token",
+			newPassword:        "n3wpa55word",
+			confirmNewPassword: "n3wpa55word",
+			wantCode:           http.StatusSeeOther,
+			wantLocation:       "/user/password/forgot",
+		},
+		{
+			name:               "Short password",
+			token:              "valid-token",
+			newPassword:        "pa55",
+			confirmNewPassword: "pa55",
+			wantCode:           http.StatusUnprocessableEntity,
+			wantBody:           "This field must be at least 8 characters long",
+		},
+		{
+			name:               "Passwords do not match",
+			token:              "valid-token",
+			newPassword:        "n3wpa55word",
+			confirmNewPassword: "n3wpa55w0rd",
+			wantCode:           http.StatusUnprocessableEntity,
+			wantBody:           "Password do not match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, body := server.get(t, "/user/password/reset/"+tt.token)
+			assert.StringContains(t, body, `<form action="/user/password/reset/`+tt.token+`" method="POST"`)
+
+			form := url.Values{}
+			form.Add("new_password", tt.newPassword)
+			form.Add("confirm_new_password", tt.confirmNewPassword)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, headers, body := server.postForm(t, "/user/password/reset/"+tt.token, form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUserPasswordResetPostLogsOut(t *testing.T) {
+	app := newTestApp(t)
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	// someone else is logged in as the user the token belongs to.
+	_, _, body := server.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "unverified@snippetbox.sh")
+	form.Add("password", "12345678")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	server.postForm(t, "/user/login", form)
+
+	code, _, _ := server.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+
+	// the owner resets the password from another browser.
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerClient := *server.Client()
+	ownerClient.Jar = jar
+	get := func(urlPath string) string {
+		res, err := ownerClient.Get(server.URL + urlPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	body = get("/user/password/reset/valid-token")
+	form = url.Values{}
+	form.Add("new_password", "n3wpa55word")
+	form.Add("confirm_new_password", "n3wpa55word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	res, err := ownerClient.PostForm(server.URL+"/user/password/reset/valid-token", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusSeeOther)
+
+	code, headers, _ := server.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+}
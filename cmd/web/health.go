@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// healthCheckTimeout limits each check of /readyz, so a hanging dependency
+// fails the check instead of the probe.
+const healthCheckTimeout = 2 * time.Second
+
+// certExpiryWarning is how close to its expiry the certificate is reported
+// with the warn status. It doesn't make the server unready.
+const certExpiryWarning = 14 * 24 * time.Hour
+
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+type healthCheck struct {
+	name string
+	// check returns details to report along with the status, and an error
+	// when the dependency isn't usable.
+	check func(ctx context.Context) (map[string]any, error)
+}
+
+type checkResult struct {
+	Name      string         `json:"name"`
+	Status    string         `json:"status"`
+	LatencyMS float64        `json:"latency_ms"`
+	Error     string         `json:"error,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+type healthResponse struct {
+	Status string        `json:"status"`
+	Checks []checkResult `json:"checks,omitempty"`
+}
+
+// healthz reports whether the process is alive, it doesn't check anything
+// the server depends on, so a database outage doesn't get it restarted.
+func (app *App) healthz(w http.ResponseWriter, r *http.Request) {
+	app.writeHealth(w, http.StatusOK, healthResponse{Status: checkOK})
+}
+
+// readyz reports whether the server can serve requests. It returns 503 when a
+// check fails or while the server is draining before a shutdown, so the load
+// balancer stops sending requests. It's served publicly, so it only tells the
+// status of each check, the errors and details are on the admin listener.
+func (app *App) readyz(w http.ResponseWriter, r *http.Request) {
+	status, response := app.readiness(r.Context())
+
+	for i := range response.Checks {
+		response.Checks[i].Error = ""
+		response.Checks[i].Details = nil
+	}
+
+	app.writeHealth(w, status, response)
+}
+
+// readyzDetails is readyz with the errors and details of the checks, for the
+// admin listener.
+func (app *App) readyzDetails(w http.ResponseWriter, r *http.Request) {
+	status, response := app.readiness(r.Context())
+	app.writeHealth(w, status, response)
+}
+
+func (app *App) readiness(ctx context.Context) (int, healthResponse) {
+	response := healthResponse{Status: checkOK}
+	status := http.StatusOK
+
+	for _, hc := range app.readinessChecks() {
+		result := runCheck(ctx, hc)
+		if result.Status == checkFail {
+			response.Status = checkFail
+			status = http.StatusServiceUnavailable
+		}
+		response.Checks = append(response.Checks, result)
+	}
+
+	if app.draining.Load() {
+		response.Status = "draining"
+		status = http.StatusServiceUnavailable
+	}
+
+	return status, response
+}
+
+func (app *App) writeHealth(w http.ResponseWriter, status int, response healthResponse) {
+	js, err := json.MarshalIndent(response, "", "\t")
+	if err != nil {
+		app.logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+}
+
+func runCheck(ctx context.Context, hc healthCheck) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := hc.check(ctx)
+
+	result := checkResult{
+		Name:      hc.name,
+		Status:    checkOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   details,
+	}
+
+	var warning *checkWarning
+	switch {
+	case errors.As(err, &warning):
+		result.Status = checkWarn
+		result.Error = err.Error()
+	case err != nil:
+		result.Status = checkFail
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// checkWarning is returned by the checks that found a problem that doesn't
+// stop the server from working yet.
+type checkWarning struct {
+	message string
+}
+
+func (w *checkWarning) Error() string {
+	return w.message
+}
+
+func (app *App) readinessChecks() []healthCheck {
+	var checks []healthCheck
+
+	if app.db != nil {
+		checks = append(checks, healthCheck{
+			name: "database",
+			check: func(ctx context.Context) (map[string]any, error) {
+				return nil, app.db.PingContext(ctx)
+			},
+		})
+	}
+
+	checks = append(checks,
+		healthCheck{name: "session_store", check: app.checkSessionStore},
+		healthCheck{name: "templates", check: app.checkTemplates},
+	)
+
+	if app.certs != nil {
+		checks = append(checks, healthCheck{name: "certificate", check: app.checkCertificate})
+	}
+
+	return checks
+}
+
+// checkSessionStore looks up a token that can't exist, which touches the
+// store the same way loading a session does. It goes to the store under the
+// instrumentation so the probes aren't counted as session loads, and gives up
+// waiting for the stores that don't take a context once ctx is done.
+func (app *App) checkSessionStore(ctx context.Context) (map[string]any, error) {
+	const token = "readiness-check"
+
+	store := app.sessionManager.Store
+	if instrumented, ok := store.(*instrumentedStore); ok {
+		store = instrumented.Store
+	}
+
+	if ctxStore, ok := store.(scs.CtxStore); ok {
+		_, _, err := ctxStore.FindCtx(ctx, token)
+		return nil, err
+	}
+
+	// buffered, so the lookup can finish after the check gave up.
+	found := make(chan error, 1)
+	go func() {
+		_, _, err := store.Find(token)
+		found <- err
+	}()
+
+	select {
+	case err := <-found:
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (app *App) checkTemplates(ctx context.Context) (map[string]any, error) {
+	if len(app.templateCache) == 0 {
+		return nil, errors.New("no templates loaded")
+	}
+
+	return map[string]any{"pages": len(app.templateCache)}, nil
+}
+
+func (app *App) checkCertificate(ctx context.Context) (map[string]any, error) {
+	leaf := app.certs.leaf()
+	remaining := time.Until(leaf.NotAfter)
+
+	details := map[string]any{
+		"subject":        leaf.Subject.CommonName,
+		"expires":        leaf.NotAfter.UTC().Format(time.RFC3339),
+		"days_to_expiry": int(app.certs.daysToExpiry()),
+	}
+
+	switch {
+	case remaining <= 0:
+		return details, errors.New("certificate expired")
+	case remaining < certExpiryWarning:
+		return details, &checkWarning{fmt.Sprintf("certificate expires in less than %d days", int(certExpiryWarning.Hours()/24))}
+	}
+
+	return details, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func TestHealthz(t *testing.T) {
+	app := newTestApp(t)
+	app.draining.Store(true)
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("Content-Type"), "application/json")
+	assert.StringContains(t, rr.Body.String(), `"status": "ok"`)
+}
+
+func TestReadyz(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCertificate(t, certFile, keyFile, "localhost", 7*24*time.Hour, time.Now())
+
+	certs, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApp(t)
+	app.certs = certs
+
+	readyz := func() (int, healthResponse) {
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		var response healthResponse
+		err := json.Unmarshal(rr.Body.Bytes(), &response)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return rr.Code, response
+	}
+
+	code, response := readyz()
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, response.Status, checkOK)
+
+	statuses := map[string]string{}
+	for _, check := range response.Checks {
+		statuses[check.Name] = check.Status
+	}
+	assert.Equal(t, statuses["session_store"], checkOK)
+	assert.Equal(t, statuses["templates"], checkOK)
+	// the certificate expires within the warning period.
+	assert.Equal(t, statuses["certificate"], checkWarn)
+
+	// the public response doesn't tell why or anything about the certificate.
+	for _, check := range response.Checks {
+		assert.Equal(t, check.Error, "")
+		assert.Equal(t, len(check.Details), 0)
+	}
+
+	t.Run("Admin listener", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		app.adminRoutes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, rr.Code, http.StatusOK)
+
+		var response healthResponse
+		err := json.Unmarshal(rr.Body.Bytes(), &response)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, check := range response.Checks {
+			if check.Name == "certificate" {
+				assert.StringContains(t, check.Error, "certificate expires in less than 14 days")
+				assert.Equal(t, check.Details["subject"], any("localhost"))
+			}
+		}
+	})
+
+	t.Run("Failed check", func(t *testing.T) {
+		templateCache := app.templateCache
+		app.templateCache = nil
+		defer func() { app.templateCache = templateCache }()
+
+		code, response := readyz()
+		assert.Equal(t, code, http.StatusServiceUnavailable)
+		assert.Equal(t, response.Status, checkFail)
+	})
+
+	t.Run("Draining", func(t *testing.T) {
+		app.draining.Store(true)
+		defer app.draining.Store(false)
+
+		code, response := readyz()
+		assert.Equal(t, code, http.StatusServiceUnavailable)
+		assert.Equal(t, response.Status, "draining")
+	})
+}
+
+// blockingStore is a session store whose lookups hang until release is closed.
+type blockingStore struct {
+	memstore.MemStore
+	release chan struct{}
+}
+
+func (s *blockingStore) Find(token string) ([]byte, bool, error) {
+	<-s.release
+	return nil, false, nil
+}
+
+func TestCheckSessionStore(t *testing.T) {
+	app := newTestApp(t)
+	store := &blockingStore{release: make(chan struct{})}
+	defer close(store.release)
+	app.sessionManager.Store = &instrumentedStore{Store: store, ops: app.metrics.sessionStoreOps}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := app.checkSessionStore(ctx)
+	assert.Equal(t, err, context.DeadlineExceeded)
+
+	// the probes aren't counted as session loads.
+	assert.Equal(t, app.metrics.sessionStoreOps.Value("find", "ok"), 0)
+	assert.Equal(t, app.metrics.sessionStoreOps.Value("find", "error"), 0)
+}
@@ -2,23 +2,59 @@ package main
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"runtime/debug"
 	"time"
 
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
 	"github.com/justinas/nosurf"
 )
 
-func (app *App) serverError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	app.errorLog.Output(2, trace)
+func (app *App) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	requestID := requestInfoFrom(r).id
+	trace := string(debug.Stack())
 
+	app.logger.Error(
+		err.Error(),
+		"request_id", requestID,
+		"method", r.Method,
+		"uri", r.URL.RequestURI(),
+		"trace", trace,
+	)
+
+	message := http.StatusText(http.StatusInternalServerError)
 	if app.debug {
-		http.Error(w, trace, http.StatusInternalServerError)
-		return
+		message = fmt.Sprintf("%s\n%s", err.Error(), trace)
+	}
+
+	// the ID lets the user report the error and us find it in the logs.
+	if requestID != "" {
+		message += "\n\nRequest ID: " + requestID
 	}
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+	http.Error(w, message, http.StatusInternalServerError)
+}
+
+// background runs fn in a goroutine that the shutdown waits for, fn must
+// return once the server stops.
+func (app *App) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Error(fmt.Sprintf("%s", err))
+			}
+		}()
+
+		fn()
+	}()
 }
 
 func (app *App) clientError(w http.ResponseWriter, status int) {
@@ -29,19 +65,23 @@ func (app *App) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
 
-func (app *App) render(w http.ResponseWriter, status int, page string, data *templateData) {
+func (app *App) render(w http.ResponseWriter, r *http.Request, status int, page string, data *templateData) {
 	ts, ok := app.templateCache[page]
 	if !ok {
 		err := fmt.Errorf("the template %s does not exist", page)
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
+	_, span := trace.Start(r.Context(), "template "+page)
+
 	// write the template to the buffer instead straight to the http.ResponseWriter.
 	buffer := new(bytes.Buffer)
 	err := ts.ExecuteTemplate(buffer, "base", data)
+	span.SetError(err)
+	span.End()
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -50,12 +90,27 @@ func (app *App) render(w http.ResponseWriter, status int, page string, data *tem
 }
 
 func (app *App) newTemplateData(r *http.Request) *templateData {
-	return &templateData{
+	data := &templateData{
 		CurrentYear:     time.Now().Year(),
 		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
 		IsAuthenticated: app.isAuthenticated(r),
 		CSRFToken:       nosurf.Token(r),
+		OIDCName:        app.oidcName,
 	}
+
+	if user := app.authenticatedUser(r); user != nil {
+		data.IsModerator = user.Role.Includes(models.RoleModerator)
+		data.IsAdmin = user.Role.Includes(models.RoleAdmin)
+	}
+
+	return data
+}
+
+// authenticatedUser returns the user logged in to the session of r, nil when
+// there's none.
+func (app *App) authenticatedUser(r *http.Request) *models.User {
+	user, _ := r.Context().Value(authenticatedUserContextKey).(*models.User)
+	return user
 }
 
 func (app *App) isAuthenticated(r *http.Request) bool {
@@ -66,3 +121,36 @@ func (app *App) isAuthenticated(r *http.Request) bool {
 
 	return isAuthenticated
 }
+
+// isHTTPS reports whether the client made the request over HTTPS, either to
+// this server or to a trusted proxy in front of it.
+func isHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+
+	proto, _ := r.Context().Value(forwardedProtoContextKey).(string)
+	return proto == "https"
+}
+
+// requestInfoFrom returns the information about the request shared between
+// the middlewares, or an empty one when the request didn't go through
+// requestID.
+func requestInfoFrom(r *http.Request) *requestInfo {
+	info, ok := r.Context().Value(requestInfoContextKey).(*requestInfo)
+	if !ok {
+		return &requestInfo{}
+	}
+
+	return info
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket
+// activation, the handover uses the same convention.
+const listenFDsStart = 3
+
+// readyFDEnv names the environment variable holding the file descriptor that
+// a process started by handOver writes to once it's ready to serve.
+const readyFDEnv = "SNIPPETBOX_READY_FD"
+
+// handoverTimeout is how long handOver waits for the new process.
+const handoverTimeout = 30 * time.Second
+
+// inheritedListeners returns the listening sockets passed to the process,
+// either by systemd socket activation or by handOver, keyed by their name in
+// LISTEN_FDNAMES. A single unnamed socket is used for the web server.
+func inheritedListeners() (map[string]net.Listener, error) {
+	listeners := map[string]net.Listener{}
+
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	// handOver can't know the PID of the new process in advance, so a missing
+	// LISTEN_PID is accepted.
+	pid := os.Getenv("LISTEN_PID")
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return listeners, nil
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return listeners, nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("fd%d", listenFDsStart+i)
+		if i < len(names) && names[i] != "" && names[i] != "unknown" {
+			name = names[i]
+		} else if i == 0 {
+			name = "web"
+		}
+
+		file := os.NewFile(uintptr(listenFDsStart+i), name)
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("inherited listener %s: %w", name, err)
+		}
+
+		listeners[name] = listener
+	}
+
+	return listeners, nil
+}
+
+// notifyReady tells the process that started this one with handOver that it
+// can stop accepting connections.
+func notifyReady() error {
+	value, ok := os.LookupEnv(readyFDEnv)
+	if !ok {
+		return nil
+	}
+	os.Unsetenv(readyFDEnv)
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", readyFDEnv, err)
+	}
+
+	file := os.NewFile(uintptr(fd), "ready")
+	defer file.Close()
+
+	_, err = file.Write([]byte{1})
+	return err
+}
+
+// handOver starts a new copy of the program with the same arguments that
+// inherits the listening sockets, and waits until it's ready to serve. Both
+// processes accept connections on the sockets until this one shuts down, so
+// no connection is refused during the restart.
+func handOver(listeners map[string]net.Listener) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(listeners))
+	for name := range listeners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	files := make([]*os.File, 0, len(listeners)+1)
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	for _, name := range names {
+		filer, ok := listeners[name].(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("listener %s can't be handed over", name)
+		}
+
+		file, err := filer.File()
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+
+	ready, readyWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer ready.Close()
+	files = append(files, readyWriter)
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		"LISTEN_FDS="+strconv.Itoa(len(names)),
+		"LISTEN_FDNAMES="+strings.Join(names, ":"),
+		readyFDEnv+"="+strconv.Itoa(listenFDsStart+len(names)),
+	)
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	// close our copy of the write end, so the read below fails with EOF if
+	// the new process exits before writing to it.
+	readyWriter.Close()
+	files = files[:len(files)-1]
+
+	readErr := make(chan error, 1)
+	go func() {
+		_, err := ready.Read(make([]byte, 1))
+		readErr <- err
+	}()
+
+	select {
+	case err = <-readErr:
+		if err != nil {
+			cmd.Wait()
+			return errors.New("the new process exited before it was ready")
+		}
+	case <-time.After(handoverTimeout):
+		cmd.Process.Kill()
+		cmd.Wait()
+		return errors.New("timed out waiting for the new process")
+	}
+
+	return cmd.Process.Release()
+}
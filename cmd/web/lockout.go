@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/mailer"
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+// loginFailureCleanupInterval is how often the failed logins older than the
+// throttle window are deleted.
+const loginFailureCleanupInterval = 10 * time.Minute
+
+// loginThrottle refuses the logins of a client IP after threshold failed
+// logins within window. The lockout of the accounts doesn't catch someone
+// trying one password on many accounts, this does.
+type loginThrottle struct {
+	threshold int
+	window    time.Duration
+}
+
+func (lt loginThrottle) enabled() bool {
+	return lt.threshold > 0 && lt.window > 0
+}
+
+// loginThrottled reports whether the client IP of r failed to log in too many
+// times recently.
+func (app *App) loginThrottled(r *http.Request) (bool, error) {
+	if app.loginFailures == nil || !app.loginThrottle.enabled() {
+		return false, nil
+	}
+
+	since := time.Now().Add(-app.loginThrottle.window)
+	count, err := app.loginFailures.Count(r.Context(), clientIP(r), since)
+	if err != nil {
+		return false, err
+	}
+
+	return count >= app.loginThrottle.threshold, nil
+}
+
+// recordLoginFailure counts a failed login against the client IP of r. It
+// only logs the errors, the login failed anyway.
+func (app *App) recordLoginFailure(r *http.Request) {
+	if app.loginFailures == nil {
+		return
+	}
+
+	err := app.loginFailures.Insert(r.Context(), clientIP(r))
+	if err != nil {
+		app.logger.Error("recording failed login", "error", err, "request_id", requestInfoFrom(r).id)
+	}
+}
+
+// notifyLockout emails the owner of the account that was just locked, so
+// they know someone is guessing their password.
+func (app *App) notifyLockout(r *http.Request, lockout *models.LockoutError) {
+	user, err := app.users.Get(r.Context(), lockout.UserID)
+	if err != nil {
+		app.logger.Error("sending lockout notice", "error", err, "user_id", lockout.UserID, "request_id", requestInfoFrom(r).id)
+		return
+	}
+
+	msg, err := mailer.NewMessage(user.Email, "lockout.tmpl", map[string]any{
+		"Name":  user.Name,
+		"Until": formatHumanReadableDate(lockout.Until) + " UTC",
+	})
+	if err != nil {
+		app.logger.Error("sending lockout notice", "error", err, "user_id", lockout.UserID, "request_id", requestInfoFrom(r).id)
+		return
+	}
+
+	app.sendEmail(msg)
+}
+
+// cleanupLoginFailures deletes the failed logins that no longer count
+// against the throttle until ctx is canceled.
+func (app *App) cleanupLoginFailures(ctx context.Context) {
+	if app.loginFailures == nil || !app.loginThrottle.enabled() {
+		return
+	}
+
+	ticker := time.NewTicker(loginFailureCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := app.loginFailures.DeleteBefore(ctx, time.Now().Add(-app.loginThrottle.window))
+			if err != nil && ctx.Err() == nil {
+				app.logger.Error("deleting old failed logins", "error", err)
+			}
+		}
+	}
+}
+
+// unlockUser unlocks the account with the given email, for -unlock-user.
+func unlockUser(ctx context.Context, users models.UserModelInterface, email string) error {
+	user, err := users.GetByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("unlocking %s: %w", email, err)
+	}
+
+	return users.Unlock(ctx, user.ID)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/memory"
+	"github.com/ahmadyogi543/snippetbox/internal/mocks"
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+func TestUserLoginPostFailures(t *testing.T) {
+	const message = "Email or password is incorrect, or there were too many failed attempts."
+
+	app := newTestApp(t)
+	sender := &recordingSender{}
+	app.mailer = sender
+	app.loginFailures = &memory.LoginFailureModel{}
+	app.loginThrottle = loginThrottle{threshold: 2, window: time.Minute}
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	login := func(email, password string) (int, string) {
+		_, _, body := server.get(t, "/user/login")
+
+		form := url.Values{}
+		form.Add("email", email)
+		form.Add("password", password)
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, _, body := server.postForm(t, "/user/login", form)
+		return code, body
+	}
+
+	t.Run("Locked", func(t *testing.T) {
+		code, body := login("locked@snippetbox.sh", "12345678")
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, message)
+
+		// the notice is sent in the background.
+		app.wg.Wait()
+		assert.Equal(t, len(sender.messages), 1)
+		assert.Equal(t, sender.messages[0].To, "ayogi@snippetbox.sh")
+	})
+
+	t.Run("Invalid Credentials", func(t *testing.T) {
+		code, body := login("nobody@snippetbox.sh", "12345678")
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, message)
+	})
+
+	t.Run("Throttled", func(t *testing.T) {
+		// the two failures above reached the threshold of the client IP, so
+		// even the right password is refused.
+		code, body := login("ayogi@snippetbox.sh", "12345678")
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, message)
+	})
+}
+
+// unverifiedUsers is the users of a directory where the email address of the
+// directory user belongs to an unverified local account.
+type unverifiedUsers struct {
+	mocks.UserModel
+}
+
+func (um *unverifiedUsers) Authenticate(ctx context.Context, email, password string) (int, error) {
+	return 0, models.ErrUnverifiedAccount
+}
+
+func TestUserLoginPostUnverifiedAccount(t *testing.T) {
+	app := newTestApp(t)
+	app.users = &unverifiedUsers{}
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	_, _, body := server.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", "ayogi@snippetbox.sh")
+	form.Add("password", "12345678")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body := server.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusForbidden)
+	assert.StringContains(t, body, "An account with your email address already exists.")
+
+	code, _, _ = server.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusSeeOther)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/mailer"
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+// sendEmailTimeout limits sending one email.
+const sendEmailTimeout = 30 * time.Second
+
+// tokenCleanupInterval is how often the expired tokens are deleted.
+const tokenCleanupInterval = time.Hour
+
+// sendEmail sends msg in the background, so a slow mail server doesn't slow
+// down the request. The errors are only logged.
+func (app *App) sendEmail(msg mailer.Message) {
+	if app.mailer == nil {
+		return
+	}
+
+	app.background(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sendEmailTimeout)
+		defer cancel()
+
+		err := app.mailer.Send(ctx, msg)
+		if err != nil {
+			app.logger.Error("sending email", "error", err, "subject", msg.Subject)
+		}
+	})
+}
+
+// sendVerificationEmail sends user a link verifying their email address.
+func (app *App) sendVerificationEmail(r *http.Request, user *models.User) error {
+	token, err := app.tokens.New(r.Context(), user.ID, app.verificationTTL, models.ScopeEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	msg, err := mailer.NewMessage(user.Email, "verification.tmpl", map[string]any{
+		"Name":   user.Name,
+		"URL":    app.baseURL + "/user/verify/" + token.Plaintext,
+		"Expiry": formatHumanReadableDate(token.Expiry) + " UTC",
+	})
+	if err != nil {
+		return err
+	}
+
+	app.sendEmail(msg)
+
+	return nil
+}
+
+// sendPasswordResetEmail sends user a link to choose a new password. Only
+// the latest link works.
+func (app *App) sendPasswordResetEmail(r *http.Request, user *models.User) error {
+	err := app.tokens.DeleteAllForUser(r.Context(), user.ID, models.ScopePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	token, err := app.tokens.New(r.Context(), user.ID, app.passwordResetTTL, models.ScopePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	msg, err := mailer.NewMessage(user.Email, "password_reset.tmpl", map[string]any{
+		"Name":   user.Name,
+		"URL":    app.baseURL + "/user/password/reset/" + token.Plaintext,
+		"Expiry": formatHumanReadableDate(token.Expiry) + " UTC",
+	})
+	if err != nil {
+		return err
+	}
+
+	app.sendEmail(msg)
+
+	return nil
+}
+
+// cleanupTokens deletes the expired tokens until ctx is canceled.
+func (app *App) cleanupTokens(ctx context.Context) {
+	if app.tokens == nil {
+		return
+	}
+
+	ticker := time.NewTicker(tokenCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := app.tokens.DeleteExpired(ctx)
+			if err != nil && ctx.Err() == nil {
+				app.logger.Error("deleting expired tokens", "error", err)
+			}
+		}
+	}
+}
+
+// newMailSender returns the transport chosen by -mailer.
+func newMailSender(cfg *config, logger *slog.Logger) (mailer.Sender, error) {
+	switch cfg.mail.transport {
+	case "log":
+		return &mailer.LogSender{Logger: logger}, nil
+	case "smtp":
+		return &mailer.SMTPSender{
+			Host:     cfg.mail.smtp.host,
+			Port:     cfg.mail.smtp.port,
+			Username: cfg.mail.smtp.username,
+			Password: cfg.mail.smtp.password,
+			From:     cfg.mail.from,
+		}, nil
+	case "file":
+		return &mailer.FileSender{Dir: cfg.mail.dir, From: cfg.mail.from}, nil
+	default:
+		return nil, fmt.Errorf("unsupported mailer %q", cfg.mail.transport)
+	}
+}
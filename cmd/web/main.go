@@ -1,78 +1,296 @@
 package main
 
 import (
-	"crypto/tls"
+	"context"
+	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	"html/template"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ahmadyogi543/snippetbox/internal/mailer"
+	"github.com/ahmadyogi543/snippetbox/internal/memory"
 	"github.com/ahmadyogi543/snippetbox/internal/models"
+	"github.com/ahmadyogi543/snippetbox/internal/oidc"
+	"github.com/ahmadyogi543/snippetbox/internal/ratelimit"
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
+	"github.com/ahmadyogi543/snippetbox/internal/webauthn"
 	"github.com/alexedwards/scs/mysqlstore"
+	"github.com/alexedwards/scs/sqlite3store"
 	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
 	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
 )
 
 type App struct {
-	debug          bool
-	errorLog       *log.Logger
-	infoLog        *log.Logger
-	snippets       models.SnippetModelInterface
-	users          models.UserModelInterface
-	templateCache  map[string]*template.Template
-	sessionManager *scs.SessionManager
+	debug              bool
+	logger             *slog.Logger
+	db                 *sql.DB
+	snippets           models.SnippetModelInterface
+	users              models.UserModelInterface
+	loginFailures      models.LoginFailureModelInterface
+	tokens             models.TokenModelInterface
+	twoFactor          models.TwoFactorModelInterface
+	credentials        models.CredentialModelInterface
+	identities         models.IdentityModelInterface
+	auditEvents        models.AuditEventModelInterface
+	sessionIndex       models.UserSessionModelInterface
+	relyingParty       *webauthn.RelyingParty
+	oidcProvider       *oidc.Provider
+	oidcName           string
+	oidcAllowedDomains domainList
+	loginThrottle      loginThrottle
+	mailer             mailer.Sender
+	outbox             *mailer.Outbox
+	baseURL            string
+	verificationTTL    time.Duration
+	passwordResetTTL   time.Duration
+	templateCache      map[string]*template.Template
+	sessionManager     *scs.SessionManager
+	sessionPolicy      sessionPolicy
+	metrics            *appMetrics
+	tracer             *trace.Tracer
+	certs              *certReloader
+	trustedProxies     prefixList
+	rateLimiter        ratelimit.Store
+	rateLimits         rateLimits
+	hsts               string
+	wg                 sync.WaitGroup
+	draining           atomic.Bool
 }
 
 func main() {
-	addr := flag.String("addr", ":3000", "HTTP network address")
-	debug := flag.Bool("debug", true, "Enable debug mode")
-	dsn := flag.String("dsn", "web:12345678@/snippetbox?parseTime=true", "MySQL data source name")
-	flag.Parse()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 
-	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
+	cfg, err := loadConfig(os.Args[1:], os.LookupEnv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	logger = newLogger(os.Stdout, cfg.log.format, cfg.log.level)
 
-	db, err := openDB("mysql", *dsn)
+	if cfg.printConfig {
+		err = cfg.print(os.Stdout)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		if err = cfg.validate(); err != nil {
+			logger.Error("invalid config", "errors", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.genDevCert {
+		caFile, err := generateDevCertificate(cfg.tls.certFile, cfg.tls.keyFile)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		logger.Info("wrote development certificate, trust the CA to avoid certificate warnings", "cert", cfg.tls.certFile, "key", cfg.tls.keyFile, "ca", caFile)
+		return
+	}
+
+	if err = cfg.validate(); err != nil {
+		logger.Error("invalid config", "errors", err.Error())
+		os.Exit(1)
+	}
+
+	// os.Exit skips the deferred calls, so run returns first to close the
+	// database and the tracer.
+	err = run(cfg, logger)
 	if err != nil {
-		errorLog.Fatal(err)
+		logger.Error(err.Error())
+		os.Exit(1)
 	}
-	defer db.Close()
+}
 
+// run runs the app with the validated configuration until it's shut down,
+// or until the administration command it was started for is done.
+func run(cfg *config, logger *slog.Logger) error {
 	templateCache, err := newTemplateCache()
 	if err != nil {
-		errorLog.Fatal(err)
+		return err
 	}
 
+	// the sessions live as long as a login, the remembered ones are given a
+	// longer deadline when logging in.
 	sessionManager := scs.New()
-	sessionManager.Store = mysqlstore.New(db)
-	sessionManager.Lifetime = 12 * time.Hour
-	sessionManager.Cookie.Secure = true
+	sessionManager.Lifetime = cfg.session.lifetime
+	sessionManager.Cookie.Persist = false
+	// behind a TLS-terminating proxy the browser still uses HTTPS, so the
+	// cookies only lose the Secure attribute when serving plain HTTP directly.
+	sessionManager.Cookie.Secure = cfg.tls.enabled || len(cfg.trustedProxies) > 0
 
 	app := &App{
-		debug:          *debug,
-		errorLog:       errorLog,
-		infoLog:        infoLog,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
-		templateCache:  templateCache,
-		sessionManager: sessionManager,
-	}
-
-	server := &http.Server{
-		Addr:     *addr,
-		ErrorLog: errorLog,
-		Handler:  app.routes(),
-		TLSConfig: &tls.Config{
-			CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
-		},
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-	}
-
-	infoLog.Printf("Starting server on %s", *addr)
-	err = server.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errorLog.Fatal(err)
+		debug:              cfg.debug,
+		logger:             logger,
+		templateCache:      templateCache,
+		sessionManager:     sessionManager,
+		sessionPolicy:      cfg.session,
+		trustedProxies:     cfg.trustedProxies,
+		rateLimiter:        &ratelimit.MemoryStore{},
+		rateLimits:         cfg.rateLimits,
+		loginThrottle:      cfg.loginThrottle,
+		baseURL:            strings.TrimSuffix(cfg.baseURL, "/"),
+		verificationTTL:    cfg.verificationTTL,
+		passwordResetTTL:   cfg.passwordResetTTL,
+		oidcAllowedDomains: cfg.oidc.allowedDomains,
+		hsts:               cfg.hstsHeader(),
+		metrics:            newAppMetrics(),
+	}
+
+	app.relyingParty, err = newRelyingParty(app.baseURL)
+	if err != nil {
+		return err
+	}
+
+	if cfg.oidc.issuer != "" {
+		app.oidcProvider = &oidc.Provider{
+			Issuer:       cfg.oidc.issuer,
+			ClientID:     cfg.oidc.clientID,
+			ClientSecret: cfg.oidc.clientSecret,
+			RedirectURL:  app.baseURL + "/user/login/oidc/callback",
+			Client:       &http.Client{Timeout: 10 * time.Second},
+		}
+		app.oidcName = cfg.oidc.name
+	}
+
+	tracer, closeTracer, err := newTracer(cfg.traceExporter)
+	if err != nil {
+		return err
+	}
+	defer closeTracer()
+	if tracer != nil {
+		tracer.OnError = func(err error) {
+			logger.Error(err.Error())
+		}
+	}
+	app.tracer = tracer
+
+	sender, err := newMailSender(cfg, logger)
+	if err != nil {
+		return err
+	}
+	app.outbox = &mailer.Outbox{Sender: sender, MaxAttempts: cfg.mail.outboxMaxAttempts, Logger: logger}
+	app.mailer = app.outbox
+
+	switch cfg.store {
+	case "memory":
+		app.snippets = &memory.SnippetModel{}
+		app.users = &memory.UserModel{BcryptCost: cfg.bcryptCost, Lockout: cfg.lockout}
+		app.loginFailures = &memory.LoginFailureModel{}
+		app.tokens = &memory.TokenModel{}
+		app.twoFactor = &memory.TwoFactorModel{}
+		app.credentials = &memory.CredentialModel{}
+		app.identities = &memory.IdentityModel{}
+		app.auditEvents = &memory.AuditEventModel{}
+		app.sessionIndex = &memory.UserSessionModel{}
+		app.outbox.Store = &memory.OutboxModel{}
+		sessionManager.Store = memstore.New()
+	case "sql":
+		driverName, dataSourceName, err := parseDatabase(cfg.db, cfg.dsn)
+		if err != nil {
+			return err
+		}
+
+		db, err := openDB(driverName, dataSourceName)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		app.db = db
+		app.metrics.registerDBStats(db)
+
+		queryOptions := models.QueryOptions{
+			Timeout:       cfg.query.timeout,
+			SlowThreshold: cfg.query.slowThreshold,
+			Logger:        logger,
+		}
+		app.snippets = &models.SnippetModel{DB: db, QueryOptions: queryOptions}
+		app.users = &models.UserModel{DB: db, BcryptCost: cfg.bcryptCost, Lockout: cfg.lockout, QueryOptions: queryOptions}
+		app.loginFailures = &models.LoginFailureModel{DB: db, QueryOptions: queryOptions}
+		app.tokens = &models.TokenModel{DB: db, QueryOptions: queryOptions}
+		app.twoFactor = &models.TwoFactorModel{DB: db, QueryOptions: queryOptions}
+		app.credentials = &models.CredentialModel{DB: db, QueryOptions: queryOptions}
+		app.identities = &models.IdentityModel{DB: db, QueryOptions: queryOptions}
+		app.auditEvents = &models.AuditEventModel{DB: db, QueryOptions: queryOptions}
+		app.sessionIndex = &models.UserSessionModel{DB: db, QueryOptions: queryOptions}
+		app.outbox.Store = &models.OutboxModel{DB: db, QueryOptions: queryOptions}
+
+		switch driverName {
+		case "sqlite":
+			sessionManager.Store = sqlite3store.New(db)
+		default:
+			sessionManager.Store = mysqlstore.New(db)
+		}
+	default:
+		return fmt.Errorf("unsupported store %q", cfg.store)
+	}
+
+	if cfg.ldap.url != "" {
+		app.users = &models.DirectoryUserModel{
+			UserModelInterface: app.users,
+			Directory: &models.LDAPAuthenticator{
+				URL:            cfg.ldap.url,
+				Timeout:        cfg.ldap.timeout,
+				BindDN:         cfg.ldap.bindDN,
+				BindPassword:   cfg.ldap.bindPassword,
+				UserDN:         cfg.ldap.userDN,
+				BaseDN:         cfg.ldap.baseDN,
+				Filter:         cfg.ldap.filter,
+				NameAttribute:  cfg.ldap.nameAttribute,
+				EmailAttribute: cfg.ldap.emailAttribute,
+			},
+			Identities: app.identities,
+			Issuer:     "ldap",
+			Logger:     logger,
+		}
+	}
+
+	if cfg.unlockUser != "" {
+		err = unlockUser(context.Background(), app.users, cfg.unlockUser)
+		if err != nil {
+			return err
+		}
+		logger.Info("unlocked account", "email", cfg.unlockUser)
+		return nil
+	}
+
+	if cfg.setRole != "" {
+		email, role, err := setRole(context.Background(), app.users, cfg.setRole)
+		if err != nil {
+			return err
+		}
+		logger.Info("changed the role of the account", "email", email, "role", role)
+		return nil
+	}
+
+	sessionManager.Store = &instrumentedStore{Store: sessionManager.Store, ops: app.metrics.sessionStoreOps}
+
+	return app.serve(cfg)
+}
+
+func newLogger(w io.Writer, format string, level slog.Level) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level}
+
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+
+	return slog.New(slog.NewTextHandler(w, options))
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/metrics"
+)
+
+// unmatchedRoute labels the requests that didn't match any route, so that
+// scanners requesting random paths don't add a series each.
+const unmatchedRoute = "unmatched"
+
+type appMetrics struct {
+	registry         *metrics.Registry
+	requests         *metrics.CounterVec
+	requestDuration  *metrics.HistogramVec
+	requestsInFlight *metrics.Gauge
+	sessionStoreOps  *metrics.CounterVec
+	snippetsCreated  *metrics.Counter
+	logins           *metrics.CounterVec
+	rateLimited      *metrics.CounterVec
+}
+
+func newAppMetrics() *appMetrics {
+	reg := metrics.NewRegistry()
+
+	return &appMetrics{
+		registry:         reg,
+		requests:         reg.NewCounterVec("snippetbox_http_requests_total", "HTTP requests by route, method and status code.", "route", "method", "status"),
+		requestDuration:  reg.NewHistogramVec("snippetbox_http_request_duration_seconds", "Duration of the HTTP requests by route and method.", metrics.DefBuckets, "route", "method"),
+		requestsInFlight: reg.NewGauge("snippetbox_http_requests_in_flight", "HTTP requests being served."),
+		sessionStoreOps:  reg.NewCounterVec("snippetbox_session_store_operations_total", "Session store operations by operation and result.", "operation", "result"),
+		snippetsCreated:  reg.NewCounter("snippetbox_snippets_created_total", "Snippets created."),
+		logins:           reg.NewCounterVec("snippetbox_logins_total", "Login attempts by result.", "result"),
+		rateLimited:      reg.NewCounterVec("snippetbox_rate_limited_total", "Requests refused by the rate limits, by limit.", "limit"),
+	}
+}
+
+// registerDBStats exposes the connection pool stats of db.
+func (m *appMetrics) registerDBStats(db *sql.DB) {
+	gauges := []struct {
+		name, help string
+		value      func(sql.DBStats) float64
+	}{
+		{"snippetbox_db_max_open_connections", "Maximum number of open connections to the database.", func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"snippetbox_db_open_connections", "Established connections to the database, in use or idle.", func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"snippetbox_db_in_use_connections", "Connections to the database in use.", func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"snippetbox_db_idle_connections", "Idle connections to the database.", func(s sql.DBStats) float64 { return float64(s.Idle) }},
+	}
+	for _, g := range gauges {
+		value := g.value
+		m.registry.NewGaugeFunc(g.name, g.help, func() float64 { return value(db.Stats()) })
+	}
+
+	counters := []struct {
+		name, help string
+		value      func(sql.DBStats) float64
+	}{
+		{"snippetbox_db_wait_count_total", "Connections waited for.", func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"snippetbox_db_wait_duration_seconds_total", "Time spent waiting for a connection.", func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		{"snippetbox_db_max_idle_closed_total", "Connections closed because of the idle connections limit.", func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"snippetbox_db_max_idle_time_closed_total", "Connections closed because of the maximum idle time.", func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+		{"snippetbox_db_max_lifetime_closed_total", "Connections closed because of the maximum lifetime.", func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+	for _, c := range counters {
+		value := c.value
+		m.registry.NewCounterFunc(c.name, c.help, func() float64 { return value(db.Stats()) })
+	}
+}
+
+// registerCertExpiry exposes how long the certificate served by certs has
+// left, following its reloads.
+func (m *appMetrics) registerCertExpiry(certs *certReloader) {
+	m.registry.NewGaugeFunc("snippetbox_tls_cert_days_to_expiry", "Days until the served TLS certificate expires.", certs.daysToExpiry)
+}
+
+// measure records the count and duration of the requests by route, so it has
+// to wrap the router that sets the route in the request info.
+func (app *App) measure(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.metrics.requestsInFlight.Inc()
+		defer app.metrics.requestsInFlight.Dec()
+
+		start := time.Now()
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		route := requestInfoFrom(r).route
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		app.metrics.requests.Inc(route, r.Method, strconv.Itoa(recorder.status))
+		app.metrics.requestDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+	})
+}
+
+// route records the route pattern of the requests handled by next, since
+// httprouter doesn't tell which route matched.
+func route(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestInfoFrom(r).route = pattern
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	app := newTestApp(t)
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	server.get(t, "/snippet/view/1")
+	server.get(t, "/snippet/view/2")
+	server.get(t, "/no/such/page")
+
+	for _, password := range []string{"wrong password", "12345678"} {
+		_, _, body := server.get(t, "/user/login")
+
+		form := url.Values{}
+		form.Add("email", "ayogi@snippetbox.sh")
+		form.Add("password", password)
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		server.postForm(t, "/user/login", form)
+	}
+
+	rr := httptest.NewRecorder()
+	app.adminRoutes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	body := rr.Body.String()
+	assert.StringContains(t, body, `snippetbox_http_requests_total{route="/snippet/view/:id",method="GET",status="200"} 1`)
+	assert.StringContains(t, body, `snippetbox_http_requests_total{route="/snippet/view/:id",method="GET",status="404"} 1`)
+	assert.StringContains(t, body, `snippetbox_http_requests_total{route="unmatched",method="GET",status="404"} 1`)
+	assert.StringContains(t, body, `snippetbox_http_request_duration_seconds_count{route="/snippet/view/:id",method="GET"} 2`)
+	assert.StringContains(t, body, `snippetbox_http_requests_in_flight 0`)
+	assert.StringContains(t, body, `snippetbox_logins_total{result="failure"} 1`)
+	assert.StringContains(t, body, `snippetbox_logins_total{result="success"} 1`)
+}
+
+func TestMetricsCertExpiry(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCertificate(t, certFile, keyFile, "localhost", 7*24*time.Hour, time.Now())
+
+	certs, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApp(t)
+	app.metrics.registerCertExpiry(certs)
+
+	rr := httptest.NewRecorder()
+	app.adminRoutes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	match := regexp.MustCompile(`(?m)^snippetbox_tls_cert_days_to_expiry (\S+)$`).FindStringSubmatch(rr.Body.String())
+	if match == nil {
+		t.Fatalf("the metrics don't have the days to expiry:\n%s", rr.Body.String())
+	}
+
+	days, err := strconv.ParseFloat(match[1], 64)
+	assert.NilError(t, err)
+	if days < 6.9 || days > 7 {
+		t.Errorf("got %v days to expiry; want about 7", days)
+	}
+}
@@ -2,13 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
+	"github.com/justinas/alice"
 	"github.com/justinas/nosurf"
 )
 
-func secureHeaders(next http.Handler) http.Handler {
+func (app *App) secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
 		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
@@ -16,24 +24,151 @@ func secureHeaders(next http.Handler) http.Handler {
 		w.Header().Set("X-Frame-Options", "deny")
 		w.Header().Set("X-XSS-Protection", "0")
 
+		// browsers ignore the header over plain HTTP.
+		if app.hsts != "" && isHTTPS(r) {
+			w.Header().Set("Strict-Transport-Security", app.hsts)
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
 
-func (app *App) logRequest(next http.Handler) http.Handler {
+// proxyHeaders replaces the remote address with the client address from
+// X-Forwarded-For, and records the scheme from X-Forwarded-Proto, for requests
+// coming from a trusted proxy. The headers of other requests are ignored, as
+// anyone can set them.
+func (app *App) proxyHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
+		remoteAddr, err := netip.ParseAddrPort(r.RemoteAddr)
+		if err != nil || !app.trustedProxies.contains(remoteAddr.Addr()) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if clientAddr, ok := forwardedFor(r.Header.Values("X-Forwarded-For"), app.trustedProxies); ok {
+			r.RemoteAddr = clientAddr.String()
+		}
+
+		switch proto := strings.ToLower(r.Header.Get("X-Forwarded-Proto")); proto {
+		case "http", "https":
+			ctx := context.WithValue(r.Context(), forwardedProtoContextKey, proto)
+			r = r.WithContext(ctx)
+		}
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// forwardedFor returns the client address from X-Forwarded-For headers. Each
+// proxy appends the address it received the request from, so the client is the
+// rightmost address that isn't a trusted proxy.
+func forwardedFor(headers []string, trustedProxies prefixList) (netip.Addr, bool) {
+	var addrs []netip.Addr
+	for _, header := range headers {
+		for _, field := range strings.Split(header, ",") {
+			addr, err := netip.ParseAddr(strings.TrimSpace(field))
+			if err != nil {
+				return netip.Addr{}, false
+			}
+			addrs = append(addrs, addr.Unmap())
+		}
+	}
+
+	for i := len(addrs) - 1; i >= 0; i-- {
+		if !trustedProxies.contains(addrs[i]) || i == 0 {
+			return addrs[i], true
+		}
+	}
+
+	return netip.Addr{}, false
+}
+
+// requestID takes the request ID from the X-Request-ID header, or generates a
+// new one, and adds it to the response and the request context.
+func (app *App) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDRX.MatchString(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		ctx := context.WithValue(r.Context(), requestInfoContextKey, &requestInfo{id: id})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestIDRX limits the request IDs taken from the clients, so they can't
+// inject anything into the logs.
+var requestIDRX = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,128}$`)
+
+func (app *App) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		info := requestInfoFrom(r)
+		attrs := []any{
+			"request_id", info.id,
+			"remote_addr", r.RemoteAddr,
+			"proto", r.Proto,
+			"method", r.Method,
+			"uri", r.URL.RequestURI(),
+			"status", recorder.status,
+			"size", recorder.size,
+			"duration", time.Since(start),
+		}
+		if info.userID != 0 {
+			attrs = append(attrs, "user_id", info.userID)
+		}
+		if span := trace.SpanFromContext(r.Context()); span != nil {
+			attrs = append(attrs, "trace_id", span.SpanContext().TraceID.String())
+		}
+
+		app.logger.Info("request", attrs...)
+	})
+}
+
+// responseRecorder records the status code and size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	size        int
+	wroteHeader bool
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if !rr.wroteHeader && status >= 200 {
+		rr.status = status
+		rr.wroteHeader = true
+	}
+
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.wroteHeader = true
+
+	n, err := rr.ResponseWriter.Write(b)
+	rr.size += n
+
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
 func (app *App) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
 				w.Header().Set("Connection", "close")
-				app.serverError(w, fmt.Errorf("%s", err))
+				app.serverError(w, r, fmt.Errorf("%s", err))
 			}
 		}()
 
@@ -44,11 +179,14 @@ func (app *App) recoverPanic(next http.Handler) http.Handler {
 func (app *App) requireAuthentication(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !app.isAuthenticated(r) {
-			app.sessionManager.Put(
-				r.Context(),
-				"flash",
-				"You have to login to access this page!",
-			)
+			// keep the reason the session expired.
+			if !app.sessionManager.Exists(r.Context(), "flash") {
+				app.sessionManager.Put(
+					r.Context(),
+					"flash",
+					"You have to login to access this page!",
+				)
+			}
 			app.sessionManager.Put(
 				r.Context(),
 				"redirectPathAfterLogin",
@@ -64,12 +202,53 @@ func (app *App) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
-func noSurf(next http.Handler) http.Handler {
+// requireVerifiedEmail sends the users who haven't verified their email
+// address yet to their account page, where they can get the email again. It
+// must come after requireAuthentication.
+func (app *App) requireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the user was loaded by authenticate.
+		user := app.authenticatedUser(r)
+		if user == nil || !user.EmailVerified() {
+			app.sessionManager.Put(
+				r.Context(),
+				"flash",
+				"You have to verify your email address to access this page!",
+			)
+			w.Header().Add("Cache-Control", "no-store")
+
+			http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireRole refuses the users who don't have role, it goes after
+// requireAuthentication.
+func (app *App) requireRole(role models.Role) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := app.authenticatedUser(r)
+			if user == nil || !user.Role.Includes(role) {
+				app.clientError(w, http.StatusForbidden)
+				return
+			}
+
+			w.Header().Add("Cache-Control", "no-store")
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (app *App) noSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
 	csrfHandler.SetBaseCookie(http.Cookie{
 		HttpOnly: true,
 		Path:     "/",
-		Secure:   true,
+		Secure:   app.sessionManager.Cookie.Secure,
 	})
 
 	return csrfHandler
@@ -83,15 +262,36 @@ func (app *App) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		exists, err := app.users.Exists(id)
-		if err != nil {
-			app.serverError(w, err)
+		if app.sessionExpired(r.Context()) {
+			err := app.logOut(r)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+
+			app.sessionManager.Put(r.Context(), "flash", "Your session has expired, please log in again.")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := app.users.Get(r.Context(), id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
 			return
 		}
 
-		if exists {
+		// the disabled users are logged out as soon as they're disabled.
+		if user != nil && !user.Disabled() {
+			err = app.touchSession(r)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserContextKey, user)
 			r = r.WithContext(ctx)
+			requestInfoFrom(r).userID = id
 		}
 
 		next.ServeHTTP(w, r)
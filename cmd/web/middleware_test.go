@@ -2,9 +2,14 @@ package main
 
 import (
 	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/ahmadyogi543/snippetbox/internal/assert"
@@ -22,7 +27,8 @@ func TestSecureHeaders(t *testing.T) {
 		w.Write([]byte("OK"))
 	})
 
-	secureHeaders(next).ServeHTTP(rr, r)
+	app := &App{}
+	app.secureHeaders(next).ServeHTTP(rr, r)
 
 	rs := rr.Result()
 
@@ -38,6 +44,7 @@ func TestSecureHeaders(t *testing.T) {
 		assert.Equal(t, rs.Header.Get(k), v)
 	}
 
+	assert.Equal(t, rs.Header.Get("Strict-Transport-Security"), "")
 	assert.Equal(t, rs.StatusCode, http.StatusOK)
 
 	defer rs.Body.Close()
@@ -49,3 +56,207 @@ func TestSecureHeaders(t *testing.T) {
 
 	assert.Equal(t, string(body), "OK")
 }
+
+func TestSecureHeadersHSTS(t *testing.T) {
+	app := &App{hsts: "max-age=31536000; includeSubDomains"}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	tests := []struct {
+		name     string
+		tls      bool
+		expected string
+	}{
+		{
+			name:     "HTTPS",
+			tls:      true,
+			expected: "max-age=31536000; includeSubDomains",
+		},
+		{
+			name:     "Plain HTTP",
+			tls:      false,
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+
+			rr := httptest.NewRecorder()
+			app.secureHeaders(next).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Header().Get("Strict-Transport-Security"), test.expected)
+		})
+	}
+}
+
+func TestProxyHeaders(t *testing.T) {
+	app := &App{}
+	err := app.trustedProxies.Set("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name               string
+		remoteAddr         string
+		forwardedFor       string
+		forwardedProto     string
+		expectedRemoteAddr string
+		expectedHTTPS      bool
+	}{
+		{
+			name:               "Trusted Proxy",
+			remoteAddr:         "10.1.2.3:51234",
+			forwardedFor:       "203.0.113.7",
+			forwardedProto:     "https",
+			expectedRemoteAddr: "203.0.113.7",
+			expectedHTTPS:      true,
+		},
+		{
+			name:               "Chain Of Trusted Proxies",
+			remoteAddr:         "192.168.1.1:51234",
+			forwardedFor:       "198.51.100.1, 203.0.113.7, 10.0.0.2",
+			forwardedProto:     "http",
+			expectedRemoteAddr: "203.0.113.7",
+			expectedHTTPS:      false,
+		},
+		{
+			name:               "Untrusted Remote",
+			remoteAddr:         "203.0.113.9:51234",
+			forwardedFor:       "198.51.100.1",
+			forwardedProto:     "https",
+			expectedRemoteAddr: "203.0.113.9:51234",
+			expectedHTTPS:      false,
+		},
+		{
+			name:               "Malformed Header",
+			remoteAddr:         "10.1.2.3:51234",
+			forwardedFor:       "unknown",
+			expectedRemoteAddr: "10.1.2.3:51234",
+			expectedHTTPS:      false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.RemoteAddr = test.remoteAddr
+			r.Header.Set("X-Forwarded-For", test.forwardedFor)
+			r.Header.Set("X-Forwarded-Proto", test.forwardedProto)
+
+			var remoteAddr string
+			var https bool
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				remoteAddr = r.RemoteAddr
+				https = isHTTPS(r)
+			})
+
+			app.proxyHeaders(next).ServeHTTP(httptest.NewRecorder(), r)
+
+			assert.Equal(t, remoteAddr, test.expectedRemoteAddr)
+			assert.Equal(t, https, test.expectedHTTPS)
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	app := &App{}
+
+	var got string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = requestInfoFrom(r).id
+	})
+
+	tests := []struct {
+		name     string
+		header   string
+		wantSame bool
+	}{
+		{name: "Incoming ID", header: "abc-123.DEF_456", wantSame: true},
+		{name: "No ID", header: ""},
+		{name: "Invalid characters", header: "abc\ninjected"},
+		{name: "Too long", header: strings.Repeat("a", 129)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("X-Request-ID", tt.header)
+			}
+
+			app.requestID(next).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Header().Get("X-Request-ID"), got)
+			assert.Equal(t, got == tt.header, tt.wantSame)
+			assert.Equal(t, got != "", true)
+		})
+	}
+}
+
+func TestLogRequest(t *testing.T) {
+	var buf bytes.Buffer
+	app := &App{logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestInfoFrom(r).userID = 7
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/snippet/view/1?x=y", nil)
+	r.Header.Set("X-Request-ID", "req-1")
+	app.requestID(app.logRequest(next)).ServeHTTP(httptest.NewRecorder(), r)
+
+	var entry struct {
+		Msg       string `json:"msg"`
+		RequestID string `json:"request_id"`
+		Method    string `json:"method"`
+		URI       string `json:"uri"`
+		Status    int    `json:"status"`
+		Size      int    `json:"size"`
+		UserID    int    `json:"user_id"`
+	}
+	err := json.Unmarshal(buf.Bytes(), &entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, entry.Msg, "request")
+	assert.Equal(t, entry.RequestID, "req-1")
+	assert.Equal(t, entry.Method, http.MethodGet)
+	assert.Equal(t, entry.URI, "/snippet/view/1?x=y")
+	assert.Equal(t, entry.Status, http.StatusTeapot)
+	assert.Equal(t, entry.Size, len("short and stout"))
+	assert.Equal(t, entry.UserID, 7)
+}
+
+func TestServerErrorRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	app := &App{logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.serverError(w, r, errors.New("boom"))
+	})
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-ID", "req-2")
+	app.requestID(next).ServeHTTP(rr, r)
+
+	assert.Equal(t, rr.Code, http.StatusInternalServerError)
+	assert.StringContains(t, rr.Body.String(), "Request ID: req-2")
+	assert.StringContains(t, buf.String(), `"msg":"boom"`)
+	assert.StringContains(t, buf.String(), `"request_id":"req-2"`)
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+	"github.com/ahmadyogi543/snippetbox/internal/validator"
+	"github.com/ahmadyogi543/snippetbox/internal/webauthn"
+	"github.com/julienschmidt/httprouter"
+)
+
+// maxWebAuthnRequestBytes limits the JSON the browsers send with the
+// responses of the authenticators, which are a few kilobytes at most.
+const maxWebAuthnRequestBytes = 64 << 10
+
+// newRelyingParty returns the WebAuthn relying party of the website at
+// baseURL. The passkeys only work on that host.
+func newRelyingParty(baseURL string) (*webauthn.RelyingParty, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webauthn.RelyingParty{
+		ID:     u.Hostname(),
+		Name:   "Snippetbox",
+		Origin: u.Scheme + "://" + u.Host,
+	}, nil
+}
+
+// webAuthnUserHandle is the user ID the authenticators keep with a passkey
+// and send back when logging in with it.
+func webAuthnUserHandle(userID int) []byte {
+	return []byte(strconv.Itoa(userID))
+}
+
+// newWebAuthnChallenge returns a challenge for the ceremony and keeps it in
+// the session of r until the response comes back.
+func (app *App) newWebAuthnChallenge(r *http.Request, ceremony string) ([]byte, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	app.sessionManager.Put(r.Context(), "webAuthnCeremony", ceremony)
+	app.sessionManager.Put(r.Context(), "webAuthnChallenge", base64.RawURLEncoding.EncodeToString(challenge))
+	app.sessionManager.Put(r.Context(), "webAuthnExpiry", time.Now().Add(webauthn.Timeout).Unix())
+
+	return challenge, nil
+}
+
+// popWebAuthnChallenge returns the challenge of the ceremony kept in the
+// session of r and forgets it, so every challenge is only answered once. It
+// returns nil when there's none or it expired.
+func (app *App) popWebAuthnChallenge(r *http.Request, ceremony string) []byte {
+	gotCeremony := app.sessionManager.PopString(r.Context(), "webAuthnCeremony")
+	encoded := app.sessionManager.PopString(r.Context(), "webAuthnChallenge")
+	expiry := time.Unix(app.sessionManager.GetInt64(r.Context(), "webAuthnExpiry"), 0)
+	app.sessionManager.Remove(r.Context(), "webAuthnExpiry")
+
+	if gotCeremony != ceremony || time.Now().After(expiry) {
+		return nil
+	}
+
+	challenge, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(challenge) == 0 {
+		return nil
+	}
+
+	return challenge
+}
+
+// readJSON decodes the JSON body of r into dst.
+func (app *App) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxWebAuthnRequestBytes)
+
+	return json.NewDecoder(r.Body).Decode(dst)
+}
+
+func (app *App) writeJSON(w http.ResponseWriter, r *http.Request, status int, data any) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+}
+
+// passkeyLoginFailed counts a failed passkey login against the client IP and
+// records it to the account of userID, zero when the passkey is unknown.
+func (app *App) passkeyLoginFailed(w http.ResponseWriter, r *http.Request, userID int, why, message string) {
+	app.metrics.logins.Inc("failure")
+	app.recordLoginFailure(r)
+
+	event := &models.AuditEvent{Type: models.EventLoginFailed, Detail: why}
+	if userID != 0 {
+		event.TargetType = models.TargetUser
+		event.TargetID = userID
+	}
+	app.audit(r, event)
+
+	app.webAuthnError(w, r, message)
+}
+
+// webAuthnError tells the script of the page what went wrong, to show it.
+func (app *App) webAuthnError(w http.ResponseWriter, r *http.Request, message string) {
+	app.writeJSON(w, r, http.StatusBadRequest, map[string]string{"error": message})
+}
+
+func (app *App) accountPasskeys(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	credentials, err := app.credentials.ListForUser(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Credentials = credentials
+	app.render(w, r, http.StatusOK, "passkeys.go.html", data)
+}
+
+func (app *App) accountPasskeyRegisterBeginPost(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	user, err := app.users.Get(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	credentials, err := app.credentials.ListForUser(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	exclude := make([][]byte, len(credentials))
+	for i, credential := range credentials {
+		exclude[i] = credential.ID
+	}
+
+	challenge, err := app.newWebAuthnChallenge(r, "register")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	options := app.relyingParty.CreationOptions(challenge, webauthn.User{
+		ID:          webAuthnUserHandle(user.ID),
+		Name:        user.Email,
+		DisplayName: user.Name,
+	}, exclude)
+
+	app.writeJSON(w, r, http.StatusOK, options)
+}
+
+type passkeyRegisterRequest struct {
+	Name     string                       `json:"name"`
+	Response webauthn.AttestationResponse `json:"response"`
+}
+
+func (app *App) accountPasskeyRegisterFinishPost(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	var input passkeyRegisterRequest
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.webAuthnError(w, r, "The request is malformed.")
+		return
+	}
+
+	var v validator.Validator
+	v.CheckField(validator.NotBlank(input.Name), "name", "Give the passkey a name.")
+	v.CheckField(validator.MaxChars(input.Name, 100), "name", "The name of the passkey cannot be more than 100 characters long.")
+	if !v.Valid() {
+		app.webAuthnError(w, r, v.FieldErrors["name"])
+		return
+	}
+
+	challenge := app.popWebAuthnChallenge(r, "register")
+	if challenge == nil {
+		app.webAuthnError(w, r, "The request expired, please try again.")
+		return
+	}
+
+	credential, err := app.relyingParty.VerifyRegistration(challenge, &input.Response)
+	if err != nil {
+		app.logger.Warn("passkey registration failed", "error", err, "user_id", userID, "request_id", requestInfoFrom(r).id)
+		app.webAuthnError(w, r, "The passkey could not be verified.")
+		return
+	}
+
+	err = app.credentials.Insert(r.Context(), &models.Credential{
+		ID:        credential.ID,
+		UserID:    userID,
+		Name:      input.Name,
+		PublicKey: credential.PublicKey,
+		SignCount: credential.SignCount,
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateCredential) {
+			app.webAuthnError(w, r, "This passkey is registered already.")
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your passkey has been added!")
+	app.writeJSON(w, r, http.StatusOK, map[string]string{"redirect": "/account/passkeys"})
+}
+
+func (app *App) accountPasskeyDeletePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	id, err := base64.RawURLEncoding.DecodeString(params.ByName("id"))
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	err = app.credentials.Delete(r.Context(), userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your passkey has been removed.")
+	http.Redirect(w, r, "/account/passkeys", http.StatusSeeOther)
+}
+
+func (app *App) userLoginPasskeyBeginPost(w http.ResponseWriter, r *http.Request) {
+	challenge, err := app.newWebAuthnChallenge(r, "login")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, app.relyingParty.RequestOptions(challenge))
+}
+
+type passkeyLoginRequest struct {
+	ID       webauthn.Base64URL         `json:"id"`
+	Response webauthn.AssertionResponse `json:"response"`
+}
+
+// userLoginPasskeyFinishPost logs in with a passkey. The passkeys verify the
+// user themselves, so there's no two-factor step after it.
+func (app *App) userLoginPasskeyFinishPost(w http.ResponseWriter, r *http.Request) {
+	var input passkeyLoginRequest
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.webAuthnError(w, r, "The request is malformed.")
+		return
+	}
+
+	throttled, err := app.loginThrottled(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if throttled {
+		app.metrics.logins.Inc("throttled")
+		app.audit(r, &models.AuditEvent{Type: models.EventLoginFailed, Detail: "throttled"})
+		app.writeJSON(w, r, http.StatusTooManyRequests, map[string]string{"error": "Too many failed logins, please try again later."})
+		return
+	}
+
+	challenge := app.popWebAuthnChallenge(r, "login")
+	if challenge == nil {
+		app.webAuthnError(w, r, "The request expired, please try again.")
+		return
+	}
+
+	credential, err := app.credentials.Get(r.Context(), input.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.passkeyLoginFailed(w, r, 0, "unknown passkey", "This passkey isn't registered, it may have been removed.")
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	signCount, err := app.relyingParty.VerifyAssertion(challenge, &webauthn.Credential{
+		ID:        credential.ID,
+		PublicKey: credential.PublicKey,
+		SignCount: credential.SignCount,
+	}, &input.Response)
+	if err == nil && string(input.Response.UserHandle) != string(webAuthnUserHandle(credential.UserID)) {
+		err = fmt.Errorf("%w: user handle of another user", webauthn.ErrVerification)
+	}
+	if err != nil {
+		app.logger.Warn("passkey login failed", "error", err, "user_id", credential.UserID, "request_id", requestInfoFrom(r).id)
+
+		why := "invalid passkey"
+		if errors.Is(err, webauthn.ErrSignCount) {
+			// the authenticator may have been cloned.
+			why = "passkey signature counter didn't increase"
+		}
+		app.passkeyLoginFailed(w, r, credential.UserID, why, "The passkey could not be verified.")
+		return
+	}
+
+	err = app.credentials.Use(r.Context(), credential.ID, signCount)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// the passkey proves who the user is, not that they may log in.
+	user, err := app.users.Get(r.Context(), credential.UserID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if user.Disabled() {
+		app.metrics.logins.Inc("disabled")
+		app.auditUser(r, models.EventLoginFailed, user.ID, "disabled")
+		app.writeJSON(w, r, http.StatusForbidden, map[string]string{"error": "Your account has been disabled."})
+		return
+	}
+
+	path, err := app.logIn(r, credential.UserID, false)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, map[string]string{"redirect": path})
+}
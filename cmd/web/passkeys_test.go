@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/memory"
+	"github.com/ahmadyogi543/snippetbox/internal/webauthn"
+	"github.com/ahmadyogi543/snippetbox/internal/webauthn/webauthntest"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// registerPasskey adds a passkey of the authenticator to the account logged
+// in on the server.
+func registerPasskey(t *testing.T, server *testServer, authenticator *webauthntest.Authenticator) {
+	_, _, body := server.get(t, "/account/passkeys")
+	csrfToken := extractCSRFToken(t, body)
+
+	code, body := server.postJSON(t, "/account/passkeys/register/begin", csrfToken, nil)
+	assert.Equal(t, code, http.StatusOK)
+
+	var options webauthn.CreationOptions
+	assert.NilError(t, json.Unmarshal([]byte(body), &options))
+	assert.Equal(t, options.RP.ID, "localhost")
+	assert.Equal(t, string(options.User.ID), "1")
+
+	response, err := authenticator.Create(&options)
+	assert.NilError(t, err)
+
+	code, body = server.postJSON(t, "/account/passkeys/register/finish", csrfToken, map[string]any{
+		"name":     "Laptop",
+		"response": response,
+	})
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"redirect":"/account/passkeys"`)
+}
+
+// loginWithPasskey logs in with the passkey of the authenticator and returns
+// the response of the server.
+func loginWithPasskey(t *testing.T, server *testServer, authenticator *webauthntest.Authenticator) (int, string) {
+	_, _, body := server.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	code, body := server.postJSON(t, "/user/login/passkey/begin", csrfToken, nil)
+	assert.Equal(t, code, http.StatusOK)
+
+	var options webauthn.RequestOptions
+	assert.NilError(t, json.Unmarshal([]byte(body), &options))
+
+	response, err := authenticator.Get(&options)
+	assert.NilError(t, err)
+
+	return server.postJSON(t, "/user/login/passkey/finish", csrfToken, map[string]any{
+		"id":       webauthn.Base64URL(authenticator.CredentialID),
+		"response": response,
+	})
+}
+
+func TestPasskeys(t *testing.T) {
+	app := newTestApp(t)
+	app.credentials = &memory.CredentialModel{}
+
+	authenticator, err := webauthntest.New("localhost", "https://localhost:3000")
+	assert.NilError(t, err)
+
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+	loginWithPassword(t, server, "ayogi@snippetbox.sh")
+	registerPasskey(t, server, authenticator)
+
+	_, _, body := server.get(t, "/account/passkeys")
+	assert.StringContains(t, body, "Your passkey has been added!")
+	assert.StringContains(t, body, "Laptop")
+
+	// another browser logs in with the passkey.
+	other := newTestServer(t, app.routes())
+	defer other.Close()
+
+	code, body := loginWithPasskey(t, other, authenticator)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"redirect":"/snippet/create"`)
+
+	code, _, _ = other.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+
+	// a clone of the authenticator is behind on the signature counter.
+	authenticator.SignCount = 0
+	clone := newTestServer(t, app.routes())
+	defer clone.Close()
+	code, body = loginWithPasskey(t, clone, authenticator)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.StringContains(t, body, "The passkey could not be verified.")
+
+	_, _, body = server.get(t, "/account/passkeys")
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	deletePath := "/account/passkeys/delete/" + base64.RawURLEncoding.EncodeToString(authenticator.CredentialID)
+
+	code, headers, _ := server.postForm(t, deletePath, form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/passkeys")
+
+	code, _, _ = server.postForm(t, deletePath, form)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, body = loginWithPasskey(t, other, authenticator)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.StringContains(t, body, "This passkey isn't registered")
+}
+
+func TestPasskeyLoginDisabled(t *testing.T) {
+	app := newTestApp(t)
+	app.credentials = &memory.CredentialModel{}
+	users := &memory.UserModel{BcryptCost: bcrypt.MinCost}
+	app.users = users
+	assert.NilError(t, users.Insert(context.Background(), "Jane Doe", "jane@snippetbox.sh", "12345678"))
+
+	authenticator, err := webauthntest.New("localhost", "https://localhost:3000")
+	assert.NilError(t, err)
+
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+	loginWithPassword(t, server, "jane@snippetbox.sh")
+	registerPasskey(t, server, authenticator)
+
+	assert.NilError(t, users.SetDisabled(context.Background(), 1, true))
+
+	other := newTestServer(t, app.routes())
+	defer other.Close()
+
+	code, body := loginWithPasskey(t, other, authenticator)
+	assert.Equal(t, code, http.StatusForbidden)
+	assert.StringContains(t, body, "Your account has been disabled.")
+
+	code, _, _ = other.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusSeeOther)
+}
+
+func TestPasskeyLoginErrors(t *testing.T) {
+	app := newTestApp(t)
+	app.credentials = &memory.CredentialModel{}
+
+	authenticator, err := webauthntest.New("localhost", "https://localhost:3000")
+	assert.NilError(t, err)
+
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+	loginWithPassword(t, server, "ayogi@snippetbox.sh")
+	registerPasskey(t, server, authenticator)
+
+	tests := []struct {
+		name          string
+		authenticator func(a *webauthntest.Authenticator)
+		wantBody      string
+	}{
+		{
+			name:          "Another origin",
+			authenticator: func(a *webauthntest.Authenticator) { a.Origin = "https://evil.example" },
+			wantBody:      "The passkey could not be verified.",
+		},
+		{
+			name:          "User not verified",
+			authenticator: func(a *webauthntest.Authenticator) { a.SkipUserVerification = true },
+			wantBody:      "The passkey could not be verified.",
+		},
+		{
+			name:          "Another user",
+			authenticator: func(a *webauthntest.Authenticator) { a.UserHandle = []byte("2") },
+			wantBody:      "The passkey could not be verified.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clone := *authenticator
+			tt.authenticator(&clone)
+
+			client := newTestServer(t, app.routes())
+			defer client.Close()
+
+			code, body := loginWithPasskey(t, client, &clone)
+			assert.Equal(t, code, http.StatusBadRequest)
+			assert.StringContains(t, body, tt.wantBody)
+
+			code, _, _ = client.get(t, "/account/view")
+			assert.Equal(t, code, http.StatusSeeOther)
+		})
+	}
+
+	t.Run("No challenge", func(t *testing.T) {
+		client := newTestServer(t, app.routes())
+		defer client.Close()
+
+		_, _, body := client.get(t, "/user/login")
+		code, body := client.postJSON(t, "/user/login/passkey/finish", extractCSRFToken(t, body), map[string]any{
+			"id": webauthn.Base64URL(authenticator.CredentialID),
+		})
+		assert.Equal(t, code, http.StatusBadRequest)
+		assert.StringContains(t, body, "The request expired")
+	})
+}
+
+func TestPasskeyLoginThrottled(t *testing.T) {
+	app := newTestApp(t)
+	app.credentials = &memory.CredentialModel{}
+	app.loginFailures = &memory.LoginFailureModel{}
+	app.loginThrottle = loginThrottle{threshold: 2, window: time.Minute}
+
+	authenticator, err := webauthntest.New("localhost", "https://localhost:3000")
+	assert.NilError(t, err)
+
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+	loginWithPassword(t, server, "ayogi@snippetbox.sh")
+	registerPasskey(t, server, authenticator)
+
+	// the failed passkey logins count against the client IP like the
+	// failed passwords.
+	unknown := *authenticator
+	unknown.CredentialID = []byte("unknown")
+	wrongOrigin := *authenticator
+	wrongOrigin.Origin = "https://evil.example"
+
+	for _, a := range []*webauthntest.Authenticator{&unknown, &wrongOrigin} {
+		client := newTestServer(t, app.routes())
+		code, _ := loginWithPasskey(t, client, a)
+		client.Close()
+		assert.Equal(t, code, http.StatusBadRequest)
+	}
+
+	count, err := app.loginFailures.Count(context.Background(), "127.0.0.1", time.Now().Add(-time.Minute))
+	assert.NilError(t, err)
+	assert.Equal(t, count, 2)
+
+	client := newTestServer(t, app.routes())
+	defer client.Close()
+
+	code, body := loginWithPasskey(t, client, authenticator)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.StringContains(t, body, "Too many failed logins")
+
+	code, _, _ = client.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusSeeOther)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/ratelimit"
+	"github.com/justinas/alice"
+)
+
+// rateLimitCleanupInterval is how often the full buckets are removed from an
+// in-process store.
+const rateLimitCleanupInterval = time.Minute
+
+// rateLimits are the limits of the route groups.
+type rateLimits struct {
+	global   ratelimit.Limit
+	auth     ratelimit.Limit
+	snippets ratelimit.Limit
+}
+
+// rateLimit limits the requests of each client IP and, once authenticate has
+// found the user, of each user to limit. The buckets are named after name, so
+// every route group using it gets its own. A request over the limit gets a
+// 429 telling the client when to retry.
+func (app *App) rateLimit(name string, limit ratelimit.Limit) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		if app.rateLimiter == nil || !limit.Enabled() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys := []string{name + ":ip:" + clientIP(r)}
+			if userID := requestInfoFrom(r).userID; userID != 0 {
+				keys = append(keys, name+":user:"+strconv.Itoa(userID))
+			}
+
+			for _, key := range keys {
+				ok, retryAfter, err := app.rateLimiter.Allow(r.Context(), key, limit)
+				if err != nil {
+					// a failing store shouldn't take the site down with it.
+					app.logger.Error("rate limiting", "error", err, "request_id", requestInfoFrom(r).id)
+					continue
+				}
+
+				if !ok {
+					app.metrics.rateLimited.Inc(name)
+					w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(retryAfter)))
+					app.clientError(w, http.StatusTooManyRequests)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// cleanupRateLimits removes the full buckets of an in-process store until ctx
+// is canceled.
+func (app *App) cleanupRateLimits(ctx context.Context) {
+	store, ok := app.rateLimiter.(interface{ Cleanup() })
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(rateLimitCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			store.Cleanup()
+		}
+	}
+}
+
+// clientIP returns the IP of the client. After proxyHeaders RemoteAddr may be
+// a bare IP rather than an address with a port.
+func clientIP(r *http.Request) string {
+	if addrPort, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		return addrPort.Addr().Unmap().String()
+	}
+
+	if addr, err := netip.ParseAddr(r.RemoteAddr); err == nil {
+		return addr.Unmap().String()
+	}
+
+	return r.RemoteAddr
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	app := newTestApp(t)
+	app.rateLimiter = &ratelimit.MemoryStore{}
+	app.rateLimits.auth = ratelimit.Limit{Burst: 2, Period: time.Minute}
+
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	_, _, body := server.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "ayogi@snippetbox.sh")
+	form.Add("password", "wrong password")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	for i := 0; i < 2; i++ {
+		code, _, _ := server.postForm(t, "/user/login", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	}
+
+	code, headers, _ := server.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.Equal(t, headers.Get("Retry-After"), "30")
+	assert.Equal(t, app.metrics.rateLimited.Value("auth"), 1.0)
+
+	// the pages outside of the group aren't limited.
+	code, _, _ = server.get(t, "/user/login")
+	assert.Equal(t, code, http.StatusOK)
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		{remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+		{remoteAddr: "192.0.2.1", want: "192.0.2.1"},
+		{remoteAddr: "[2001:db8::1]:1234", want: "2001:db8::1"},
+		{remoteAddr: "2001:db8::1", want: "2001:db8::1"},
+		{remoteAddr: "[::ffff:192.0.2.1]:1234", want: "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.remoteAddr, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+
+			assert.Equal(t, clientIP(r), tt.want)
+		})
+	}
+}
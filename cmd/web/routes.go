@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 
+	"github.com/ahmadyogi543/snippetbox/internal/models"
 	"github.com/ahmadyogi543/snippetbox/ui"
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
@@ -14,27 +15,85 @@ func (app *App) routes() http.Handler {
 		app.notFound(w)
 	})
 
+	// handle registers the handlers through route, so the metrics are
+	// recorded by route pattern rather than by path.
+	handle := func(method, pattern string, handler http.Handler) {
+		router.Handler(method, pattern, route(pattern, handler))
+	}
+
 	fileServer := http.FileServer(http.FS(ui.Files))
-	router.Handler(http.MethodGet, "/static/*filepath", fileServer)
-	router.HandlerFunc(http.MethodGet, "/ping", ping)
-
-	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
-	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
-	router.Handler(http.MethodGet, "/about", dynamic.ThenFunc(app.about))
-	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
-	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
-	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
-	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
-	router.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
+	handle(http.MethodGet, "/static/*filepath", fileServer)
+	handle(http.MethodGet, "/ping", http.HandlerFunc(ping))
+	handle(http.MethodGet, "/healthz", http.HandlerFunc(app.healthz))
+	handle(http.MethodGet, "/readyz", http.HandlerFunc(app.readyz))
+
+	dynamic := alice.New(app.sessionManager.LoadAndSave, app.noSurf, app.authenticate, app.rateLimit("global", app.rateLimits.global))
+	auth := dynamic.Append(app.rateLimit("auth", app.rateLimits.auth))
+	handle(http.MethodGet, "/", dynamic.ThenFunc(app.home))
+	handle(http.MethodGet, "/about", dynamic.ThenFunc(app.about))
+	handle(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
+	handle(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
+	handle(http.MethodPost, "/user/signup", auth.ThenFunc(app.userSignupPost))
+	handle(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
+	handle(http.MethodPost, "/user/login", auth.ThenFunc(app.userLoginPost))
+	handle(http.MethodGet, "/user/login/two-factor", dynamic.ThenFunc(app.userLoginTwoFactor))
+	handle(http.MethodPost, "/user/login/two-factor", auth.ThenFunc(app.userLoginTwoFactorPost))
+	handle(http.MethodPost, "/user/login/passkey/begin", auth.ThenFunc(app.userLoginPasskeyBeginPost))
+	handle(http.MethodPost, "/user/login/passkey/finish", auth.ThenFunc(app.userLoginPasskeyFinishPost))
+	handle(http.MethodGet, "/user/login/oidc", auth.ThenFunc(app.userLoginOIDC))
+	handle(http.MethodGet, "/user/login/oidc/callback", auth.ThenFunc(app.userLoginOIDCCallback))
+	handle(http.MethodGet, "/user/verify/:token", auth.ThenFunc(app.userVerify))
+	handle(http.MethodGet, "/user/password/forgot", dynamic.ThenFunc(app.userPasswordForgot))
+	handle(http.MethodPost, "/user/password/forgot", auth.ThenFunc(app.userPasswordForgotPost))
+	handle(http.MethodGet, "/user/password/reset/:token", dynamic.ThenFunc(app.userPasswordReset))
+	handle(http.MethodPost, "/user/password/reset/:token", auth.ThenFunc(app.userPasswordResetPost))
 
 	protected := dynamic.Append(app.requireAuthentication)
-	router.Handler(http.MethodGet, "/account/view", protected.ThenFunc(app.accountView))
-	router.Handler(http.MethodGet, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdate))
-	router.Handler(http.MethodPost, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdatePost))
-	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreateForm))
-	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
-	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
-
-	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
+	handle(http.MethodGet, "/account/view", protected.ThenFunc(app.accountView))
+	handle(http.MethodGet, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdate))
+	handle(http.MethodPost, "/account/password/update", protected.Append(app.rateLimit("auth", app.rateLimits.auth)).ThenFunc(app.accountPasswordUpdatePost))
+	handle(http.MethodPost, "/user/verify/resend", protected.Append(app.rateLimit("auth", app.rateLimits.auth)).ThenFunc(app.userVerifyResendPost))
+	handle(http.MethodPost, "/account/sessions/revoke/:id", protected.ThenFunc(app.accountSessionRevokePost))
+	handle(http.MethodPost, "/account/sessions/revoke-others", protected.ThenFunc(app.accountSessionsRevokeOthersPost))
+	handle(http.MethodGet, "/account/two-factor", protected.ThenFunc(app.accountTwoFactor))
+	handle(http.MethodGet, "/account/two-factor/qr.png", protected.ThenFunc(app.accountTwoFactorQRCode))
+	handle(http.MethodPost, "/account/two-factor/setup", protected.ThenFunc(app.accountTwoFactorSetupPost))
+	handle(http.MethodPost, "/account/two-factor/enable", protected.Append(app.rateLimit("auth", app.rateLimits.auth)).ThenFunc(app.accountTwoFactorEnablePost))
+	handle(http.MethodPost, "/account/two-factor/disable", protected.Append(app.rateLimit("auth", app.rateLimits.auth)).ThenFunc(app.accountTwoFactorDisablePost))
+	handle(http.MethodPost, "/account/two-factor/recovery-codes", protected.Append(app.rateLimit("auth", app.rateLimits.auth)).ThenFunc(app.accountTwoFactorRecoveryCodesPost))
+	handle(http.MethodGet, "/account/passkeys", protected.ThenFunc(app.accountPasskeys))
+	handle(http.MethodPost, "/account/passkeys/register/begin", protected.ThenFunc(app.accountPasskeyRegisterBeginPost))
+	handle(http.MethodPost, "/account/passkeys/register/finish", protected.Append(app.rateLimit("auth", app.rateLimits.auth)).ThenFunc(app.accountPasskeyRegisterFinishPost))
+	handle(http.MethodPost, "/account/passkeys/delete/:id", protected.ThenFunc(app.accountPasskeyDeletePost))
+
+	verified := protected.Append(app.requireVerifiedEmail)
+	handle(http.MethodGet, "/snippet/create", verified.ThenFunc(app.snippetCreateForm))
+	handle(http.MethodPost, "/snippet/create", verified.Append(app.rateLimit("snippets", app.rateLimits.snippets)).ThenFunc(app.snippetCreatePost))
+	handle(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
+
+	moderator := protected.Append(app.requireRole(models.RoleModerator))
+	handle(http.MethodGet, "/admin", moderator.ThenFunc(app.adminDashboard))
+	handle(http.MethodPost, "/admin/snippets/:id/delete", moderator.ThenFunc(app.adminSnippetDeletePost))
+
+	admin := protected.Append(app.requireRole(models.RoleAdmin))
+	handle(http.MethodGet, "/admin/users", admin.ThenFunc(app.adminUsers))
+	handle(http.MethodPost, "/admin/users/:id/role", admin.ThenFunc(app.adminUserRolePost))
+	handle(http.MethodPost, "/admin/users/:id/disable", admin.ThenFunc(app.adminUserDisablePost))
+	handle(http.MethodPost, "/admin/users/:id/enable", admin.ThenFunc(app.adminUserEnablePost))
+	handle(http.MethodPost, "/admin/users/:id/unlock", admin.ThenFunc(app.adminUserUnlockPost))
+	handle(http.MethodGet, "/admin/audit", admin.ThenFunc(app.adminAudit))
+	handle(http.MethodGet, "/admin/audit.csv", admin.ThenFunc(app.adminAuditExport))
+
+	standard := alice.New(app.requestID, app.traceRequest, app.measure, app.proxyHeaders, app.logRequest, app.recoverPanic, app.secureHeaders)
 	return standard.Then(router)
 }
+
+// adminRoutes are served on the admin listener, which shouldn't be reachable
+// from outside.
+func (app *App) adminRoutes() http.Handler {
+	router := httprouter.New()
+	router.Handler(http.MethodGet, "/metrics", app.metrics.registry.Handler())
+	router.HandlerFunc(http.MethodGet, "/readyz", app.readyzDetails)
+
+	return app.recoverPanic(router)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// serve runs the server until it receives SIGINT or SIGTERM, then stops
+// accepting connections and waits up to the shutdown timeout for the requests
+// in flight. On SIGHUP it hands the listening sockets over to a new process
+// before shutting down.
+func (app *App) serve(cfg *config) error {
+	server := &http.Server{
+		Addr:         cfg.addr,
+		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+		Handler:      app.routes(),
+		IdleTimeout:  cfg.server.idleTimeout,
+		ReadTimeout:  cfg.server.readTimeout,
+		WriteTimeout: cfg.server.writeTimeout,
+	}
+
+	workers, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	if cfg.tls.enabled {
+		certs, err := newCertReloader(cfg.tls.certFile, cfg.tls.keyFile)
+		if err != nil {
+			return err
+		}
+		app.certs = certs
+		app.metrics.registerCertExpiry(certs)
+		app.logCertificate(certs.leaf())
+
+		server.TLSConfig = &tls.Config{
+			CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+			GetCertificate:   certs.GetCertificate,
+		}
+
+		app.background(func() {
+			certs.watch(workers, cfg.tls.reloadInterval, app.logCertificate, func(err error) {
+				app.logger.Error("reloading TLS certificate", "error", err)
+			})
+		})
+	}
+
+	app.background(func() {
+		app.cleanupRateLimits(workers)
+	})
+
+	app.background(func() {
+		app.cleanupLoginFailures(workers)
+	})
+
+	app.background(func() {
+		app.cleanupTokens(workers)
+	})
+
+	app.background(func() {
+		app.cleanupSessionIndex(workers)
+	})
+
+	if app.outbox != nil {
+		app.background(func() {
+			app.outbox.Run(workers, cfg.mail.outboxInterval)
+		})
+	}
+
+	inherited, err := inheritedListeners()
+	if err != nil {
+		return err
+	}
+
+	listeners := map[string]net.Listener{}
+	listeners["web"], err = app.listen(inherited, "web", cfg.addr)
+	if err != nil {
+		return err
+	}
+
+	servers := []*http.Server{server}
+
+	if cfg.httpRedirectAddr != "" {
+		listeners["http"], err = app.listen(inherited, "http", cfg.httpRedirectAddr)
+		if err != nil {
+			return err
+		}
+
+		_, httpsPort, err := net.SplitHostPort(listeners["web"].Addr().String())
+		if err != nil {
+			return err
+		}
+
+		redirectServer := &http.Server{
+			ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+			Handler:      redirectToHTTPS(httpsPort),
+			IdleTimeout:  cfg.server.idleTimeout,
+			ReadTimeout:  cfg.server.readTimeout,
+			WriteTimeout: cfg.server.writeTimeout,
+		}
+		servers = append(servers, redirectServer)
+
+		go func() {
+			app.logger.Info("redirecting HTTP to HTTPS", "addr", listeners["http"].Addr().String())
+			err := redirectServer.Serve(listeners["http"])
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.Error(err.Error())
+			}
+		}()
+	}
+
+	if cfg.adminAddr != "" {
+		listeners["admin"], err = app.listen(inherited, "admin", cfg.adminAddr)
+		if err != nil {
+			return err
+		}
+
+		adminServer := &http.Server{
+			ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+			Handler:      app.adminRoutes(),
+			IdleTimeout:  cfg.server.idleTimeout,
+			ReadTimeout:  cfg.server.readTimeout,
+			WriteTimeout: cfg.server.writeTimeout,
+		}
+		servers = append(servers, adminServer)
+
+		go func() {
+			app.logger.Info("starting admin server", "addr", listeners["admin"].Addr().String())
+			err := adminServer.Serve(listeners["admin"])
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.Error(err.Error())
+			}
+		}()
+	}
+
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+		for s := range quit {
+			if s == syscall.SIGHUP {
+				err := handOver(listeners)
+				if err != nil {
+					app.logger.Error("handover failed, still serving", "error", err)
+					continue
+				}
+				app.logger.Info("handed the listeners over to a new process")
+			} else {
+				// the listeners stay open for the drain delay, so the load
+				// balancers see /readyz fail and stop sending requests
+				// before the connections are refused. After a handover the
+				// new process keeps accepting on the same sockets, so there's
+				// nothing to drain.
+				app.draining.Store(true)
+				if cfg.server.drainDelay > 0 {
+					app.logger.Info("draining", "signal", s.String(), "delay", cfg.server.drainDelay.String())
+					time.Sleep(cfg.server.drainDelay)
+				}
+			}
+
+			app.logger.Info("shutting down server", "signal", s.String())
+
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.server.shutdownTimeout)
+			defer cancel()
+
+			var errs []error
+			for i := len(servers) - 1; i >= 0; i-- {
+				errs = append(errs, servers[i].Shutdown(ctx))
+			}
+
+			shutdownError <- errors.Join(errs...)
+			return
+		}
+	}()
+
+	if err = notifyReady(); err != nil {
+		app.logger.Error(err.Error())
+	}
+
+	if cfg.tls.enabled {
+		app.logger.Info("starting server", "addr", listeners["web"].Addr().String())
+		err = server.ServeTLS(listeners["web"], "", "")
+	} else {
+		app.logger.Info("starting server without TLS", "addr", listeners["web"].Addr().String())
+		err = server.Serve(listeners["web"])
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownError
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("stopping background workers")
+	stopWorkers()
+	app.stopBackgroundWorkers()
+
+	app.logger.Info("stopped server")
+	return nil
+}
+
+// listen returns the inherited listener called name, or a new one on addr.
+func (app *App) listen(inherited map[string]net.Listener, name, addr string) (net.Listener, error) {
+	if listener, ok := inherited[name]; ok {
+		app.logger.Info("using inherited listener", "name", name, "addr", listener.Addr().String())
+		return listener, nil
+	}
+
+	return net.Listen("tcp", addr)
+}
+
+// stopBackgroundWorkers stops the goroutines started outside of the request
+// handlers, so nothing touches the database once it's closed. The workers
+// started with app.background must already have been told to stop.
+func (app *App) stopBackgroundWorkers() {
+	if store, ok := app.sessionManager.Store.(interface{ StopCleanup() }); ok {
+		store.StopCleanup()
+	}
+
+	app.wg.Wait()
+}
+
+func (app *App) logCertificate(cert *x509.Certificate) {
+	app.logger.Info(
+		"loaded TLS certificate",
+		"subject", cert.Subject.CommonName,
+		"expires", cert.NotAfter.UTC().Format(time.RFC3339),
+		"days_to_expiry", int(time.Until(cert.NotAfter).Hours()/24),
+	)
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+	"github.com/julienschmidt/httprouter"
+)
+
+// sessionTouchInterval is how often the last seen time of a session is
+// updated, so the session isn't saved again on every request.
+const sessionTouchInterval = time.Minute
+
+// maxUserAgentLength limits the user agents kept in the sessions.
+const maxUserAgentLength = 256
+
+// sessionIndexCleanupInterval is how often the expired sessions are deleted
+// from the index of the sessions of the users.
+const sessionIndexCleanupInterval = time.Hour
+
+// sessionPolicy is how long the users stay logged in. A login lasts
+// lifetime at most and ends after idleTimeout without a request, unless the
+// user asked to be remembered, then it lasts rememberLifetime.
+type sessionPolicy struct {
+	lifetime         time.Duration
+	idleTimeout      time.Duration
+	rememberLifetime time.Duration
+}
+
+// userSession is a session a user is logged in to, as listed on the account
+// page. ID identifies it without giving away its token.
+type userSession struct {
+	ID        string
+	Created   time.Time
+	LastSeen  time.Time
+	IP        string
+	UserAgent string
+	Expires   time.Time
+	Current   bool
+}
+
+// logIn puts the user in the session of r and returns where to send them,
+// the page they were going to before they had to log in. A remembered
+// session outlives the browser and isn't logged out when idle.
+func (app *App) logIn(r *http.Request, id int, remember bool) (string, error) {
+	// the user logged in to the session before, if any, is logged out first,
+	// so the new token isn't indexed under them.
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+
+	err := app.renewToken(r.Context())
+	if err != nil {
+		return "", err
+	}
+
+	err = app.startSession(r)
+	if err != nil {
+		return "", err
+	}
+
+	err = app.users.ResetFailedLogins(r.Context(), id)
+	if err != nil {
+		return "", err
+	}
+
+	// the sessions last as long as a login does, only the remembered ones
+	// are kept longer.
+	lifetime := app.sessionPolicy.lifetime
+	if remember {
+		lifetime = app.sessionPolicy.rememberLifetime
+	}
+
+	app.metrics.logins.Inc("success")
+	app.sessionManager.SetDeadline(r.Context(), time.Now().Add(lifetime).UTC())
+	app.sessionManager.RememberMe(r.Context(), remember)
+	app.sessionManager.Put(r.Context(), "sessionRemembered", remember)
+	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+
+	err = app.indexSession(r.Context())
+	if err != nil {
+		return "", err
+	}
+
+	app.auditUser(r, models.EventLogin, id, "")
+
+	path := app.sessionManager.PopString(r.Context(), "redirectPathAfterLogin")
+	if path != "" {
+		return path, nil
+	}
+
+	return "/snippet/create", nil
+}
+
+// logOut removes the user from the session of r.
+func (app *App) logOut(r *http.Request) error {
+	app.sessionManager.RememberMe(r.Context(), false)
+	app.sessionManager.Remove(r.Context(), "sessionRemembered")
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+
+	return app.renewToken(r.Context())
+}
+
+// renewToken gives the session in ctx a new token, and moves it to the new
+// token in the index of the sessions of its user. A remembered session keeps
+// its deadline.
+func (app *App) renewToken(ctx context.Context) error {
+	old := app.sessionManager.Token(ctx)
+	deadline := app.sessionManager.Deadline(ctx)
+
+	err := app.sessionManager.RenewToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	if app.sessionManager.GetBool(ctx, "sessionRemembered") {
+		app.sessionManager.SetDeadline(ctx, deadline)
+	}
+
+	err = app.indexSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	if old == "" {
+		return nil
+	}
+
+	return app.sessionIndex.Delete(ctx, old)
+}
+
+// indexSession adds the session in ctx to the index of the sessions of the
+// user logged in to it. A session that can't be indexed is destroyed, it
+// couldn't be found to be revoked.
+func (app *App) indexSession(ctx context.Context) error {
+	userID := app.sessionManager.GetInt(ctx, "authenticatedUserID")
+	if userID == 0 {
+		return nil
+	}
+
+	err := app.sessionIndex.Insert(ctx, app.sessionManager.Token(ctx), userID, app.sessionManager.Deadline(ctx))
+	if err != nil {
+		return errors.Join(err, app.sessionManager.Destroy(ctx))
+	}
+
+	return nil
+}
+
+// storedSession is a context without the session of the request, the session
+// manager only loads a session into a context that has none.
+type storedSession struct {
+	context.Context
+}
+
+func (storedSession) Value(key any) any {
+	return nil
+}
+
+// indexedSessions calls fn with each session the user is logged in to, as
+// stored, found through the index. The tokens that no longer belong to a
+// session of the user, because it ended or was given a new token, are
+// deleted from the index along the way.
+func (app *App) indexedSessions(ctx context.Context, userID int, fn func(ctx context.Context) error) error {
+	tokens, err := app.sessionIndex.Tokens(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		sessionCtx, err := app.sessionManager.Load(storedSession{ctx}, token)
+		if err != nil {
+			return err
+		}
+
+		if app.sessionManager.GetInt(sessionCtx, "authenticatedUserID") != userID {
+			err = app.sessionIndex.Delete(ctx, token)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = fn(sessionCtx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cleanupSessionIndex deletes the expired sessions from the index until ctx
+// is canceled.
+func (app *App) cleanupSessionIndex(ctx context.Context) {
+	if app.sessionIndex == nil {
+		return
+	}
+
+	ticker := time.NewTicker(sessionIndexCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := app.sessionIndex.DeleteExpired(ctx)
+			if err != nil && ctx.Err() == nil {
+				app.logger.Error("deleting expired sessions from the index", "error", err)
+			}
+		}
+	}
+}
+
+// startSession records where and when the session of r was logged in to,
+// for the users to recognise it.
+func (app *App) startSession(r *http.Request) error {
+	randomBytes := make([]byte, 16)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	app.sessionManager.Put(r.Context(), "sessionID", base64.RawURLEncoding.EncodeToString(randomBytes))
+	app.sessionManager.Put(r.Context(), "sessionCreated", now)
+	app.sessionManager.Put(r.Context(), "sessionLastSeen", now)
+	app.sessionManager.Put(r.Context(), "sessionIP", clientIP(r))
+	app.sessionManager.Put(r.Context(), "sessionUserAgent", truncate(r.UserAgent(), maxUserAgentLength))
+
+	return nil
+}
+
+// touchSession records that the session of r is still in use, at most once
+// every sessionTouchInterval.
+func (app *App) touchSession(r *http.Request) error {
+	if app.sessionManager.GetString(r.Context(), "sessionID") == "" {
+		// logged in before the sessions were recorded.
+		return app.startSession(r)
+	}
+
+	lastSeen := time.Unix(app.sessionManager.GetInt64(r.Context(), "sessionLastSeen"), 0)
+	if time.Since(lastSeen) < sessionTouchInterval {
+		return nil
+	}
+
+	app.sessionManager.Put(r.Context(), "sessionLastSeen", time.Now().Unix())
+	app.sessionManager.Put(r.Context(), "sessionIP", clientIP(r))
+	app.sessionManager.Put(r.Context(), "sessionUserAgent", truncate(r.UserAgent(), maxUserAgentLength))
+
+	return nil
+}
+
+// sessionExpiry returns when the login of the session in ctx ends. The
+// sessions logged in to before their start was recorded don't expire until
+// they're touched.
+func (app *App) sessionExpiry(ctx context.Context) time.Time {
+	if app.sessionManager.GetBool(ctx, "sessionRemembered") {
+		return app.sessionManager.Deadline(ctx)
+	}
+
+	created := app.sessionManager.GetInt64(ctx, "sessionCreated")
+	if created == 0 {
+		return app.sessionManager.Deadline(ctx)
+	}
+
+	expiry := time.Unix(created, 0).Add(app.sessionPolicy.lifetime)
+	idleExpiry := time.Unix(app.sessionManager.GetInt64(ctx, "sessionLastSeen"), 0).Add(app.sessionPolicy.idleTimeout)
+	if idleExpiry.Before(expiry) {
+		return idleExpiry
+	}
+
+	return expiry
+}
+
+func (app *App) sessionExpired(ctx context.Context) bool {
+	return !time.Now().Before(app.sessionExpiry(ctx))
+}
+
+// userSessions returns the sessions the user is logged in to, the one of r
+// first and then the most recently used.
+func (app *App) userSessions(r *http.Request, userID int) ([]*userSession, error) {
+	currentID := app.sessionManager.GetString(r.Context(), "sessionID")
+
+	var sessions []*userSession
+	err := app.indexedSessions(r.Context(), userID, func(ctx context.Context) error {
+		// the expired sessions stay in the store until their deadline.
+		if app.sessionExpired(ctx) {
+			return nil
+		}
+
+		id := app.sessionManager.GetString(ctx, "sessionID")
+		sessions = append(sessions, &userSession{
+			ID:        id,
+			Created:   time.Unix(app.sessionManager.GetInt64(ctx, "sessionCreated"), 0),
+			LastSeen:  time.Unix(app.sessionManager.GetInt64(ctx, "sessionLastSeen"), 0),
+			IP:        app.sessionManager.GetString(ctx, "sessionIP"),
+			UserAgent: app.sessionManager.GetString(ctx, "sessionUserAgent"),
+			Expires:   app.sessionExpiry(ctx),
+			Current:   id != "" && id == currentID,
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].Current != sessions[j].Current {
+			return sessions[i].Current
+		}
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+
+	return sessions, nil
+}
+
+// revokeSessions destroys the sessions of the user for which revoke returns
+// true, and returns how many there were.
+func (app *App) revokeSessions(ctx context.Context, userID int, revoke func(ctx context.Context) bool) (int, error) {
+	revoked := 0
+
+	err := app.indexedSessions(ctx, userID, func(sessionCtx context.Context) error {
+		if !revoke(sessionCtx) {
+			return nil
+		}
+
+		// the token is gone from the session once it's destroyed.
+		token := app.sessionManager.Token(sessionCtx)
+
+		revoked++
+		err := app.sessionManager.Destroy(sessionCtx)
+		if err != nil {
+			return err
+		}
+
+		return app.sessionIndex.Delete(ctx, token)
+	})
+
+	return revoked, err
+}
+
+// destroyUserSessions logs the user out everywhere by destroying every
+// session they're logged in to. The session of the current request isn't
+// touched, it's committed again at the end of the request.
+func (app *App) destroyUserSessions(ctx context.Context, userID int) error {
+	_, err := app.revokeSessions(ctx, userID, func(ctx context.Context) bool {
+		return true
+	})
+
+	return err
+}
+
+// destroyOtherSessions logs the user out of every session but the one of r,
+// which gets a new token too.
+func (app *App) destroyOtherSessions(r *http.Request, userID int) error {
+	currentID := app.sessionManager.GetString(r.Context(), "sessionID")
+
+	_, err := app.revokeSessions(r.Context(), userID, func(ctx context.Context) bool {
+		return currentID == "" || app.sessionManager.GetString(ctx, "sessionID") != currentID
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.renewToken(r.Context())
+}
+
+func (app *App) accountSessionRevokePost(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	id := httprouter.ParamsFromContext(r.Context()).ByName("id")
+
+	// the current session is ended by logging out.
+	if id == "" || id == app.sessionManager.GetString(r.Context(), "sessionID") {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := app.revokeSessions(r.Context(), userID, func(ctx context.Context) bool {
+		return app.sessionManager.GetString(ctx, "sessionID") == id
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if revoked == 0 {
+		app.notFound(w)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The session has been revoked.")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *App) accountSessionsRevokeOthersPost(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err := app.destroyOtherSessions(r, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "You've been logged out of all your other devices.")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return strings.ToValidUTF8(s[:n], "")
+}
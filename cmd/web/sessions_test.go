@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/memory"
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+var revokeSessionRX = regexp.MustCompile(`action="/account/sessions/revoke/([^"]+)"`)
+
+// postWithCSRF posts the form from the account page, with its CSRF token.
+func postWithCSRF(t *testing.T, server *testServer, urlPath string) (int, http.Header) {
+	_, _, body := server.get(t, "/account/view")
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, header, _ := server.postForm(t, urlPath, form)
+	return code, header
+}
+
+func TestAccountSessions(t *testing.T) {
+	server := newTestServer(t, newTestApp(t).routes())
+	defer server.Close()
+
+	laptop := server.newDevice(t)
+	phone := server.newDevice(t)
+	for _, device := range []*testServer{laptop, phone} {
+		loginWithPassword(t, device, "ayogi@snippetbox.sh")
+	}
+	// another user's session isn't listed.
+	loginWithPassword(t, server.newDevice(t), "unverified@snippetbox.sh")
+
+	code, _, body := laptop.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Count(body, "This device"), 1)
+	assert.StringContains(t, body, "Log out of all other devices")
+
+	matches := revokeSessionRX.FindAllStringSubmatch(body, -1)
+	assert.Equal(t, len(matches), 1)
+	phoneSession := matches[0][1]
+
+	code, _ = postWithCSRF(t, laptop, "/account/sessions/revoke/"+phoneSession)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, header, _ := phone.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	code, _, body = laptop.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "The session has been revoked.")
+	assert.Equal(t, len(revokeSessionRX.FindAllString(body, -1)), 0)
+
+	// it's gone already.
+	code, _ = postWithCSRF(t, laptop, "/account/sessions/revoke/"+phoneSession)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestAccountSessionsIndex(t *testing.T) {
+	app := newTestApp(t)
+	// the sessions are found through the index, the store can't list them.
+	app.sessionManager.Store = struct{ scs.Store }{memstore.New()}
+	index := &memory.UserSessionModel{}
+	app.sessionIndex = index
+
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	laptop := server.newDevice(t)
+	phone := server.newDevice(t)
+	for _, device := range []*testServer{laptop, phone} {
+		loginWithPassword(t, device, "ayogi@snippetbox.sh")
+	}
+
+	tokens, err := index.Tokens(context.Background(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 2)
+
+	code, _, body := laptop.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(revokeSessionRX.FindAllString(body, -1)), 1)
+
+	// logging out takes the session out of the index.
+	code, _ = postWithCSRF(t, phone, "/user/logout")
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	tokens, err = index.Tokens(context.Background(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 1)
+
+	loginWithPassword(t, phone, "ayogi@snippetbox.sh")
+	code, _ = postWithCSRF(t, laptop, "/account/sessions/revoke-others")
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = phone.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// the laptop has a new token, indexed in place of the old one.
+	tokens, err = index.Tokens(context.Background(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 1)
+
+	code, _, _ = laptop.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+}
+
+func TestAccountSessionsRevokeOthers(t *testing.T) {
+	server := newTestServer(t, newTestApp(t).routes())
+	defer server.Close()
+
+	devices := []*testServer{server.newDevice(t), server.newDevice(t), server.newDevice(t)}
+	for _, device := range devices {
+		loginWithPassword(t, device, "ayogi@snippetbox.sh")
+	}
+	other := server.newDevice(t)
+	loginWithPassword(t, other, "unverified@snippetbox.sh")
+
+	code, header := postWithCSRF(t, devices[0], "/account/sessions/revoke-others")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/account/view")
+
+	code, _, body := devices[0].get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "You&#39;ve been logged out of all your other devices.")
+
+	for _, device := range devices[1:] {
+		code, _, _ = device.get(t, "/account/view")
+		assert.Equal(t, code, http.StatusSeeOther)
+	}
+
+	code, _, _ = other.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+}
+
+func TestAccountPasswordUpdatePostLogsOutOthers(t *testing.T) {
+	server := newTestServer(t, newTestApp(t).routes())
+	defer server.Close()
+
+	laptop := server.newDevice(t)
+	phone := server.newDevice(t)
+	for _, device := range []*testServer{laptop, phone} {
+		loginWithPassword(t, device, "ayogi@snippetbox.sh")
+	}
+
+	_, _, body := laptop.get(t, "/account/password/update")
+	form := url.Values{}
+	form.Add("current_password", "12345678")
+	form.Add("new_password", "n3wpa55word")
+	form.Add("confirm_new_password", "n3wpa55word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, header, _ := laptop.postForm(t, "/account/password/update", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/account/view")
+
+	code, _, _ = laptop.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _, _ = phone.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusSeeOther)
+}
+
+// loginRemembered logs in like loginWithPassword, with the remember me box
+// ticked, and returns the session cookie.
+func loginRemembered(t *testing.T, server *testServer, email string) string {
+	_, _, body := server.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", "12345678")
+	form.Add("remember_me", "on")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, header, _ := server.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	return header.Get("Set-Cookie")
+}
+
+// ageSessions moves the time recorded under key in the sessions of the user
+// back by d.
+func ageSessions(t *testing.T, app *App, userID int, key string, d time.Duration) {
+	err := app.sessionManager.Iterate(context.Background(), func(ctx context.Context) error {
+		if app.sessionManager.GetInt(ctx, "authenticatedUserID") != userID {
+			return nil
+		}
+
+		app.sessionManager.Put(ctx, key, app.sessionManager.GetInt64(ctx, key)-int64(d/time.Second))
+		_, _, err := app.sessionManager.Commit(ctx)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sessionDeadlines returns the deadlines of the sessions of the user in the
+// store.
+func sessionDeadlines(t *testing.T, app *App, userID int) []time.Time {
+	var deadlines []time.Time
+	err := app.sessionManager.Iterate(context.Background(), func(ctx context.Context) error {
+		if app.sessionManager.GetInt(ctx, "authenticatedUserID") == userID {
+			deadlines = append(deadlines, app.sessionManager.Deadline(ctx))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return deadlines
+}
+
+func TestUserLoginPostRememberMe(t *testing.T) {
+	app := newTestApp(t)
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	remembered := server.newDevice(t)
+	cookie := loginRemembered(t, remembered, "ayogi@snippetbox.sh")
+	assert.StringContains(t, cookie, "Max-Age=")
+
+	code, _, body := remembered.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "in 29 days")
+
+	// the others get a cookie deleted with the browser.
+	other := server.newDevice(t)
+	_, _, body = other.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "unverified@snippetbox.sh")
+	form.Add("password", "12345678")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, header, _ := other.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	if strings.Contains(header.Get("Set-Cookie"), "Max-Age=") {
+		t.Errorf("got a persistent cookie %q", header.Get("Set-Cookie"))
+	}
+
+	code, _, body = other.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "in 29 minutes")
+
+	// only the remembered session is kept in the store for longer than a
+	// login lasts.
+	deadlines := sessionDeadlines(t, app, 1)
+	assert.Equal(t, len(deadlines), 1)
+	assert.Equal(t, time.Until(deadlines[0]) > 29*24*time.Hour, true)
+
+	deadlines = sessionDeadlines(t, app, 2)
+	assert.Equal(t, len(deadlines), 1)
+	assert.Equal(t, time.Until(deadlines[0]) <= app.sessionPolicy.lifetime, true)
+
+	// a new token doesn't make it any shorter.
+	code, _ = postWithCSRF(t, remembered, "/account/sessions/revoke-others")
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	deadlines = sessionDeadlines(t, app, 1)
+	assert.Equal(t, len(deadlines), 1)
+	assert.Equal(t, time.Until(deadlines[0]) > 29*24*time.Hour, true)
+}
+
+func TestSessionExpiry(t *testing.T) {
+	tests := []struct {
+		name        string
+		remember    bool
+		key         string
+		age         time.Duration
+		wantExpired bool
+	}{
+		{
+			name: "Active",
+			key:  "sessionLastSeen",
+			age:  20 * time.Minute,
+		},
+		{
+			name:        "Idle",
+			key:         "sessionLastSeen",
+			age:         31 * time.Minute,
+			wantExpired: true,
+		},
+		{
+			name:        "Past lifetime",
+			key:         "sessionCreated",
+			age:         13 * time.Hour,
+			wantExpired: true,
+		},
+		{
+			name:     "Remembered idle",
+			remember: true,
+			key:      "sessionLastSeen",
+			age:      31 * time.Minute,
+		},
+		{
+			name:     "Remembered past lifetime",
+			remember: true,
+			key:      "sessionCreated",
+			age:      13 * time.Hour,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := newTestApp(t)
+			server := newTestServer(t, app.routes())
+			defer server.Close()
+
+			if test.remember {
+				loginRemembered(t, server, "ayogi@snippetbox.sh")
+			} else {
+				loginWithPassword(t, server, "ayogi@snippetbox.sh")
+			}
+			ageSessions(t, app, 1, test.key, test.age)
+
+			code, header, _ := server.get(t, "/account/view")
+			if !test.wantExpired {
+				assert.Equal(t, code, http.StatusOK)
+				return
+			}
+
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, header.Get("Location"), "/user/login")
+
+			_, _, body := server.get(t, "/user/login")
+			assert.StringContains(t, body, "Your session has expired, please log in again.")
+		})
+	}
+}
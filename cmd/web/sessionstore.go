@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/metrics"
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
+	"github.com/alexedwards/scs/v2"
+)
+
+// instrumentedStore counts and traces the operations of the session store it
+// wraps. It implements the Ctx methods, so the session manager passes the
+// request context on and the load and save of the session are part of the
+// request trace.
+type instrumentedStore struct {
+	scs.Store
+	ops *metrics.CounterVec
+}
+
+func (s *instrumentedStore) Find(token string) ([]byte, bool, error) {
+	return s.FindCtx(context.Background(), token)
+}
+
+func (s *instrumentedStore) FindCtx(ctx context.Context, token string) ([]byte, bool, error) {
+	_, span := trace.Start(ctx, "session.load")
+	defer span.End()
+
+	b, found, err := s.Store.Find(token)
+	s.count("find", err)
+	span.SetAttribute("found", found)
+	span.SetError(err)
+
+	return b, found, err
+}
+
+func (s *instrumentedStore) Commit(token string, b []byte, expiry time.Time) error {
+	return s.CommitCtx(context.Background(), token, b, expiry)
+}
+
+func (s *instrumentedStore) CommitCtx(ctx context.Context, token string, b []byte, expiry time.Time) error {
+	_, span := trace.Start(ctx, "session.save")
+	defer span.End()
+
+	err := s.Store.Commit(token, b, expiry)
+	s.count("commit", err)
+	span.SetError(err)
+
+	return err
+}
+
+func (s *instrumentedStore) Delete(token string) error {
+	return s.DeleteCtx(context.Background(), token)
+}
+
+func (s *instrumentedStore) DeleteCtx(ctx context.Context, token string) error {
+	_, span := trace.Start(ctx, "session.delete")
+	defer span.End()
+
+	err := s.Store.Delete(token)
+	s.count("delete", err)
+	span.SetError(err)
+
+	return err
+}
+
+// All keeps the wrapped store iterable by the session manager.
+func (s *instrumentedStore) All() (map[string][]byte, error) {
+	return s.AllCtx(context.Background())
+}
+
+func (s *instrumentedStore) AllCtx(ctx context.Context) (map[string][]byte, error) {
+	store, ok := s.Store.(scs.IterableStore)
+	if !ok {
+		return nil, errors.New("session store doesn't support iteration")
+	}
+
+	_, span := trace.Start(ctx, "session.all")
+	defer span.End()
+
+	sessions, err := store.All()
+	s.count("all", err)
+	span.SetError(err)
+
+	return sessions, err
+}
+
+func (s *instrumentedStore) StopCleanup() {
+	if store, ok := s.Store.(interface{ StopCleanup() }); ok {
+		store.StopCleanup()
+	}
+}
+
+func (s *instrumentedStore) count(operation string, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+
+	s.ops.Inc(operation, result)
+}
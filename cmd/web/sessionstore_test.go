@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func TestInstrumentedStore(t *testing.T) {
+	m := newAppMetrics()
+	store := &instrumentedStore{Store: memstore.New(), ops: m.sessionStoreOps}
+	defer store.StopCleanup()
+
+	err := store.Commit("token", []byte("data"), time.Now().Add(time.Hour))
+	assert.NilError(t, err)
+
+	_, found, err := store.Find("token")
+	assert.NilError(t, err)
+	assert.Equal(t, found, true)
+
+	sessions, err := store.All()
+	assert.NilError(t, err)
+	assert.Equal(t, len(sessions), 1)
+
+	assert.NilError(t, store.Delete("token"))
+
+	for _, operation := range []string{"commit", "find", "all", "delete"} {
+		assert.Equal(t, m.sessionStoreOps.Value(operation, "ok"), 1.0)
+	}
+}
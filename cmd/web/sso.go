@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+	"github.com/ahmadyogi543/snippetbox/internal/oidc"
+)
+
+// oidcLoginTimeout is how long the users have to log in at the provider
+// before the state kept in their session expires.
+const oidcLoginTimeout = 10 * time.Minute
+
+// userLoginOIDC sends the user to log in at the OpenID Connect provider,
+// keeping the state, nonce and PKCE verifier of the login in their session.
+func (app *App) userLoginOIDC(w http.ResponseWriter, r *http.Request) {
+	if app.oidcProvider == nil {
+		app.notFound(w)
+		return
+	}
+
+	var values [3]string
+	for i := range values {
+		value, err := oidc.RandomString()
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	authURL, err := app.oidcProvider.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		app.logger.Error(err.Error(), "request_id", requestInfoFrom(r).id)
+		app.sessionManager.Put(r.Context(), "flash", "Single sign-on isn't available right now, please try again later.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "oidcState", state)
+	app.sessionManager.Put(r.Context(), "oidcNonce", nonce)
+	app.sessionManager.Put(r.Context(), "oidcVerifier", verifier)
+	app.sessionManager.Put(r.Context(), "oidcExpiry", time.Now().Add(oidcLoginTimeout).Unix())
+
+	http.Redirect(w, r, authURL, http.StatusSeeOther)
+}
+
+// userLoginOIDCCallback logs in the user coming back from the provider. The
+// identity is linked to an account the first time, the one with the same
+// verified email address or a new one.
+func (app *App) userLoginOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if app.oidcProvider == nil {
+		app.notFound(w)
+		return
+	}
+
+	state := app.sessionManager.PopString(r.Context(), "oidcState")
+	nonce := app.sessionManager.PopString(r.Context(), "oidcNonce")
+	verifier := app.sessionManager.PopString(r.Context(), "oidcVerifier")
+	expiry := time.Unix(app.sessionManager.GetInt64(r.Context(), "oidcExpiry"), 0)
+	app.sessionManager.Remove(r.Context(), "oidcExpiry")
+
+	query := r.URL.Query()
+
+	if query.Get("error") != "" {
+		app.oidcLoginFailed(w, r, "The single sign-on login was cancelled.")
+		return
+	}
+
+	if state == "" || time.Now().After(expiry) || subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+		app.oidcLoginFailed(w, r, "The single sign-on login expired, please try again.")
+		return
+	}
+
+	claims, err := app.oidcProvider.Exchange(r.Context(), query.Get("code"), verifier, nonce)
+	if err != nil {
+		app.logger.Warn("single sign-on failed", "error", err.Error(), "request_id", requestInfoFrom(r).id)
+		app.oidcLoginFailed(w, r, "The single sign-on login failed, please try again.")
+		return
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		app.oidcLoginFailed(w, r, "Your single sign-on account needs a verified email address.")
+		return
+	}
+	if !app.oidcAllowedDomains.allows(claims.Email) {
+		app.oidcLoginFailed(w, r, "Your email address isn't allowed to log in with single sign-on.")
+		return
+	}
+
+	id, err := app.oidcUser(r.Context(), claims)
+	if err != nil {
+		if errors.Is(err, errUnverifiedAccount) {
+			app.oidcLoginFailed(w, r, "An account with your email address already exists. Log in with your password and verify your email address to use single sign-on.")
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// the provider vouches for the identity, not for the account.
+	user, err := app.users.Get(r.Context(), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if user.Disabled() {
+		app.metrics.logins.Inc("disabled")
+		app.auditUser(r, models.EventLoginFailed, id, "disabled")
+		app.sessionManager.Put(r.Context(), "flash", "Your account has been disabled.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	// the users with two-factor authentication type their code too, the
+	// provider doesn't know about it.
+	tf, err := app.twoFactor.Get(r.Context(), id)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+	if tf != nil && tf.Enabled() {
+		app.startTwoFactorLogin(w, r, id, false)
+		return
+	}
+
+	path, err := app.logIn(r, id, false)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, path, http.StatusSeeOther)
+}
+
+// errUnverifiedAccount is returned by oidcUser for an identity with the email
+// address of an account that hasn't verified it.
+var errUnverifiedAccount = errors.New("account with the email address isn't verified")
+
+// oidcUser returns the ID of the user logging in with the identity of the
+// claims, linking it to the account with the same email address, or to a
+// new account, the first time. It's only linked to an account that proved it
+// owns the address, anyone can sign up with the address of someone else and
+// wait for them to log in with single sign-on.
+func (app *App) oidcUser(ctx context.Context, claims *oidc.Claims) (int, error) {
+	id, err := app.identities.Get(ctx, claims.Issuer, claims.Subject)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, models.ErrNoRecord) {
+		return 0, err
+	}
+
+	user, err := app.users.GetByEmail(ctx, claims.Email)
+	switch {
+	case err == nil:
+		if !user.EmailVerified() {
+			return 0, errUnverifiedAccount
+		}
+		id = user.ID
+	case errors.Is(err, models.ErrNoRecord):
+		name := strings.TrimSpace(claims.Name)
+		if name == "" {
+			name, _, _ = strings.Cut(claims.Email, "@")
+		}
+
+		id, err = app.users.Provision(ctx, name, claims.Email)
+		if err != nil {
+			return 0, err
+		}
+	default:
+		return 0, err
+	}
+
+	err = app.identities.Insert(ctx, claims.Issuer, claims.Subject, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (app *App) oidcLoginFailed(w http.ResponseWriter, r *http.Request, message string) {
+	app.metrics.logins.Inc("failure")
+	app.sessionManager.Put(r.Context(), "flash", message)
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/memory"
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+	"github.com/ahmadyogi543/snippetbox/internal/oidc"
+	"github.com/ahmadyogi543/snippetbox/internal/oidc/oidctest"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newSSOTestApp returns an app logging in with a test provider, with
+// in-memory users so the provisioned accounts can be checked.
+func newSSOTestApp(t *testing.T) (*App, *oidctest.Provider) {
+	provider := oidctest.NewProvider("snippetbox", "secret")
+	t.Cleanup(provider.Close)
+
+	app := newTestApp(t)
+	app.users = &memory.UserModel{BcryptCost: bcrypt.MinCost}
+	app.identities = &memory.IdentityModel{}
+	app.twoFactor = &memory.TwoFactorModel{}
+	app.oidcName = "Example"
+	app.oidcProvider = &oidc.Provider{
+		Issuer:       provider.Issuer(),
+		ClientID:     "snippetbox",
+		ClientSecret: "secret",
+		RedirectURL:  "https://localhost:3000/user/login/oidc/callback",
+	}
+
+	return app, provider
+}
+
+// ssoLogin logs in at the provider and returns where the app sends the user
+// after coming back.
+func ssoLogin(t *testing.T, server *testServer) string {
+	code, header, _ := server.get(t, "/user/login/oidc")
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusFound)
+
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, header, _ = server.get(t, callback.RequestURI())
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	return header.Get("Location")
+}
+
+func TestUserLoginOIDC(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		server := newTestServer(t, newTestApp(t).routes())
+		defer server.Close()
+
+		code, _, body := server.get(t, "/user/login")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, strings.Contains(body, "/user/login/oidc"), false)
+
+		code, _, _ = server.get(t, "/user/login/oidc")
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	t.Run("Provisions a new account", func(t *testing.T) {
+		app, _ := newSSOTestApp(t)
+		server := newTestServer(t, app.routes())
+		defer server.Close()
+
+		_, _, body := server.get(t, "/user/login")
+		assert.StringContains(t, body, `<a href="/user/login/oidc">Login with Example</a>`)
+
+		assert.Equal(t, ssoLogin(t, server), "/snippet/create")
+
+		user, err := app.users.GetByEmail(context.Background(), "sso@snippetbox.sh")
+		assert.NilError(t, err)
+		assert.Equal(t, user.Name, "Jane Sso")
+		assert.Equal(t, user.EmailVerified(), true)
+
+		code, _, _ := server.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusOK)
+
+		// logging in again uses the linked account.
+		assert.Equal(t, ssoLogin(t, server), "/snippet/create")
+		_, err = app.users.Get(context.Background(), 2)
+		assert.Equal(t, err, models.ErrNoRecord)
+	})
+
+	t.Run("Links the account with the email address", func(t *testing.T) {
+		app, _ := newSSOTestApp(t)
+		err := app.users.Insert(context.Background(), "Jane Doe", "SSO@snippetbox.sh", "pa55word")
+		assert.NilError(t, err)
+		assert.NilError(t, app.users.VerifyEmail(context.Background(), 1))
+
+		server := newTestServer(t, app.routes())
+		defer server.Close()
+
+		assert.Equal(t, ssoLogin(t, server), "/snippet/create")
+
+		id, err := app.identities.Get(context.Background(), app.oidcProvider.Issuer, "248289761001")
+		assert.NilError(t, err)
+		assert.Equal(t, id, 1)
+	})
+
+	t.Run("Doesn't link an unverified account", func(t *testing.T) {
+		app, _ := newSSOTestApp(t)
+		ctx := context.Background()
+		// someone signed up with the address before its owner used single
+		// sign-on.
+		assert.NilError(t, app.users.Insert(ctx, "Jane Doe", "sso@snippetbox.sh", "pa55word"))
+
+		server := newTestServer(t, app.routes())
+		defer server.Close()
+
+		assert.Equal(t, ssoLogin(t, server), "/user/login")
+
+		_, _, body := server.get(t, "/user/login")
+		assert.StringContains(t, body, "Log in with your password and verify your email address")
+
+		_, err := app.identities.Get(ctx, app.oidcProvider.Issuer, "248289761001")
+		assert.Equal(t, err, models.ErrNoRecord)
+
+		user, err := app.users.Get(ctx, 1)
+		assert.NilError(t, err)
+		assert.Equal(t, user.EmailVerified(), false)
+
+		code, _, _ := server.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusSeeOther)
+	})
+
+	t.Run("Disabled account", func(t *testing.T) {
+		app, _ := newSSOTestApp(t)
+		ctx := context.Background()
+		assert.NilError(t, app.users.Insert(ctx, "Jane Doe", "sso@snippetbox.sh", "pa55word"))
+		assert.NilError(t, app.users.VerifyEmail(ctx, 1))
+		assert.NilError(t, app.users.SetDisabled(ctx, 1, true))
+
+		server := newTestServer(t, app.routes())
+		defer server.Close()
+
+		assert.Equal(t, ssoLogin(t, server), "/user/login")
+
+		_, _, body := server.get(t, "/user/login")
+		assert.StringContains(t, body, "Your account has been disabled.")
+
+		code, _, _ := server.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusSeeOther)
+	})
+
+	t.Run("Two-factor authentication", func(t *testing.T) {
+		app, _ := newSSOTestApp(t)
+		ctx := context.Background()
+		assert.NilError(t, app.users.Insert(ctx, "Jane Doe", "sso@snippetbox.sh", "pa55word"))
+		assert.NilError(t, app.users.VerifyEmail(ctx, 1))
+		assert.NilError(t, app.twoFactor.Setup(ctx, 1, "JBSWY3DPEHPK3PXP"))
+		_, err := app.twoFactor.Enable(ctx, 1, 1)
+		assert.NilError(t, err)
+
+		server := newTestServer(t, app.routes())
+		defer server.Close()
+
+		assert.Equal(t, ssoLogin(t, server), "/user/login/two-factor")
+
+		code, _, _ := server.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusSeeOther)
+	})
+
+	tests := []struct {
+		name     string
+		setup    func(app *App, provider *oidctest.Provider)
+		expected string
+	}{
+		{
+			name: "Unverified email",
+			setup: func(app *App, provider *oidctest.Provider) {
+				provider.User.EmailVerified = false
+			},
+			expected: "Your single sign-on account needs a verified email address.",
+		},
+		{
+			name: "Domain not allowed",
+			setup: func(app *App, provider *oidctest.Provider) {
+				app.oidcAllowedDomains = domainList{"example.com"}
+			},
+			expected: "Your email address isn&#39;t allowed to log in with single sign-on.",
+		},
+		{
+			name: "Invalid ID token",
+			setup: func(app *App, provider *oidctest.Provider) {
+				provider.Claims = func(claims map[string]any) {
+					claims["aud"] = "another"
+				}
+			},
+			expected: "The single sign-on login failed, please try again.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, provider := newSSOTestApp(t)
+			tt.setup(app, provider)
+
+			server := newTestServer(t, app.routes())
+			defer server.Close()
+
+			assert.Equal(t, ssoLogin(t, server), "/user/login")
+
+			_, _, body := server.get(t, "/user/login")
+			assert.StringContains(t, body, tt.expected)
+
+			_, err := app.users.GetByEmail(context.Background(), "sso@snippetbox.sh")
+			assert.Equal(t, err, models.ErrNoRecord)
+		})
+	}
+
+	t.Run("Wrong state", func(t *testing.T) {
+		app, _ := newSSOTestApp(t)
+		server := newTestServer(t, app.routes())
+		defer server.Close()
+
+		code, _, _ := server.get(t, "/user/login/oidc")
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		code, header, _ := server.get(t, "/user/login/oidc/callback?code=abc&state=forged")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/base64"
 	"html/template"
 	"io/fs"
 	"path/filepath"
@@ -10,18 +11,35 @@ import (
 )
 
 type templateData struct {
-	CurrentYear     int
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
-	User            *models.User
+	CurrentYear       int
+	Snippet           *models.Snippet
+	Snippets          []*models.Snippet
+	Form              any
+	Flash             string
+	IsAuthenticated   bool
+	CSRFToken         string
+	User              *models.User
+	TwoFactor         *models.TwoFactor
+	Credentials       []*models.Credential
+	RecoveryCodes     []string
+	RecoveryCodesLeft int
+	OIDCName          string
+	Sessions          []*userSession
+	IsModerator       bool
+	IsAdmin           bool
+	AdminStats        *adminStats
+	Users             []*models.User
+	Roles             []models.Role
+	AuditEvents       []*models.AuditEvent
+	AuditEventTypes   []models.AuditEventType
+	UserEmails        map[int]string
 }
 
 var templateFunctions = template.FuncMap{
 	"humanDate": formatHumanReadableDate,
+	"timeLeft":  formatTimeLeft,
+	"bytes":     formatBytes,
+	"base64URL": base64.RawURLEncoding.EncodeToString,
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
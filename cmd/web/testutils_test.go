@@ -2,29 +2,62 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"html"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/ahmadyogi543/snippetbox/internal/mailer"
+	"github.com/ahmadyogi543/snippetbox/internal/memory"
 	"github.com/ahmadyogi543/snippetbox/internal/mocks"
+	"github.com/ahmadyogi543/snippetbox/internal/webauthn"
 	"github.com/alexedwards/scs/v2"
 )
 
 var csrfTokenRX = regexp.MustCompile(`<input type="hidden" name="csrf_token" value="(.+)" />`)
 
+var verificationLinkRX = regexp.MustCompile(`https://\S+/user/verify/\S+`)
+
 type testServer struct {
 	*httptest.Server
+	// client is the browser making the requests, the client of the server
+	// when nil.
+	client *http.Client
+}
+
+func (ts *testServer) browser() *http.Client {
+	if ts.client != nil {
+		return ts.client
+	}
+
+	return ts.Client()
+}
+
+// newDevice returns the server as seen from another browser, with its own
+// cookies.
+func (ts *testServer) newDevice(t *testing.T) *testServer {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := *ts.Client()
+	client.Jar = jar
+
+	return &testServer{Server: ts.Server, client: &client}
 }
 
 func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
-	result, err := ts.Client().Get(ts.URL + urlPath)
+	result, err := ts.browser().Get(ts.URL + urlPath)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -40,7 +73,7 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, strin
 }
 
 func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, string) {
-	result, err := ts.Client().PostForm(ts.URL+urlPath, form)
+	result, err := ts.browser().PostForm(ts.URL+urlPath, form)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -55,6 +88,35 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 	return result.StatusCode, result.Header, string(body)
 }
 
+// postJSON sends data as JSON, with the CSRF token in the header like the
+// scripts of the pages do.
+func (ts *testServer) postJSON(t *testing.T, urlPath, csrfToken string, data any) (int, string) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+urlPath, bytes.NewReader(js))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-CSRF-Token", csrfToken)
+
+	result, err := ts.browser().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer result.Body.Close()
+	body, err := io.ReadAll(result.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result.StatusCode, string(body)
+}
+
 func newTestApp(t *testing.T) *App {
 	templateCache, err := newTemplateCache()
 	if err != nil {
@@ -64,14 +126,26 @@ func newTestApp(t *testing.T) *App {
 	sessionManager := scs.New()
 	sessionManager.Lifetime = 12 * time.Hour
 	sessionManager.Cookie.Secure = true
+	sessionManager.Cookie.Persist = false
 
 	return &App{
-		errorLog:       log.New(io.Discard, "", 0),
-		infoLog:        log.New(io.Discard, "", 0),
-		templateCache:  templateCache,
-		sessionManager: sessionManager,
-		users:          &mocks.UserModel{},
-		snippets:       &mocks.SnippetModel{},
+		logger:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		templateCache:    templateCache,
+		sessionManager:   sessionManager,
+		sessionPolicy:    sessionPolicy{lifetime: 12 * time.Hour, idleTimeout: 30 * time.Minute, rememberLifetime: 30 * 24 * time.Hour},
+		users:            &mocks.UserModel{},
+		loginFailures:    &mocks.LoginFailureModel{},
+		tokens:           &mocks.TokenModel{},
+		twoFactor:        &mocks.TwoFactorModel{},
+		credentials:      &mocks.CredentialModel{},
+		identities:       &mocks.IdentityModel{},
+		auditEvents:      &mocks.AuditEventModel{},
+		sessionIndex:     &memory.UserSessionModel{},
+		relyingParty:     &webauthn.RelyingParty{ID: "localhost", Name: "Snippetbox", Origin: "https://localhost:3000"},
+		verificationTTL:  48 * time.Hour,
+		passwordResetTTL: 30 * time.Minute,
+		snippets:         &mocks.SnippetModel{},
+		metrics:          newAppMetrics(),
 	}
 }
 
@@ -88,7 +162,7 @@ func newTestServer(t *testing.T, h http.Handler) *testServer {
 		return http.ErrUseLastResponse
 	}
 
-	return &testServer{server}
+	return &testServer{Server: server}
 }
 
 func extractCSRFToken(t *testing.T, body string) string {
@@ -99,3 +173,17 @@ func extractCSRFToken(t *testing.T, body string) string {
 
 	return html.UnescapeString(string(matches[1]))
 }
+
+// recordingSender keeps the messages instead of sending them.
+type recordingSender struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg mailer.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = append(s.messages, msg)
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
+)
+
+// newTracer returns the tracer for the -trace-exporter setting, nil when
+// tracing is disabled, and a function closing its exporter.
+func newTracer(exporter string) (*trace.Tracer, func() error, error) {
+	switch {
+	case exporter == "":
+		return nil, func() error { return nil }, nil
+	case exporter == "stdout":
+		e := trace.NewWriterExporter(os.Stdout)
+		return trace.NewTracer(e), e.Close, nil
+	default:
+		e, err := trace.NewFileExporter(strings.TrimPrefix(exporter, "file:"))
+		if err != nil {
+			return nil, nil, err
+		}
+		return trace.NewTracer(e), e.Close, nil
+	}
+}
+
+// traceRequest starts the span of the request, continuing the trace of the
+// caller when it sends a traceparent header. The span is named after the
+// route once the router has matched it.
+func (app *App) traceRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.tracer == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		if remote, ok := trace.ParseTraceparent(r.Header.Get(trace.TraceparentHeader)); ok {
+			ctx = trace.ContextWithRemoteSpanContext(ctx, remote)
+		}
+
+		ctx, span := app.tracer.Start(ctx, r.Method)
+		defer span.End()
+
+		info := requestInfoFrom(r)
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.target", r.URL.RequestURI())
+		span.SetAttribute("request_id", info.id)
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		route := info.route
+		if route == "" {
+			route = unmatchedRoute
+		}
+		span.SetName(r.Method + " " + route)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.status_code", recorder.status)
+		if info.userID != 0 {
+			span.SetAttribute("user_id", info.userID)
+		}
+	})
+}
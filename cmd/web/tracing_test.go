@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
+)
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []trace.SpanData
+}
+
+func (e *recordingExporter) ExportSpan(data trace.SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, data)
+	return nil
+}
+
+func TestTraceRequest(t *testing.T) {
+	exporter := &recordingExporter{}
+
+	app := newTestApp(t)
+	app.tracer = trace.NewTracer(exporter)
+
+	r := httptest.NewRequest(http.MethodGet, "/snippet/view/1", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	spans := map[string]trace.SpanData{}
+	for _, span := range exporter.spans {
+		spans[span.Name] = span
+	}
+
+	request, ok := spans["GET /snippet/view/:id"]
+	assert.Equal(t, ok, true)
+	assert.Equal(t, request.TraceID.String(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Equal(t, request.ParentID.String(), "00f067aa0ba902b7")
+	assert.Equal(t, request.Attributes["http.status_code"], any(http.StatusOK))
+
+	template, ok := spans["template view.go.html"]
+	assert.Equal(t, ok, true)
+	assert.Equal(t, template.TraceID, request.TraceID)
+	assert.Equal(t, template.ParentID, request.SpanID)
+}
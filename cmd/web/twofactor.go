@@ -0,0 +1,454 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+	"github.com/ahmadyogi543/snippetbox/internal/totp"
+	"github.com/ahmadyogi543/snippetbox/internal/validator"
+	"rsc.io/qr"
+)
+
+const (
+	// totpIssuer is the name the authenticator apps show the codes under.
+	totpIssuer = "Snippetbox"
+	// twoFactorLoginTimeout is how long the users have to type the code
+	// after their password.
+	twoFactorLoginTimeout = 5 * time.Minute
+	// twoFactorMaxAttempts is how many wrong codes are allowed before the
+	// users have to type their password again.
+	twoFactorMaxAttempts = 5
+)
+
+type twoFactorCodeForm struct {
+	Code                string `form:"code"`
+	validator.Validator `form:"-"`
+}
+
+// checkTwoFactorCode reports whether code is the current code of the
+// authenticator app of the user or one of their recovery codes, and uses it
+// up.
+func (app *App) checkTwoFactorCode(ctx context.Context, tf *models.TwoFactor, code string) (ok, recovery bool, err error) {
+	if len(code) == totp.Digits {
+		counter, ok := totp.Validate(tf.Secret, code, time.Now())
+		if !ok {
+			return false, false, nil
+		}
+
+		ok, err = app.twoFactor.UseCounter(ctx, tf.UserID, counter)
+		return ok, false, err
+	}
+
+	ok, err = app.twoFactor.UseRecoveryCode(ctx, tf.UserID, code)
+	return ok, ok, err
+}
+
+// startTwoFactorLogin remembers the user who typed their password in the
+// session of r, until they type their code too. remember is whether they
+// asked to be remembered.
+func (app *App) startTwoFactorLogin(w http.ResponseWriter, r *http.Request, id int, remember bool) {
+	err := app.renewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "twoFactorUserID", id)
+	app.sessionManager.Put(r.Context(), "twoFactorStarted", time.Now().Unix())
+	app.sessionManager.Put(r.Context(), "twoFactorAttempts", 0)
+	app.sessionManager.Put(r.Context(), "twoFactorRememberMe", remember)
+
+	http.Redirect(w, r, "/user/login/two-factor", http.StatusSeeOther)
+}
+
+// twoFactorLoginUser returns the user who typed their password in the
+// session of r, zero when there's none or they took too long.
+func (app *App) twoFactorLoginUser(r *http.Request) int {
+	started := time.Unix(app.sessionManager.GetInt64(r.Context(), "twoFactorStarted"), 0)
+	if time.Since(started) > twoFactorLoginTimeout {
+		app.clearTwoFactorLogin(r)
+		return 0
+	}
+
+	return app.sessionManager.GetInt(r.Context(), "twoFactorUserID")
+}
+
+func (app *App) clearTwoFactorLogin(r *http.Request) {
+	app.sessionManager.Remove(r.Context(), "twoFactorUserID")
+	app.sessionManager.Remove(r.Context(), "twoFactorStarted")
+	app.sessionManager.Remove(r.Context(), "twoFactorAttempts")
+	app.sessionManager.Remove(r.Context(), "twoFactorRememberMe")
+}
+
+func (app *App) userLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if app.twoFactorLoginUser(r) == 0 {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = twoFactorCodeForm{}
+
+	app.render(w, r, http.StatusOK, "login_two_factor.go.html", data)
+}
+
+func (app *App) userLoginTwoFactorPost(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID := app.twoFactorLoginUser(r)
+	if userID == 0 {
+		app.sessionManager.Put(r.Context(), "flash", "Please log in again.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	form := twoFactorCodeForm{
+		Code: r.PostForm.Get("code"),
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "login_two_factor.go.html", data)
+		return
+	}
+
+	throttled, err := app.loginThrottled(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// the account can get locked by other logins while the code is typed,
+	// the code doesn't get past the lock.
+	user, err := app.users.Get(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if user.Locked() {
+		app.metrics.logins.Inc("locked")
+		app.auditUser(r, models.EventLoginFailed, userID, "locked")
+		app.twoFactorLoginLocked(w, r)
+		return
+	}
+
+	ok := false
+	recovery := false
+	if !throttled {
+		tf, err := app.twoFactor.Get(r.Context(), userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		ok, recovery, err = app.checkTwoFactorCode(r.Context(), tf, form.Code)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	if !ok {
+		if throttled {
+			app.metrics.logins.Inc("throttled")
+			app.auditUser(r, models.EventLoginFailed, userID, "throttled")
+		} else {
+			app.metrics.logins.Inc("failure")
+			app.recordLoginFailure(r)
+			app.auditUser(r, models.EventLoginFailed, userID, "incorrect two-factor code")
+
+			// the wrong codes count against the account like the wrong
+			// passwords, a new login doesn't start the count again.
+			err = app.users.RecordFailedLogin(r.Context(), userID)
+			var lockout *models.LockoutError
+			switch {
+			case errors.As(err, &lockout):
+				if lockout.Started {
+					app.notifyLockout(r, lockout)
+				}
+				app.twoFactorLoginLocked(w, r)
+				return
+			case err != nil && !errors.Is(err, models.ErrInvalidCredentials):
+				app.serverError(w, r, err)
+				return
+			}
+		}
+
+		attempts := app.sessionManager.GetInt(r.Context(), "twoFactorAttempts") + 1
+		if attempts >= twoFactorMaxAttempts {
+			app.clearTwoFactorLogin(r)
+			app.sessionManager.Put(r.Context(), "flash", "Too many incorrect codes. Please log in again.")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "twoFactorAttempts", attempts)
+
+		form.AddNonFieldError("The code is incorrect, or there were too many failed attempts. Please try again.")
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "login_two_factor.go.html", data)
+		return
+	}
+
+	remember := app.sessionManager.GetBool(r.Context(), "twoFactorRememberMe")
+	app.clearTwoFactorLogin(r)
+
+	if recovery {
+		left, err := app.twoFactor.RecoveryCodesLeft(r.Context(), userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You used a recovery code, %d left. You can get new ones on your account page.", left))
+	}
+
+	path, err := app.logIn(r, userID, remember)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, path, http.StatusSeeOther)
+}
+
+func (app *App) accountTwoFactor(w http.ResponseWriter, r *http.Request) {
+	app.renderAccountTwoFactor(w, r, http.StatusOK, twoFactorCodeForm{})
+}
+
+// renderAccountTwoFactor renders the page to set up, enable or disable the
+// two-factor authentication, depending on how far the user got.
+func (app *App) renderAccountTwoFactor(w http.ResponseWriter, r *http.Request, status int, form twoFactorCodeForm) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	data := app.newTemplateData(r)
+	data.Form = form
+
+	tf, err := app.twoFactor.Get(r.Context(), userID)
+	switch {
+	case err == nil:
+		data.TwoFactor = tf
+	case !errors.Is(err, models.ErrNoRecord):
+		app.serverError(w, r, err)
+		return
+	}
+
+	if tf != nil && tf.Enabled() {
+		data.RecoveryCodesLeft, err = app.twoFactor.RecoveryCodesLeft(r.Context(), userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	app.render(w, r, status, "two_factor.go.html", data)
+}
+
+func (app *App) accountTwoFactorSetupPost(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.twoFactor.Setup(r.Context(), userID, secret)
+	if err != nil && !errors.Is(err, models.ErrTwoFactorEnabled) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/account/two-factor", http.StatusSeeOther)
+}
+
+// accountTwoFactorQRCode serves the QR code of the secret being set up, for
+// the authenticator apps to scan.
+func (app *App) accountTwoFactorQRCode(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	tf, err := app.twoFactor.Get(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	// the secret isn't shown again once it's enabled.
+	if tf.Enabled() {
+		app.notFound(w)
+		return
+	}
+
+	user, err := app.users.Get(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	code, err := qr.Encode(totp.URL(totpIssuer, user.Email, tf.Secret), qr.M)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(code.PNG())
+}
+
+func (app *App) accountTwoFactorEnablePost(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	form := twoFactorCodeForm{
+		Code: r.PostForm.Get("code"),
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	if !form.Valid() {
+		app.renderAccountTwoFactor(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	tf, err := app.twoFactor.Get(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.Redirect(w, r, "/account/two-factor", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	counter, ok := totp.Validate(tf.Secret, form.Code, time.Now())
+	if !ok {
+		form.AddFieldError("code", "The code is incorrect, check the clock of your device")
+		app.renderAccountTwoFactor(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	codes, err := app.twoFactor.Enable(r.Context(), userID, counter)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.Redirect(w, r, "/account/two-factor", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Flash = "Two-factor authentication is enabled!"
+	data.RecoveryCodes = codes
+	app.render(w, r, http.StatusOK, "recovery_codes.go.html", data)
+}
+
+// checkAccountTwoFactorCode checks the code in the form the users send to
+// change the two-factor authentication they enabled. It renders the page
+// again and returns nil when they didn't type a working code.
+func (app *App) checkAccountTwoFactorCode(w http.ResponseWriter, r *http.Request) *models.TwoFactor {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return nil
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	form := twoFactorCodeForm{
+		Code: r.PostForm.Get("code"),
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	if !form.Valid() {
+		app.renderAccountTwoFactor(w, r, http.StatusUnprocessableEntity, form)
+		return nil
+	}
+
+	tf, err := app.twoFactor.Get(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.Redirect(w, r, "/account/two-factor", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil
+	}
+
+	ok, _, err := app.checkTwoFactorCode(r.Context(), tf, form.Code)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil
+	}
+	if !ok {
+		form.AddFieldError("code", "The code is incorrect")
+		app.renderAccountTwoFactor(w, r, http.StatusUnprocessableEntity, form)
+		return nil
+	}
+
+	return tf
+}
+
+func (app *App) accountTwoFactorDisablePost(w http.ResponseWriter, r *http.Request) {
+	tf := app.checkAccountTwoFactorCode(w, r)
+	if tf == nil {
+		return
+	}
+
+	err := app.twoFactor.Disable(r.Context(), tf.UserID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Two-factor authentication is disabled.")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *App) accountTwoFactorRecoveryCodesPost(w http.ResponseWriter, r *http.Request) {
+	tf := app.checkAccountTwoFactorCode(w, r)
+	if tf == nil {
+		return
+	}
+
+	codes, err := app.twoFactor.RegenerateRecoveryCodes(r.Context(), tf.UserID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Flash = "Your old recovery codes don't work anymore."
+	data.RecoveryCodes = codes
+	app.render(w, r, http.StatusOK, "recovery_codes.go.html", data)
+}
+
+// twoFactorLoginLocked ends the login of a user whose account is locked
+// before they typed the right code. It tells them no more than the login
+// form does about a locked account.
+func (app *App) twoFactorLoginLocked(w http.ResponseWriter, r *http.Request) {
+	app.clearTwoFactorLogin(r)
+	app.sessionManager.Put(r.Context(), "flash", "Too many incorrect codes. Please log in again.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/memory"
+	"github.com/ahmadyogi543/snippetbox/internal/mocks"
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+	"github.com/ahmadyogi543/snippetbox/internal/totp"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// loginWithPassword sends the login form and returns where it redirected to.
+func loginWithPassword(t *testing.T, server *testServer, email string) string {
+	_, _, body := server.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", "12345678")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, headers, _ := server.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	return headers.Get("Location")
+}
+
+func TestUserLoginTwoFactor(t *testing.T) {
+	currentCode, err := totp.Code(mocks.TwoFactorSecret, time.Now())
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name         string
+		codes        []string
+		wantCode     int
+		wantLocation string
+		wantFlash    string
+	}{
+		{
+			name:         "Authenticator code",
+			codes:        []string{currentCode},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/account/view",
+		},
+		{
+			name:         "Recovery code",
+			codes:        []string{mocks.RecoveryCode},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/account/view",
+			wantFlash:    "You used a recovery code",
+		},
+		{
+			name:     "Wrong code",
+			codes:    []string{"000000"},
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "Too many wrong codes",
+			codes:        []string{"000000", "000000", "000000", "000000", "000000"},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/login",
+			wantFlash:    "Too many incorrect codes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			server := newTestServer(t, app.routes())
+			defer server.Close()
+
+			location := loginWithPassword(t, server, "twofactor@snippetbox.sh")
+			assert.Equal(t, location, "/user/login/two-factor")
+
+			// the password alone doesn't log in, and the login goes on to
+			// the page asked for.
+			_, headers, _ := server.get(t, "/account/view")
+			assert.Equal(t, headers.Get("Location"), "/user/login")
+
+			var code int
+			for _, c := range tt.codes {
+				_, _, body := server.get(t, "/user/login/two-factor")
+
+				form := url.Values{}
+				form.Add("code", c)
+				form.Add("csrf_token", extractCSRFToken(t, body))
+				code, headers, _ = server.postForm(t, "/user/login/two-factor", form)
+			}
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			if tt.wantFlash != "" {
+				_, _, body := server.get(t, "/about")
+				assert.StringContains(t, body, tt.wantFlash)
+			}
+
+			code, _, _ = server.get(t, "/account/view")
+			if tt.wantLocation == "/account/view" {
+				assert.Equal(t, code, http.StatusOK)
+			} else {
+				assert.Equal(t, code, http.StatusSeeOther)
+			}
+		})
+	}
+}
+
+func TestUserLoginTwoFactorLockout(t *testing.T) {
+	app := newTestApp(t)
+	users := &memory.UserModel{BcryptCost: bcrypt.MinCost, Lockout: models.LockoutPolicy{Threshold: 3, Duration: time.Minute, MaxDuration: time.Hour}}
+	app.users = users
+	twoFactor := &memory.TwoFactorModel{}
+	app.twoFactor = twoFactor
+
+	ctx := context.Background()
+	assert.NilError(t, users.Insert(ctx, "Jane Doe", "jane@snippetbox.sh", "12345678"))
+	assert.NilError(t, twoFactor.Setup(ctx, 1, mocks.TwoFactorSecret))
+	_, err := twoFactor.Enable(ctx, 1, 0)
+	assert.NilError(t, err)
+
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	// the right password before each wrong code doesn't start the count
+	// again.
+	var headers http.Header
+	for i := 0; i < 3; i++ {
+		location := loginWithPassword(t, server, "jane@snippetbox.sh")
+		assert.Equal(t, location, "/user/login/two-factor")
+
+		_, _, body := server.get(t, "/user/login/two-factor")
+		form := url.Values{}
+		form.Add("code", "000000")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		_, headers, _ = server.postForm(t, "/user/login/two-factor", form)
+	}
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	user, err := users.Get(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Locked(), true)
+
+	// the password doesn't get to the code while it's locked.
+	_, _, body := server.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "jane@snippetbox.sh")
+	form.Add("password", "12345678")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ := server.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+}
+
+func TestUserLoginTwoFactorNotStarted(t *testing.T) {
+	app := newTestApp(t)
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	code, headers, _ := server.get(t, "/user/login/two-factor")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	// the users without it log in with their password.
+	location := loginWithPassword(t, server, "ayogi@snippetbox.sh")
+	assert.Equal(t, location, "/snippet/create")
+}
+
+func TestAccountTwoFactorEnable(t *testing.T) {
+	app := newTestApp(t)
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	// user 1 has set it up but not enabled it.
+	loginWithPassword(t, server, "ayogi@snippetbox.sh")
+
+	code, _, body := server.get(t, "/account/two-factor")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<img src="/account/two-factor/qr.png"`)
+	assert.StringContains(t, body, mocks.TwoFactorSecret)
+
+	code, headers, body := server.get(t, "/account/two-factor/qr.png")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "image/png")
+	assert.Equal(t, strings.HasPrefix(body, "\x89PNG\r\n"), true)
+
+	_, _, body = server.get(t, "/account/two-factor")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("code", "000000")
+	form.Add("csrf_token", csrfToken)
+	code, _, body = server.postForm(t, "/account/two-factor/enable", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "The code is incorrect")
+
+	currentCode, err := totp.Code(mocks.TwoFactorSecret, time.Now())
+	assert.NilError(t, err)
+
+	form = url.Values{}
+	form.Add("code", currentCode)
+	form.Add("csrf_token", csrfToken)
+	code, _, body = server.postForm(t, "/account/two-factor/enable", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, mocks.RecoveryCode)
+}
+
+func TestAccountTwoFactorEnabled(t *testing.T) {
+	app := newTestApp(t)
+	server := newTestServer(t, app.routes())
+	defer server.Close()
+
+	loginWithPassword(t, server, "twofactor@snippetbox.sh")
+	_, _, body := server.get(t, "/user/login/two-factor")
+	form := url.Values{}
+	form.Add("code", mocks.RecoveryCode)
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	server.postForm(t, "/user/login/two-factor", form)
+
+	code, _, body := server.get(t, "/account/two-factor")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "recovery codes left")
+
+	// the secret isn't shown once it's enabled.
+	code, _, _ = server.get(t, "/account/two-factor/qr.png")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	form = url.Values{}
+	form.Add("code", "wrong-code")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ = server.postForm(t, "/account/two-factor/disable", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	form.Set("code", mocks.RecoveryCode)
+	code, _, body = server.postForm(t, "/account/two-factor/recovery-codes", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, mocks.RecoveryCode)
+
+	code, headers, _ := server.postForm(t, "/account/two-factor/disable", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/view")
+}
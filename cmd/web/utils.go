@@ -2,9 +2,35 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
 )
 
+// parseDatabase splits the value of the -db flag into a driver name and a
+// data source name. The MySQL backend falls back to mySQLDSN when no data
+// source is given after the colon.
+func parseDatabase(database string, mySQLDSN string) (string, string, error) {
+	name, dsn, _ := strings.Cut(database, ":")
+
+	switch name {
+	case "mysql":
+		if dsn == "" {
+			dsn = mySQLDSN
+		}
+		return "mysql", dsn, nil
+	case "sqlite":
+		if dsn == "" {
+			return "", "", fmt.Errorf("the sqlite backend needs a path, for example sqlite:./snippetbox.db")
+		}
+		return "sqlite", dsn + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", nil
+	default:
+		return "", "", fmt.Errorf("unsupported database backend %q", name)
+	}
+}
+
 func openDB(driverName string, dsn string) (*sql.DB, error) {
 	db, err := sql.Open(driverName, dsn)
 	if err != nil {
@@ -15,6 +41,15 @@ func openDB(driverName string, dsn string) (*sql.DB, error) {
 		return nil, err
 	}
 
+	// an SQLite database is a single local file, so create the schema on the
+	// fly instead of requiring a separate setup step.
+	if driverName == "sqlite" {
+		if err = models.CreateSQLiteSchema(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
 	return db, nil
 }
 
@@ -25,3 +60,44 @@ func formatHumanReadableDate(t time.Time) string {
 
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
+
+// formatTimeLeft rounds down the time left until t, like "3 days" or "25
+// minutes".
+func formatTimeLeft(t time.Time) string {
+	d := time.Until(t)
+
+	switch {
+	case d < time.Minute:
+		return "less than a minute"
+	case d < time.Hour:
+		return plural(int(d/time.Minute), "minute")
+	case d < 48*time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	default:
+		return plural(int(d/(24*time.Hour)), "day")
+	}
+}
+
+// formatBytes formats a size in bytes with the largest unit it has one of.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+
+	return fmt.Sprintf("%d %ss", n, unit)
+}
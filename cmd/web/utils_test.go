@@ -37,3 +37,42 @@ func TestFormatHumanReadableDate(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatTimeLeft(t *testing.T) {
+	tests := []struct {
+		name     string
+		left     time.Duration
+		expected string
+	}{
+		{name: "Seconds", left: 30 * time.Second, expected: "less than a minute"},
+		{name: "Past", left: -time.Hour, expected: "less than a minute"},
+		{name: "Minute", left: time.Minute + 30*time.Second, expected: "1 minute"},
+		{name: "Minutes", left: 25*time.Minute + 30*time.Second, expected: "25 minutes"},
+		{name: "Hours", left: 47*time.Hour + 30*time.Minute, expected: "47 hours"},
+		{name: "Days", left: 30 * 24 * time.Hour, expected: "29 days"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, formatTimeLeft(time.Now().Add(test.left)), test.expected)
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n        int64
+		expected string
+	}{
+		{n: 0, expected: "0 B"},
+		{n: 1023, expected: "1023 B"},
+		{n: 1536, expected: "1.5 KiB"},
+		{n: 5 << 20, expected: "5.0 MiB"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expected, func(t *testing.T) {
+			assert.Equal(t, formatBytes(test.n), test.expected)
+		})
+	}
+}
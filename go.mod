@@ -1,6 +1,6 @@
 module github.com/ahmadyogi543/snippetbox
 
-go 1.20
+go 1.21
 
 require github.com/go-sql-driver/mysql v1.7.1
 
@@ -10,9 +10,31 @@ require github.com/julienschmidt/httprouter v1.3.0
 
 require (
 	github.com/alexedwards/scs/mysqlstore v0.0.0-20230327161757-10d4299e3b24
-	github.com/alexedwards/scs/v2 v2.5.1
+	github.com/alexedwards/scs/v2 v2.9.0
 )
 
 require golang.org/x/crypto v0.11.0
 
-require github.com/justinas/nosurf v1.1.1
+require rsc.io/qr v0.2.0
+
+require (
+	github.com/alexedwards/scs/sqlite3store v0.0.0-20231113091146-cef4b05350c8
+	github.com/justinas/nosurf v1.1.1
+	modernc.org/sqlite v1.29.5
+)
+
+require (
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.3.0 // indirect
+	github.com/hashicorp/golang-lru/v2 v2.0.7 // indirect
+	github.com/mattn/go-isatty v0.0.16 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/sys v0.16.0 // indirect
+	modernc.org/gc/v3 v3.0.0-20240107210532-573471604cb6 // indirect
+	modernc.org/libc v1.41.0 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.7.2 // indirect
+	modernc.org/strutil v1.2.0 // indirect
+	modernc.org/token v1.1.0 // indirect
+)
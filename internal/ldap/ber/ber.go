@@ -0,0 +1,236 @@
+// Package ber encodes and decodes the subset of the ASN.1 Basic Encoding
+// Rules LDAP uses: single byte tags and definite lengths.
+package ber
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The classes and the constructed bit of the tags.
+const (
+	ClassUniversal   = 0x00
+	ClassApplication = 0x40
+	ClassContext     = 0x80
+	Constructed      = 0x20
+)
+
+// The universal tags.
+const (
+	TagBoolean     = 0x01
+	TagInteger     = 0x02
+	TagOctetString = 0x04
+	TagNull        = 0x05
+	TagEnumerated  = 0x0a
+	TagSequence    = Constructed | 0x10
+	TagSet         = Constructed | 0x11
+)
+
+// MaxLength limits the packets read, so a peer can't make the reader
+// allocate without bounds.
+const MaxLength = 1 << 20
+
+// MaxDepth limits the nesting of the constructed packets, so a peer can't
+// make the reader recurse without bounds. The deepest LDAP messages, the
+// nested filters, stay well under it.
+const MaxDepth = 32
+
+var ErrMalformed = errors.New("ber: malformed packet")
+
+// Packet is an element, holding either the content of a primitive type in
+// Value or the elements of a constructed type in Children.
+type Packet struct {
+	Tag      byte
+	Value    []byte
+	Children []*Packet
+}
+
+// NewConstructed returns a constructed packet of the children.
+func NewConstructed(tag byte, children ...*Packet) *Packet {
+	return &Packet{Tag: tag | Constructed, Children: children}
+}
+
+// NewString returns a primitive packet holding the string.
+func NewString(tag byte, s string) *Packet {
+	return &Packet{Tag: tag, Value: []byte(s)}
+}
+
+// NewInteger returns a packet holding the integer in two's complement, as
+// INTEGER and ENUMERATED are encoded.
+func NewInteger(tag byte, n int64) *Packet {
+	value := []byte{byte(n)}
+	for n > 127 || n < -128 {
+		n >>= 8
+		value = append([]byte{byte(n)}, value...)
+	}
+
+	return &Packet{Tag: tag, Value: value}
+}
+
+func NewBoolean(tag byte, b bool) *Packet {
+	if b {
+		return &Packet{Tag: tag, Value: []byte{0xff}}
+	}
+
+	return &Packet{Tag: tag, Value: []byte{0x00}}
+}
+
+func (p *Packet) IsConstructed() bool {
+	return p.Tag&Constructed != 0
+}
+
+// String returns the value of a primitive packet as a string.
+func (p *Packet) String() string {
+	return string(p.Value)
+}
+
+// Int returns the value of an INTEGER or ENUMERATED packet.
+func (p *Packet) Int() (int64, error) {
+	if p.IsConstructed() || len(p.Value) == 0 || len(p.Value) > 8 {
+		return 0, ErrMalformed
+	}
+
+	n := int64(int8(p.Value[0]))
+	for _, b := range p.Value[1:] {
+		n = n<<8 | int64(b)
+	}
+
+	return n, nil
+}
+
+// Bool returns the value of a BOOLEAN packet.
+func (p *Packet) Bool() (bool, error) {
+	if p.IsConstructed() || len(p.Value) != 1 {
+		return false, ErrMalformed
+	}
+
+	return p.Value[0] != 0, nil
+}
+
+// Bytes returns the encoding of the packet.
+func (p *Packet) Bytes() []byte {
+	content := p.Value
+	if p.IsConstructed() {
+		var buf bytes.Buffer
+		for _, child := range p.Children {
+			buf.Write(child.Bytes())
+		}
+		content = buf.Bytes()
+	}
+
+	encoded := append([]byte{p.Tag}, encodeLength(len(content))...)
+	return append(encoded, content...)
+}
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+
+	var length []byte
+	for ; n > 0; n >>= 8 {
+		length = append([]byte{byte(n)}, length...)
+	}
+
+	return append([]byte{0x80 | byte(len(length))}, length...)
+}
+
+// Read reads one packet from r.
+func Read(r *bufio.Reader) (*Packet, error) {
+	return read(r, 0)
+}
+
+// read reads a packet nested in depth constructed packets.
+func read(r *bufio.Reader, depth int) (*Packet, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if tag&0x1f == 0x1f {
+		return nil, fmt.Errorf("%w: multi-byte tag", ErrMalformed)
+	}
+
+	first, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	length := int(first)
+	if first&0x80 != 0 {
+		size := int(first & 0x7f)
+		if size == 0 || size > 4 {
+			return nil, fmt.Errorf("%w: unsupported length", ErrMalformed)
+		}
+
+		length = 0
+		for i := 0; i < size; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	if length > MaxLength {
+		return nil, fmt.Errorf("%w: packet of %d bytes is too long", ErrMalformed, length)
+	}
+
+	content := make([]byte, length)
+	_, err = io.ReadFull(r, content)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	return decodeContent(tag, content, depth)
+}
+
+// Decode decodes the packet encoded in data, which must hold nothing else.
+func Decode(data []byte) (*Packet, error) {
+	r := bufio.NewReader(bytes.NewReader(data))
+
+	p, err := Read(r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if r.Buffered() > 0 {
+		return nil, fmt.Errorf("%w: trailing data", ErrMalformed)
+	}
+
+	return p, nil
+}
+
+func decodeContent(tag byte, content []byte, depth int) (*Packet, error) {
+	p := &Packet{Tag: tag}
+	if tag&Constructed == 0 {
+		p.Value = content
+		return p, nil
+	}
+	if depth >= MaxDepth {
+		return nil, fmt.Errorf("%w: packets nested too deep", ErrMalformed)
+	}
+
+	r := bufio.NewReader(bytes.NewReader(content))
+	for {
+		child, err := read(r, depth+1)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		p.Children = append(p.Children, child)
+	}
+
+	return p, nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %w", ErrMalformed, io.ErrUnexpectedEOF)
+	}
+
+	return err
+}
@@ -0,0 +1,95 @@
+package ber
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestInteger(t *testing.T) {
+	tests := []struct {
+		n       int64
+		encoded []byte
+	}{
+		{0, []byte{0x02, 0x01, 0x00}},
+		{127, []byte{0x02, 0x01, 0x7f}},
+		{128, []byte{0x02, 0x02, 0x00, 0x80}},
+		{256, []byte{0x02, 0x02, 0x01, 0x00}},
+		{-1, []byte{0x02, 0x01, 0xff}},
+		{-129, []byte{0x02, 0x02, 0xff, 0x7f}},
+	}
+
+	for _, tt := range tests {
+		encoded := NewInteger(TagInteger, tt.n).Bytes()
+		assert.Equal(t, bytes.Equal(encoded, tt.encoded), true)
+
+		p, err := Decode(encoded)
+		assert.NilError(t, err)
+		n, err := p.Int()
+		assert.NilError(t, err)
+		assert.Equal(t, n, tt.n)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	long := string(bytes.Repeat([]byte("a"), 300))
+
+	p := NewConstructed(TagSequence,
+		NewInteger(TagInteger, 1),
+		NewConstructed(ClassApplication|0,
+			NewString(TagOctetString, "cn=admin,dc=example,dc=com"),
+			NewString(ClassContext|0, long),
+		),
+		NewBoolean(TagBoolean, true),
+	)
+
+	decoded, err := Decode(p.Bytes())
+	assert.NilError(t, err)
+	assert.Equal(t, len(decoded.Children), 3)
+	assert.Equal(t, decoded.Children[1].Tag, byte(ClassApplication|Constructed))
+	assert.Equal(t, decoded.Children[1].Children[0].String(), "cn=admin,dc=example,dc=com")
+	assert.Equal(t, decoded.Children[1].Children[1].String(), long)
+
+	b, err := decoded.Children[2].Bool()
+	assert.NilError(t, err)
+	assert.Equal(t, b, true)
+}
+
+// nested returns a packet of the depth, sequences holding a null.
+func nested(depth int) *Packet {
+	p := &Packet{Tag: TagNull}
+	for i := 0; i < depth; i++ {
+		p = NewConstructed(TagSequence, p)
+	}
+
+	return p
+}
+
+func TestDecodeNested(t *testing.T) {
+	_, err := Decode(nested(MaxDepth).Bytes())
+	assert.NilError(t, err)
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Truncated", []byte{0x04, 0x05, 'a'}},
+		{"Indefinite length", []byte{0x30, 0x80, 0x00, 0x00}},
+		{"Too long", []byte{0x04, 0x84, 0x7f, 0xff, 0xff, 0xff}},
+		{"Multi-byte tag", []byte{0x1f, 0x81, 0x00, 0x00}},
+		{"Truncated child", []byte{0x30, 0x02, 0x04, 0x05}},
+		{"Trailing data", []byte{0x05, 0x00, 0x05, 0x00}},
+		{"Nested too deep", nested(MaxDepth + 1).Bytes()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.data)
+			assert.Equal(t, errors.Is(err, ErrMalformed), true)
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ahmadyogi543/snippetbox/internal/ldap/ber"
+)
+
+// The tags of the filter choices.
+const (
+	filterAnd            = ber.ClassContext | ber.Constructed | 0
+	filterOr             = ber.ClassContext | ber.Constructed | 1
+	filterNot            = ber.ClassContext | ber.Constructed | 2
+	filterEquality       = ber.ClassContext | ber.Constructed | 3
+	filterSubstrings     = ber.ClassContext | ber.Constructed | 4
+	filterGreaterOrEqual = ber.ClassContext | ber.Constructed | 5
+	filterLessOrEqual    = ber.ClassContext | ber.Constructed | 6
+	filterPresent        = ber.ClassContext | 7
+	filterApprox         = ber.ClassContext | ber.Constructed | 8
+)
+
+var errFilter = errors.New("ldap: invalid filter")
+
+// Filter is a search filter. Extensible matches aren't supported.
+type Filter struct {
+	op        byte
+	children  []*Filter
+	attribute string
+	value     string
+	initial   string
+	any       []string
+	final     string
+}
+
+// ParseFilter parses the string representation of a filter, like
+// "(&(objectClass=person)(mail=jane@example.com))".
+func ParseFilter(s string) (*Filter, error) {
+	f, rest, err := parseFilter(s)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("%w: trailing %q", errFilter, rest)
+	}
+
+	return f, nil
+}
+
+func parseFilter(s string) (*Filter, string, error) {
+	if !strings.HasPrefix(s, "(") {
+		return nil, "", fmt.Errorf("%w: expected ( at %q", errFilter, s)
+	}
+	s = s[1:]
+
+	var f *Filter
+	var err error
+
+	switch {
+	case strings.HasPrefix(s, "&"), strings.HasPrefix(s, "|"):
+		f = &Filter{op: filterAnd}
+		if s[0] == '|' {
+			f.op = filterOr
+		}
+		s = s[1:]
+		for strings.HasPrefix(s, "(") {
+			var child *Filter
+			child, s, err = parseFilter(s)
+			if err != nil {
+				return nil, "", err
+			}
+			f.children = append(f.children, child)
+		}
+	case strings.HasPrefix(s, "!"):
+		var child *Filter
+		child, s, err = parseFilter(s[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		f = &Filter{op: filterNot, children: []*Filter{child}}
+	default:
+		end := strings.IndexByte(s, ')')
+		if end < 0 {
+			return nil, "", fmt.Errorf("%w: missing )", errFilter)
+		}
+		f, err = parseItem(s[:end])
+		if err != nil {
+			return nil, "", err
+		}
+		s = s[end:]
+	}
+
+	if !strings.HasPrefix(s, ")") {
+		return nil, "", fmt.Errorf("%w: missing )", errFilter)
+	}
+
+	return f, s[1:], nil
+}
+
+func parseItem(s string) (*Filter, error) {
+	eq := strings.IndexByte(s, '=')
+	if eq < 1 {
+		return nil, fmt.Errorf("%w: %q isn't an assertion", errFilter, s)
+	}
+
+	attribute, value := s[:eq], s[eq+1:]
+	f := &Filter{op: filterEquality, attribute: attribute}
+
+	switch attribute[len(attribute)-1] {
+	case '~':
+		f.op = filterApprox
+	case '>':
+		f.op = filterGreaterOrEqual
+	case '<':
+		f.op = filterLessOrEqual
+	}
+	if f.op != filterEquality {
+		f.attribute = attribute[:len(attribute)-1]
+	}
+	if f.attribute == "" || strings.ContainsAny(f.attribute, "()*\\ ") {
+		return nil, fmt.Errorf("%w: invalid attribute %q", errFilter, f.attribute)
+	}
+
+	if f.op == filterEquality && value == "*" {
+		f.op = filterPresent
+		return f, nil
+	}
+
+	parts := strings.Split(value, "*")
+	for i, part := range parts {
+		unescaped, err := unescapeFilterValue(part)
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = unescaped
+	}
+
+	if len(parts) == 1 {
+		f.value = parts[0]
+		return f, nil
+	}
+	if f.op != filterEquality {
+		return nil, fmt.Errorf("%w: wildcard in %q", errFilter, s)
+	}
+
+	f.op = filterSubstrings
+	f.initial = parts[0]
+	f.final = parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		if part == "" {
+			return nil, fmt.Errorf("%w: empty substring in %q", errFilter, s)
+		}
+		f.any = append(f.any, part)
+	}
+
+	return f, nil
+}
+
+func unescapeFilterValue(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		if strings.ContainsAny(s, "()") {
+			return "", fmt.Errorf("%w: unescaped parenthesis in %q", errFilter, s)
+		}
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', ')':
+			return "", fmt.Errorf("%w: unescaped parenthesis in %q", errFilter, s)
+		case '\\':
+			if i+3 > len(s) {
+				return "", fmt.Errorf("%w: truncated escape in %q", errFilter, s)
+			}
+			decoded, err := hex.DecodeString(s[i+1 : i+3])
+			if err != nil {
+				return "", fmt.Errorf("%w: invalid escape in %q", errFilter, s)
+			}
+			b.Write(decoded)
+			i += 2
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String(), nil
+}
+
+// EscapeFilter escapes the special characters of a value put in a filter.
+func EscapeFilter(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '*', '(', ')', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+// encode returns the BER encoding of the filter.
+func (f *Filter) encode() *ber.Packet {
+	switch f.op {
+	case filterAnd, filterOr, filterNot:
+		p := &ber.Packet{Tag: f.op}
+		for _, child := range f.children {
+			p.Children = append(p.Children, child.encode())
+		}
+		return p
+	case filterPresent:
+		return ber.NewString(f.op, f.attribute)
+	case filterSubstrings:
+		substrings := ber.NewConstructed(ber.TagSequence)
+		if f.initial != "" {
+			substrings.Children = append(substrings.Children, ber.NewString(ber.ClassContext|0, f.initial))
+		}
+		for _, part := range f.any {
+			substrings.Children = append(substrings.Children, ber.NewString(ber.ClassContext|1, part))
+		}
+		if f.final != "" {
+			substrings.Children = append(substrings.Children, ber.NewString(ber.ClassContext|2, f.final))
+		}
+		return &ber.Packet{Tag: f.op, Children: []*ber.Packet{ber.NewString(ber.TagOctetString, f.attribute), substrings}}
+	default:
+		return &ber.Packet{Tag: f.op, Children: []*ber.Packet{
+			ber.NewString(ber.TagOctetString, f.attribute),
+			ber.NewString(ber.TagOctetString, f.value),
+		}}
+	}
+}
+
+// DecodeFilter decodes the filter of a search request.
+func DecodeFilter(p *ber.Packet) (*Filter, error) {
+	f := &Filter{op: p.Tag}
+
+	switch p.Tag {
+	case filterAnd, filterOr, filterNot:
+		if p.Tag == filterNot && len(p.Children) != 1 {
+			return nil, errFilter
+		}
+		for _, child := range p.Children {
+			decoded, err := DecodeFilter(child)
+			if err != nil {
+				return nil, err
+			}
+			f.children = append(f.children, decoded)
+		}
+	case filterPresent:
+		f.attribute = p.String()
+	case filterEquality, filterGreaterOrEqual, filterLessOrEqual, filterApprox:
+		if len(p.Children) != 2 {
+			return nil, errFilter
+		}
+		f.attribute = p.Children[0].String()
+		f.value = p.Children[1].String()
+	case filterSubstrings:
+		if len(p.Children) != 2 {
+			return nil, errFilter
+		}
+		f.attribute = p.Children[0].String()
+		for _, part := range p.Children[1].Children {
+			switch part.Tag {
+			case ber.ClassContext | 0:
+				f.initial = part.String()
+			case ber.ClassContext | 1:
+				f.any = append(f.any, part.String())
+			case ber.ClassContext | 2:
+				f.final = part.String()
+			default:
+				return nil, errFilter
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported filter type %#x", errFilter, p.Tag)
+	}
+
+	return f, nil
+}
+
+// Match reports whether an entry with the attributes matches the filter.
+// The attribute names and values are compared ignoring case, the matching
+// rule of most of the attributes of people.
+func (f *Filter) Match(attributes map[string][]string) bool {
+	switch f.op {
+	case filterAnd:
+		for _, child := range f.children {
+			if !child.Match(attributes) {
+				return false
+			}
+		}
+		return true
+	case filterOr:
+		for _, child := range f.children {
+			if child.Match(attributes) {
+				return true
+			}
+		}
+		return false
+	case filterNot:
+		return !f.children[0].Match(attributes)
+	}
+
+	values := lookup(attributes, f.attribute)
+	if f.op == filterPresent {
+		return len(values) > 0 || strings.EqualFold(f.attribute, "objectClass")
+	}
+
+	for _, value := range values {
+		value = strings.ToLower(value)
+		asserted := strings.ToLower(f.value)
+
+		switch f.op {
+		case filterEquality, filterApprox:
+			if value == asserted {
+				return true
+			}
+		case filterGreaterOrEqual:
+			if value >= asserted {
+				return true
+			}
+		case filterLessOrEqual:
+			if value <= asserted {
+				return true
+			}
+		case filterSubstrings:
+			if matchSubstrings(value, strings.ToLower(f.initial), f.any, strings.ToLower(f.final)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func matchSubstrings(value, initial string, any []string, final string) bool {
+	if !strings.HasPrefix(value, initial) {
+		return false
+	}
+	value = value[len(initial):]
+
+	for _, part := range any {
+		i := strings.Index(value, strings.ToLower(part))
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+
+	return strings.HasSuffix(value, final)
+}
+
+func lookup(attributes map[string][]string, name string) []string {
+	for key, values := range attributes {
+		if strings.EqualFold(key, name) {
+			return values
+		}
+	}
+
+	return nil
+}
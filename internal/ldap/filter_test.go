@@ -0,0 +1,88 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestFilterMatch(t *testing.T) {
+	attributes := map[string][]string{
+		"objectClass":    {"top", "person", "inetOrgPerson"},
+		"uid":            {"jane"},
+		"cn":             {"Jane Doe"},
+		"mail":           {"Jane@Example.com"},
+		"employeeNumber": {"42"},
+	}
+
+	tests := []struct {
+		filter   string
+		expected bool
+	}{
+		{"(uid=jane)", true},
+		{"(UID=JANE)", true},
+		{"(uid=john)", false},
+		{"(mail=jane@example.com)", true},
+		{"(objectClass=*)", true},
+		{"(telephoneNumber=*)", false},
+		{"(cn=Jane*)", true},
+		{"(cn=*Doe)", true},
+		{"(cn=J*e D*e)", true},
+		{"(cn=*ohn*)", false},
+		{"(employeeNumber>=40)", true},
+		{"(employeeNumber<=40)", false},
+		{"(&(objectClass=person)(uid=jane))", true},
+		{"(&(objectClass=person)(uid=john))", false},
+		{"(|(uid=john)(mail=jane@example.com))", true},
+		{"(!(uid=jane))", false},
+		{"(&(objectClass=person)(|(uid=john)(!(cn=*smith))))", true},
+		{`(cn=Jane\20Doe)`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			filter, err := ParseFilter(tt.filter)
+			assert.NilError(t, err)
+			assert.Equal(t, filter.Match(attributes), tt.expected)
+
+			// the servers see the same filter.
+			decoded, err := DecodeFilter(filter.encode())
+			assert.NilError(t, err)
+			assert.Equal(t, decoded.Match(attributes), tt.expected)
+		})
+	}
+}
+
+func TestParseFilterInvalid(t *testing.T) {
+	for _, filter := range []string{
+		"",
+		"uid=jane",
+		"(uid=jane",
+		"(uid=jane))",
+		"(=jane)",
+		"(uid=ja(ne)",
+		`(uid=jane\4)`,
+		`(uid=jane\zz)`,
+		"(uid>=ja*ne)",
+		"(uid=ja**ne)",
+		"(&(uid=jane)",
+	} {
+		t.Run(filter, func(t *testing.T) {
+			_, err := ParseFilter(filter)
+			assert.Equal(t, err != nil, true)
+		})
+	}
+}
+
+func TestEscape(t *testing.T) {
+	value := `*)(uid=*)(\`
+
+	filter, err := ParseFilter("(mail=" + EscapeFilter(value) + ")")
+	assert.NilError(t, err)
+	assert.Equal(t, filter.Match(map[string][]string{"mail": {value}}), true)
+	assert.Equal(t, filter.Match(map[string][]string{"mail": {"jane@example.com"}}), false)
+
+	assert.Equal(t, EscapeDN(`jane,ou=admins`), `jane\,ou\=admins`)
+	assert.Equal(t, EscapeDN(` #jane `), `\ #jane\ `)
+	assert.Equal(t, EscapeDN("#jane"), `\#jane`)
+}
@@ -0,0 +1,383 @@
+// Package ldap implements the client side of the LDAPv3 operations needed
+// to authenticate users against a directory: simple binds and searches.
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/ahmadyogi543/snippetbox/internal/ldap/ber"
+)
+
+// The tags of the protocol operations.
+const (
+	opBindRequest           = ber.ClassApplication | ber.Constructed | 0
+	opBindResponse          = ber.ClassApplication | ber.Constructed | 1
+	opUnbindRequest         = ber.ClassApplication | 2
+	opSearchRequest         = ber.ClassApplication | ber.Constructed | 3
+	opSearchResultEntry     = ber.ClassApplication | ber.Constructed | 4
+	opSearchResultDone      = ber.ClassApplication | ber.Constructed | 5
+	opSearchResultReference = ber.ClassApplication | ber.Constructed | 19
+	opExtendedRequest       = ber.ClassApplication | ber.Constructed | 23
+	opExtendedResponse      = ber.ClassApplication | ber.Constructed | 24
+)
+
+// OIDStartTLS is the name of the extended operation upgrading a connection
+// to TLS.
+const OIDStartTLS = "1.3.6.1.4.1.1466.20037"
+
+const (
+	protocolVersion      = 3
+	derefAliasesNever    = 0
+	authenticationSimple = ber.ClassContext | 0
+)
+
+// The result codes the callers need to tell apart.
+const (
+	ResultSuccess            = 0
+	ResultSizeLimitExceeded  = 4
+	ResultNoSuchObject       = 32
+	ResultInvalidCredentials = 49
+)
+
+// The scopes of a search.
+const (
+	ScopeBaseObject   = 0
+	ScopeSingleLevel  = 1
+	ScopeWholeSubtree = 2
+)
+
+var errProtocol = errors.New("ldap: unexpected response")
+
+// Error is an operation the server didn't complete.
+type Error struct {
+	ResultCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ldap: result code %d", e.ResultCode)
+	}
+
+	return fmt.Sprintf("ldap: result code %d: %s", e.ResultCode, e.Message)
+}
+
+// IsResult reports whether err is an Error with the result code.
+func IsResult(err error, code int) bool {
+	var ldapErr *Error
+	return errors.As(err, &ldapErr) && ldapErr.ResultCode == code
+}
+
+// Entry is an entry returned by a search. The attribute names are the ones
+// the server returned, use Get to look them up ignoring case.
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Get returns the first value of the attribute, or an empty string.
+func (e *Entry) Get(name string) string {
+	values := lookup(e.Attributes, name)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+// SearchRequest is a search for the entries matching Filter under BaseDN.
+// SizeLimit is the maximum number of entries returned, zero for no limit.
+type SearchRequest struct {
+	BaseDN     string
+	Scope      int
+	Filter     string
+	Attributes []string
+	SizeLimit  int
+}
+
+// Conn is a connection to a directory, not safe for concurrent use.
+type Conn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	nextID int64
+}
+
+// Dial connects to the directory at rawURL, either ldap:// or ldaps://. The
+// ldap:// connections are upgraded with StartTLS before anything else is
+// sent, so the passwords of the binds are never in cleartext. The deadline
+// of ctx applies to the operations on the connection too.
+func Dial(ctx context.Context, rawURL string, tlsConfig *tls.Config) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var port string
+	switch u.Scheme {
+	case "ldap":
+		port = "389"
+	case "ldaps":
+		port = "636"
+	default:
+		return nil, fmt.Errorf("ldap: unsupported URL scheme %q", u.Scheme)
+	}
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	config := &tls.Config{}
+	if tlsConfig != nil {
+		config = tlsConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = u.Hostname()
+	}
+
+	c := &Conn{conn: conn, r: bufio.NewReader(conn)}
+	if u.Scheme == "ldap" {
+		err = c.startTLS()
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap: StartTLS: %w", err)
+		}
+	}
+
+	tlsConn := tls.Client(conn, config)
+	err = tlsConn.HandshakeContext(ctx)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.conn = tlsConn
+	c.r = bufio.NewReader(tlsConn)
+
+	return c, nil
+}
+
+// startTLS asks the server to start TLS on the connection, the handshake
+// follows the response.
+func (c *Conn) startTLS() error {
+	id, err := c.send(ber.NewConstructed(opExtendedRequest,
+		ber.NewString(ber.ClassContext|0, OIDStartTLS),
+	))
+	if err != nil {
+		return err
+	}
+
+	op, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if op.Tag != opExtendedResponse {
+		return errProtocol
+	}
+	// the server can't have sent anything else before the handshake.
+	if c.r.Buffered() > 0 {
+		return errProtocol
+	}
+
+	return result(op)
+}
+
+// Close unbinds and closes the connection.
+func (c *Conn) Close() error {
+	c.send(&ber.Packet{Tag: opUnbindRequest})
+
+	return c.conn.Close()
+}
+
+// Bind authenticates the connection as the entry with the password. An
+// empty password is refused, the servers take it for an anonymous bind and
+// succeed without checking anything.
+func (c *Conn) Bind(dn, password string) error {
+	if password == "" {
+		return &Error{ResultCode: ResultInvalidCredentials, Message: "empty password"}
+	}
+
+	id, err := c.send(ber.NewConstructed(opBindRequest,
+		ber.NewInteger(ber.TagInteger, protocolVersion),
+		ber.NewString(ber.TagOctetString, dn),
+		ber.NewString(authenticationSimple, password),
+	))
+	if err != nil {
+		return err
+	}
+
+	op, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if op.Tag != opBindResponse {
+		return errProtocol
+	}
+
+	return result(op)
+}
+
+// Search returns the entries matching the request. When the size limit is
+// exceeded, it returns the entries it got with an Error.
+func (c *Conn) Search(req *SearchRequest) ([]*Entry, error) {
+	filter, err := ParseFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := ber.NewConstructed(ber.TagSequence)
+	for _, attribute := range req.Attributes {
+		attributes.Children = append(attributes.Children, ber.NewString(ber.TagOctetString, attribute))
+	}
+
+	id, err := c.send(ber.NewConstructed(opSearchRequest,
+		ber.NewString(ber.TagOctetString, req.BaseDN),
+		ber.NewInteger(ber.TagEnumerated, int64(req.Scope)),
+		ber.NewInteger(ber.TagEnumerated, derefAliasesNever),
+		ber.NewInteger(ber.TagInteger, int64(req.SizeLimit)),
+		ber.NewInteger(ber.TagInteger, 0),
+		ber.NewBoolean(ber.TagBoolean, false),
+		filter.encode(),
+		attributes,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for {
+		op, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op.Tag {
+		case opSearchResultEntry:
+			entry, err := parseEntry(op)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case opSearchResultReference:
+			// the referrals to other servers aren't followed.
+		case opSearchResultDone:
+			return entries, result(op)
+		default:
+			return nil, errProtocol
+		}
+	}
+}
+
+// send writes the message with the operation and returns its ID.
+func (c *Conn) send(op *ber.Packet) (int64, error) {
+	c.nextID++
+
+	message := ber.NewConstructed(ber.TagSequence, ber.NewInteger(ber.TagInteger, c.nextID), op)
+	_, err := c.conn.Write(message.Bytes())
+
+	return c.nextID, err
+}
+
+// receive reads the next message, which must answer the request with the
+// ID, and returns its operation.
+func (c *Conn) receive(id int64) (*ber.Packet, error) {
+	message, err := ber.Read(c.r)
+	if err != nil {
+		return nil, err
+	}
+	if message.Tag != ber.TagSequence || len(message.Children) < 2 {
+		return nil, errProtocol
+	}
+
+	gotID, err := message.Children[0].Int()
+	if err != nil {
+		return nil, errProtocol
+	}
+
+	op := message.Children[1]
+	if gotID == 0 && op.Tag == opExtendedResponse {
+		// a notice of disconnection.
+		if err := result(op); err != nil {
+			return nil, err
+		}
+		return nil, errProtocol
+	}
+	if gotID != id {
+		return nil, errProtocol
+	}
+
+	return op, nil
+}
+
+// result returns the Error of the LDAPResult of the operation, nil when it
+// succeeded.
+func result(op *ber.Packet) error {
+	if len(op.Children) < 3 {
+		return errProtocol
+	}
+
+	code, err := op.Children[0].Int()
+	if err != nil {
+		return errProtocol
+	}
+	if code == ResultSuccess {
+		return nil
+	}
+
+	return &Error{ResultCode: int(code), Message: op.Children[2].String()}
+}
+
+func parseEntry(op *ber.Packet) (*Entry, error) {
+	if len(op.Children) != 2 {
+		return nil, errProtocol
+	}
+
+	entry := &Entry{DN: op.Children[0].String(), Attributes: map[string][]string{}}
+	for _, attribute := range op.Children[1].Children {
+		if len(attribute.Children) != 2 {
+			return nil, errProtocol
+		}
+
+		name := attribute.Children[0].String()
+		for _, value := range attribute.Children[1].Children {
+			entry.Attributes[name] = append(entry.Attributes[name], value.String())
+		}
+	}
+
+	return entry, nil
+}
+
+// EscapeDN escapes the special characters of a value put in a DN.
+func EscapeDN(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == 0:
+			b.WriteString("\\00")
+		case strings.IndexByte(`\,+"<>;=`, c) >= 0,
+			i == 0 && (c == ' ' || c == '#'),
+			i == len(s)-1 && c == ' ':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,120 @@
+package ldap_test
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/ldap"
+	"github.com/ahmadyogi543/snippetbox/internal/ldap/ldaptest"
+)
+
+func newServer(t *testing.T) *ldaptest.Server {
+	server := ldaptest.NewServer(
+		ldaptest.Entry{DN: "ou=people,dc=example,dc=com"},
+		ldaptest.Entry{
+			DN:       "uid=jane,ou=people,dc=example,dc=com",
+			Password: "pa55word",
+			Attributes: map[string][]string{
+				"objectClass": {"inetOrgPerson"},
+				"uid":         {"jane"},
+				"cn":          {"Jane Doe"},
+				"mail":        {"jane@example.com"},
+			},
+		},
+		ldaptest.Entry{
+			DN: "uid=john,ou=people,dc=example,dc=com",
+			Attributes: map[string][]string{
+				"objectClass": {"inetOrgPerson"},
+				"uid":         {"john"},
+				"cn":          {"John Doe"},
+			},
+		},
+	)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func dial(t *testing.T, server *ldaptest.Server) *ldap.Conn {
+	conn, err := ldap.Dial(context.Background(), server.URL(), server.ClientTLSConfig())
+	assert.NilError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func TestBind(t *testing.T) {
+	server := newServer(t)
+	conn := dial(t, server)
+
+	err := conn.Bind("uid=jane,ou=people,dc=example,dc=com", "wrong password")
+	assert.Equal(t, ldap.IsResult(err, ldap.ResultInvalidCredentials), true)
+
+	err = conn.Bind("uid=nobody,ou=people,dc=example,dc=com", "pa55word")
+	assert.Equal(t, ldap.IsResult(err, ldap.ResultInvalidCredentials), true)
+
+	// the empty passwords never reach the server.
+	err = conn.Bind("uid=jane,ou=people,dc=example,dc=com", "")
+	assert.Equal(t, ldap.IsResult(err, ldap.ResultInvalidCredentials), true)
+
+	assert.NilError(t, conn.Bind("uid=jane,ou=people,dc=example,dc=com", "pa55word"))
+	assert.Equal(t, len(server.Binds()), 1)
+}
+
+func TestSearch(t *testing.T) {
+	conn := dial(t, newServer(t))
+
+	entries, err := conn.Search(&ldap.SearchRequest{
+		BaseDN:     "dc=example,dc=com",
+		Scope:      ldap.ScopeWholeSubtree,
+		Filter:     "(&(objectClass=inetOrgPerson)(mail=JANE@example.com))",
+		Attributes: []string{"cn", "mail"},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, len(entries), 1)
+	assert.Equal(t, entries[0].DN, "uid=jane,ou=people,dc=example,dc=com")
+	assert.Equal(t, entries[0].Get("CN"), "Jane Doe")
+	assert.Equal(t, entries[0].Get("mail"), "jane@example.com")
+	assert.Equal(t, entries[0].Get("uid"), "")
+
+	entries, err = conn.Search(&ldap.SearchRequest{
+		BaseDN: "uid=john,ou=people,dc=example,dc=com",
+		Scope:  ldap.ScopeBaseObject,
+		Filter: "(objectClass=*)",
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, len(entries), 1)
+	assert.Equal(t, entries[0].Get("cn"), "John Doe")
+
+	entries, err = conn.Search(&ldap.SearchRequest{
+		BaseDN:    "dc=example,dc=com",
+		Scope:     ldap.ScopeWholeSubtree,
+		Filter:    "(objectClass=inetOrgPerson)",
+		SizeLimit: 1,
+	})
+	assert.Equal(t, ldap.IsResult(err, ldap.ResultSizeLimitExceeded), true)
+	assert.Equal(t, len(entries), 1)
+
+	_, err = conn.Search(&ldap.SearchRequest{
+		BaseDN: "uid=nobody,ou=people,dc=example,dc=com",
+		Scope:  ldap.ScopeBaseObject,
+		Filter: "(objectClass=*)",
+	})
+	assert.Equal(t, ldap.IsResult(err, ldap.ResultNoSuchObject), true)
+}
+
+func TestDialStartTLS(t *testing.T) {
+	server := newServer(t)
+
+	// the connection is upgraded, so the certificate of the directory must
+	// be trusted.
+	_, err := ldap.Dial(context.Background(), server.URL(), nil)
+	var unknownAuthority x509.UnknownAuthorityError
+	assert.Equal(t, errors.As(err, &unknownAuthority), true)
+
+	conn := dial(t, server)
+	assert.NilError(t, conn.Bind("uid=jane,ou=people,dc=example,dc=com", "pa55word"))
+}
@@ -0,0 +1,340 @@
+// Package ldaptest provides an in-process LDAP directory for tests. It
+// answers StartTLS, simple binds and searches over a fixed list of entries.
+package ldaptest
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/ldap"
+	"github.com/ahmadyogi543/snippetbox/internal/ldap/ber"
+)
+
+const (
+	opBindRequest       = ber.ClassApplication | ber.Constructed | 0
+	opBindResponse      = ber.ClassApplication | ber.Constructed | 1
+	opUnbindRequest     = ber.ClassApplication | 2
+	opSearchRequest     = ber.ClassApplication | ber.Constructed | 3
+	opSearchResultEntry = ber.ClassApplication | ber.Constructed | 4
+	opSearchResultDone  = ber.ClassApplication | ber.Constructed | 5
+	opExtendedRequest   = ber.ClassApplication | ber.Constructed | 23
+	opExtendedResponse  = ber.ClassApplication | ber.Constructed | 24
+
+	resultUnwillingToPerform      = 53
+	resultProtocolError           = 2
+	resultConfidentialityRequired = 13
+)
+
+// Entry is an entry of the directory. Binding as it needs the password.
+type Entry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// Server is a running directory. The entries can't be changed once it's
+// started. Like the directories configured to, it refuses the binds before
+// StartTLS.
+type Server struct {
+	entries     []Entry
+	listener    net.Listener
+	certificate tls.Certificate
+	wg          sync.WaitGroup
+
+	mu    sync.Mutex
+	binds []string
+}
+
+// NewServer starts a directory with the entries on a local port. Close it
+// when done.
+func NewServer(entries ...Entry) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	certificate, err := newCertificate()
+	if err != nil {
+		listener.Close()
+		panic(err)
+	}
+
+	s := &Server{entries: entries, listener: listener, certificate: certificate}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn)
+			}()
+		}
+	}()
+
+	return s
+}
+
+// URL is the ldap:// URL of the directory.
+func (s *Server) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+// ClientTLSConfig returns a TLS configuration trusting the certificate of
+// the directory, to dial it with.
+func (s *Server) ClientTLSConfig() *tls.Config {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.certificate.Leaf)
+
+	return &tls.Config{RootCAs: pool}
+}
+
+// Close stops the directory, once the connections are closed by the
+// clients.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+// Binds returns the DNs of the successful binds so far.
+func (s *Server) Binds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.binds...)
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+
+	r := bufio.NewReader(conn)
+	secure := false
+	for {
+		message, err := ber.Read(r)
+		if err != nil {
+			return
+		}
+		if message.Tag != ber.TagSequence || len(message.Children) < 2 {
+			return
+		}
+		id, err := message.Children[0].Int()
+		if err != nil {
+			return
+		}
+
+		op := message.Children[1]
+		var responses []*ber.Packet
+		startTLS := false
+
+		switch op.Tag {
+		case opBindRequest:
+			if secure {
+				responses = []*ber.Packet{s.bind(op)}
+			} else {
+				responses = []*ber.Packet{ldapResult(opBindResponse, resultConfidentialityRequired, "binds need StartTLS")}
+			}
+		case opSearchRequest:
+			responses = s.search(op)
+		case opExtendedRequest:
+			if secure || len(op.Children) == 0 || op.Children[0].String() != ldap.OIDStartTLS {
+				responses = []*ber.Packet{ldapResult(opExtendedResponse, resultProtocolError, "unsupported extended operation")}
+			} else {
+				responses = []*ber.Packet{ldapResult(opExtendedResponse, ldap.ResultSuccess, "")}
+				startTLS = true
+			}
+		case opUnbindRequest:
+			return
+		default:
+			return
+		}
+
+		for _, response := range responses {
+			_, err = conn.Write(ber.NewConstructed(ber.TagSequence, ber.NewInteger(ber.TagInteger, id), response).Bytes())
+			if err != nil {
+				return
+			}
+		}
+
+		if startTLS {
+			tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{s.certificate}})
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, r, secure = tlsConn, bufio.NewReader(tlsConn), true
+		}
+	}
+}
+
+func (s *Server) bind(op *ber.Packet) *ber.Packet {
+	if len(op.Children) != 3 || op.Children[2].Tag != ber.ClassContext|0 {
+		return ldapResult(opBindResponse, resultProtocolError, "only simple binds are supported")
+	}
+
+	dn, password := op.Children[1].String(), op.Children[2].String()
+	if password == "" {
+		return ldapResult(opBindResponse, resultUnwillingToPerform, "unauthenticated binds are disabled")
+	}
+
+	entry, ok := s.find(dn)
+	if !ok || entry.Password == "" || entry.Password != password {
+		return ldapResult(opBindResponse, ldap.ResultInvalidCredentials, "")
+	}
+
+	s.mu.Lock()
+	s.binds = append(s.binds, entry.DN)
+	s.mu.Unlock()
+
+	return ldapResult(opBindResponse, ldap.ResultSuccess, "")
+}
+
+func (s *Server) search(op *ber.Packet) []*ber.Packet {
+	if len(op.Children) != 8 {
+		return []*ber.Packet{ldapResult(opSearchResultDone, resultProtocolError, "malformed search")}
+	}
+
+	baseDN := normalize(op.Children[0].String())
+	scope, err1 := op.Children[1].Int()
+	sizeLimit, err2 := op.Children[3].Int()
+	filter, err3 := ldap.DecodeFilter(op.Children[6])
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return []*ber.Packet{ldapResult(opSearchResultDone, resultProtocolError, err.Error())}
+	}
+
+	var requested []string
+	for _, attribute := range op.Children[7].Children {
+		requested = append(requested, attribute.String())
+	}
+
+	if scope == ldap.ScopeBaseObject {
+		if _, ok := s.find(baseDN); !ok {
+			return []*ber.Packet{ldapResult(opSearchResultDone, ldap.ResultNoSuchObject, "")}
+		}
+	}
+
+	var responses []*ber.Packet
+	for _, entry := range s.entries {
+		dn := normalize(entry.DN)
+
+		inScope := false
+		switch scope {
+		case ldap.ScopeBaseObject:
+			inScope = dn == baseDN
+		case ldap.ScopeSingleLevel:
+			_, parent, _ := strings.Cut(dn, ",")
+			inScope = parent == baseDN
+		default:
+			inScope = dn == baseDN || strings.HasSuffix(dn, ","+baseDN)
+		}
+		if !inScope || !filter.Match(entry.Attributes) {
+			continue
+		}
+
+		if sizeLimit > 0 && int64(len(responses)) == sizeLimit {
+			return append(responses, ldapResult(opSearchResultDone, ldap.ResultSizeLimitExceeded, ""))
+		}
+		responses = append(responses, searchResultEntry(entry, requested))
+	}
+
+	return append(responses, ldapResult(opSearchResultDone, ldap.ResultSuccess, ""))
+}
+
+func (s *Server) find(dn string) (Entry, bool) {
+	for _, entry := range s.entries {
+		if normalize(entry.DN) == normalize(dn) {
+			return entry, true
+		}
+	}
+
+	return Entry{}, false
+}
+
+// newCertificate returns a self-signed certificate for 127.0.0.1.
+func newCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ldaptest"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+func searchResultEntry(entry Entry, requested []string) *ber.Packet {
+	attributes := ber.NewConstructed(ber.TagSequence)
+	for name, values := range entry.Attributes {
+		if len(requested) > 0 && !containsFold(requested, name) {
+			continue
+		}
+
+		set := ber.NewConstructed(ber.TagSet)
+		for _, value := range values {
+			set.Children = append(set.Children, ber.NewString(ber.TagOctetString, value))
+		}
+		attributes.Children = append(attributes.Children, ber.NewConstructed(ber.TagSequence, ber.NewString(ber.TagOctetString, name), set))
+	}
+
+	return ber.NewConstructed(opSearchResultEntry, ber.NewString(ber.TagOctetString, entry.DN), attributes)
+}
+
+func ldapResult(tag byte, code int64, message string) *ber.Packet {
+	return ber.NewConstructed(tag,
+		ber.NewInteger(ber.TagEnumerated, code),
+		ber.NewString(ber.TagOctetString, ""),
+		ber.NewString(ber.TagOctetString, message),
+	)
+}
+
+// normalize lower-cases the DN and drops the spaces around its separators,
+// enough to compare the DNs of the tests.
+func normalize(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, part := range parts {
+		parts[i] = strings.ToLower(strings.TrimSpace(part))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// FileSender writes the messages to a maildir in Dir for development, most
+// mail clients can open it. Dir and its tmp, new and cur directories are
+// created when needed.
+type FileSender struct {
+	Dir  string
+	From string
+}
+
+var fileSenderCount atomic.Int64
+
+func (s *FileSender) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+
+	data, err := msg.Bytes(s.From, now)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range []string{"tmp", "new", "cur"} {
+		err = os.MkdirAll(filepath.Join(s.Dir, dir), 0o700)
+		if err != nil {
+			return err
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+
+	// the file is written to tmp and moved to new once it's complete, so a
+	// mail client never reads half a message.
+	name := fmt.Sprintf("%d.%d_%d.%s", now.Unix(), os.Getpid(), fileSenderCount.Add(1), hostname)
+	tmpPath := filepath.Join(s.Dir, "tmp", name)
+
+	err = os.WriteFile(tmpPath, data, 0o600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, filepath.Join(s.Dir, "new", name))
+}
@@ -0,0 +1,32 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestFileSender(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	sender := &FileSender{Dir: dir, From: "no-reply@snippetbox.sh"}
+
+	for i := 0; i < 2; i++ {
+		err := sender.Send(context.Background(), Message{To: "jane@snippetbox.sh", Subject: "Hello", Text: "Hi Jane,\n"})
+		assert.NilError(t, err)
+	}
+
+	files, err := os.ReadDir(filepath.Join(dir, "new"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 2)
+
+	tmpFiles, err := os.ReadDir(filepath.Join(dir, "tmp"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(tmpFiles), 0)
+
+	content, err := os.ReadFile(filepath.Join(dir, "new", files[0].Name()))
+	assert.NilError(t, err)
+	assert.StringContains(t, string(content), "To: <jane@snippetbox.sh>\r\n")
+}
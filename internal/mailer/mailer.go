@@ -0,0 +1,79 @@
+// Package mailer sends the emails of the application, like the notices about
+// the accounts. The emails are rendered from the templates embedded in the
+// package and delivered by a Sender chosen at startup.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	htmltemplate "html/template"
+	"log/slog"
+	"text/template"
+)
+
+//go:embed "templates"
+var templateFS embed.FS
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// NewMessage renders the templates/<templateFile> file with data. The file
+// defines the "subject", "plainBody" and "htmlBody" templates, the last one
+// is escaped as HTML.
+func NewMessage(to, templateFile string, data any) (Message, error) {
+	msg := Message{To: to}
+
+	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return Message{}, err
+	}
+
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return Message{}, err
+	}
+	msg.Subject = subject.String()
+
+	plainBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return Message{}, err
+	}
+	msg.Text = plainBody.String()
+
+	htmlTmpl, err := htmltemplate.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return Message{}, err
+	}
+
+	htmlBody := new(bytes.Buffer)
+	err = htmlTmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return Message{}, err
+	}
+	msg.HTML = htmlBody.String()
+
+	return msg, nil
+}
+
+// Sender delivers messages. Send returns once the message is handed over,
+// not when it's delivered.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogSender logs the messages instead of sending them, for development.
+type LogSender struct {
+	Logger *slog.Logger
+}
+
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	s.Logger.InfoContext(ctx, "email", "to", msg.To, "subject", msg.Subject, "text", msg.Text)
+	return nil
+}
@@ -0,0 +1,85 @@
+package mailer
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestNewMessage(t *testing.T) {
+	msg, err := NewMessage("jane@snippetbox.sh", "verification.tmpl", map[string]any{
+		"Name":   "Jane <Doe>",
+		"URL":    "https://snippetbox.sh/user/verify/TOKEN",
+		"Expiry": "01 Jan 2024 at 10:00 UTC",
+	})
+	assert.NilError(t, err)
+
+	assert.Equal(t, msg.To, "jane@snippetbox.sh")
+	assert.Equal(t, msg.Subject, "Verify your Snippetbox email address")
+	assert.StringContains(t, msg.Text, "Hi Jane <Doe>,")
+	assert.StringContains(t, msg.Text, "https://snippetbox.sh/user/verify/TOKEN")
+	assert.StringContains(t, msg.HTML, "Hi Jane &lt;Doe&gt;,")
+	assert.StringContains(t, msg.HTML, `<a href="https://snippetbox.sh/user/verify/TOKEN">`)
+
+	_, err = NewMessage("jane@snippetbox.sh", "missing.tmpl", nil)
+	if err == nil {
+		t.Error("expected an error; got nil instead")
+	}
+}
+
+func TestMessageBytes(t *testing.T) {
+	msg := Message{
+		To:      "Jane Doe <jane@snippetbox.sh>",
+		Subject: "Héllo\r\nBcc: someone@example.com",
+		Text:    "Hi Jane,\n\nA line long enough to be wrapped by the quoted-printable encoding, which limits the lines to 76 characters.\n",
+		HTML:    "<p>Hi Jane,</p>",
+	}
+
+	data, err := msg.Bytes("Snippetbox <no-reply@snippetbox.sh>", time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+	assert.NilError(t, err)
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	assert.NilError(t, err)
+
+	assert.Equal(t, parsed.Header.Get("From"), `"Snippetbox" <no-reply@snippetbox.sh>`)
+	assert.Equal(t, parsed.Header.Get("To"), `"Jane Doe" <jane@snippetbox.sh>`)
+	assert.Equal(t, parsed.Header.Get("Date"), "Mon, 01 Jan 2024 10:00:00 +0000")
+	assert.Equal(t, parsed.Header.Get("Bcc"), "")
+	assert.StringContains(t, parsed.Header.Get("Message-ID"), "@snippetbox.sh>")
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	assert.NilError(t, err)
+	assert.Equal(t, subject, msg.Subject)
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	assert.NilError(t, err)
+	assert.Equal(t, mediaType, "multipart/alternative")
+
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, expected := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", msg.Text},
+		{"text/html; charset=UTF-8", msg.HTML},
+	} {
+		part, err := parts.NextRawPart()
+		assert.NilError(t, err)
+		assert.Equal(t, part.Header.Get("Content-Type"), expected.contentType)
+
+		content, err := io.ReadAll(quotedprintable.NewReader(part))
+		assert.NilError(t, err)
+		// the lines end with CRLF in the email.
+		assert.Equal(t, strings.ReplaceAll(string(content), "\r\n", "\n"), expected.content)
+	}
+
+	_, err = Message{To: "not an address"}.Bytes("no-reply@snippetbox.sh", time.Now())
+	if err == nil || !strings.Contains(err.Error(), "invalid to address") {
+		t.Errorf("got: %v; want: an invalid to address error", err)
+	}
+}
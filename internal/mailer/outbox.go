@@ -0,0 +1,159 @@
+package mailer
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// outboxBatchSize is how many messages a Flush sends at most.
+const outboxBatchSize = 50
+
+// DefaultMaxAttempts is how many times an Outbox tries to send a message
+// when MaxAttempts isn't set.
+const DefaultMaxAttempts = 8
+
+// The delay before trying to send a message again doubles after every
+// failure, between these two.
+const (
+	minRetryDelay = time.Minute
+	maxRetryDelay = time.Hour
+)
+
+// QueuedMessage is a message in an OutboxStore.
+type QueuedMessage struct {
+	ID       int
+	Message  Message
+	Attempts int
+}
+
+// OutboxStore keeps the messages until they're sent. Reschedule counts a
+// failed attempt, and a zero next means the Outbox gave up on the message.
+type OutboxStore interface {
+	Insert(ctx context.Context, msg Message) error
+	Due(ctx context.Context, now time.Time, limit int) ([]*QueuedMessage, error)
+	Delete(ctx context.Context, id int) error
+	Reschedule(ctx context.Context, id int, next time.Time, lastError string) error
+}
+
+// Outbox is a Sender that stores the messages and sends them through Sender
+// from Run, trying again later when it fails. A message is sent again if
+// deleting it from the store fails, so the messages are sent at least once.
+type Outbox struct {
+	Store       OutboxStore
+	Sender      Sender
+	MaxAttempts int
+	Logger      *slog.Logger
+
+	once sync.Once
+	wake chan struct{}
+}
+
+// Send stores msg and wakes Run up to send it. When the store fails, msg is
+// sent right away, it's only not tried again.
+func (o *Outbox) Send(ctx context.Context, msg Message) error {
+	err := o.Store.Insert(ctx, msg)
+	if err != nil {
+		o.Logger.ErrorContext(ctx, "queuing email, sending it directly", "error", err, "subject", msg.Subject)
+		return o.Sender.Send(ctx, msg)
+	}
+
+	select {
+	case o.wakeChan() <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Run sends the messages as they're queued, and every interval the ones due
+// to be tried again, until ctx is canceled.
+func (o *Outbox) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := o.Flush(ctx)
+		if err != nil && ctx.Err() == nil {
+			o.Logger.Error("sending queued emails", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-o.wakeChan():
+		}
+	}
+}
+
+// Flush tries to send the messages that are due and returns how many were
+// sent. The failures are recorded in the store, the returned error is about
+// the store.
+func (o *Outbox) Flush(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+
+	due, err := o.Store.Due(ctx, now, outboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, queued := range due {
+		err = o.Sender.Send(ctx, queued.Message)
+		if err == nil {
+			sent++
+			err = o.Store.Delete(ctx, queued.ID)
+			if err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		attempts := queued.Attempts + 1
+		next := time.Time{}
+		if attempts < o.maxAttempts() {
+			next = now.Add(retryDelay(attempts))
+			o.Logger.Warn("sending email failed, trying again later", "error", err, "id", queued.ID, "attempts", attempts, "next_attempt", next)
+		} else {
+			o.Logger.Error("sending email failed, giving up", "error", err, "id", queued.ID, "attempts", attempts)
+		}
+
+		err = o.Store.Reschedule(ctx, queued.ID, next, err.Error())
+		if err != nil {
+			return sent, err
+		}
+	}
+
+	return sent, nil
+}
+
+func (o *Outbox) wakeChan() chan struct{} {
+	o.once.Do(func() {
+		o.wake = make(chan struct{}, 1)
+	})
+
+	return o.wake
+}
+
+func (o *Outbox) maxAttempts() int {
+	if o.MaxAttempts <= 0 {
+		return DefaultMaxAttempts
+	}
+
+	return o.MaxAttempts
+}
+
+// retryDelay returns how long to wait after the attempts-th failure.
+func retryDelay(attempts int) time.Duration {
+	d := minRetryDelay
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+
+	return d
+}
@@ -0,0 +1,133 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+// testStore is an OutboxStore keeping the messages in a slice.
+type testStore struct {
+	messages   []*QueuedMessage
+	next       map[int]time.Time
+	insertErr  error
+	lastErrors map[int]string
+}
+
+func (s *testStore) Insert(ctx context.Context, msg Message) error {
+	if s.insertErr != nil {
+		return s.insertErr
+	}
+
+	id := len(s.messages) + 1
+	s.messages = append(s.messages, &QueuedMessage{ID: id, Message: msg})
+	s.next[id] = time.Now().UTC()
+
+	return nil
+}
+
+func (s *testStore) Due(ctx context.Context, now time.Time, limit int) ([]*QueuedMessage, error) {
+	var due []*QueuedMessage
+	for _, m := range s.messages {
+		if next, ok := s.next[m.ID]; ok && !next.IsZero() && !next.After(now) {
+			queued := *m
+			due = append(due, &queued)
+		}
+	}
+
+	return due, nil
+}
+
+func (s *testStore) Delete(ctx context.Context, id int) error {
+	delete(s.next, id)
+	return nil
+}
+
+func (s *testStore) Reschedule(ctx context.Context, id int, next time.Time, lastError string) error {
+	s.messages[id-1].Attempts++
+	s.next[id] = next
+	s.lastErrors[id] = lastError
+	return nil
+}
+
+// flakySender fails the first failures sends.
+type flakySender struct {
+	failures int
+	sent     []Message
+}
+
+func (s *flakySender) Send(ctx context.Context, msg Message) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("connection refused")
+	}
+
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestOutbox(t *testing.T) {
+	ctx := context.Background()
+	store := &testStore{next: map[int]time.Time{}, lastErrors: map[int]string{}}
+	sender := &flakySender{failures: 1}
+	outbox := &Outbox{
+		Store:       store,
+		Sender:      sender,
+		MaxAttempts: 2,
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	err := outbox.Send(ctx, Message{To: "jane@snippetbox.sh", Subject: "Hello"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(sender.sent), 0)
+
+	// the first attempt fails and is tried again later.
+	sent, err := outbox.Flush(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, sent, 0)
+	assert.Equal(t, store.messages[0].Attempts, 1)
+	assert.Equal(t, store.lastErrors[1], "connection refused")
+	assert.Equal(t, store.next[1].After(time.Now()), true)
+
+	store.next[1] = time.Now().UTC()
+	sent, err = outbox.Flush(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, sent, 1)
+	assert.Equal(t, len(sender.sent), 1)
+	_, queued := store.next[1]
+	assert.Equal(t, queued, false)
+
+	t.Run("Giving Up", func(t *testing.T) {
+		sender.failures = 2
+		assert.NilError(t, outbox.Send(ctx, Message{To: "john@snippetbox.sh"}))
+
+		for i := 0; i < 2; i++ {
+			store.next[2] = time.Now().UTC()
+			_, err = outbox.Flush(ctx)
+			assert.NilError(t, err)
+		}
+
+		assert.Equal(t, store.next[2].IsZero(), true)
+		assert.Equal(t, store.messages[1].Attempts, 2)
+	})
+
+	t.Run("Store Failing", func(t *testing.T) {
+		store.insertErr = errors.New("database is locked")
+
+		err := outbox.Send(ctx, Message{To: "jim@snippetbox.sh"})
+		assert.NilError(t, err)
+		assert.Equal(t, sender.sent[len(sender.sent)-1].To, "jim@snippetbox.sh")
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, retryDelay(1), time.Minute)
+	assert.Equal(t, retryDelay(2), 2*time.Minute)
+	assert.Equal(t, retryDelay(4), 8*time.Minute)
+	assert.Equal(t, retryDelay(10), time.Hour)
+}
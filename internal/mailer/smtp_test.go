@@ -0,0 +1,115 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+// smtpStandIn accepts one message over SMTP, it only speaks enough of the
+// protocol for net/smtp.
+type smtpStandIn struct {
+	listener net.Listener
+	commands []string
+	data     string
+	done     chan struct{}
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	s := &smtpStandIn{listener: listener, done: make(chan struct{})}
+	go s.serve()
+
+	return s
+}
+
+func (s *smtpStandIn) serve() {
+	defer close(s.done)
+
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimSpace(line)
+		s.commands = append(s.commands, command)
+
+		switch verb := strings.ToUpper(strings.Fields(command)[0]); verb {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case "MAIL", "RCPT", "RSET", "NOOP":
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.data = data.String()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSMTPSender(t *testing.T) {
+	standIn := newSMTPStandIn(t)
+	_, port, _ := net.SplitHostPort(standIn.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	sender := &SMTPSender{
+		Host: "127.0.0.1",
+		Port: portNumber,
+		From: "Snippetbox <no-reply@snippetbox.sh>",
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := sender.Send(ctx, Message{To: "jane@snippetbox.sh", Subject: "Hello", Text: "Hi Jane,\n"})
+	assert.NilError(t, err)
+	<-standIn.done
+
+	assert.StringContains(t, strings.Join(standIn.commands, "\n"), "MAIL FROM:<no-reply@snippetbox.sh>")
+	assert.StringContains(t, strings.Join(standIn.commands, "\n"), "RCPT TO:<jane@snippetbox.sh>")
+	assert.StringContains(t, standIn.data, "Subject: Hello\r\n")
+	assert.StringContains(t, standIn.data, "Hi Jane,")
+}
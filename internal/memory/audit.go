@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+// AuditEventModel is an in-memory models.AuditEventModelInterface. The zero
+// value is ready to use and safe for concurrent use.
+type AuditEventModel struct {
+	mu     sync.RWMutex
+	events []models.AuditEvent
+}
+
+func (am *AuditEventModel) Insert(ctx context.Context, event *models.AuditEvent) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	inserted := *event
+	inserted.ID = len(am.events) + 1
+	inserted.Created = time.Now().UTC()
+	am.events = append(am.events, inserted)
+
+	return nil
+}
+
+func (am *AuditEventModel) List(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEvent, error) {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+
+	events := []*models.AuditEvent{}
+	for i := len(am.events) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(events) == filter.Limit {
+			break
+		}
+
+		event := am.events[i]
+		if matchAuditFilter(&event, filter) {
+			events = append(events, &event)
+		}
+	}
+
+	return events, nil
+}
+
+func matchAuditFilter(event *models.AuditEvent, filter models.AuditFilter) bool {
+	switch {
+	case filter.Type != "" && event.Type != filter.Type:
+		return false
+	case filter.UserID != 0 && event.ActorID != filter.UserID &&
+		(event.TargetType != models.TargetUser || event.TargetID != filter.UserID):
+		return false
+	case filter.IP != "" && event.IP != filter.IP:
+		return false
+	case !filter.Since.IsZero() && event.Created.Before(filter.Since):
+		return false
+	case !filter.Until.IsZero() && !event.Created.Before(filter.Until):
+		return false
+	case filter.BeforeID != 0 && event.ID >= filter.BeforeID:
+		return false
+	}
+
+	return true
+}
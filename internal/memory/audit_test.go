@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+func TestAuditEventModel(t *testing.T) {
+	am := AuditEventModel{}
+	ctx := context.Background()
+
+	events := []*models.AuditEvent{
+		{Type: models.EventLoginFailed, TargetType: models.TargetUser, TargetID: 1, IP: "192.0.2.1"},
+		{Type: models.EventLogin, ActorID: 1, TargetType: models.TargetUser, TargetID: 1, IP: "192.0.2.2"},
+		{Type: models.EventSnippetCreate, ActorID: 1, TargetType: models.TargetSnippet, TargetID: 2, IP: "192.0.2.2"},
+		{Type: models.EventUserDisable, ActorID: 2, TargetType: models.TargetUser, TargetID: 3, IP: "192.0.2.3"},
+	}
+	for _, event := range events {
+		assert.NilError(t, am.Insert(ctx, event))
+	}
+
+	all, err := am.List(ctx, models.AuditFilter{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(all), 4)
+	assert.Equal(t, all[0].ID, 4)
+	assert.Equal(t, all[0].Type, models.EventUserDisable)
+
+	// the listed events are copies.
+	all[0].Type = models.EventLogin
+	all, _ = am.List(ctx, models.AuditFilter{Limit: 1})
+	assert.Equal(t, len(all), 1)
+	assert.Equal(t, all[0].Type, models.EventUserDisable)
+
+	tests := []struct {
+		name      string
+		filter    models.AuditFilter
+		wantCount int
+	}{
+		{name: "Type", filter: models.AuditFilter{Type: models.EventLogin}, wantCount: 1},
+		{name: "User", filter: models.AuditFilter{UserID: 1}, wantCount: 3},
+		{name: "Target user", filter: models.AuditFilter{UserID: 3}, wantCount: 1},
+		{name: "IP", filter: models.AuditFilter{IP: "192.0.2.2"}, wantCount: 2},
+		{name: "Before ID", filter: models.AuditFilter{BeforeID: 3}, wantCount: 2},
+		{name: "Since", filter: models.AuditFilter{Since: time.Now().Add(time.Minute)}, wantCount: 0},
+		{name: "Until", filter: models.AuditFilter{Until: time.Now().Add(-time.Minute)}, wantCount: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events, err := am.List(ctx, test.filter)
+			assert.NilError(t, err)
+			assert.Equal(t, len(events), test.wantCount)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+// CredentialModel is an in-memory models.CredentialModelInterface. The zero
+// value is ready to use and safe for concurrent use.
+type CredentialModel struct {
+	mu          sync.Mutex
+	credentials map[string]models.Credential
+}
+
+func (cm *CredentialModel) Insert(ctx context.Context, credential *models.Credential) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if _, ok := cm.credentials[string(credential.ID)]; ok {
+		return models.ErrDuplicateCredential
+	}
+
+	if cm.credentials == nil {
+		cm.credentials = map[string]models.Credential{}
+	}
+	stored := *credential
+	stored.Created = time.Now().UTC()
+	cm.credentials[string(credential.ID)] = stored
+
+	return nil
+}
+
+func (cm *CredentialModel) Get(ctx context.Context, id []byte) (*models.Credential, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	credential, ok := cm.credentials[string(id)]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	return &credential, nil
+}
+
+func (cm *CredentialModel) ListForUser(ctx context.Context, userID int) ([]*models.Credential, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	credentials := []*models.Credential{}
+	for _, credential := range cm.credentials {
+		if credential.UserID == userID {
+			credential := credential
+			credentials = append(credentials, &credential)
+		}
+	}
+
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].Created.Before(credentials[j].Created)
+	})
+
+	return credentials, nil
+}
+
+func (cm *CredentialModel) Use(ctx context.Context, id []byte, signCount uint32) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	credential, ok := cm.credentials[string(id)]
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	credential.SignCount = signCount
+	credential.LastUsed = time.Now().UTC()
+	cm.credentials[string(id)] = credential
+
+	return nil
+}
+
+func (cm *CredentialModel) Delete(ctx context.Context, userID int, id []byte) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	credential, ok := cm.credentials[string(id)]
+	if !ok || credential.UserID != userID {
+		return models.ErrNoRecord
+	}
+
+	delete(cm.credentials, string(id))
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+func TestCredentialModel(t *testing.T) {
+	cm := CredentialModel{}
+	ctx := context.Background()
+
+	credential := &models.Credential{ID: []byte{1, 2, 3}, UserID: 1, Name: "Laptop", PublicKey: []byte{4, 5, 6}}
+	assert.NilError(t, cm.Insert(ctx, credential))
+
+	err := cm.Insert(ctx, credential)
+	assert.Equal(t, err, models.ErrDuplicateCredential)
+
+	assert.NilError(t, cm.Use(ctx, []byte{1, 2, 3}, 8))
+
+	got, err := cm.Get(ctx, []byte{1, 2, 3})
+	assert.NilError(t, err)
+	assert.Equal(t, got.SignCount, uint32(8))
+	assert.Equal(t, got.LastUsed.IsZero(), false)
+
+	credentials, err := cm.ListForUser(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(credentials), 1)
+
+	err = cm.Delete(ctx, 2, []byte{1, 2, 3})
+	assert.Equal(t, err, models.ErrNoRecord)
+
+	assert.NilError(t, cm.Delete(ctx, 1, []byte{1, 2, 3}))
+
+	_, err = cm.Get(ctx, []byte{1, 2, 3})
+	assert.Equal(t, err, models.ErrNoRecord)
+}
@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+// IdentityModel is an in-memory models.IdentityModelInterface. The zero value
+// is ready to use and safe for concurrent use.
+type IdentityModel struct {
+	mu         sync.Mutex
+	identities map[identity]int
+}
+
+type identity struct {
+	issuer  string
+	subject string
+}
+
+func (im *IdentityModel) Get(ctx context.Context, issuer, subject string) (int, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	userID, ok := im.identities[identity{issuer, subject}]
+	if !ok {
+		return 0, models.ErrNoRecord
+	}
+
+	return userID, nil
+}
+
+func (im *IdentityModel) Insert(ctx context.Context, issuer, subject string, userID int) error {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	key := identity{issuer, subject}
+	if _, ok := im.identities[key]; ok {
+		return models.ErrDuplicateIdentity
+	}
+
+	if im.identities == nil {
+		im.identities = map[identity]int{}
+	}
+	im.identities[key] = userID
+
+	return nil
+}
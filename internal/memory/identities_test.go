@@ -0,0 +1,29 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+func TestIdentityModel(t *testing.T) {
+	im := IdentityModel{}
+	ctx := context.Background()
+
+	_, err := im.Get(ctx, "https://accounts.example.com", "248289761001")
+	assert.Equal(t, err, models.ErrNoRecord)
+
+	assert.NilError(t, im.Insert(ctx, "https://accounts.example.com", "248289761001", 1))
+
+	err = im.Insert(ctx, "https://accounts.example.com", "248289761001", 2)
+	assert.Equal(t, err, models.ErrDuplicateIdentity)
+
+	userID, err := im.Get(ctx, "https://accounts.example.com", "248289761001")
+	assert.NilError(t, err)
+	assert.Equal(t, userID, 1)
+
+	_, err = im.Get(ctx, "https://login.example.org", "248289761001")
+	assert.Equal(t, err, models.ErrNoRecord)
+}
@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LoginFailureModel is an in-memory models.LoginFailureModelInterface. The
+// zero value is ready to use and safe for concurrent use.
+type LoginFailureModel struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+}
+
+func (lm *LoginFailureModel) Insert(ctx context.Context, ip string) error {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	if lm.failures == nil {
+		lm.failures = map[string][]time.Time{}
+	}
+	lm.failures[ip] = append(lm.failures[ip], time.Now().UTC())
+
+	return nil
+}
+
+func (lm *LoginFailureModel) Count(ctx context.Context, ip string, since time.Time) (int, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	count := 0
+	for _, created := range lm.failures[ip] {
+		if created.After(since) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (lm *LoginFailureModel) DeleteBefore(ctx context.Context, before time.Time) error {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	for ip, failures := range lm.failures {
+		// the failures are appended in order, so the ones to keep are at the
+		// end.
+		i := 0
+		for i < len(failures) && failures[i].Before(before) {
+			i++
+		}
+
+		if i == len(failures) {
+			delete(lm.failures, ip)
+		} else {
+			lm.failures[ip] = failures[i:]
+		}
+	}
+
+	return nil
+}
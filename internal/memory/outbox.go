@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/mailer"
+)
+
+// OutboxModel is an in-memory mailer.OutboxStore. The zero value is ready to
+// use and safe for concurrent use.
+type OutboxModel struct {
+	mu       sync.Mutex
+	nextID   int
+	messages map[int]*outboxMessage
+}
+
+type outboxMessage struct {
+	queued      mailer.QueuedMessage
+	nextAttempt time.Time
+	lastError   string
+}
+
+func (om *OutboxModel) Insert(ctx context.Context, msg mailer.Message) error {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	if om.messages == nil {
+		om.messages = map[int]*outboxMessage{}
+	}
+	om.nextID++
+	om.messages[om.nextID] = &outboxMessage{
+		queued:      mailer.QueuedMessage{ID: om.nextID, Message: msg},
+		nextAttempt: time.Now().UTC(),
+	}
+
+	return nil
+}
+
+func (om *OutboxModel) Due(ctx context.Context, now time.Time, limit int) ([]*mailer.QueuedMessage, error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	due := []*outboxMessage{}
+	for _, m := range om.messages {
+		if !m.nextAttempt.IsZero() && !m.nextAttempt.After(now) {
+			due = append(due, m)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].nextAttempt.Before(due[j].nextAttempt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	messages := make([]*mailer.QueuedMessage, len(due))
+	for i, m := range due {
+		queued := m.queued
+		messages[i] = &queued
+	}
+
+	return messages, nil
+}
+
+func (om *OutboxModel) Delete(ctx context.Context, id int) error {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	delete(om.messages, id)
+
+	return nil
+}
+
+func (om *OutboxModel) Reschedule(ctx context.Context, id int, next time.Time, lastError string) error {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	if m, ok := om.messages[id]; ok {
+		m.queued.Attempts++
+		m.nextAttempt = next
+		m.lastError = lastError
+	}
+
+	return nil
+}
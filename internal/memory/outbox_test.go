@@ -0,0 +1,30 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/mailer"
+)
+
+func TestOutboxModel(t *testing.T) {
+	om := OutboxModel{}
+	ctx := context.Background()
+
+	assert.NilError(t, om.Insert(ctx, mailer.Message{To: "jane@snippetbox.sh"}))
+	assert.NilError(t, om.Insert(ctx, mailer.Message{To: "john@snippetbox.sh"}))
+
+	due, err := om.Due(ctx, time.Now().Add(time.Second), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(due), 1)
+	assert.Equal(t, due[0].Message.To, "jane@snippetbox.sh")
+
+	assert.NilError(t, om.Reschedule(ctx, due[0].ID, time.Time{}, "connection refused"))
+	assert.NilError(t, om.Delete(ctx, 2))
+
+	due, err = om.Due(ctx, time.Now().Add(time.Hour), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(due), 0)
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+// SnippetModel is an in-memory models.SnippetModelInterface. The zero value is
+// ready to use and safe for concurrent use. The deleted snippets are kept as
+// zero values, so the IDs stay the indexes plus one.
+type SnippetModel struct {
+	mu       sync.RWMutex
+	snippets []models.Snippet
+}
+
+func (sm *SnippetModel) Insert(ctx context.Context, title string, content string, expires int) (int, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	created := time.Now().UTC()
+	snippet := models.Snippet{
+		ID:      len(sm.snippets) + 1,
+		Title:   title,
+		Content: content,
+		Created: created,
+		Expires: created.AddDate(0, 0, expires),
+	}
+	sm.snippets = append(sm.snippets, snippet)
+
+	return snippet.ID, nil
+}
+
+func (sm *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if id < 1 || id > len(sm.snippets) {
+		return nil, models.ErrNoRecord
+	}
+
+	snippet := sm.snippets[id-1]
+	if !snippet.Expires.After(time.Now()) {
+		return nil, models.ErrNoRecord
+	}
+
+	return &snippet, nil
+}
+
+func (sm *SnippetModel) Latest(ctx context.Context) ([]*models.Snippet, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	now := time.Now()
+	snippets := []*models.Snippet{}
+	for i := len(sm.snippets) - 1; i >= 0 && len(snippets) < 10; i-- {
+		snippet := sm.snippets[i]
+		if snippet.Expires.After(now) {
+			snippets = append(snippets, &snippet)
+		}
+	}
+
+	return snippets, nil
+}
+
+func (sm *SnippetModel) Delete(ctx context.Context, id int) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if id < 1 || id > len(sm.snippets) || sm.snippets[id-1].ID == 0 {
+		return models.ErrNoRecord
+	}
+
+	sm.snippets[id-1] = models.Snippet{}
+
+	return nil
+}
+
+func (sm *SnippetModel) Stats(ctx context.Context) (*models.SnippetStats, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	now := time.Now()
+	stats := &models.SnippetStats{}
+	for _, snippet := range sm.snippets {
+		if snippet.ID == 0 {
+			continue
+		}
+
+		stats.Count++
+		if !snippet.Expires.After(now) {
+			stats.Expired++
+		}
+		stats.Size += int64(len(snippet.Title) + len(snippet.Content))
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+func TestSnippetModel(t *testing.T) {
+	sm := SnippetModel{}
+
+	id, err := sm.Insert(context.Background(), "A Title", "A content", 7)
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	snippet, err := sm.Get(context.Background(), id)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Content, "A content")
+
+	t.Run("Expired", func(t *testing.T) {
+		id, err := sm.Insert(context.Background(), "Expired", "This snippet expires right away", 0)
+		assert.NilError(t, err)
+
+		_, err = sm.Get(context.Background(), id)
+		assert.Equal(t, err, models.ErrNoRecord)
+	})
+
+	t.Run("Non-existent ID", func(t *testing.T) {
+		_, err := sm.Get(context.Background(), 100)
+		assert.Equal(t, err, models.ErrNoRecord)
+	})
+
+	t.Run("Latest", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sm.Insert(context.Background(), "Concurrent", "Inserted concurrently", 1)
+			}()
+		}
+		wg.Wait()
+
+		snippets, err := sm.Latest(context.Background())
+		assert.NilError(t, err)
+		assert.Equal(t, len(snippets), 10)
+		assert.Equal(t, snippets[0].ID, 22)
+	})
+}
+
+func TestSnippetModelDeleteAndStats(t *testing.T) {
+	sm := SnippetModel{}
+	ctx := context.Background()
+
+	id, err := sm.Insert(ctx, "A Title", "A content", 7)
+	assert.NilError(t, err)
+	_, err = sm.Insert(ctx, "Expired", "Gone", 0)
+	assert.NilError(t, err)
+
+	stats, err := sm.Stats(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, *stats, models.SnippetStats{Count: 2, Expired: 1, Size: 27})
+
+	assert.NilError(t, sm.Delete(ctx, id))
+	assert.Equal(t, sm.Delete(ctx, id), models.ErrNoRecord)
+	assert.Equal(t, sm.Delete(ctx, 100), models.ErrNoRecord)
+
+	_, err = sm.Get(ctx, id)
+	assert.Equal(t, err, models.ErrNoRecord)
+
+	stats, err = sm.Stats(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, *stats, models.SnippetStats{Count: 1, Expired: 1, Size: 11})
+}
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+// TokenModel is an in-memory models.TokenModelInterface. The zero value is
+// ready to use and safe for concurrent use.
+type TokenModel struct {
+	mu     sync.Mutex
+	tokens map[string]models.Token
+}
+
+func (tm *TokenModel) New(ctx context.Context, userID int, ttl time.Duration, scope string) (*models.Token, error) {
+	token, err := models.NewToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if tm.tokens == nil {
+		tm.tokens = map[string]models.Token{}
+	}
+	// like the SQL model, only the hash is kept.
+	stored := *token
+	stored.Plaintext = ""
+	tm.tokens[string(token.Hash)] = stored
+
+	return token, nil
+}
+
+func (tm *TokenModel) Use(ctx context.Context, plaintext, scope string) (int, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	hash := string(models.HashToken(plaintext))
+
+	token, ok := tm.tokens[hash]
+	if !ok || token.Scope != scope || !token.Expiry.After(time.Now().UTC()) {
+		return 0, models.ErrNoRecord
+	}
+
+	delete(tm.tokens, hash)
+
+	return token.UserID, nil
+}
+
+func (tm *TokenModel) DeleteAllForUser(ctx context.Context, userID int, scope string) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	for hash, token := range tm.tokens {
+		if token.UserID == userID && token.Scope == scope {
+			delete(tm.tokens, hash)
+		}
+	}
+
+	return nil
+}
+
+func (tm *TokenModel) DeleteExpired(ctx context.Context) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	now := time.Now().UTC()
+	for hash, token := range tm.tokens {
+		if !token.Expiry.After(now) {
+			delete(tm.tokens, hash)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+func TestTokenModel(t *testing.T) {
+	tm := TokenModel{}
+	ctx := context.Background()
+
+	token, err := tm.New(ctx, 1, time.Hour, models.ScopeEmailVerification)
+	assert.NilError(t, err)
+
+	_, err = tm.Use(ctx, token.Plaintext, "another_scope")
+	assert.Equal(t, err, models.ErrNoRecord)
+
+	userID, err := tm.Use(ctx, token.Plaintext, models.ScopeEmailVerification)
+	assert.NilError(t, err)
+	assert.Equal(t, userID, 1)
+
+	_, err = tm.Use(ctx, token.Plaintext, models.ScopeEmailVerification)
+	assert.Equal(t, err, models.ErrNoRecord)
+
+	expired, err := tm.New(ctx, 1, -time.Minute, models.ScopeEmailVerification)
+	assert.NilError(t, err)
+	_, err = tm.Use(ctx, expired.Plaintext, models.ScopeEmailVerification)
+	assert.Equal(t, err, models.ErrNoRecord)
+
+	assert.NilError(t, tm.DeleteExpired(ctx))
+	assert.Equal(t, len(tm.tokens), 0)
+}
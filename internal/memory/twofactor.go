@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+// TwoFactorModel is an in-memory models.TwoFactorModelInterface. The zero
+// value is ready to use and safe for concurrent use.
+type TwoFactorModel struct {
+	mu      sync.Mutex
+	secrets map[int]models.TwoFactor
+	// recoveryCodes are the hashes of the codes by user.
+	recoveryCodes map[int]map[string]bool
+}
+
+func (tm *TwoFactorModel) Get(ctx context.Context, userID int) (*models.TwoFactor, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tf, ok := tm.secrets[userID]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	return &tf, nil
+}
+
+func (tm *TwoFactorModel) Setup(ctx context.Context, userID int, secret string) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if tf, ok := tm.secrets[userID]; ok && tf.Enabled() {
+		return models.ErrTwoFactorEnabled
+	}
+
+	if tm.secrets == nil {
+		tm.secrets = map[int]models.TwoFactor{}
+	}
+	tm.secrets[userID] = models.TwoFactor{UserID: userID, Secret: secret, Created: time.Now().UTC()}
+
+	return nil
+}
+
+func (tm *TwoFactorModel) Enable(ctx context.Context, userID int, counter int64) ([]string, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tf, ok := tm.secrets[userID]
+	if !ok || tf.Enabled() {
+		return nil, models.ErrNoRecord
+	}
+
+	tf.EnabledAt = time.Now().UTC()
+	tf.LastCounter = counter
+	tm.secrets[userID] = tf
+
+	return tm.replaceRecoveryCodes(userID)
+}
+
+func (tm *TwoFactorModel) Disable(ctx context.Context, userID int) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	delete(tm.secrets, userID)
+	delete(tm.recoveryCodes, userID)
+
+	return nil
+}
+
+func (tm *TwoFactorModel) UseCounter(ctx context.Context, userID int, counter int64) (bool, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tf, ok := tm.secrets[userID]
+	if !ok || !tf.Enabled() || tf.LastCounter >= counter {
+		return false, nil
+	}
+
+	tf.LastCounter = counter
+	tm.secrets[userID] = tf
+
+	return true, nil
+}
+
+func (tm *TwoFactorModel) UseRecoveryCode(ctx context.Context, userID int, code string) (bool, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	hash := string(models.HashRecoveryCode(code))
+	if !tm.recoveryCodes[userID][hash] {
+		return false, nil
+	}
+
+	delete(tm.recoveryCodes[userID], hash)
+
+	return true, nil
+}
+
+func (tm *TwoFactorModel) RegenerateRecoveryCodes(ctx context.Context, userID int) ([]string, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	return tm.replaceRecoveryCodes(userID)
+}
+
+func (tm *TwoFactorModel) RecoveryCodesLeft(ctx context.Context, userID int) (int, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	return len(tm.recoveryCodes[userID]), nil
+}
+
+func (tm *TwoFactorModel) replaceRecoveryCodes(userID int) ([]string, error) {
+	codes, err := models.NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := map[string]bool{}
+	for _, code := range codes {
+		hashes[string(models.HashRecoveryCode(code))] = true
+	}
+
+	if tm.recoveryCodes == nil {
+		tm.recoveryCodes = map[int]map[string]bool{}
+	}
+	tm.recoveryCodes[userID] = hashes
+
+	return codes, nil
+}
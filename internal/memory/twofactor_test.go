@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+func TestTwoFactorModel(t *testing.T) {
+	tm := TwoFactorModel{}
+	ctx := context.Background()
+
+	_, err := tm.Get(ctx, 1)
+	assert.Equal(t, err, models.ErrNoRecord)
+
+	assert.NilError(t, tm.Setup(ctx, 1, "JBSWY3DPEHPK3PXP"))
+
+	codes, err := tm.Enable(ctx, 1, 100)
+	assert.NilError(t, err)
+	assert.Equal(t, len(codes), models.RecoveryCodeCount)
+
+	err = tm.Setup(ctx, 1, "JBSWY3DPEHPK3PXP")
+	assert.Equal(t, err, models.ErrTwoFactorEnabled)
+
+	ok, err := tm.UseCounter(ctx, 1, 100)
+	assert.NilError(t, err)
+	assert.Equal(t, ok, false)
+
+	ok, err = tm.UseCounter(ctx, 1, 101)
+	assert.NilError(t, err)
+	assert.Equal(t, ok, true)
+
+	ok, err = tm.UseRecoveryCode(ctx, 1, codes[0])
+	assert.NilError(t, err)
+	assert.Equal(t, ok, true)
+
+	ok, err = tm.UseRecoveryCode(ctx, 1, codes[0])
+	assert.NilError(t, err)
+	assert.Equal(t, ok, false)
+
+	left, err := tm.RecoveryCodesLeft(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, left, models.RecoveryCodeCount-1)
+
+	assert.NilError(t, tm.Disable(ctx, 1))
+
+	_, err = tm.Get(ctx, 1)
+	assert.Equal(t, err, models.ErrNoRecord)
+}
@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// UserSessionModel is an in-memory models.UserSessionModelInterface. The zero
+// value is ready to use and safe for concurrent use.
+type UserSessionModel struct {
+	mu       sync.Mutex
+	sessions map[string]userSession
+}
+
+type userSession struct {
+	userID int
+	expiry time.Time
+}
+
+func (sm *UserSessionModel) Insert(ctx context.Context, token string, userID int, expiry time.Time) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.sessions == nil {
+		sm.sessions = map[string]userSession{}
+	}
+	sm.sessions[token] = userSession{userID: userID, expiry: expiry}
+
+	return nil
+}
+
+func (sm *UserSessionModel) Tokens(ctx context.Context, userID int) ([]string, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	now := time.Now()
+
+	var tokens []string
+	for token, session := range sm.sessions {
+		if session.userID == userID && session.expiry.After(now) {
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens, nil
+}
+
+func (sm *UserSessionModel) Delete(ctx context.Context, token string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	delete(sm.sessions, token)
+
+	return nil
+}
+
+func (sm *UserSessionModel) DeleteExpired(ctx context.Context) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	now := time.Now()
+	for token, session := range sm.sessions {
+		if !session.expiry.After(now) {
+			delete(sm.sessions, token)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestUserSessionModel(t *testing.T) {
+	sm := UserSessionModel{}
+	ctx := context.Background()
+
+	assert.NilError(t, sm.Insert(ctx, "token-1", 1, time.Now().Add(time.Hour)))
+	assert.NilError(t, sm.Insert(ctx, "token-2", 2, time.Now().Add(time.Hour)))
+	assert.NilError(t, sm.Insert(ctx, "expired", 1, time.Now().Add(-time.Minute)))
+
+	tokens, err := sm.Tokens(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 1)
+	assert.Equal(t, tokens[0], "token-1")
+
+	assert.NilError(t, sm.Delete(ctx, "token-1"))
+	tokens, err = sm.Tokens(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 0)
+
+	assert.NilError(t, sm.DeleteExpired(ctx))
+	assert.Equal(t, len(sm.sessions), 1)
+}
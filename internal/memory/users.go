@@ -0,0 +1,416 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserModel is an in-memory models.UserModelInterface. The zero value is ready
+// to use and safe for concurrent use. Emails are compared case-insensitively,
+// like the default collation of the MySQL schema.
+type UserModel struct {
+	BcryptCost int
+	Lockout    models.LockoutPolicy
+
+	mu    sync.RWMutex
+	users []models.User
+}
+
+func (um *UserModel) Get(ctx context.Context, id int) (*models.User, error) {
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+
+	user, ok := um.find(id)
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	// the SQL model doesn't load the password hash either.
+	user.HashedPassword = nil
+
+	return &user, nil
+}
+
+func (um *UserModel) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+
+	user, ok := um.findByEmail(email)
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	user.HashedPassword = nil
+
+	return &user, nil
+}
+
+func (um *UserModel) UpdatePassword(ctx context.Context, id int, currentPassword, newPassword string) error {
+	// bcrypt is slow, the lock isn't held while it runs.
+	um.mu.RLock()
+	user, ok := um.find(id)
+	um.mu.RUnlock()
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	err := compareHashAndPassword(user.HashedPassword, currentPassword)
+	if err != nil {
+		return err
+	}
+
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), um.bcryptCost())
+	if err != nil {
+		return err
+	}
+
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	// the current password is no longer the one checked when it was changed
+	// in the meantime.
+	if !bytes.Equal(um.users[id-1].HashedPassword, user.HashedPassword) {
+		return models.ErrInvalidCredentials
+	}
+
+	um.users[id-1].HashedPassword = newHashedPassword
+
+	return nil
+}
+
+func (um *UserModel) SetPassword(ctx context.Context, id int, newPassword string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), um.bcryptCost())
+	if err != nil {
+		return err
+	}
+
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if _, ok := um.find(id); !ok {
+		return models.ErrNoRecord
+	}
+
+	um.users[id-1].HashedPassword = hashedPassword
+	um.users[id-1].FailedLogins = 0
+	um.users[id-1].LockedUntil = time.Time{}
+
+	return nil
+}
+
+func (um *UserModel) Insert(ctx context.Context, name, email, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), um.bcryptCost())
+	if err != nil {
+		return err
+	}
+
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if _, ok := um.findByEmail(email); ok {
+		return models.ErrDuplicateEmail
+	}
+
+	um.users = append(um.users, models.User{
+		ID:             len(um.users) + 1,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        time.Now().UTC(),
+		Role:           models.RoleUser,
+	})
+
+	return nil
+}
+
+func (um *UserModel) Provision(ctx context.Context, name, email string) (int, error) {
+	password := make([]byte, 32)
+	_, err := rand.Read(password)
+	if err != nil {
+		return 0, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(base64.RawURLEncoding.EncodeToString(password)), um.bcryptCost())
+	if err != nil {
+		return 0, err
+	}
+
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if _, ok := um.findByEmail(email); ok {
+		return 0, models.ErrDuplicateEmail
+	}
+
+	now := time.Now().UTC()
+	id := len(um.users) + 1
+	um.users = append(um.users, models.User{
+		ID:              id,
+		Name:            name,
+		Email:           email,
+		HashedPassword:  hashedPassword,
+		Created:         now,
+		EmailVerifiedAt: now,
+		Role:            models.RoleUser,
+	})
+
+	return id, nil
+}
+
+func (um *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	um.mu.RLock()
+	user, ok := um.findByEmail(email)
+	um.mu.RUnlock()
+
+	if !ok {
+		models.CompareDummyHash(password, um.bcryptCost())
+		return 0, models.ErrInvalidCredentials
+	}
+
+	now := time.Now().UTC()
+	if user.LockedUntil.After(now) {
+		models.CompareDummyHash(password, um.bcryptCost())
+		return 0, &models.LockoutError{UserID: user.ID, Until: user.LockedUntil}
+	}
+
+	err := compareHashAndPassword(user.HashedPassword, password)
+
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	stored := &um.users[user.ID-1]
+	if err != nil {
+		if !errors.Is(err, models.ErrInvalidCredentials) {
+			return 0, err
+		}
+
+		stored.FailedLogins++
+		stored.LockedUntil = time.Time{}
+		if d := um.Lockout.LockDuration(stored.FailedLogins); d > 0 {
+			stored.LockedUntil = now.Add(d)
+			return 0, &models.LockoutError{UserID: user.ID, Until: stored.LockedUntil, Started: true}
+		}
+
+		return 0, err
+	}
+
+	if stored.Disabled() {
+		return 0, models.ErrAccountDisabled
+	}
+
+	return user.ID, nil
+}
+
+func (um *UserModel) RecordFailedLogin(ctx context.Context, id int) error {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if _, ok := um.find(id); !ok {
+		return models.ErrNoRecord
+	}
+
+	stored := &um.users[id-1]
+	stored.FailedLogins++
+	stored.LockedUntil = time.Time{}
+	if d := um.Lockout.LockDuration(stored.FailedLogins); d > 0 {
+		stored.LockedUntil = time.Now().UTC().Add(d)
+		return &models.LockoutError{UserID: id, Until: stored.LockedUntil, Started: true}
+	}
+
+	return models.ErrInvalidCredentials
+}
+
+func (um *UserModel) ResetFailedLogins(ctx context.Context, id int) error {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if _, ok := um.find(id); !ok {
+		return nil
+	}
+
+	stored := &um.users[id-1]
+	if stored.LockedUntil.After(time.Now()) {
+		return nil
+	}
+
+	stored.FailedLogins = 0
+	stored.LockedUntil = time.Time{}
+
+	return nil
+}
+
+func (um *UserModel) Exists(ctx context.Context, id int) (bool, error) {
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+
+	_, ok := um.find(id)
+
+	return ok, nil
+}
+
+func (um *UserModel) Unlock(ctx context.Context, id int) error {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if _, ok := um.find(id); !ok {
+		return models.ErrNoRecord
+	}
+
+	um.users[id-1].FailedLogins = 0
+	um.users[id-1].LockedUntil = time.Time{}
+
+	return nil
+}
+
+func (um *UserModel) VerifyEmail(ctx context.Context, id int) error {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	user, ok := um.find(id)
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	if user.EmailVerifiedAt.IsZero() {
+		um.users[id-1].EmailVerifiedAt = time.Now().UTC()
+	}
+
+	return nil
+}
+
+func (um *UserModel) List(ctx context.Context) ([]*models.User, error) {
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+
+	users := make([]*models.User, 0, len(um.users))
+	for _, user := range um.users {
+		user := user
+		user.HashedPassword = nil
+		users = append(users, &user)
+	}
+
+	return users, nil
+}
+
+func (um *UserModel) Count(ctx context.Context) (int, int, error) {
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+
+	disabled := 0
+	for _, user := range um.users {
+		if user.Disabled() {
+			disabled++
+		}
+	}
+
+	return len(um.users), disabled, nil
+}
+
+func (um *UserModel) Emails(ctx context.Context, ids []int) (map[int]string, error) {
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+
+	emails := make(map[int]string, len(ids))
+	for _, id := range ids {
+		if user, ok := um.find(id); ok {
+			emails[id] = user.Email
+		}
+	}
+
+	return emails, nil
+}
+
+func (um *UserModel) Signups(ctx context.Context, since time.Time) ([]time.Time, error) {
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+
+	signups := []time.Time{}
+	for _, user := range um.users {
+		if !user.Created.Before(since) {
+			signups = append(signups, user.Created)
+		}
+	}
+
+	return signups, nil
+}
+
+func (um *UserModel) SetRole(ctx context.Context, id int, role models.Role) error {
+	if !role.Valid() {
+		return models.ErrInvalidRole
+	}
+
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if _, ok := um.find(id); !ok {
+		return models.ErrNoRecord
+	}
+
+	um.users[id-1].Role = role
+
+	return nil
+}
+
+func (um *UserModel) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	user, ok := um.find(id)
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	switch {
+	case !disabled:
+		um.users[id-1].DisabledAt = time.Time{}
+	case !user.Disabled():
+		um.users[id-1].DisabledAt = time.Now().UTC()
+	}
+
+	return nil
+}
+
+// find and findByEmail return a copy of the user, the caller must hold the
+// lock.
+func (um *UserModel) find(id int) (models.User, bool) {
+	if id < 1 || id > len(um.users) {
+		return models.User{}, false
+	}
+
+	return um.users[id-1], true
+}
+
+func (um *UserModel) findByEmail(email string) (models.User, bool) {
+	for _, user := range um.users {
+		if strings.EqualFold(user.Email, email) {
+			return user, true
+		}
+	}
+
+	return models.User{}, false
+}
+
+func (um *UserModel) bcryptCost() int {
+	if um.BcryptCost == 0 {
+		return models.DefaultBcryptCost
+	}
+
+	return um.BcryptCost
+}
+
+func compareHashAndPassword(hashedPassword []byte, password string) error {
+	err := bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return models.ErrInvalidCredentials
+	}
+
+	return err
+}
@@ -0,0 +1,194 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+func TestUserModel(t *testing.T) {
+	um := UserModel{}
+
+	err := um.Insert(context.Background(), "Jane Doe", "jane@snippetbox.sh", "pa55word")
+	assert.NilError(t, err)
+
+	err = um.Insert(context.Background(), "Jane Doe", "JANE@snippetbox.sh", "pa55word")
+	assert.Equal(t, err, models.ErrDuplicateEmail)
+
+	id, err := um.Authenticate(context.Background(), "jane@snippetbox.sh", "pa55word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	_, err = um.Authenticate(context.Background(), "jane@snippetbox.sh", "wrong password")
+	assert.Equal(t, err, models.ErrInvalidCredentials)
+
+	_, err = um.Authenticate(context.Background(), "john@snippetbox.sh", "pa55word")
+	assert.Equal(t, err, models.ErrInvalidCredentials)
+
+	err = um.UpdatePassword(context.Background(), id, "wrong password", "n3wpa55word")
+	assert.Equal(t, err, models.ErrInvalidCredentials)
+
+	err = um.UpdatePassword(context.Background(), id, "pa55word", "n3wpa55word")
+	assert.NilError(t, err)
+
+	_, err = um.Authenticate(context.Background(), "jane@snippetbox.sh", "n3wpa55word")
+	assert.NilError(t, err)
+
+	exists, err := um.Exists(context.Background(), id)
+	assert.NilError(t, err)
+	assert.Equal(t, exists, true)
+
+	exists, err = um.Exists(context.Background(), 2)
+	assert.NilError(t, err)
+	assert.Equal(t, exists, false)
+}
+
+func TestUserModelProvision(t *testing.T) {
+	um := UserModel{BcryptCost: 4}
+
+	id, err := um.Provision(context.Background(), "Jane Doe", "jane@snippetbox.sh")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	user, err := um.Get(context.Background(), id)
+	assert.NilError(t, err)
+	assert.Equal(t, user.EmailVerified(), true)
+
+	_, err = um.Provision(context.Background(), "Jane Doe", "JANE@snippetbox.sh")
+	assert.Equal(t, err, models.ErrDuplicateEmail)
+}
+
+func TestUserModelLockout(t *testing.T) {
+	um := UserModel{BcryptCost: 4, Lockout: models.LockoutPolicy{Threshold: 2, Duration: time.Minute, MaxDuration: time.Hour}}
+	ctx := context.Background()
+
+	err := um.Insert(ctx, "Jane Doe", "jane@snippetbox.sh", "pa55word")
+	assert.NilError(t, err)
+
+	_, err = um.Authenticate(ctx, "jane@snippetbox.sh", "wrong password")
+	assert.Equal(t, err, models.ErrInvalidCredentials)
+
+	_, err = um.Authenticate(ctx, "jane@snippetbox.sh", "wrong password")
+	var lockout *models.LockoutError
+	assert.Equal(t, errors.As(err, &lockout), true)
+	assert.Equal(t, lockout.Started, true)
+
+	// the right password doesn't help while it's locked.
+	_, err = um.Authenticate(ctx, "jane@snippetbox.sh", "pa55word")
+	assert.Equal(t, errors.Is(err, models.ErrAccountLocked), true)
+
+	user, err := um.GetByEmail(ctx, "jane@snippetbox.sh")
+	assert.NilError(t, err)
+	assert.Equal(t, user.Locked(), true)
+
+	err = um.Unlock(ctx, user.ID)
+	assert.NilError(t, err)
+
+	_, err = um.Authenticate(ctx, "jane@snippetbox.sh", "pa55word")
+	assert.NilError(t, err)
+
+	err = um.Unlock(ctx, 2)
+	assert.Equal(t, err, models.ErrNoRecord)
+
+	// a wrong code after the right password counts, and the right password
+	// doesn't forget it.
+	err = um.RecordFailedLogin(ctx, 1)
+	assert.Equal(t, err, models.ErrInvalidCredentials)
+
+	_, err = um.Authenticate(ctx, "jane@snippetbox.sh", "pa55word")
+	assert.NilError(t, err)
+
+	err = um.RecordFailedLogin(ctx, 1)
+	assert.Equal(t, errors.As(err, &lockout), true)
+	assert.Equal(t, lockout.Started, true)
+
+	// logging in doesn't lift the lock.
+	assert.NilError(t, um.ResetFailedLogins(ctx, 1))
+	_, err = um.Authenticate(ctx, "jane@snippetbox.sh", "pa55word")
+	assert.Equal(t, errors.Is(err, models.ErrAccountLocked), true)
+
+	assert.NilError(t, um.Unlock(ctx, 1))
+	err = um.RecordFailedLogin(ctx, 1)
+	assert.Equal(t, err, models.ErrInvalidCredentials)
+	assert.NilError(t, um.ResetFailedLogins(ctx, 1))
+
+	user, err = um.Get(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, user.FailedLogins, 0)
+
+	err = um.RecordFailedLogin(ctx, 100)
+	assert.Equal(t, err, models.ErrNoRecord)
+}
+
+func TestLoginFailureModel(t *testing.T) {
+	lm := LoginFailureModel{}
+	ctx := context.Background()
+	start := time.Now().Add(-time.Second)
+
+	for i := 0; i < 3; i++ {
+		assert.NilError(t, lm.Insert(ctx, "192.0.2.1"))
+	}
+	assert.NilError(t, lm.Insert(ctx, "192.0.2.2"))
+
+	count, err := lm.Count(ctx, "192.0.2.1", start)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 3)
+
+	assert.NilError(t, lm.DeleteBefore(ctx, time.Now().Add(time.Second)))
+
+	count, err = lm.Count(ctx, "192.0.2.1", start)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 0)
+}
+
+func TestUserModelRolesAndDisabled(t *testing.T) {
+	um := UserModel{BcryptCost: 4}
+	ctx := context.Background()
+
+	start := time.Now()
+	for _, email := range []string{"jane@snippetbox.sh", "john@snippetbox.sh"} {
+		err := um.Insert(ctx, "Jane Doe", email, "pa55word")
+		assert.NilError(t, err)
+	}
+
+	assert.NilError(t, um.SetRole(ctx, 1, models.RoleAdmin))
+	assert.Equal(t, um.SetRole(ctx, 1, models.Role("root")), models.ErrInvalidRole)
+	assert.Equal(t, um.SetRole(ctx, 100, models.RoleAdmin), models.ErrNoRecord)
+
+	assert.NilError(t, um.SetDisabled(ctx, 2, true))
+	_, err := um.Authenticate(ctx, "john@snippetbox.sh", "pa55word")
+	assert.Equal(t, err, models.ErrAccountDisabled)
+
+	users, err := um.List(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(users), 2)
+	assert.Equal(t, users[0].Role, models.RoleAdmin)
+	assert.Equal(t, users[1].Role, models.RoleUser)
+	assert.Equal(t, users[1].Disabled(), true)
+
+	total, disabled, err := um.Count(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 2)
+	assert.Equal(t, disabled, 1)
+
+	emails, err := um.Emails(ctx, []int{2, 100})
+	assert.NilError(t, err)
+	assert.Equal(t, len(emails), 1)
+	assert.Equal(t, emails[2], "john@snippetbox.sh")
+
+	assert.NilError(t, um.SetDisabled(ctx, 2, false))
+	_, err = um.Authenticate(ctx, "john@snippetbox.sh", "pa55word")
+	assert.NilError(t, err)
+
+	signups, err := um.Signups(ctx, start)
+	assert.NilError(t, err)
+	assert.Equal(t, len(signups), 2)
+
+	signups, err = um.Signups(ctx, time.Now().Add(time.Minute))
+	assert.NilError(t, err)
+	assert.Equal(t, len(signups), 0)
+}
@@ -0,0 +1,405 @@
+// Package metrics implements the counters, gauges and histograms the server
+// exposes, and writes them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default histogram buckets, in seconds. They suit the
+// latency of HTTP requests.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// labelSeparator joins the label values into the key of a sample. It sorts
+// before any printable character, so the samples are written in the order of
+// their label values.
+const labelSeparator = "\x00"
+
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds the metrics that are exposed together.
+type Registry struct {
+	mu         sync.Mutex
+	names      map[string]bool
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+func (reg *Registry) register(name string, c collector) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if reg.names[name] {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+
+	reg.names[name] = true
+	reg.collectors = append(reg.collectors, c)
+}
+
+// WriteTo writes every metric of the registry in the text exposition format.
+func (reg *Registry) WriteTo(w io.Writer) (int64, error) {
+	reg.mu.Lock()
+	collectors := append([]collector(nil), reg.collectors...)
+	reg.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	err := bw.Flush()
+
+	return cw.n, err
+}
+
+// Handler serves the metrics of the registry to the Prometheus scraper.
+func (reg *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		reg.WriteTo(w)
+	})
+}
+
+// Counter is a value that only goes up.
+type Counter struct {
+	vec *CounterVec
+}
+
+func (reg *Registry) NewCounter(name, help string) *Counter {
+	return &Counter{vec: reg.NewCounterVec(name, help)}
+}
+
+func (c *Counter) Inc() {
+	c.vec.Add(1)
+}
+
+func (c *Counter) Add(v float64) {
+	c.vec.Add(v)
+}
+
+func (c *Counter) Value() float64 {
+	return c.vec.Value()
+}
+
+// CounterVec is a counter partitioned by labels. The label values are given
+// in the order of the label names.
+type CounterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]*sample
+}
+
+type sample struct {
+	labelValues []string
+	value       float64
+}
+
+func (reg *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, values: map[string]*sample{}}
+	reg.register(name, c)
+	return c
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counters can't decrease")
+	}
+	checkLabels(c.name, c.labels, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := strings.Join(labelValues, labelSeparator)
+	s, ok := c.values[key]
+	if !ok {
+		s = &sample{labelValues: labelValues}
+		c.values[key] = s
+	}
+	s.value += v
+}
+
+// Value returns the current value of the counter with the label values.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.values[strings.Join(labelValues, labelSeparator)]
+	if !ok {
+		return 0
+	}
+
+	return s.value
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// a counter without labels is always written, even before it's used.
+	if len(c.labels) == 0 && len(c.values) == 0 {
+		writeSample(w, c.name, nil, nil, "", "", 0)
+		return
+	}
+
+	for _, s := range sortedSamples(c.values) {
+		writeSample(w, c.name, c.labels, s.labelValues, "", "", s.value)
+	}
+}
+
+// Gauge is a value that goes up and down.
+type Gauge struct {
+	name, help string
+
+	mu    sync.Mutex
+	value float64
+}
+
+func (reg *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	reg.register(name, g)
+	return g
+}
+
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	g.value = v
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Add(v float64) {
+	g.mu.Lock()
+	g.value += v
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Inc() { g.Add(1) }
+
+func (g *Gauge) Dec() { g.Add(-1) }
+
+func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.value
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	writeSample(w, g.name, nil, nil, "", "", g.Value())
+}
+
+// funcMetric reads its value when the metrics are written, for values that
+// are kept somewhere else, like the stats of a connection pool.
+type funcMetric struct {
+	name, help, kind string
+	fn               func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is returned by fn.
+func (reg *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	reg.register(name, &funcMetric{name: name, help: help, kind: "gauge", fn: fn})
+}
+
+// NewCounterFunc registers a counter whose value is returned by fn, which must
+// never decrease.
+func (reg *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	reg.register(name, &funcMetric{name: name, help: help, kind: "counter", fn: fn})
+}
+
+func (f *funcMetric) write(w *bufio.Writer) {
+	writeHeader(w, f.name, f.help, f.kind)
+	writeSample(w, f.name, nil, nil, "", "", f.fn())
+}
+
+// HistogramVec counts observations, like request durations, into buckets and
+// is partitioned by labels.
+type HistogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	values map[string]*histogram
+}
+
+type histogram struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+func (reg *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("metrics: buckets of %s aren't sorted", name))
+	}
+
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, values: map[string]*histogram{}}
+	reg.register(name, h)
+	return h
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	checkLabels(h.name, h.labels, labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := strings.Join(labelValues, labelSeparator)
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogram{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.values[key] = s
+	}
+
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// Count returns the number of observations with the label values.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.values[strings.Join(labelValues, labelSeparator)]
+	if !ok {
+		return 0
+	}
+
+	return s.count
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.values))
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := h.values[k]
+
+		// the buckets are cumulative, each one counts the observations less
+		// than or equal to its upper bound.
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			writeSample(w, h.name+"_bucket", h.labels, s.labelValues, "le", formatFloat(upper), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, s.labelValues, "le", "+Inf", float64(s.count))
+		writeSample(w, h.name+"_sum", h.labels, s.labelValues, "", "", s.sum)
+		writeSample(w, h.name+"_count", h.labels, s.labelValues, "", "", float64(s.count))
+	}
+}
+
+func checkLabels(name string, labels, values []string) {
+	if len(labels) != len(values) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", name, len(labels), len(values)))
+	}
+}
+
+func sortedSamples(values map[string]*sample) []*sample {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	samples := make([]*sample, len(keys))
+	for i, k := range keys {
+		samples[i] = values[k]
+	}
+
+	return samples
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, helpEscaper.Replace(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+// writeSample writes one line of a metric. extraLabel is used for the le
+// label of the histogram buckets and is skipped when empty.
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraLabel, extraValue string, v float64) {
+	w.WriteString(name)
+
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, label, labelValueEscaper.Replace(values[i]))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, extraLabel, extraValue)
+		}
+		w.WriteByte('}')
+	}
+
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
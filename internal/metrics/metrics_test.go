@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry()
+
+	requests := reg.NewCounterVec("requests_total", "Requests by route.", "route", "status")
+	requests.Inc("/snippet/view/:id", "200")
+	requests.Inc("/snippet/view/:id", "200")
+	requests.Inc("/", "500")
+
+	inFlight := reg.NewGauge("in_flight", "Requests in flight.")
+	inFlight.Inc()
+	inFlight.Inc()
+	inFlight.Dec()
+
+	reg.NewGaugeFunc("open_connections", "Open connections.", func() float64 { return 4 })
+
+	duration := reg.NewHistogramVec("duration_seconds", "Request duration.", []float64{0.1, 1}, "route")
+	duration.Observe(0.05, "/")
+	duration.Observe(0.1, "/")
+	duration.Observe(0.5, "/")
+	duration.Observe(3, "/")
+
+	reg.NewCounter("unused_total", "A counter that isn't used yet.")
+
+	escaped := reg.NewCounterVec("escaped_total", "Help with a \\ and a\nnewline.", "value")
+	escaped.Inc("a \"quoted\"\nvalue")
+
+	want := `# HELP requests_total Requests by route.
+# TYPE requests_total counter
+requests_total{route="/",status="500"} 1
+requests_total{route="/snippet/view/:id",status="200"} 2
+# HELP in_flight Requests in flight.
+# TYPE in_flight gauge
+in_flight 1
+# HELP open_connections Open connections.
+# TYPE open_connections gauge
+open_connections 4
+# HELP duration_seconds Request duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/",le="0.1"} 2
+duration_seconds_bucket{route="/",le="1"} 3
+duration_seconds_bucket{route="/",le="+Inf"} 4
+duration_seconds_sum{route="/"} 3.65
+duration_seconds_count{route="/"} 4
+# HELP unused_total A counter that isn't used yet.
+# TYPE unused_total counter
+unused_total 0
+# HELP escaped_total Help with a \\ and a\nnewline.
+# TYPE escaped_total counter
+escaped_total{value="a \"quoted\"\nvalue"} 1
+`
+
+	rr := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	rs := rr.Result()
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	assert.NilError(t, err)
+
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.Equal(t, rs.Header.Get("Content-Type"), "text/plain; version=0.0.4; charset=utf-8")
+	assert.Equal(t, string(body), want)
+
+	assert.Equal(t, requests.Value("/snippet/view/:id", "200"), 2.0)
+	assert.Equal(t, duration.Count("/"), uint64(4))
+}
+
+func TestRegistryDuplicateName(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounter("snippets_created_total", "Snippets created.")
+
+	defer func() {
+		assert.Equal(t, recover() != nil, true)
+	}()
+
+	reg.NewGauge("snippets_created_total", "Snippets created.")
+}
@@ -0,0 +1,39 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+var mockAuditEvent = &models.AuditEvent{
+	ID:         1,
+	Type:       models.EventLogin,
+	ActorID:    1,
+	TargetType: models.TargetUser,
+	TargetID:   1,
+	IP:         "192.0.2.1",
+	UserAgent:  "Mozilla/5.0",
+	Created:    time.Now(),
+}
+
+type AuditEventModel struct{}
+
+func (am *AuditEventModel) Insert(ctx context.Context, event *models.AuditEvent) error {
+	return nil
+}
+
+func (am *AuditEventModel) List(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEvent, error) {
+	if filter.Type != "" && filter.Type != mockAuditEvent.Type {
+		return []*models.AuditEvent{}, nil
+	}
+	if filter.UserID != 0 && filter.UserID != mockAuditEvent.ActorID {
+		return []*models.AuditEvent{}, nil
+	}
+	if filter.BeforeID != 0 && filter.BeforeID <= mockAuditEvent.ID {
+		return []*models.AuditEvent{}, nil
+	}
+
+	return []*models.AuditEvent{mockAuditEvent}, nil
+}
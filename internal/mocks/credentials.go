@@ -0,0 +1,31 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+// CredentialModel has no credentials, the passkey tests need real keys and
+// use the in-memory model.
+type CredentialModel struct{}
+
+func (cm *CredentialModel) Insert(ctx context.Context, credential *models.Credential) error {
+	return nil
+}
+
+func (cm *CredentialModel) Get(ctx context.Context, id []byte) (*models.Credential, error) {
+	return nil, models.ErrNoRecord
+}
+
+func (cm *CredentialModel) ListForUser(ctx context.Context, userID int) ([]*models.Credential, error) {
+	return []*models.Credential{}, nil
+}
+
+func (cm *CredentialModel) Use(ctx context.Context, id []byte, signCount uint32) error {
+	return models.ErrNoRecord
+}
+
+func (cm *CredentialModel) Delete(ctx context.Context, userID int, id []byte) error {
+	return models.ErrNoRecord
+}
@@ -0,0 +1,19 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+// IdentityModel has no identities, the single sign-on tests use the
+// in-memory model with a test provider.
+type IdentityModel struct{}
+
+func (im *IdentityModel) Get(ctx context.Context, issuer, subject string) (int, error) {
+	return 0, models.ErrNoRecord
+}
+
+func (im *IdentityModel) Insert(ctx context.Context, issuer, subject string, userID int) error {
+	return nil
+}
@@ -0,0 +1,20 @@
+package mocks
+
+import (
+	"context"
+	"time"
+)
+
+type LoginFailureModel struct{}
+
+func (lm *LoginFailureModel) Insert(ctx context.Context, ip string) error {
+	return nil
+}
+
+func (lm *LoginFailureModel) Count(ctx context.Context, ip string, since time.Time) (int, error) {
+	return 0, nil
+}
+
+func (lm *LoginFailureModel) DeleteBefore(ctx context.Context, before time.Time) error {
+	return nil
+}
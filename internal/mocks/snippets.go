@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"time"
 
 	"github.com/ahmadyogi543/snippetbox/internal/models"
@@ -16,11 +17,11 @@ var mockSnippet = &models.Snippet{
 
 type SnippetModel struct{}
 
-func (sm *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+func (sm *SnippetModel) Insert(ctx context.Context, title string, content string, expires int) (int, error) {
 	return 2, nil
 }
 
-func (sm *SnippetModel) Get(id int) (*models.Snippet, error) {
+func (sm *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
@@ -29,6 +30,19 @@ func (sm *SnippetModel) Get(id int) (*models.Snippet, error) {
 	}
 }
 
-func (sm *SnippetModel) Latest() ([]*models.Snippet, error) {
+func (sm *SnippetModel) Latest(ctx context.Context) ([]*models.Snippet, error) {
 	return []*models.Snippet{mockSnippet}, nil
 }
+
+func (sm *SnippetModel) Delete(ctx context.Context, id int) error {
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (sm *SnippetModel) Stats(ctx context.Context) (*models.SnippetStats, error) {
+	return &models.SnippetStats{Count: 1, Size: int64(len(mockSnippet.Title) + len(mockSnippet.Content))}, nil
+}
@@ -0,0 +1,36 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+type TokenModel struct{}
+
+func (tm *TokenModel) New(ctx context.Context, userID int, ttl time.Duration, scope string) (*models.Token, error) {
+	return &models.Token{
+		Plaintext: "valid-token",
+		Hash:      models.HashToken("valid-token"),
+		UserID:    userID,
+		Expiry:    time.Now().Add(ttl),
+		Scope:     scope,
+	}, nil
+}
+
+func (tm *TokenModel) Use(ctx context.Context, plaintext, scope string) (int, error) {
+	if plaintext == "valid-token" {
+		return 2, nil
+	}
+
+	return 0, models.ErrNoRecord
+}
+
+func (tm *TokenModel) DeleteAllForUser(ctx context.Context, userID int, scope string) error {
+	return nil
+}
+
+func (tm *TokenModel) DeleteExpired(ctx context.Context) error {
+	return nil
+}
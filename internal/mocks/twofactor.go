@@ -0,0 +1,68 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/models"
+)
+
+// TwoFactorSecret is the TOTP secret of the users in TwoFactorModel, user 1
+// has it set up but not enabled and user 3 has it enabled.
+const TwoFactorSecret = "JBSWY3DPEHPK3PXP"
+
+// RecoveryCode is the recovery code of user 3 that works.
+const RecoveryCode = "abcd-efgh-ijkl-mnop"
+
+type TwoFactorModel struct{}
+
+func (tm *TwoFactorModel) Get(ctx context.Context, userID int) (*models.TwoFactor, error) {
+	switch userID {
+	case 1:
+		return &models.TwoFactor{UserID: 1, Secret: TwoFactorSecret, Created: time.Now()}, nil
+	case 3:
+		return &models.TwoFactor{UserID: 3, Secret: TwoFactorSecret, EnabledAt: time.Now(), Created: time.Now()}, nil
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (tm *TwoFactorModel) Setup(ctx context.Context, userID int, secret string) error {
+	if userID == 3 {
+		return models.ErrTwoFactorEnabled
+	}
+
+	return nil
+}
+
+func (tm *TwoFactorModel) Enable(ctx context.Context, userID int, counter int64) ([]string, error) {
+	if userID != 1 {
+		return nil, models.ErrNoRecord
+	}
+
+	return []string{RecoveryCode}, nil
+}
+
+func (tm *TwoFactorModel) Disable(ctx context.Context, userID int) error {
+	return nil
+}
+
+func (tm *TwoFactorModel) UseCounter(ctx context.Context, userID int, counter int64) (bool, error) {
+	return userID == 3, nil
+}
+
+func (tm *TwoFactorModel) UseRecoveryCode(ctx context.Context, userID int, code string) (bool, error) {
+	return userID == 3 && code == RecoveryCode, nil
+}
+
+func (tm *TwoFactorModel) RegenerateRecoveryCodes(ctx context.Context, userID int) ([]string, error) {
+	return []string{RecoveryCode}, nil
+}
+
+func (tm *TwoFactorModel) RecoveryCodesLeft(ctx context.Context, userID int) (int, error) {
+	if userID == 3 {
+		return models.RecoveryCodeCount, nil
+	}
+
+	return 0, nil
+}
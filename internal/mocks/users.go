@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"time"
 
 	"github.com/ahmadyogi543/snippetbox/internal/models"
@@ -8,24 +9,88 @@ import (
 
 type UserModel struct{}
 
-func (um *UserModel) Get(id int) (*models.User, error) {
-	if id == 1 {
+func (um *UserModel) Get(ctx context.Context, id int) (*models.User, error) {
+	switch id {
+	case 1:
+		return &models.User{
+			ID:              1,
+			Name:            "Ahmad Yogi",
+			Email:           "ayogi@snippetbox.sh",
+			Created:         time.Now(),
+			EmailVerifiedAt: time.Now(),
+			Role:            models.RoleUser,
+		}, nil
+	case 2:
 		return &models.User{
-			ID:      1,
-			Name:    "Ahmad Yogi",
-			Email:   "ayogi@snippetbox.sh",
+			ID:      2,
+			Name:    "Jane Doe",
+			Email:   "unverified@snippetbox.sh",
 			Created: time.Now(),
+			Role:    models.RoleUser,
+		}, nil
+	case 3:
+		return &models.User{
+			ID:              3,
+			Name:            "John Doe",
+			Email:           "twofactor@snippetbox.sh",
+			Created:         time.Now(),
+			EmailVerifiedAt: time.Now(),
+			Role:            models.RoleUser,
+		}, nil
+	case 5:
+		return &models.User{
+			ID:              5,
+			Name:            "Ada Admin",
+			Email:           "admin@snippetbox.sh",
+			Created:         time.Now(),
+			EmailVerifiedAt: time.Now(),
+			Role:            models.RoleAdmin,
+		}, nil
+	case 6:
+		return &models.User{
+			ID:              6,
+			Name:            "Mo Moderator",
+			Email:           "moderator@snippetbox.sh",
+			Created:         time.Now(),
+			EmailVerifiedAt: time.Now(),
+			Role:            models.RoleModerator,
 		}, nil
 	}
 
 	return nil, models.ErrNoRecord
 }
 
-func (um *UserModel) UpdatePassword(id int, currentPassword, newPassword string) error {
+func (um *UserModel) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	switch email {
+	case "ayogi@snippetbox.sh":
+		return um.Get(ctx, 1)
+	case "unverified@snippetbox.sh":
+		return um.Get(ctx, 2)
+	case "twofactor@snippetbox.sh":
+		return um.Get(ctx, 3)
+	case "admin@snippetbox.sh":
+		return um.Get(ctx, 5)
+	case "moderator@snippetbox.sh":
+		return um.Get(ctx, 6)
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (um *UserModel) UpdatePassword(ctx context.Context, id int, currentPassword, newPassword string) error {
 	return nil
 }
 
-func (um *UserModel) Insert(name, email, password string) error {
+func (um *UserModel) SetPassword(ctx context.Context, id int, newPassword string) error {
+	switch id {
+	case 1, 2:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (um *UserModel) Insert(ctx context.Context, name, email, password string) error {
 	switch email {
 	case "duplicate@snippetbox.sh":
 		return models.ErrDuplicateEmail
@@ -34,18 +99,123 @@ func (um *UserModel) Insert(name, email, password string) error {
 	}
 }
 
-func (um *UserModel) Authenticate(email, password string) (int, error) {
+func (um *UserModel) Provision(ctx context.Context, name, email string) (int, error) {
+	switch email {
+	case "ayogi@snippetbox.sh", "unverified@snippetbox.sh", "twofactor@snippetbox.sh":
+		return 0, models.ErrDuplicateEmail
+	default:
+		return 4, nil
+	}
+}
+
+func (um *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
 	if email == "ayogi@snippetbox.sh" && password == "12345678" {
 		return 1, nil
 	}
+	if email == "unverified@snippetbox.sh" && password == "12345678" {
+		return 2, nil
+	}
+	if email == "twofactor@snippetbox.sh" && password == "12345678" {
+		return 3, nil
+	}
+	if email == "admin@snippetbox.sh" && password == "12345678" {
+		return 5, nil
+	}
+	if email == "moderator@snippetbox.sh" && password == "12345678" {
+		return 6, nil
+	}
+	if email == "locked@snippetbox.sh" {
+		return 0, &models.LockoutError{UserID: 1, Until: time.Now().Add(15 * time.Minute), Started: true}
+	}
 	return 0, models.ErrInvalidCredentials
 }
 
-func (um *UserModel) Exists(id int) (bool, error) {
+func (um *UserModel) Exists(ctx context.Context, id int) (bool, error) {
 	switch id {
-	case 1:
+	case 1, 2, 3, 5, 6:
 		return true, nil
 	default:
 		return false, nil
 	}
 }
+
+func (um *UserModel) Unlock(ctx context.Context, id int) error {
+	if id == 1 {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (um *UserModel) VerifyEmail(ctx context.Context, id int) error {
+	switch id {
+	case 1, 2:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (um *UserModel) List(ctx context.Context) ([]*models.User, error) {
+	var users []*models.User
+	for _, id := range []int{1, 2, 3, 5, 6} {
+		user, err := um.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+func (um *UserModel) RecordFailedLogin(ctx context.Context, id int) error {
+	return models.ErrInvalidCredentials
+}
+
+func (um *UserModel) ResetFailedLogins(ctx context.Context, id int) error {
+	return nil
+}
+
+func (um *UserModel) Count(ctx context.Context) (int, int, error) {
+	return 5, 0, nil
+}
+
+func (um *UserModel) Emails(ctx context.Context, ids []int) (map[int]string, error) {
+	emails := make(map[int]string, len(ids))
+	for _, id := range ids {
+		if user, err := um.Get(ctx, id); err == nil {
+			emails[id] = user.Email
+		}
+	}
+
+	return emails, nil
+}
+
+func (um *UserModel) Signups(ctx context.Context, since time.Time) ([]time.Time, error) {
+	return []time.Time{time.Now()}, nil
+}
+
+func (um *UserModel) SetRole(ctx context.Context, id int, role models.Role) error {
+	if !role.Valid() {
+		return models.ErrInvalidRole
+	}
+
+	return um.set(ctx, id)
+}
+
+func (um *UserModel) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	return um.set(ctx, id)
+}
+
+func (um *UserModel) set(ctx context.Context, id int) error {
+	exists, err := um.Exists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
+)
+
+// AuditEventType is what happened in an audit event.
+type AuditEventType string
+
+const (
+	EventLogin          AuditEventType = "login"
+	EventLoginFailed    AuditEventType = "login.failed"
+	EventLogout         AuditEventType = "logout"
+	EventSignup         AuditEventType = "signup"
+	EventPasswordChange AuditEventType = "password.change"
+	EventPasswordReset  AuditEventType = "password.reset"
+	EventSnippetCreate  AuditEventType = "snippet.create"
+	EventSnippetDelete  AuditEventType = "snippet.delete"
+	EventUserRole       AuditEventType = "user.role"
+	EventUserDisable    AuditEventType = "user.disable"
+	EventUserEnable     AuditEventType = "user.enable"
+	EventUserUnlock     AuditEventType = "user.unlock"
+)
+
+// AuditEventTypes are all the event types, for the filters.
+var AuditEventTypes = []AuditEventType{
+	EventLogin,
+	EventLoginFailed,
+	EventLogout,
+	EventSignup,
+	EventPasswordChange,
+	EventPasswordReset,
+	EventSnippetCreate,
+	EventSnippetDelete,
+	EventUserRole,
+	EventUserDisable,
+	EventUserEnable,
+	EventUserUnlock,
+}
+
+func (t AuditEventType) Valid() bool {
+	for _, eventType := range AuditEventTypes {
+		if eventType == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The types of the targets of the audit events.
+const (
+	TargetUser    = "user"
+	TargetSnippet = "snippet"
+)
+
+// AuditEvent is a security-relevant event, like a login or an action of an
+// admin.
+type AuditEvent struct {
+	ID   int
+	Type AuditEventType
+	// ActorID is the user who did it, zero for an anonymous client like
+	// someone failing to log in.
+	ActorID int
+	// TargetType and TargetID are what it was done to, empty and zero when
+	// it was nothing in particular.
+	TargetType string
+	TargetID   int
+	IP         string
+	UserAgent  string
+	// Detail is anything else worth knowing, like the role given to a user.
+	Detail  string
+	Created time.Time
+}
+
+// AuditFilter selects the audit events returned by List. The zero value
+// selects them all.
+type AuditFilter struct {
+	Type AuditEventType
+	// UserID selects the events done by the user or to them.
+	UserID int
+	IP     string
+	// Since and Until bound the time of the events, Until excluded.
+	Since time.Time
+	Until time.Time
+	// Limit is how many events to return at most, no limit when zero.
+	Limit int
+	// BeforeID selects the events older than the one with the ID, to page
+	// through them with Limit.
+	BeforeID int
+}
+
+// AuditEventModelInterface is append-only, the events can't be changed or
+// deleted.
+type AuditEventModelInterface interface {
+	Insert(ctx context.Context, event *AuditEvent) error
+	List(ctx context.Context, filter AuditFilter) ([]*AuditEvent, error)
+}
+
+type AuditEventModel struct {
+	DB *sql.DB
+	QueryOptions
+}
+
+func (am *AuditEventModel) Insert(ctx context.Context, event *AuditEvent) error {
+	ctx, span := trace.Start(ctx, "AuditEventModel.Insert")
+	defer span.End()
+
+	query := `
+		INSERT INTO audit_events (type, actor_id, target_type, target_id, ip, user_agent, detail, created)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	queryCtx, done := am.startQuery(ctx, query)
+	_, err := am.DB.ExecContext(queryCtx, query, event.Type, nullID(event.ActorID), event.TargetType, nullID(event.TargetID), event.IP, event.UserAgent, event.Detail, time.Now().UTC())
+	done()
+
+	return err
+}
+
+// List returns the events selected by filter, the most recent first.
+func (am *AuditEventModel) List(ctx context.Context, filter AuditFilter) ([]*AuditEvent, error) {
+	ctx, span := trace.Start(ctx, "AuditEventModel.List")
+	defer span.End()
+
+	var where []string
+	var args []any
+	if filter.Type != "" {
+		where = append(where, "type = ?")
+		args = append(args, filter.Type)
+	}
+	if filter.UserID != 0 {
+		where = append(where, "(actor_id = ? OR (target_type = ? AND target_id = ?))")
+		args = append(args, filter.UserID, TargetUser, filter.UserID)
+	}
+	if filter.IP != "" {
+		where = append(where, "ip = ?")
+		args = append(args, filter.IP)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "created >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "created < ?")
+		args = append(args, filter.Until.UTC())
+	}
+	if filter.BeforeID != 0 {
+		where = append(where, "id < ?")
+		args = append(args, filter.BeforeID)
+	}
+
+	query := "SELECT id, type, actor_id, target_type, target_id, ip, user_agent, detail, created FROM audit_events"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	queryCtx, done := am.startQuery(ctx, query)
+	defer done()
+
+	rows, err := am.DB.QueryContext(queryCtx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*AuditEvent{}
+	for rows.Next() {
+		var event AuditEvent
+		var actorID, targetID sql.NullInt64
+
+		err := rows.Scan(
+			&event.ID,
+			&event.Type,
+			&actorID,
+			&event.TargetType,
+			&targetID,
+			&event.IP,
+			&event.UserAgent,
+			&event.Detail,
+			&event.Created,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		event.ActorID = int(actorID.Int64)
+		event.TargetID = int(targetID.Int64)
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// nullID stores the zero IDs as NULL.
+func nullID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestAuditEventModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestAuditEventModel test")
+	}
+
+	db := newTestDB(t)
+	am := AuditEventModel{DB: db}
+	ctx := context.Background()
+
+	start := time.Now().Add(-time.Minute)
+	events := []*AuditEvent{
+		{Type: EventLoginFailed, TargetType: TargetUser, TargetID: 1, IP: "192.0.2.1", UserAgent: "curl/8.0", Detail: "ahmady@snippetbox.sh"},
+		{Type: EventLogin, ActorID: 1, TargetType: TargetUser, TargetID: 1, IP: "192.0.2.2", UserAgent: "Firefox"},
+		{Type: EventSnippetCreate, ActorID: 1, TargetType: TargetSnippet, TargetID: 2, IP: "192.0.2.2", UserAgent: "Firefox"},
+		{Type: EventUserDisable, ActorID: 2, TargetType: TargetUser, TargetID: 3, IP: "192.0.2.3", UserAgent: "Chrome"},
+	}
+	for _, event := range events {
+		assert.NilError(t, am.Insert(ctx, event))
+	}
+
+	all, err := am.List(ctx, AuditFilter{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(all), 4)
+	assert.Equal(t, all[0].Type, EventUserDisable)
+	assert.Equal(t, all[3].ActorID, 0)
+	assert.Equal(t, all[3].Detail, "ahmady@snippetbox.sh")
+	assert.Equal(t, all[3].Created.After(start), true)
+
+	tests := []struct {
+		name      string
+		filter    AuditFilter
+		wantTypes []AuditEventType
+	}{
+		{
+			name:      "Type",
+			filter:    AuditFilter{Type: EventLogin},
+			wantTypes: []AuditEventType{EventLogin},
+		},
+		{
+			name:      "User",
+			filter:    AuditFilter{UserID: 1},
+			wantTypes: []AuditEventType{EventSnippetCreate, EventLogin, EventLoginFailed},
+		},
+		{
+			name:      "Target user",
+			filter:    AuditFilter{UserID: 3},
+			wantTypes: []AuditEventType{EventUserDisable},
+		},
+		{
+			name:      "IP",
+			filter:    AuditFilter{IP: "192.0.2.2"},
+			wantTypes: []AuditEventType{EventSnippetCreate, EventLogin},
+		},
+		{
+			name:      "Limit",
+			filter:    AuditFilter{UserID: 1, Limit: 1},
+			wantTypes: []AuditEventType{EventSnippetCreate},
+		},
+		{
+			name:      "Before ID",
+			filter:    AuditFilter{UserID: 1, BeforeID: all[1].ID, Limit: 1},
+			wantTypes: []AuditEventType{EventLogin},
+		},
+		{
+			name:      "Since",
+			filter:    AuditFilter{Since: time.Now().Add(time.Minute)},
+			wantTypes: []AuditEventType{},
+		},
+		{
+			name:      "Until",
+			filter:    AuditFilter{Until: start},
+			wantTypes: []AuditEventType{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events, err := am.List(ctx, test.filter)
+			assert.NilError(t, err)
+
+			assert.Equal(t, len(events), len(test.wantTypes))
+			for i, event := range events {
+				assert.Equal(t, event.Type, test.wantTypes[i])
+			}
+		})
+	}
+
+	t.Run("Append-only", func(t *testing.T) {
+		_, err := db.Exec("UPDATE audit_events SET type = ?", EventLogin)
+		if err == nil {
+			t.Error("expected an error updating the events; got nil instead")
+		}
+
+		_, err = db.Exec("DELETE FROM audit_events")
+		if err == nil {
+			t.Error("expected an error deleting the events; got nil instead")
+		}
+	})
+}
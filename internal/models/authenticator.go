@@ -0,0 +1,134 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
+)
+
+// Authenticator checks the passwords of the users against a directory
+// outside the database. It returns ErrInvalidCredentials when the directory
+// doesn't know the user or the password is wrong.
+type Authenticator interface {
+	Authenticate(ctx context.Context, email, password string) (*DirectoryUser, error)
+}
+
+// DirectoryUser is a user an Authenticator logged in, with the attributes
+// their account is created with. ID identifies them in the directory for
+// good, unlike their email address.
+type DirectoryUser struct {
+	ID    string
+	Name  string
+	Email string
+}
+
+// DirectoryUserModel is a UserModelInterface logging the users in with a
+// directory first. The users it knows get an account the first time they
+// log in, the others log in with their local password, which keeps working
+// when the directory is down too. The accounts are linked to the directory
+// users in Identities, under Issuer, which should stay the same when the
+// directory moves to a new URL.
+type DirectoryUserModel struct {
+	UserModelInterface
+	Directory  Authenticator
+	Identities IdentityModelInterface
+	Issuer     string
+	Logger     *slog.Logger
+}
+
+func (dm *DirectoryUserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	ctx, span := trace.Start(ctx, "DirectoryUserModel.Authenticate")
+	defer span.End()
+
+	// the lockouts apply to the directory logins too, or they'd be a way to
+	// keep guessing the passwords of the locked accounts.
+	user, err := dm.GetByEmail(ctx, email)
+	if err != nil && !errors.Is(err, ErrNoRecord) {
+		return 0, err
+	}
+	if user != nil && user.Locked() {
+		return 0, &LockoutError{UserID: user.ID, Until: user.LockedUntil}
+	}
+
+	dirUser, err := dm.Directory.Authenticate(ctx, email, password)
+	if err != nil {
+		if !errors.Is(err, ErrInvalidCredentials) && dm.Logger != nil {
+			dm.Logger.Warn("directory login failed, trying the local accounts", "error", err.Error())
+		}
+
+		return dm.UserModelInterface.Authenticate(ctx, email, password)
+	}
+
+	return dm.account(ctx, dirUser)
+}
+
+// account returns the ID of the account linked to the directory user. The
+// first time, it's linked to the account with the same email address, or to
+// a new account. An account that didn't prove it owns the address isn't
+// linked, anyone can sign up with the address of a directory user and wait
+// for them to log in, it returns ErrUnverifiedAccount.
+func (dm *DirectoryUserModel) account(ctx context.Context, dirUser *DirectoryUser) (int, error) {
+	id, err := dm.Identities.Get(ctx, dm.Issuer, dirUser.ID)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrNoRecord):
+		id, err = dm.link(ctx, dirUser)
+		if err != nil {
+			return 0, err
+		}
+	default:
+		return 0, err
+	}
+
+	user, err := dm.Get(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	if user.Locked() {
+		return 0, &LockoutError{UserID: user.ID, Until: user.LockedUntil}
+	}
+
+	if user.Disabled() {
+		return 0, ErrAccountDisabled
+	}
+
+	return user.ID, nil
+}
+
+// link links the directory user to the account with their email address,
+// or to a new one, and returns its ID.
+func (dm *DirectoryUserModel) link(ctx context.Context, dirUser *DirectoryUser) (int, error) {
+	var id int
+
+	user, err := dm.GetByEmail(ctx, dirUser.Email)
+	switch {
+	case err == nil:
+		if !user.EmailVerified() {
+			return 0, ErrUnverifiedAccount
+		}
+		id = user.ID
+	case errors.Is(err, ErrNoRecord):
+		name := strings.TrimSpace(dirUser.Name)
+		if name == "" {
+			name, _, _ = strings.Cut(dirUser.Email, "@")
+		}
+
+		id, err = dm.Provision(ctx, name, dirUser.Email)
+		if err != nil {
+			return 0, err
+		}
+	default:
+		return 0, err
+	}
+
+	err = dm.Identities.Insert(ctx, dm.Issuer, dirUser.ID, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
@@ -0,0 +1,192 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
+)
+
+// Credential is a passkey of a user, the WebAuthn credential they log in
+// with instead of their password.
+type Credential struct {
+	ID     []byte
+	UserID int
+	Name   string
+	// PublicKey is the COSE_Key of the credential.
+	PublicKey []byte
+	SignCount uint32
+	Created   time.Time
+	// LastUsed is zero until the passkey is used to log in.
+	LastUsed time.Time
+}
+
+type CredentialModelInterface interface {
+	Insert(ctx context.Context, credential *Credential) error
+	Get(ctx context.Context, id []byte) (*Credential, error)
+	ListForUser(ctx context.Context, userID int) ([]*Credential, error)
+	Use(ctx context.Context, id []byte, signCount uint32) error
+	Delete(ctx context.Context, userID int, id []byte) error
+}
+
+type CredentialModel struct {
+	DB *sql.DB
+	QueryOptions
+}
+
+// Insert stores the credential. It returns ErrDuplicateCredential when it's
+// registered already, to this user or another.
+func (cm *CredentialModel) Insert(ctx context.Context, credential *Credential) error {
+	ctx, span := trace.Start(ctx, "CredentialModel.Insert")
+	defer span.End()
+
+	query := `
+		INSERT INTO credentials (id, user_id, name, public_key, sign_count, created)
+		VALUES(?, ?, ?, ?, ?, ?)
+	`
+
+	queryCtx, done := cm.startQuery(ctx, query)
+	_, err := cm.DB.ExecContext(queryCtx, query, credential.ID, credential.UserID, credential.Name, credential.PublicKey, credential.SignCount, time.Now().UTC())
+	done()
+	if err != nil {
+		if isUniqueViolation(err, "PRIMARY", "credentials.id") {
+			return ErrDuplicateCredential
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (cm *CredentialModel) Get(ctx context.Context, id []byte) (*Credential, error) {
+	ctx, span := trace.Start(ctx, "CredentialModel.Get")
+	defer span.End()
+
+	query := `
+		SELECT id, user_id, name, public_key, sign_count, created, last_used
+		FROM credentials WHERE id = ?
+	`
+
+	queryCtx, done := cm.startQuery(ctx, query)
+	credential, err := scanCredential(cm.DB.QueryRowContext(queryCtx, query, id))
+	done()
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return credential, nil
+}
+
+func (cm *CredentialModel) ListForUser(ctx context.Context, userID int) ([]*Credential, error) {
+	ctx, span := trace.Start(ctx, "CredentialModel.ListForUser")
+	defer span.End()
+
+	query := `
+		SELECT id, user_id, name, public_key, sign_count, created, last_used
+		FROM credentials WHERE user_id = ? ORDER BY created
+	`
+
+	queryCtx, done := cm.startQuery(ctx, query)
+	defer done()
+
+	rows, err := cm.DB.QueryContext(queryCtx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credentials := []*Credential{}
+	for rows.Next() {
+		credential, err := scanCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// Use records a login with the credential and its new signature counter.
+func (cm *CredentialModel) Use(ctx context.Context, id []byte, signCount uint32) error {
+	ctx, span := trace.Start(ctx, "CredentialModel.Use")
+	defer span.End()
+
+	query := "UPDATE credentials SET sign_count = ?, last_used = ? WHERE id = ?"
+
+	queryCtx, done := cm.startQuery(ctx, query)
+	result, err := cm.DB.ExecContext(queryCtx, query, signCount, time.Now().UTC(), id)
+	done()
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Delete deletes the credential of the user, ErrNoRecord when they have no
+// such credential.
+func (cm *CredentialModel) Delete(ctx context.Context, userID int, id []byte) error {
+	ctx, span := trace.Start(ctx, "CredentialModel.Delete")
+	defer span.End()
+
+	query := "DELETE FROM credentials WHERE user_id = ? AND id = ?"
+
+	queryCtx, done := cm.startQuery(ctx, query)
+	result, err := cm.DB.ExecContext(queryCtx, query, userID, id)
+	done()
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func scanCredential(row interface{ Scan(...any) error }) (*Credential, error) {
+	var credential Credential
+	var lastUsed sql.NullTime
+
+	err := row.Scan(
+		&credential.ID,
+		&credential.UserID,
+		&credential.Name,
+		&credential.PublicKey,
+		&credential.SignCount,
+		&credential.Created,
+		&lastUsed,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	credential.LastUsed = lastUsed.Time
+
+	return &credential, nil
+}
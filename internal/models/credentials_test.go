@@ -0,0 +1,53 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestCredentialModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestCredentialModel test")
+	}
+
+	db := newTestDB(t)
+	cm := CredentialModel{DB: db}
+	ctx := context.Background()
+
+	credential := &Credential{ID: []byte{1, 2, 3}, UserID: 1, Name: "Laptop", PublicKey: []byte{4, 5, 6}, SignCount: 7}
+	assert.NilError(t, cm.Insert(ctx, credential))
+
+	err := cm.Insert(ctx, credential)
+	assert.Equal(t, err, ErrDuplicateCredential)
+
+	got, err := cm.Get(ctx, []byte{1, 2, 3})
+	assert.NilError(t, err)
+	assert.Equal(t, got.UserID, 1)
+	assert.Equal(t, got.Name, "Laptop")
+	assert.Equal(t, string(got.PublicKey), string([]byte{4, 5, 6}))
+	assert.Equal(t, got.SignCount, uint32(7))
+	assert.Equal(t, got.LastUsed.IsZero(), true)
+
+	_, err = cm.Get(ctx, []byte{9})
+	assert.Equal(t, err, ErrNoRecord)
+
+	assert.NilError(t, cm.Use(ctx, []byte{1, 2, 3}, 8))
+
+	credentials, err := cm.ListForUser(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(credentials), 1)
+	assert.Equal(t, credentials[0].SignCount, uint32(8))
+	assert.Equal(t, credentials[0].LastUsed.IsZero(), false)
+
+	// only the owner can delete it.
+	err = cm.Delete(ctx, 2, []byte{1, 2, 3})
+	assert.Equal(t, err, ErrNoRecord)
+
+	assert.NilError(t, cm.Delete(ctx, 1, []byte{1, 2, 3}))
+
+	credentials, err = cm.ListForUser(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(credentials), 0)
+}
@@ -1,9 +1,59 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
 
 var (
-	ErrNoRecord           = errors.New("models: no matching record found")
-	ErrInvalidCredentials = errors.New("models: invalid credentials")
-	ErrDuplicateEmail     = errors.New("models: duplicate email")
+	ErrNoRecord            = errors.New("models: no matching record found")
+	ErrInvalidCredentials  = errors.New("models: invalid credentials")
+	ErrDuplicateEmail      = errors.New("models: duplicate email")
+	ErrAccountLocked       = errors.New("models: account locked")
+	ErrTwoFactorEnabled    = errors.New("models: two-factor authentication already enabled")
+	ErrDuplicateCredential = errors.New("models: duplicate credential")
+	ErrDuplicateIdentity   = errors.New("models: duplicate identity")
+	ErrAccountDisabled     = errors.New("models: account disabled")
+	ErrInvalidRole         = errors.New("models: invalid role")
+	ErrUnverifiedAccount   = errors.New("models: account with the email address isn't verified")
 )
+
+// LockoutError is returned by Authenticate for a locked account. Started is
+// set when the failed attempt is the one that locked it.
+type LockoutError struct {
+	UserID  int
+	Until   time.Time
+	Started bool
+}
+
+func (e *LockoutError) Error() string {
+	return ErrAccountLocked.Error()
+}
+
+func (e *LockoutError) Is(target error) bool {
+	return target == ErrAccountLocked
+}
+
+// isUniqueViolation reports whether err is a unique or primary key constraint
+// violation. MySQL reports the name of the violated constraint, while SQLite
+// reports the table and column, so both are needed to recognise the same
+// violation.
+func isUniqueViolation(err error, mySQLConstraint, sqliteColumn string) bool {
+	var mySQLError *mysql.MySQLError
+	if errors.As(err, &mySQLError) {
+		return mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, mySQLConstraint)
+	}
+
+	var sqliteError *sqlite.Error
+	if errors.As(err, &sqliteError) {
+		code := sqliteError.Code()
+		return (code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY) && strings.Contains(sqliteError.Error(), sqliteColumn)
+	}
+
+	return false
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
+)
+
+// IdentityModelInterface links the accounts to the users of single sign-on
+// providers, known by the issuer and the subject of their ID tokens.
+type IdentityModelInterface interface {
+	Get(ctx context.Context, issuer, subject string) (int, error)
+	Insert(ctx context.Context, issuer, subject string, userID int) error
+}
+
+type IdentityModel struct {
+	DB *sql.DB
+	QueryOptions
+}
+
+// Get returns the ID of the user linked to the identity.
+func (im *IdentityModel) Get(ctx context.Context, issuer, subject string) (int, error) {
+	ctx, span := trace.Start(ctx, "IdentityModel.Get")
+	defer span.End()
+
+	var userID int
+
+	query := "SELECT user_id FROM identities WHERE issuer = ? AND subject = ?"
+
+	queryCtx, done := im.startQuery(ctx, query)
+	err := im.DB.QueryRowContext(queryCtx, query, issuer, subject).Scan(&userID)
+	done()
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		} else {
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+// Insert links the identity to the user. An identity is linked to one user
+// only, linking it again returns ErrDuplicateIdentity.
+func (im *IdentityModel) Insert(ctx context.Context, issuer, subject string, userID int) error {
+	ctx, span := trace.Start(ctx, "IdentityModel.Insert")
+	defer span.End()
+
+	query := `
+		INSERT INTO identities (issuer, subject, user_id, created)
+		VALUES(?, ?, ?, ?)
+	`
+
+	queryCtx, done := im.startQuery(ctx, query)
+	_, err := im.DB.ExecContext(queryCtx, query, issuer, subject, userID, time.Now().UTC())
+	done()
+	if err != nil {
+		if isUniqueViolation(err, "PRIMARY", "identities.issuer") {
+			return ErrDuplicateIdentity
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestIdentityModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestIdentityModel test")
+	}
+
+	db := newTestDB(t)
+	im := IdentityModel{DB: db}
+	ctx := context.Background()
+
+	_, err := im.Get(ctx, "https://accounts.example.com", "248289761001")
+	assert.Equal(t, err, ErrNoRecord)
+
+	assert.NilError(t, im.Insert(ctx, "https://accounts.example.com", "248289761001", 1))
+
+	err = im.Insert(ctx, "https://accounts.example.com", "248289761001", 1)
+	assert.Equal(t, err, ErrDuplicateIdentity)
+
+	userID, err := im.Get(ctx, "https://accounts.example.com", "248289761001")
+	assert.NilError(t, err)
+	assert.Equal(t, userID, 1)
+
+	// the subjects are only unique for their issuer.
+	_, err = im.Get(ctx, "https://login.example.org", "248289761001")
+	assert.Equal(t, err, ErrNoRecord)
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/ldap"
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
+)
+
+// LDAPAuthenticator is an Authenticator binding to an LDAP directory as the
+// users. Their entry is either UserDN, or found with Filter under BaseDN
+// after binding as BindDN, anonymously when it's empty. In UserDN and
+// Filter, {email} is replaced with the email address the user typed and
+// {username} with the part before the @.
+type LDAPAuthenticator struct {
+	URL          string
+	TLSConfig    *tls.Config
+	Timeout      time.Duration
+	BindDN       string
+	BindPassword string
+	UserDN       string
+	BaseDN       string
+	Filter       string
+	// NameAttribute and EmailAttribute are the attributes of the entries
+	// the accounts are created with, cn and mail when empty. The entries
+	// without an email address can't log in.
+	NameAttribute  string
+	EmailAttribute string
+}
+
+func (la *LDAPAuthenticator) Authenticate(ctx context.Context, email, password string) (*DirectoryUser, error) {
+	ctx, span := trace.Start(ctx, "LDAPAuthenticator.Authenticate")
+	defer span.End()
+
+	if password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	if la.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, la.Timeout)
+		defer cancel()
+	}
+
+	conn, err := ldap.Dial(ctx, la.URL, la.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	nameAttribute, emailAttribute := la.attributes()
+
+	var entry *ldap.Entry
+	if la.UserDN != "" {
+		dn := expandTemplate(la.UserDN, email, ldap.EscapeDN)
+
+		err = conn.Bind(dn, password)
+		if err != nil {
+			return nil, bindError(err)
+		}
+
+		entries, err := conn.Search(&ldap.SearchRequest{
+			BaseDN:     dn,
+			Scope:      ldap.ScopeBaseObject,
+			Filter:     "(objectClass=*)",
+			Attributes: []string{"entryUUID", nameAttribute, emailAttribute},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) != 1 {
+			return nil, fmt.Errorf("ldap: no entry for %q", dn)
+		}
+		entry = entries[0]
+	} else {
+		if la.BindDN != "" {
+			err = conn.Bind(la.BindDN, la.BindPassword)
+			if err != nil {
+				return nil, fmt.Errorf("ldap: binding as %q: %w", la.BindDN, err)
+			}
+		}
+
+		entries, err := conn.Search(&ldap.SearchRequest{
+			BaseDN:     la.BaseDN,
+			Scope:      ldap.ScopeWholeSubtree,
+			Filter:     expandTemplate(la.Filter, email, ldap.EscapeFilter),
+			Attributes: []string{"entryUUID", nameAttribute, emailAttribute},
+			SizeLimit:  2,
+		})
+		switch {
+		case ldap.IsResult(err, ldap.ResultSizeLimitExceeded) || len(entries) > 1:
+			return nil, fmt.Errorf("ldap: several entries match the filter for %q", email)
+		case err != nil:
+			return nil, err
+		case len(entries) == 0:
+			return nil, ErrInvalidCredentials
+		}
+		entry = entries[0]
+
+		err = conn.Bind(entry.DN, password)
+		if err != nil {
+			return nil, bindError(err)
+		}
+	}
+
+	// the entries keep their entryUUID when they're renamed or moved, the
+	// directories without it are left with the DN.
+	id := entry.Get("entryUUID")
+	if id == "" {
+		id = entry.DN
+	}
+
+	dirUser := &DirectoryUser{ID: id, Name: entry.Get(nameAttribute), Email: entry.Get(emailAttribute)}
+	if dirUser.Email == "" {
+		return nil, fmt.Errorf("ldap: entry %q has no %s attribute", entry.DN, emailAttribute)
+	}
+
+	return dirUser, nil
+}
+
+func (la *LDAPAuthenticator) attributes() (name, email string) {
+	name, email = la.NameAttribute, la.EmailAttribute
+	if name == "" {
+		name = "cn"
+	}
+	if email == "" {
+		email = "mail"
+	}
+
+	return name, email
+}
+
+// expandTemplate replaces the placeholders of the template with the email
+// address and its username, escaped.
+func expandTemplate(template, email string, escape func(string) string) string {
+	username, _, _ := strings.Cut(email, "@")
+
+	return strings.NewReplacer("{email}", escape(email), "{username}", escape(username)).Replace(template)
+}
+
+func bindError(err error) error {
+	if ldap.IsResult(err, ldap.ResultInvalidCredentials) {
+		return ErrInvalidCredentials
+	}
+
+	return err
+}
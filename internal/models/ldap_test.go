@@ -0,0 +1,295 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/ldap/ldaptest"
+)
+
+func newTestDirectory(t *testing.T) *ldaptest.Server {
+	server := ldaptest.NewServer(
+		ldaptest.Entry{
+			DN:       "cn=snippetbox,ou=services,dc=example,dc=com",
+			Password: "s3rvice",
+		},
+		ldaptest.Entry{
+			DN:       "uid=jane,ou=people,dc=example,dc=com",
+			Password: "pa55word",
+			Attributes: map[string][]string{
+				"objectClass": {"inetOrgPerson"},
+				"uid":         {"jane"},
+				"cn":          {"Jane Doe"},
+				"displayName": {"Jane"},
+				"mail":        {"jane@example.com"},
+				"entryUUID":   {"0c6d4ba4-3f1e-4b8e-9d5c-6a8b1f2e7d10"},
+			},
+		},
+		ldaptest.Entry{
+			DN:       "uid=john,ou=people,dc=example,dc=com",
+			Password: "pa55word",
+			Attributes: map[string][]string{
+				"objectClass": {"inetOrgPerson"},
+				"uid":         {"john"},
+				"cn":          {"John Doe"},
+			},
+		},
+	)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestLDAPAuthenticator(t *testing.T) {
+	server := newTestDirectory(t)
+
+	authenticators := map[string]*LDAPAuthenticator{
+		"DN template": {
+			URL:       server.URL(),
+			TLSConfig: server.ClientTLSConfig(),
+			UserDN:    "uid={username},ou=people,dc=example,dc=com",
+		},
+		"Search filter": {
+			URL:          server.URL(),
+			TLSConfig:    server.ClientTLSConfig(),
+			BindDN:       "cn=snippetbox,ou=services,dc=example,dc=com",
+			BindPassword: "s3rvice",
+			BaseDN:       "dc=example,dc=com",
+			Filter:       "(&(objectClass=inetOrgPerson)(mail={email}))",
+		},
+	}
+
+	for name, authenticator := range authenticators {
+		t.Run(name, func(t *testing.T) {
+			user, err := authenticator.Authenticate(context.Background(), "jane@example.com", "pa55word")
+			assert.NilError(t, err)
+			assert.Equal(t, *user, DirectoryUser{ID: "0c6d4ba4-3f1e-4b8e-9d5c-6a8b1f2e7d10", Name: "Jane Doe", Email: "jane@example.com"})
+
+			_, err = authenticator.Authenticate(context.Background(), "jane@example.com", "wrong password")
+			assert.Equal(t, err, ErrInvalidCredentials)
+
+			_, err = authenticator.Authenticate(context.Background(), "jane@example.com", "")
+			assert.Equal(t, err, ErrInvalidCredentials)
+
+			_, err = authenticator.Authenticate(context.Background(), "nobody@example.com", "pa55word")
+			assert.Equal(t, err, ErrInvalidCredentials)
+
+			// the values are escaped, they can't change the filter or DN.
+			_, err = authenticator.Authenticate(context.Background(), "*@example.com", "pa55word")
+			assert.Equal(t, err, ErrInvalidCredentials)
+		})
+	}
+
+	t.Run("Attribute mapping", func(t *testing.T) {
+		authenticator := &LDAPAuthenticator{
+			URL:           server.URL(),
+			TLSConfig:     server.ClientTLSConfig(),
+			UserDN:        "uid={username},ou=people,dc=example,dc=com",
+			NameAttribute: "displayName",
+		}
+
+		user, err := authenticator.Authenticate(context.Background(), "jane@example.com", "pa55word")
+		assert.NilError(t, err)
+		assert.Equal(t, user.Name, "Jane")
+
+		// john has no email address, so he can't have an account.
+		_, err = authenticator.Authenticate(context.Background(), "john@example.com", "pa55word")
+		assert.Equal(t, err != nil, true)
+		assert.Equal(t, errors.Is(err, ErrInvalidCredentials), false)
+	})
+
+	t.Run("Several entries", func(t *testing.T) {
+		authenticator := &LDAPAuthenticator{
+			URL:       server.URL(),
+			TLSConfig: server.ClientTLSConfig(),
+			BaseDN:    "dc=example,dc=com",
+			Filter:    "(objectClass=inetOrgPerson)",
+		}
+
+		_, err := authenticator.Authenticate(context.Background(), "jane@example.com", "pa55word")
+		assert.Equal(t, err != nil, true)
+		assert.Equal(t, errors.Is(err, ErrInvalidCredentials), false)
+	})
+}
+
+func TestDirectoryUserModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestDirectoryUserModel test")
+	}
+
+	server := newTestDirectory(t)
+	db := newTestDB(t)
+	um := &UserModel{DB: db, BcryptCost: 4, Lockout: LockoutPolicy{Threshold: 2, Duration: time.Minute}}
+	dm := &DirectoryUserModel{
+		UserModelInterface: um,
+		Directory: &LDAPAuthenticator{
+			URL:       server.URL(),
+			TLSConfig: server.ClientTLSConfig(),
+			UserDN:    "uid={username},ou=people,dc=example,dc=com",
+		},
+		Identities: &IdentityModel{DB: db},
+		Issuer:     "ldap",
+	}
+	ctx := context.Background()
+
+	// the first login creates the account.
+	id, err := dm.Authenticate(ctx, "jane@example.com", "pa55word")
+	assert.NilError(t, err)
+
+	user, err := dm.Get(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Name, "Jane Doe")
+	assert.Equal(t, user.EmailVerified(), true)
+
+	again, err := dm.Authenticate(ctx, "jane@example.com", "pa55word")
+	assert.NilError(t, err)
+	assert.Equal(t, again, id)
+
+	// the account is linked to the entry, not to its email address.
+	linked, err := dm.Identities.Get(ctx, "ldap", "0c6d4ba4-3f1e-4b8e-9d5c-6a8b1f2e7d10")
+	assert.NilError(t, err)
+	assert.Equal(t, linked, id)
+
+	// the local accounts still work.
+	assert.NilError(t, dm.Insert(ctx, "John Doe", "john@snippetbox.sh", "l0calpa55"))
+
+	localID, err := dm.Authenticate(ctx, "john@snippetbox.sh", "l0calpa55")
+	assert.NilError(t, err)
+
+	_, err = dm.Authenticate(ctx, "john@snippetbox.sh", "wrong password")
+	assert.Equal(t, err, ErrInvalidCredentials)
+
+	// and so do they when the directory is down.
+	server.Close()
+	id, err = dm.Authenticate(ctx, "john@snippetbox.sh", "l0calpa55")
+	assert.NilError(t, err)
+	assert.Equal(t, id, localID)
+}
+
+func TestDirectoryUserModelLockout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestDirectoryUserModelLockout test")
+	}
+
+	server := newTestDirectory(t)
+	db := newTestDB(t)
+	um := &UserModel{DB: db, BcryptCost: 4, Lockout: LockoutPolicy{Threshold: 1, Duration: time.Minute}}
+	dm := &DirectoryUserModel{
+		UserModelInterface: um,
+		Directory: &LDAPAuthenticator{
+			URL:       server.URL(),
+			TLSConfig: server.ClientTLSConfig(),
+			UserDN:    "uid={username},ou=people,dc=example,dc=com",
+		},
+		Identities: &IdentityModel{DB: db},
+		Issuer:     "ldap",
+	}
+	ctx := context.Background()
+
+	_, err := dm.Authenticate(ctx, "jane@example.com", "pa55word")
+	assert.NilError(t, err)
+
+	// the wrong directory passwords count against the local account.
+	_, err = dm.Authenticate(ctx, "jane@example.com", "wrong password")
+	assert.Equal(t, errors.Is(err, ErrAccountLocked), true)
+
+	_, err = dm.Authenticate(ctx, "jane@example.com", "pa55word")
+	assert.Equal(t, errors.Is(err, ErrAccountLocked), true)
+}
+
+func TestDirectoryUserModelDisabled(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestDirectoryUserModelDisabled test")
+	}
+
+	server := newTestDirectory(t)
+	db := newTestDB(t)
+	um := &UserModel{DB: db, BcryptCost: 4}
+	dm := &DirectoryUserModel{
+		UserModelInterface: um,
+		Directory: &LDAPAuthenticator{
+			URL:       server.URL(),
+			TLSConfig: server.ClientTLSConfig(),
+			UserDN:    "uid={username},ou=people,dc=example,dc=com",
+		},
+		Identities: &IdentityModel{DB: db},
+		Issuer:     "ldap",
+	}
+	ctx := context.Background()
+
+	id, err := dm.Authenticate(ctx, "jane@example.com", "pa55word")
+	assert.NilError(t, err)
+
+	assert.NilError(t, um.SetDisabled(ctx, id, true))
+
+	_, err = dm.Authenticate(ctx, "jane@example.com", "pa55word")
+	assert.Equal(t, err, ErrAccountDisabled)
+}
+
+func TestDirectoryUserModelLinking(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestDirectoryUserModelLinking test")
+	}
+
+	tests := []struct {
+		name     string
+		verified bool
+		wantErr  error
+	}{
+		{
+			name:     "Verified account",
+			verified: true,
+		},
+		{
+			// someone signed up with the address before the directory user
+			// logged in.
+			name:    "Unverified account",
+			wantErr: ErrUnverifiedAccount,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestDirectory(t)
+			db := newTestDB(t)
+			um := &UserModel{DB: db, BcryptCost: 4}
+			dm := &DirectoryUserModel{
+				UserModelInterface: um,
+				Directory: &LDAPAuthenticator{
+					URL:       server.URL(),
+					TLSConfig: server.ClientTLSConfig(),
+					UserDN:    "uid={username},ou=people,dc=example,dc=com",
+				},
+				Identities: &IdentityModel{DB: db},
+				Issuer:     "ldap",
+			}
+			ctx := context.Background()
+
+			assert.NilError(t, um.Insert(ctx, "Jane Doe", "jane@example.com", "l0calpa55"))
+			local, err := um.GetByEmail(ctx, "jane@example.com")
+			assert.NilError(t, err)
+			if tt.verified {
+				assert.NilError(t, um.VerifyEmail(ctx, local.ID))
+			}
+
+			id, err := dm.Authenticate(ctx, "jane@example.com", "pa55word")
+			if tt.wantErr != nil {
+				assert.Equal(t, err, tt.wantErr)
+
+				_, err = dm.Identities.Get(ctx, "ldap", "0c6d4ba4-3f1e-4b8e-9d5c-6a8b1f2e7d10")
+				assert.Equal(t, err, ErrNoRecord)
+
+				user, err := um.Get(ctx, local.ID)
+				assert.NilError(t, err)
+				assert.Equal(t, user.EmailVerified(), false)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, id, local.ID)
+		})
+	}
+}
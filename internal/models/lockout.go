@@ -0,0 +1,64 @@
+package models
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// LockoutPolicy locks an account after Threshold failed logins in a row, for
+// Duration. Every failure after that, once the lock is over, locks it again
+// for twice as long, up to MaxDuration. The zero value never locks.
+type LockoutPolicy struct {
+	Threshold   int
+	Duration    time.Duration
+	MaxDuration time.Duration
+}
+
+// LockDuration returns how long the account is locked after its failures-th
+// failed login in a row, zero when it isn't.
+func (p LockoutPolicy) LockDuration(failures int) time.Duration {
+	if p.Threshold <= 0 || failures < p.Threshold {
+		return 0
+	}
+
+	d := p.Duration
+	for i := p.Threshold; i < failures; i++ {
+		d *= 2
+		if p.MaxDuration > 0 && d >= p.MaxDuration {
+			return p.MaxDuration
+		}
+	}
+
+	if p.MaxDuration > 0 && d > p.MaxDuration {
+		return p.MaxDuration
+	}
+
+	return d
+}
+
+var (
+	dummyHashesMu sync.Mutex
+	dummyHashes   = map[int][]byte{}
+)
+
+// CompareDummyHash takes as long as checking password against a real hash of
+// the given cost. Authenticate calls it when there's no hash to check, so
+// the response time doesn't tell whether an account exists.
+func CompareDummyHash(password string, cost int) {
+	dummyHashesMu.Lock()
+	hash, ok := dummyHashes[cost]
+	if !ok {
+		var err error
+		hash, err = bcrypt.GenerateFromPassword([]byte("not the password"), cost)
+		if err != nil {
+			dummyHashesMu.Unlock()
+			return
+		}
+		dummyHashes[cost] = hash
+	}
+	dummyHashesMu.Unlock()
+
+	bcrypt.CompareHashAndPassword(hash, []byte(password))
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestLockoutPolicyLockDuration(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 3, Duration: time.Minute, MaxDuration: 10 * time.Minute}
+
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{failures: 1, expected: 0},
+		{failures: 2, expected: 0},
+		{failures: 3, expected: time.Minute},
+		{failures: 4, expected: 2 * time.Minute},
+		{failures: 5, expected: 4 * time.Minute},
+		{failures: 6, expected: 8 * time.Minute},
+		{failures: 7, expected: 10 * time.Minute},
+		{failures: 100, expected: 10 * time.Minute},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, policy.LockDuration(test.failures), test.expected)
+	}
+
+	assert.Equal(t, LockoutPolicy{}.LockDuration(100), time.Duration(0))
+}
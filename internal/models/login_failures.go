@@ -0,0 +1,63 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
+)
+
+// LoginFailureModelInterface records the failed logins by client IP, to slow
+// down the attempts spread over many accounts.
+type LoginFailureModelInterface interface {
+	Insert(ctx context.Context, ip string) error
+	Count(ctx context.Context, ip string, since time.Time) (int, error)
+	DeleteBefore(ctx context.Context, before time.Time) error
+}
+
+type LoginFailureModel struct {
+	DB *sql.DB
+	QueryOptions
+}
+
+func (lm *LoginFailureModel) Insert(ctx context.Context, ip string) error {
+	ctx, span := trace.Start(ctx, "LoginFailureModel.Insert")
+	defer span.End()
+
+	query := "INSERT INTO login_failures (ip, created) VALUES(?, ?)"
+
+	queryCtx, done := lm.startQuery(ctx, query)
+	_, err := lm.DB.ExecContext(queryCtx, query, ip, time.Now().UTC())
+	done()
+
+	return err
+}
+
+func (lm *LoginFailureModel) Count(ctx context.Context, ip string, since time.Time) (int, error) {
+	ctx, span := trace.Start(ctx, "LoginFailureModel.Count")
+	defer span.End()
+
+	var count int
+
+	query := "SELECT COUNT(*) FROM login_failures WHERE ip = ? AND created > ?"
+
+	queryCtx, done := lm.startQuery(ctx, query)
+	err := lm.DB.QueryRowContext(queryCtx, query, ip, since.UTC()).Scan(&count)
+	done()
+
+	return count, err
+}
+
+func (lm *LoginFailureModel) DeleteBefore(ctx context.Context, before time.Time) error {
+	ctx, span := trace.Start(ctx, "LoginFailureModel.DeleteBefore")
+	defer span.End()
+
+	query := "DELETE FROM login_failures WHERE created < ?"
+
+	queryCtx, done := lm.startQuery(ctx, query)
+	_, err := lm.DB.ExecContext(queryCtx, query, before.UTC())
+	done()
+
+	return err
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/mailer"
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
+)
+
+// OutboxModel keeps the emails waiting to be sent, it's a mailer.OutboxStore.
+// The emails the outbox gave up on are kept with no next attempt, for
+// looking into what went wrong.
+type OutboxModel struct {
+	DB *sql.DB
+	QueryOptions
+}
+
+func (om *OutboxModel) Insert(ctx context.Context, msg mailer.Message) error {
+	ctx, span := trace.Start(ctx, "OutboxModel.Insert")
+	defer span.End()
+
+	query := `
+		INSERT INTO outbox (recipient, subject, text_body, html_body, next_attempt, created)
+		VALUES(?, ?, ?, ?, ?, ?)
+	`
+
+	now := time.Now().UTC()
+	queryCtx, done := om.startQuery(ctx, query)
+	_, err := om.DB.ExecContext(queryCtx, query, msg.To, msg.Subject, msg.Text, msg.HTML, now, now)
+	done()
+
+	return err
+}
+
+func (om *OutboxModel) Due(ctx context.Context, now time.Time, limit int) ([]*mailer.QueuedMessage, error) {
+	ctx, span := trace.Start(ctx, "OutboxModel.Due")
+	defer span.End()
+
+	query := `
+		SELECT id, recipient, subject, text_body, html_body, attempts
+		FROM outbox
+		WHERE next_attempt <= ?
+		ORDER BY next_attempt LIMIT ?
+	`
+
+	queryCtx, done := om.startQuery(ctx, query)
+	defer done()
+
+	rows, err := om.DB.QueryContext(queryCtx, query, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	messages := []*mailer.QueuedMessage{}
+	for rows.Next() {
+		queued := &mailer.QueuedMessage{}
+
+		err := rows.Scan(
+			&queued.ID,
+			&queued.Message.To,
+			&queued.Message.Subject,
+			&queued.Message.Text,
+			&queued.Message.HTML,
+			&queued.Attempts,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, queued)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (om *OutboxModel) Delete(ctx context.Context, id int) error {
+	ctx, span := trace.Start(ctx, "OutboxModel.Delete")
+	defer span.End()
+
+	query := "DELETE FROM outbox WHERE id = ?"
+
+	queryCtx, done := om.startQuery(ctx, query)
+	_, err := om.DB.ExecContext(queryCtx, query, id)
+	done()
+
+	return err
+}
+
+func (om *OutboxModel) Reschedule(ctx context.Context, id int, next time.Time, lastError string) error {
+	ctx, span := trace.Start(ctx, "OutboxModel.Reschedule")
+	defer span.End()
+
+	var nextAttempt sql.NullTime
+	if !next.IsZero() {
+		nextAttempt = sql.NullTime{Time: next.UTC(), Valid: true}
+	}
+
+	// the column is limited to 1024 characters.
+	if len(lastError) > 1024 {
+		lastError = lastError[:1024]
+	}
+
+	query := "UPDATE outbox SET attempts = attempts + 1, next_attempt = ?, last_error = ? WHERE id = ?"
+
+	queryCtx, done := om.startQuery(ctx, query)
+	_, err := om.DB.ExecContext(queryCtx, query, nextAttempt, lastError, id)
+	done()
+
+	return err
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/mailer"
+)
+
+func TestOutboxModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestOutboxModel test")
+	}
+
+	db := newTestDB(t)
+	om := OutboxModel{DB: db}
+	ctx := context.Background()
+
+	msg := mailer.Message{To: "jane@snippetbox.sh", Subject: "Hello", Text: "Hi Jane,\n", HTML: "<p>Hi Jane,</p>"}
+	assert.NilError(t, om.Insert(ctx, msg))
+	assert.NilError(t, om.Insert(ctx, mailer.Message{To: "john@snippetbox.sh"}))
+
+	due, err := om.Due(ctx, time.Now().Add(time.Second), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(due), 2)
+	assert.Equal(t, due[0].Message, msg)
+	assert.Equal(t, due[0].Attempts, 0)
+
+	// a message tried again later isn't due until then, and one given up on
+	// is never due again.
+	assert.NilError(t, om.Reschedule(ctx, due[0].ID, time.Now().Add(time.Hour), "connection refused"))
+	assert.NilError(t, om.Reschedule(ctx, due[1].ID, time.Time{}, "connection refused"))
+
+	later, err := om.Due(ctx, time.Now().Add(time.Second), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(later), 0)
+
+	later, err = om.Due(ctx, time.Now().Add(2*time.Hour), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(later), 1)
+	assert.Equal(t, later[0].Attempts, 1)
+
+	assert.NilError(t, om.Delete(ctx, later[0].ID))
+
+	later, err = om.Due(ctx, time.Now().Add(2*time.Hour), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(later), 0)
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
+)
+
+// QueryOptions are the settings shared by the queries of the SQL models. The
+// zero value sets no timeout and logs nothing.
+type QueryOptions struct {
+	// Timeout limits how long a query can run, on top of the deadline of the
+	// context it gets.
+	Timeout time.Duration
+	// SlowThreshold is how long a query runs before it's logged as slow.
+	SlowThreshold time.Duration
+	Logger        *slog.Logger
+}
+
+// startQuery applies the timeout to ctx and traces the query. The returned
+// function must be called once the rows of the query are read, not right
+// after the call, since canceling the context closes them.
+func (o QueryOptions) startQuery(ctx context.Context, query string) (context.Context, func()) {
+	query = strings.Join(strings.Fields(query), " ")
+
+	ctx, span := trace.Start(ctx, "sql")
+	span.SetAttribute("db.statement", query)
+
+	cancel := context.CancelFunc(func() {})
+	if o.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+	}
+
+	start := time.Now()
+
+	return ctx, func() {
+		duration := time.Since(start)
+		span.SetError(ctx.Err())
+		cancel()
+		span.End()
+
+		if o.SlowThreshold > 0 && duration >= o.SlowThreshold && o.Logger != nil {
+			o.Logger.WarnContext(ctx, "slow query", "query", query, "duration", duration)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestQueryOptions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestQueryOptions test")
+	}
+
+	db := newTestDB(t)
+
+	t.Run("Slow query", func(t *testing.T) {
+		var buf bytes.Buffer
+		um := UserModel{DB: db, QueryOptions: QueryOptions{
+			SlowThreshold: time.Nanosecond,
+			Logger:        slog.New(slog.NewTextHandler(&buf, nil)),
+		}}
+
+		exists, err := um.Exists(context.Background(), 1)
+		assert.NilError(t, err)
+		assert.Equal(t, exists, true)
+
+		assert.StringContains(t, buf.String(), `msg="slow query"`)
+		assert.StringContains(t, buf.String(), `query="SELECT EXISTS(SELECT true FROM users WHERE id = ?)"`)
+	})
+
+	t.Run("Canceled context", func(t *testing.T) {
+		sm := SnippetModel{DB: db, QueryOptions: QueryOptions{Timeout: time.Second}}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := sm.Latest(ctx)
+		assert.Equal(t, errors.Is(err, context.Canceled), true)
+	})
+}
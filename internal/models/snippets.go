@@ -1,15 +1,20 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
 )
 
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
-	Get(id int) (*Snippet, error)
-	Latest() ([]*Snippet, error)
+	Insert(ctx context.Context, title string, content string, expires int) (int, error)
+	Get(ctx context.Context, id int) (*Snippet, error)
+	Latest(ctx context.Context) ([]*Snippet, error)
+	Delete(ctx context.Context, id int) error
+	Stats(ctx context.Context) (*SnippetStats, error)
 }
 
 type Snippet struct {
@@ -20,17 +25,36 @@ type Snippet struct {
 	Expires time.Time
 }
 
+// SnippetStats is how much room the snippets take. The expired snippets are
+// counted too, they stay in the table.
+type SnippetStats struct {
+	Count   int
+	Expired int
+	// Size is the length of the titles and contents, in bytes with MySQL
+	// and in characters with SQLite.
+	Size int64
+}
+
 type SnippetModel struct {
 	DB *sql.DB
+	QueryOptions
 }
 
-func (sm *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+func (sm *SnippetModel) Insert(ctx context.Context, title string, content string, expires int) (int, error) {
+	ctx, span := trace.Start(ctx, "SnippetModel.Insert")
+	defer span.End()
+
 	query := `
 		INSERT INTO snippets (title, content, created, expires)
-		VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))
+		VALUES(?, ?, ?, ?)
 	`
 
-	result, err := sm.DB.Exec(query, title, content, expires)
+	// the timestamps are computed here instead of using UTC_TIMESTAMP() so that
+	// the same query works for both MySQL and SQLite.
+	created := time.Now().UTC()
+	queryCtx, done := sm.startQuery(ctx, query)
+	result, err := sm.DB.ExecContext(queryCtx, query, title, content, created, created.AddDate(0, 0, expires))
+	done()
 	if err != nil {
 		return 0, err
 	}
@@ -43,15 +67,19 @@ func (sm *SnippetModel) Insert(title string, content string, expires int) (int,
 	return int(id), nil
 }
 
-func (sm *SnippetModel) Get(id int) (*Snippet, error) {
+func (sm *SnippetModel) Get(ctx context.Context, id int) (*Snippet, error) {
+	ctx, span := trace.Start(ctx, "SnippetModel.Get")
+	defer span.End()
+
 	query := `
 		SELECT id, title, content, created, expires
 		FROM snippets
-		WHERE expires > UTC_TIMESTAMP() AND id = ?
+		WHERE expires > ? AND id = ?
 	`
 
 	snippet := &Snippet{}
-	row := sm.DB.QueryRow(query, id)
+	queryCtx, done := sm.startQuery(ctx, query)
+	row := sm.DB.QueryRowContext(queryCtx, query, time.Now().UTC(), id)
 
 	err := row.Scan(
 		&snippet.ID,
@@ -60,6 +88,7 @@ func (sm *SnippetModel) Get(id int) (*Snippet, error) {
 		&snippet.Created,
 		&snippet.Expires,
 	)
+	done()
 	if err != nil {
 
 		if errors.Is(err, sql.ErrNoRows) {
@@ -72,15 +101,21 @@ func (sm *SnippetModel) Get(id int) (*Snippet, error) {
 	return snippet, nil
 }
 
-func (sm *SnippetModel) Latest() ([]*Snippet, error) {
+func (sm *SnippetModel) Latest(ctx context.Context) ([]*Snippet, error) {
+	ctx, span := trace.Start(ctx, "SnippetModel.Latest")
+	defer span.End()
+
 	query := `
 		SELECT id, title, content, created, expires
 		FROM snippets
-		WHERE expires > UTC_TIMESTAMP()
+		WHERE expires > ?
 		ORDER BY id DESC LIMIT 10
 	`
 
-	rows, err := sm.DB.Query(query)
+	queryCtx, done := sm.startQuery(ctx, query)
+	defer done()
+
+	rows, err := sm.DB.QueryContext(queryCtx, query, time.Now().UTC())
 	if err != nil {
 		return nil, err
 	}
@@ -112,3 +147,50 @@ func (sm *SnippetModel) Latest() ([]*Snippet, error) {
 
 	return snippets, nil
 }
+
+// Delete removes the snippet, expired or not.
+func (sm *SnippetModel) Delete(ctx context.Context, id int) error {
+	ctx, span := trace.Start(ctx, "SnippetModel.Delete")
+	defer span.End()
+
+	query := "DELETE FROM snippets WHERE id = ?"
+
+	queryCtx, done := sm.startQuery(ctx, query)
+	result, err := sm.DB.ExecContext(queryCtx, query, id)
+	done()
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (sm *SnippetModel) Stats(ctx context.Context) (*SnippetStats, error) {
+	ctx, span := trace.Start(ctx, "SnippetModel.Stats")
+	defer span.End()
+
+	query := `
+		SELECT COUNT(*),
+			COALESCE(SUM(CASE WHEN expires > ? THEN 0 ELSE 1 END), 0),
+			COALESCE(SUM(LENGTH(title) + LENGTH(content)), 0)
+		FROM snippets
+	`
+
+	stats := &SnippetStats{}
+	queryCtx, done := sm.startQuery(ctx, query)
+	err := sm.DB.QueryRowContext(queryCtx, query, time.Now().UTC()).Scan(&stats.Count, &stats.Expired, &stats.Size)
+	done()
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
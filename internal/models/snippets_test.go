@@ -0,0 +1,64 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestSnippetModelInsertAndGet(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestSnippetModelInsertAndGet test")
+	}
+
+	db := newTestDB(t)
+	sm := SnippetModel{DB: db}
+
+	id, err := sm.Insert(context.Background(), "A Title", "A content", 7)
+	assert.NilError(t, err)
+
+	snippet, err := sm.Get(context.Background(), id)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Title, "A Title")
+	assert.Equal(t, snippet.Content, "A content")
+	assert.Equal(t, snippet.Expires.Sub(snippet.Created).Hours(), 7*24.0)
+
+	_, err = sm.Get(context.Background(), id+1)
+	assert.Equal(t, err, ErrNoRecord)
+
+	snippets, err := sm.Latest(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+}
+
+func TestSnippetModelDeleteAndStats(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestSnippetModelDeleteAndStats test")
+	}
+
+	db := newTestDB(t)
+	sm := SnippetModel{DB: db}
+	ctx := context.Background()
+
+	id, err := sm.Insert(ctx, "A Title", "A content", 7)
+	assert.NilError(t, err)
+	_, err = sm.Insert(ctx, "Expired", "Gone", 0)
+	assert.NilError(t, err)
+
+	stats, err := sm.Stats(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, *stats, SnippetStats{Count: 2, Expired: 1, Size: 27})
+
+	assert.NilError(t, sm.Delete(ctx, id))
+
+	_, err = sm.Get(ctx, id)
+	assert.Equal(t, err, ErrNoRecord)
+
+	err = sm.Delete(ctx, id)
+	assert.Equal(t, err, ErrNoRecord)
+
+	stats, err = sm.Stats(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, *stats, SnippetStats{Count: 1, Expired: 1, Size: 11})
+}
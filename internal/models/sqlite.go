@@ -0,0 +1,112 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+// sqliteMigrations holds the SQLite schema as numbered scripts. The number of
+// the last one applied is kept in the user_version of the database.
+//
+//go:embed sqlite/*.sql
+var sqliteMigrations embed.FS
+
+// CreateSQLiteSchema creates the tables used by the models and the session
+// store, or brings an existing database up to date, so an SQLite database
+// works without any manual setup.
+func CreateSQLiteSchema(db *sql.DB) error {
+	var version int
+	err := db.QueryRow("PRAGMA user_version").Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(sqliteMigrations, "sqlite/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		if i < version {
+			continue
+		}
+
+		script, err := sqliteMigrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		err = applySQLiteMigration(db, string(script), i+1)
+		if err != nil {
+			return fmt.Errorf("applying %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// applySQLiteMigration runs script and sets the user_version in the same
+// transaction, so a failed script can be fixed and run again. The foreign
+// keys are checked before committing rather than enforced while it runs, so
+// a script can rebuild a table the others refer to.
+func applySQLiteMigration(db *sql.DB, script string, version int) error {
+	ctx := context.Background()
+
+	// the pragma is per connection and ignored inside a transaction.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var foreignKeys bool
+	err = conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys)
+	if err != nil {
+		return err
+	}
+	if foreignKeys {
+		_, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF")
+		if err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(script)
+	if err != nil {
+		return err
+	}
+
+	if foreignKeys {
+		var table, parent string
+		var rowID sql.NullInt64
+		var foreignKeyID int
+		err = tx.QueryRow("PRAGMA foreign_key_check").Scan(&table, &rowID, &parent, &foreignKeyID)
+		if err == nil {
+			return fmt.Errorf("the script breaks a foreign key of %s to %s", table, parent)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+
+	// PRAGMA doesn't take parameters.
+	_, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN failed_logins INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN locked_until DATETIME NULL;
+
+CREATE TABLE login_failures (
+  id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  ip VARCHAR(45) NOT NULL,
+  created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_login_failures_ip_created ON login_failures(ip, created);
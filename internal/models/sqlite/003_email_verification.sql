@@ -0,0 +1,13 @@
+ALTER TABLE users ADD COLUMN email_verified_at DATETIME NULL;
+
+-- the accounts created before the verification was added are trusted.
+UPDATE users SET email_verified_at = created;
+
+CREATE TABLE tokens (
+  hash BLOB NOT NULL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  expiry DATETIME NOT NULL,
+  scope VARCHAR(32) NOT NULL
+);
+
+CREATE INDEX idx_tokens_user_id_scope ON tokens(user_id, scope);
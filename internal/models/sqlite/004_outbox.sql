@@ -0,0 +1,13 @@
+CREATE TABLE outbox (
+  id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  recipient VARCHAR(255) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  text_body TEXT NOT NULL,
+  html_body TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt DATETIME NULL,
+  last_error VARCHAR(1024) NOT NULL DEFAULT '',
+  created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_outbox_next_attempt ON outbox(next_attempt);
@@ -0,0 +1,13 @@
+CREATE TABLE two_factor (
+  user_id INTEGER NOT NULL PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  secret VARCHAR(64) NOT NULL,
+  enabled_at DATETIME NULL,
+  last_counter INTEGER NOT NULL DEFAULT 0,
+  created DATETIME NOT NULL
+);
+
+CREATE TABLE recovery_codes (
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  hash BLOB NOT NULL,
+  PRIMARY KEY (user_id, hash)
+);
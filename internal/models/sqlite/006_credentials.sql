@@ -0,0 +1,11 @@
+CREATE TABLE credentials (
+  id BLOB NOT NULL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(100) NOT NULL,
+  public_key BLOB NOT NULL,
+  sign_count INTEGER NOT NULL DEFAULT 0,
+  created DATETIME NOT NULL,
+  last_used DATETIME NULL
+);
+
+CREATE INDEX idx_credentials_user_id ON credentials(user_id);
@@ -0,0 +1,9 @@
+CREATE TABLE identities (
+  issuer VARCHAR(255) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created DATETIME NOT NULL,
+  PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX idx_identities_user_id ON identities(user_id);
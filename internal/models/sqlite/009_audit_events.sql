@@ -0,0 +1,27 @@
+-- there are no foreign keys, the events outlive the users and snippets.
+CREATE TABLE audit_events (
+  id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  type VARCHAR(32) NOT NULL,
+  actor_id INTEGER NULL,
+  target_type VARCHAR(16) NOT NULL DEFAULT '',
+  target_id INTEGER NULL,
+  ip VARCHAR(45) NOT NULL,
+  user_agent VARCHAR(256) NOT NULL,
+  detail VARCHAR(255) NOT NULL DEFAULT '',
+  created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_audit_events_created ON audit_events(created);
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id);
+CREATE INDEX idx_audit_events_target ON audit_events(target_type, target_id);
+
+-- the log is append-only.
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+BEGIN
+  SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events
+BEGIN
+  SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
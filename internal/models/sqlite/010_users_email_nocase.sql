@@ -0,0 +1,23 @@
+-- the emails are compared without case, like with the default collation of
+-- MySQL. SQLite can't change the collation of a column, so the table is
+-- rebuilt. Emails differing only in case have to be merged by hand first.
+CREATE TABLE users_nocase (
+  id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  name VARCHAR(255) NOT NULL,
+  email VARCHAR(255) NOT NULL COLLATE NOCASE,
+  hashed_password CHAR(60) NOT NULL,
+  created DATETIME NOT NULL,
+  failed_logins INTEGER NOT NULL DEFAULT 0,
+  locked_until DATETIME NULL,
+  email_verified_at DATETIME NULL,
+  role VARCHAR(16) NOT NULL DEFAULT 'user',
+  disabled_at DATETIME NULL,
+  CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+INSERT INTO users_nocase (id, name, email, hashed_password, created, failed_logins, locked_until, email_verified_at, role, disabled_at)
+SELECT id, name, email, hashed_password, created, failed_logins, locked_until, email_verified_at, role, disabled_at FROM users;
+
+DROP TABLE users;
+
+ALTER TABLE users_nocase RENAME TO users;
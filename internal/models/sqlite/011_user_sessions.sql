@@ -0,0 +1,8 @@
+CREATE TABLE user_sessions (
+  token CHAR(43) NOT NULL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  expiry DATETIME NOT NULL
+);
+
+CREATE INDEX idx_user_sessions_user_id ON user_sessions(user_id);
+CREATE INDEX idx_user_sessions_expiry ON user_sessions(expiry);
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"database/sql"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestCreateSQLiteSchemaUpgrade(t *testing.T) {
+	// the foreign keys are enforced like in the app.
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db")+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// a database created before the migrations were numbered has the first
+	// script applied and a user_version of 0.
+	script, err := sqliteMigrations.ReadFile("sqlite/001_schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(string(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO users (name, email, hashed_password, created) VALUES ('Jane Doe', 'jane@snippetbox.sh', 'x', '2024-01-01 00:00:00')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err = CreateSQLiteSchema(db)
+		assert.NilError(t, err)
+	}
+
+	var version, failedLogins int
+	err = db.QueryRow("PRAGMA user_version").Scan(&version)
+	assert.NilError(t, err)
+	names, err := fs.Glob(sqliteMigrations, "sqlite/*.sql")
+	assert.NilError(t, err)
+	assert.Equal(t, version, len(names))
+
+	err = db.QueryRow("SELECT failed_logins FROM users WHERE email = 'jane@snippetbox.sh'").Scan(&failedLogins)
+	assert.NilError(t, err)
+	assert.Equal(t, failedLogins, 0)
+
+	var foreignKeys bool
+	err = db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys)
+	assert.NilError(t, err)
+	assert.Equal(t, foreignKeys, true)
+}
+
+func TestCreateSQLiteSchemaRebuildKeepsReferences(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db")+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// stop before the users are rebuilt with their emails without case.
+	names, err := fs.Glob(sqliteMigrations, "sqlite/*.sql")
+	assert.NilError(t, err)
+	for i, name := range names[:9] {
+		script, err := sqliteMigrations.ReadFile(name)
+		assert.NilError(t, err)
+		assert.NilError(t, applySQLiteMigration(db, string(script), i+1))
+	}
+
+	_, err = db.Exec(`INSERT INTO users (name, email, hashed_password, created) VALUES ('Jane Doe', 'Jane@snippetbox.sh', 'x', '2024-01-01 00:00:00')`)
+	assert.NilError(t, err)
+	_, err = db.Exec(`INSERT INTO tokens (hash, user_id, expiry, scope) VALUES (x'00', 1, '2999-01-01 00:00:00', 'test')`)
+	assert.NilError(t, err)
+
+	assert.NilError(t, CreateSQLiteSchema(db))
+
+	// the tokens of the user aren't deleted with the old table.
+	var tokens int
+	err = db.QueryRow("SELECT COUNT(*) FROM tokens WHERE user_id = 1").Scan(&tokens)
+	assert.NilError(t, err)
+	assert.Equal(t, tokens, 1)
+
+	var id int
+	err = db.QueryRow("SELECT id FROM users WHERE email = 'jane@snippetbox.sh'").Scan(&id)
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	// the tokens still refer to the users.
+	_, err = db.Exec("DELETE FROM users WHERE id = 1")
+	assert.NilError(t, err)
+	err = db.QueryRow("SELECT COUNT(*) FROM tokens").Scan(&tokens)
+	assert.NilError(t, err)
+	assert.Equal(t, tokens, 0)
+}
@@ -0,0 +1,12 @@
+INSERT INTO users (
+  name,
+  email,
+  hashed_password,
+  created
+)
+VALUES (
+  'Ahmad Yogi',
+  'ahmady@snippetbox.sh',
+  '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
+  '2023-01-01 10:00:00'
+);
@@ -11,20 +11,116 @@ CREATE TABLE users (
   id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT, name VARCHAR(255) NOT NULL,
   email VARCHAR(255) NOT NULL,
   hashed_password CHAR(60) NOT NULL,
-  created DATETIME NOT NULL
+  created DATETIME NOT NULL,
+  failed_logins INTEGER NOT NULL DEFAULT 0,
+  locked_until DATETIME NULL,
+  email_verified_at DATETIME NULL,
+  role VARCHAR(16) NOT NULL DEFAULT 'user',
+  disabled_at DATETIME NULL
 );
 
 ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
 
-INSERT INTO users (
-  name,
-  email,
-  hashed_password,
-  created
-)
-VALUES (
-  'Ahmad Yogi',
-  'ahmady@snippetbox.sh',
-  '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
-  '2023-01-01 10:00:00'
+CREATE TABLE login_failures (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  ip VARCHAR(45) NOT NULL,
+  created DATETIME NOT NULL
 );
+
+CREATE INDEX idx_login_failures_ip_created ON login_failures(ip, created);
+
+CREATE TABLE tokens (
+  hash BINARY(32) NOT NULL PRIMARY KEY,
+  user_id INTEGER NOT NULL,
+  expiry DATETIME NOT NULL,
+  scope VARCHAR(32) NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_tokens_user_id_scope ON tokens(user_id, scope);
+
+CREATE TABLE outbox (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  recipient VARCHAR(255) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  text_body TEXT NOT NULL,
+  html_body TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt DATETIME NULL,
+  last_error VARCHAR(1024) NOT NULL DEFAULT '',
+  created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_outbox_next_attempt ON outbox(next_attempt);
+
+CREATE TABLE two_factor (
+  user_id INTEGER NOT NULL PRIMARY KEY,
+  secret VARCHAR(64) NOT NULL,
+  enabled_at DATETIME NULL,
+  last_counter BIGINT NOT NULL DEFAULT 0,
+  created DATETIME NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE recovery_codes (
+  user_id INTEGER NOT NULL,
+  hash BINARY(32) NOT NULL,
+  PRIMARY KEY (user_id, hash),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE credentials (
+  id VARBINARY(1023) NOT NULL PRIMARY KEY,
+  user_id INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  public_key BLOB NOT NULL,
+  sign_count BIGINT NOT NULL DEFAULT 0,
+  created DATETIME NOT NULL,
+  last_used DATETIME NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_credentials_user_id ON credentials(user_id);
+
+CREATE TABLE identities (
+  issuer VARCHAR(255) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  user_id INTEGER NOT NULL,
+  created DATETIME NOT NULL,
+  PRIMARY KEY (issuer, subject),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_identities_user_id ON identities(user_id);
+
+CREATE TABLE user_sessions (
+  token CHAR(43) NOT NULL PRIMARY KEY,
+  user_id INTEGER NOT NULL,
+  expiry DATETIME NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_sessions_user_id ON user_sessions(user_id);
+CREATE INDEX idx_user_sessions_expiry ON user_sessions(expiry);
+
+CREATE TABLE audit_events (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  type VARCHAR(32) NOT NULL,
+  actor_id INTEGER NULL,
+  target_type VARCHAR(16) NOT NULL DEFAULT '',
+  target_id INTEGER NULL,
+  ip VARCHAR(45) NOT NULL,
+  user_agent VARCHAR(256) NOT NULL,
+  detail VARCHAR(255) NOT NULL DEFAULT '',
+  created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_audit_events_created ON audit_events(created);
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id);
+CREATE INDEX idx_audit_events_target ON audit_events(target_type, target_id);
+
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
+
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
@@ -1,3 +1,21 @@
+DROP TABLE audit_events;
+
+DROP TABLE user_sessions;
+
+DROP TABLE identities;
+
+DROP TABLE credentials;
+
+DROP TABLE recovery_codes;
+
+DROP TABLE two_factor;
+
+DROP TABLE outbox;
+
+DROP TABLE tokens;
+
+DROP TABLE login_failures;
+
 DROP TABLE users;
 
 DROP TABLE snippets;
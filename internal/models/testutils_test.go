@@ -3,39 +3,62 @@ package models
 import (
 	"database/sql"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
+// newTestDB returns a database containing the schema and the test data. It
+// uses a temporary SQLite database unless SNIPPETBOX_TEST_MYSQL_DSN is set,
+// for example to "test_web:12345678@/test_snippetbox?parseTime=true&multiStatements=true".
 func newTestDB(t *testing.T) *sql.DB {
-	dsn := "test_web:12345678@/test_snippetbox?parseTime=true&multiStatements=true"
+	dsn := os.Getenv("SNIPPETBOX_TEST_MYSQL_DSN")
+	if dsn == "" {
+		return newTestSQLiteDB(t)
+	}
+
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	script, err := os.ReadFile("./testdata/setup.sql")
+	execScript(t, db, "./testdata/setup.sql")
+	execScript(t, db, "./testdata/seed.sql")
+
+	t.Cleanup(func() {
+		execScript(t, db, "./testdata/teardown.sql")
+		db.Close()
+	})
+
+	return db
+}
+
+func newTestSQLiteDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
 	if err != nil {
 		t.Fatal(err)
 	}
+	t.Cleanup(func() {
+		db.Close()
+	})
 
-	_, err = db.Exec(string(script))
+	err = CreateSQLiteSchema(db)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	t.Cleanup(func() {
-		script, err := os.ReadFile("./testdata/teardown.sql")
-		if err != nil {
-			t.Fatal(err)
-		}
+	execScript(t, db, "./testdata/seed.sql")
 
-		_, err = db.Exec(string(script))
-		if err != nil {
-			t.Fatal(err)
-		}
+	return db
+}
 
-		db.Close()
-	})
+func execScript(t *testing.T, db *sql.DB, path string) {
+	script, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	return db
+	_, err = db.Exec(string(script))
+	if err != nil {
+		t.Fatal(err)
+	}
 }
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
+)
+
+// The scopes of the tokens, a token only works for the scope it was created
+// for.
+const (
+	ScopeEmailVerification = "email_verification"
+	ScopePasswordReset     = "password_reset"
+)
+
+// Token is a random secret sent to a user, like in the link verifying their
+// email address. Only its hash is stored, so the tokens in a leaked database
+// can't be used.
+type Token struct {
+	Plaintext string
+	Hash      []byte
+	UserID    int
+	Expiry    time.Time
+	Scope     string
+}
+
+// NewToken returns a token for the user valid for ttl.
+func NewToken(userID int, ttl time.Duration, scope string) (*Token, error) {
+	randomBytes := make([]byte, 16)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &Token{
+		Plaintext: base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes),
+		UserID:    userID,
+		Expiry:    time.Now().UTC().Add(ttl),
+		Scope:     scope,
+	}
+	token.Hash = HashToken(token.Plaintext)
+
+	return token, nil
+}
+
+// HashToken returns the hash a token is stored under.
+func HashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+type TokenModelInterface interface {
+	New(ctx context.Context, userID int, ttl time.Duration, scope string) (*Token, error)
+	Use(ctx context.Context, plaintext, scope string) (int, error)
+	DeleteAllForUser(ctx context.Context, userID int, scope string) error
+	DeleteExpired(ctx context.Context) error
+}
+
+type TokenModel struct {
+	DB *sql.DB
+	QueryOptions
+}
+
+func (tm *TokenModel) New(ctx context.Context, userID int, ttl time.Duration, scope string) (*Token, error) {
+	ctx, span := trace.Start(ctx, "TokenModel.New")
+	defer span.End()
+
+	token, err := NewToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "INSERT INTO tokens (hash, user_id, expiry, scope) VALUES(?, ?, ?, ?)"
+
+	queryCtx, done := tm.startQuery(ctx, query)
+	_, err = tm.DB.ExecContext(queryCtx, query, token.Hash, token.UserID, token.Expiry, token.Scope)
+	done()
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// Use returns the ID of the user of the token and deletes the token, so it
+// only works once. It returns ErrNoRecord when the token doesn't exist, has
+// expired or is for another scope.
+func (tm *TokenModel) Use(ctx context.Context, plaintext, scope string) (int, error) {
+	ctx, span := trace.Start(ctx, "TokenModel.Use")
+	defer span.End()
+
+	var userID int
+	hash := HashToken(plaintext)
+
+	query := "SELECT user_id FROM tokens WHERE hash = ? AND scope = ? AND expiry > ?"
+
+	queryCtx, done := tm.startQuery(ctx, query)
+	err := tm.DB.QueryRowContext(queryCtx, query, hash, scope, time.Now().UTC()).Scan(&userID)
+	done()
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		} else {
+			return 0, err
+		}
+	}
+
+	query = "DELETE FROM tokens WHERE hash = ?"
+
+	queryCtx, done = tm.startQuery(ctx, query)
+	result, err := tm.DB.ExecContext(queryCtx, query, hash)
+	done()
+	if err != nil {
+		return 0, err
+	}
+
+	// another request used the token in between.
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rows == 0 {
+		return 0, ErrNoRecord
+	}
+
+	return userID, nil
+}
+
+func (tm *TokenModel) DeleteAllForUser(ctx context.Context, userID int, scope string) error {
+	ctx, span := trace.Start(ctx, "TokenModel.DeleteAllForUser")
+	defer span.End()
+
+	query := "DELETE FROM tokens WHERE user_id = ? AND scope = ?"
+
+	queryCtx, done := tm.startQuery(ctx, query)
+	_, err := tm.DB.ExecContext(queryCtx, query, userID, scope)
+	done()
+
+	return err
+}
+
+func (tm *TokenModel) DeleteExpired(ctx context.Context) error {
+	ctx, span := trace.Start(ctx, "TokenModel.DeleteExpired")
+	defer span.End()
+
+	query := "DELETE FROM tokens WHERE expiry <= ?"
+
+	queryCtx, done := tm.startQuery(ctx, query)
+	_, err := tm.DB.ExecContext(queryCtx, query, time.Now().UTC())
+	done()
+
+	return err
+}
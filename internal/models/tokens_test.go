@@ -0,0 +1,46 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestTokenModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestTokenModel test")
+	}
+
+	db := newTestDB(t)
+	tm := TokenModel{DB: db}
+	ctx := context.Background()
+
+	token, err := tm.New(ctx, 1, time.Hour, ScopeEmailVerification)
+	assert.NilError(t, err)
+	assert.Equal(t, len(token.Plaintext), 26)
+
+	_, err = tm.Use(ctx, token.Plaintext, "another_scope")
+	assert.Equal(t, err, ErrNoRecord)
+
+	userID, err := tm.Use(ctx, token.Plaintext, ScopeEmailVerification)
+	assert.NilError(t, err)
+	assert.Equal(t, userID, 1)
+
+	// the tokens only work once.
+	_, err = tm.Use(ctx, token.Plaintext, ScopeEmailVerification)
+	assert.Equal(t, err, ErrNoRecord)
+
+	expired, err := tm.New(ctx, 1, -time.Minute, ScopeEmailVerification)
+	assert.NilError(t, err)
+	_, err = tm.Use(ctx, expired.Plaintext, ScopeEmailVerification)
+	assert.Equal(t, err, ErrNoRecord)
+	assert.NilError(t, tm.DeleteExpired(ctx))
+
+	token, err = tm.New(ctx, 1, time.Hour, ScopeEmailVerification)
+	assert.NilError(t, err)
+	assert.NilError(t, tm.DeleteAllForUser(ctx, 1, ScopeEmailVerification))
+	_, err = tm.Use(ctx, token.Plaintext, ScopeEmailVerification)
+	assert.Equal(t, err, ErrNoRecord)
+}
@@ -0,0 +1,308 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
+)
+
+// RecoveryCodeCount is how many recovery codes a user gets at a time.
+const RecoveryCodeCount = 10
+
+// TwoFactor is the TOTP secret of a user. It's only asked for at login once
+// the user typed a code from their authenticator app, which enables it.
+type TwoFactor struct {
+	UserID    int
+	Secret    string
+	EnabledAt time.Time
+	// LastCounter is the period of the last code used, so a code seen over
+	// someone's shoulder can't be typed again.
+	LastCounter int64
+	Created     time.Time
+}
+
+func (tf *TwoFactor) Enabled() bool {
+	return !tf.EnabledAt.IsZero()
+}
+
+// NewRecoveryCodes returns RecoveryCodeCount random codes for logging in
+// without the authenticator app, formatted like xxxx-xxxx-xxxx-xxxx.
+func NewRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+
+	for i := range codes {
+		randomBytes := make([]byte, 10)
+		_, err := rand.Read(randomBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(randomBytes))
+		codes[i] = code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+	}
+
+	return codes, nil
+}
+
+// HashRecoveryCode returns the hash a recovery code is stored under. The
+// dashes, spaces and case don't matter.
+func HashRecoveryCode(code string) []byte {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+
+	return HashToken(code)
+}
+
+type TwoFactorModelInterface interface {
+	Get(ctx context.Context, userID int) (*TwoFactor, error)
+	Setup(ctx context.Context, userID int, secret string) error
+	Enable(ctx context.Context, userID int, counter int64) ([]string, error)
+	Disable(ctx context.Context, userID int) error
+	UseCounter(ctx context.Context, userID int, counter int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID int, code string) (bool, error)
+	RegenerateRecoveryCodes(ctx context.Context, userID int) ([]string, error)
+	RecoveryCodesLeft(ctx context.Context, userID int) (int, error)
+}
+
+type TwoFactorModel struct {
+	DB *sql.DB
+	QueryOptions
+}
+
+// Get returns the TOTP secret of the user, ErrNoRecord when they never set
+// one up.
+func (tm *TwoFactorModel) Get(ctx context.Context, userID int) (*TwoFactor, error) {
+	ctx, span := trace.Start(ctx, "TwoFactorModel.Get")
+	defer span.End()
+
+	tf := TwoFactor{UserID: userID}
+	var enabledAt sql.NullTime
+
+	query := "SELECT secret, enabled_at, last_counter, created FROM two_factor WHERE user_id = ?"
+
+	queryCtx, done := tm.startQuery(ctx, query)
+	err := tm.DB.QueryRowContext(queryCtx, query, userID).Scan(&tf.Secret, &enabledAt, &tf.LastCounter, &tf.Created)
+	done()
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	tf.EnabledAt = enabledAt.Time
+
+	return &tf, nil
+}
+
+// Setup stores a new secret for the user, not enabled yet. It replaces the
+// secret of an earlier setup the user didn't finish, but returns
+// ErrTwoFactorEnabled rather than replace an enabled one.
+func (tm *TwoFactorModel) Setup(ctx context.Context, userID int, secret string) error {
+	ctx, span := trace.Start(ctx, "TwoFactorModel.Setup")
+	defer span.End()
+
+	query := "DELETE FROM two_factor WHERE user_id = ? AND enabled_at IS NULL"
+
+	queryCtx, done := tm.startQuery(ctx, query)
+	_, err := tm.DB.ExecContext(queryCtx, query, userID)
+	done()
+	if err != nil {
+		return err
+	}
+
+	query = "INSERT INTO two_factor (user_id, secret, created) VALUES(?, ?, ?)"
+
+	queryCtx, done = tm.startQuery(ctx, query)
+	_, err = tm.DB.ExecContext(queryCtx, query, userID, secret, time.Now().UTC())
+	done()
+	if err != nil {
+		if isUniqueViolation(err, "PRIMARY", "two_factor.user_id") {
+			return ErrTwoFactorEnabled
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Enable turns on the secret of the user once they typed the code of
+// counter, and returns their new recovery codes. It returns ErrNoRecord when
+// there's no setup to enable.
+func (tm *TwoFactorModel) Enable(ctx context.Context, userID int, counter int64) ([]string, error) {
+	ctx, span := trace.Start(ctx, "TwoFactorModel.Enable")
+	defer span.End()
+
+	tx, err := tm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE two_factor SET enabled_at = ?, last_counter = ? WHERE user_id = ? AND enabled_at IS NULL"
+
+	queryCtx, done := tm.startQuery(ctx, query)
+	result, err := tx.ExecContext(queryCtx, query, time.Now().UTC(), counter, userID)
+	done()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, ErrNoRecord
+	}
+
+	codes, err := tm.replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, tx.Commit()
+}
+
+// Disable deletes the secret and the recovery codes of the user.
+func (tm *TwoFactorModel) Disable(ctx context.Context, userID int) error {
+	ctx, span := trace.Start(ctx, "TwoFactorModel.Disable")
+	defer span.End()
+
+	tx, err := tm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"DELETE FROM recovery_codes WHERE user_id = ?",
+		"DELETE FROM two_factor WHERE user_id = ?",
+	} {
+		queryCtx, done := tm.startQuery(ctx, query)
+		_, err = tx.ExecContext(queryCtx, query, userID)
+		done()
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseCounter records that the code of counter was used to log in. It reports
+// false when that code, or a later one, was used already.
+func (tm *TwoFactorModel) UseCounter(ctx context.Context, userID int, counter int64) (bool, error) {
+	ctx, span := trace.Start(ctx, "TwoFactorModel.UseCounter")
+	defer span.End()
+
+	query := "UPDATE two_factor SET last_counter = ? WHERE user_id = ? AND last_counter < ? AND enabled_at IS NOT NULL"
+
+	queryCtx, done := tm.startQuery(ctx, query)
+	result, err := tm.DB.ExecContext(queryCtx, query, counter, userID, counter)
+	done()
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// UseRecoveryCode deletes the recovery code of the user, so it only works
+// once. It reports false when the user has no such code.
+func (tm *TwoFactorModel) UseRecoveryCode(ctx context.Context, userID int, code string) (bool, error) {
+	ctx, span := trace.Start(ctx, "TwoFactorModel.UseRecoveryCode")
+	defer span.End()
+
+	query := "DELETE FROM recovery_codes WHERE user_id = ? AND hash = ?"
+
+	queryCtx, done := tm.startQuery(ctx, query)
+	result, err := tm.DB.ExecContext(queryCtx, query, userID, HashRecoveryCode(code))
+	done()
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user, for when
+// they lost them or used most of them.
+func (tm *TwoFactorModel) RegenerateRecoveryCodes(ctx context.Context, userID int) ([]string, error) {
+	ctx, span := trace.Start(ctx, "TwoFactorModel.RegenerateRecoveryCodes")
+	defer span.End()
+
+	tx, err := tm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	codes, err := tm.replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, tx.Commit()
+}
+
+func (tm *TwoFactorModel) RecoveryCodesLeft(ctx context.Context, userID int) (int, error) {
+	ctx, span := trace.Start(ctx, "TwoFactorModel.RecoveryCodesLeft")
+	defer span.End()
+
+	var count int
+
+	query := "SELECT COUNT(*) FROM recovery_codes WHERE user_id = ?"
+
+	queryCtx, done := tm.startQuery(ctx, query)
+	err := tm.DB.QueryRowContext(queryCtx, query, userID).Scan(&count)
+	done()
+
+	return count, err
+}
+
+func (tm *TwoFactorModel) replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int) ([]string, error) {
+	codes, err := NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	query := "DELETE FROM recovery_codes WHERE user_id = ?"
+
+	queryCtx, done := tm.startQuery(ctx, query)
+	_, err = tx.ExecContext(queryCtx, query, userID)
+	done()
+	if err != nil {
+		return nil, err
+	}
+
+	query = "INSERT INTO recovery_codes (user_id, hash) VALUES(?, ?)"
+	for _, code := range codes {
+		queryCtx, done := tm.startQuery(ctx, query)
+		_, err = tx.ExecContext(queryCtx, query, userID, HashRecoveryCode(code))
+		done()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestTwoFactorModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestTwoFactorModel test")
+	}
+
+	db := newTestDB(t)
+	tm := TwoFactorModel{DB: db}
+	ctx := context.Background()
+
+	_, err := tm.Get(ctx, 1)
+	assert.Equal(t, err, ErrNoRecord)
+
+	// setting up again replaces the secret that wasn't enabled.
+	assert.NilError(t, tm.Setup(ctx, 1, "FIRSTSECRET"))
+	assert.NilError(t, tm.Setup(ctx, 1, "SECONDSECRET"))
+
+	tf, err := tm.Get(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, tf.Secret, "SECONDSECRET")
+	assert.Equal(t, tf.Enabled(), false)
+
+	// the codes aren't asked for until it's enabled.
+	ok, err := tm.UseCounter(ctx, 1, 100)
+	assert.NilError(t, err)
+	assert.Equal(t, ok, false)
+
+	codes, err := tm.Enable(ctx, 1, 100)
+	assert.NilError(t, err)
+	assert.Equal(t, len(codes), RecoveryCodeCount)
+
+	_, err = tm.Enable(ctx, 1, 100)
+	assert.Equal(t, err, ErrNoRecord)
+
+	err = tm.Setup(ctx, 1, "THIRDSECRET")
+	assert.Equal(t, err, ErrTwoFactorEnabled)
+
+	tf, err = tm.Get(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, tf.Enabled(), true)
+	assert.Equal(t, tf.LastCounter, int64(100))
+
+	// the code used to enable it can't log in.
+	ok, err = tm.UseCounter(ctx, 1, 100)
+	assert.NilError(t, err)
+	assert.Equal(t, ok, false)
+
+	ok, err = tm.UseCounter(ctx, 1, 101)
+	assert.NilError(t, err)
+	assert.Equal(t, ok, true)
+
+	ok, err = tm.UseRecoveryCode(ctx, 1, strings.ToUpper(codes[0]))
+	assert.NilError(t, err)
+	assert.Equal(t, ok, true)
+
+	ok, err = tm.UseRecoveryCode(ctx, 1, codes[0])
+	assert.NilError(t, err)
+	assert.Equal(t, ok, false)
+
+	left, err := tm.RecoveryCodesLeft(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, left, RecoveryCodeCount-1)
+
+	newCodes, err := tm.RegenerateRecoveryCodes(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(newCodes), RecoveryCodeCount)
+
+	ok, err = tm.UseRecoveryCode(ctx, 1, codes[1])
+	assert.NilError(t, err)
+	assert.Equal(t, ok, false)
+
+	assert.NilError(t, tm.Disable(ctx, 1))
+
+	_, err = tm.Get(ctx, 1)
+	assert.Equal(t, err, ErrNoRecord)
+
+	left, err = tm.RecoveryCodesLeft(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, left, 0)
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	codes, err := NewRecoveryCodes()
+	assert.NilError(t, err)
+	assert.Equal(t, len(codes[0]), 19)
+
+	want := HashRecoveryCode(codes[0])
+	assert.Equal(t, string(HashRecoveryCode(strings.ToUpper(codes[0]))), string(want))
+	assert.Equal(t, string(HashRecoveryCode(strings.ReplaceAll(codes[0], "-", " "))), string(want))
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
+)
+
+// UserSessionModelInterface indexes the tokens of the sessions the users are
+// logged in to by user, so their sessions are found without going through
+// the whole session store.
+type UserSessionModelInterface interface {
+	Insert(ctx context.Context, token string, userID int, expiry time.Time) error
+	Tokens(ctx context.Context, userID int) ([]string, error)
+	Delete(ctx context.Context, token string) error
+	DeleteExpired(ctx context.Context) error
+}
+
+type UserSessionModel struct {
+	DB *sql.DB
+	QueryOptions
+}
+
+// Insert adds the session token of the user, until the session expires.
+func (sm *UserSessionModel) Insert(ctx context.Context, token string, userID int, expiry time.Time) error {
+	ctx, span := trace.Start(ctx, "UserSessionModel.Insert")
+	defer span.End()
+
+	query := "INSERT INTO user_sessions (token, user_id, expiry) VALUES(?, ?, ?)"
+
+	queryCtx, done := sm.startQuery(ctx, query)
+	_, err := sm.DB.ExecContext(queryCtx, query, token, userID, expiry.UTC())
+	done()
+
+	return err
+}
+
+// Tokens returns the tokens of the sessions of the user that haven't expired.
+func (sm *UserSessionModel) Tokens(ctx context.Context, userID int) ([]string, error) {
+	ctx, span := trace.Start(ctx, "UserSessionModel.Tokens")
+	defer span.End()
+
+	query := "SELECT token FROM user_sessions WHERE user_id = ? AND expiry > ?"
+
+	queryCtx, done := sm.startQuery(ctx, query)
+	defer done()
+
+	rows, err := sm.DB.QueryContext(queryCtx, query, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []string
+	for rows.Next() {
+		var token string
+		err = rows.Scan(&token)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Delete removes the session token, deleting one that isn't there isn't an
+// error.
+func (sm *UserSessionModel) Delete(ctx context.Context, token string) error {
+	ctx, span := trace.Start(ctx, "UserSessionModel.Delete")
+	defer span.End()
+
+	query := "DELETE FROM user_sessions WHERE token = ?"
+
+	queryCtx, done := sm.startQuery(ctx, query)
+	_, err := sm.DB.ExecContext(queryCtx, query, token)
+	done()
+
+	return err
+}
+
+func (sm *UserSessionModel) DeleteExpired(ctx context.Context) error {
+	ctx, span := trace.Start(ctx, "UserSessionModel.DeleteExpired")
+	defer span.End()
+
+	query := "DELETE FROM user_sessions WHERE expiry <= ?"
+
+	queryCtx, done := sm.startQuery(ctx, query)
+	_, err := sm.DB.ExecContext(queryCtx, query, time.Now().UTC())
+	done()
+
+	return err
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestUserSessionModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestUserSessionModel test")
+	}
+
+	db := newTestDB(t)
+	sm := UserSessionModel{DB: db}
+	ctx := context.Background()
+
+	assert.NilError(t, sm.Insert(ctx, "token-1", 1, time.Now().Add(time.Hour)))
+	assert.NilError(t, sm.Insert(ctx, "token-2", 1, time.Now().Add(time.Hour)))
+	assert.NilError(t, sm.Insert(ctx, "expired", 1, time.Now().Add(-time.Minute)))
+
+	tokens, err := sm.Tokens(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 2)
+
+	tokens, err = sm.Tokens(ctx, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 0)
+
+	assert.NilError(t, sm.Delete(ctx, "token-1"))
+	assert.NilError(t, sm.Delete(ctx, "token-1"))
+
+	tokens, err = sm.Tokens(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 1)
+	assert.Equal(t, tokens[0], "token-2")
+
+	assert.NilError(t, sm.DeleteExpired(ctx))
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM user_sessions").Scan(&count)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 1)
+}
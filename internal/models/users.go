@@ -1,21 +1,69 @@
 package models
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"errors"
 	"strings"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
+	"github.com/ahmadyogi543/snippetbox/internal/trace"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserModelInterface interface {
-	Get(id int) (*User, error)
-	UpdatePassword(id int, currentPassword, newPassword string) error
-	Insert(name, email, password string) error
-	Authenticate(email, password string) (int, error)
-	Exists(id int) (bool, error)
+	Get(ctx context.Context, id int) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	UpdatePassword(ctx context.Context, id int, currentPassword, newPassword string) error
+	SetPassword(ctx context.Context, id int, newPassword string) error
+	Insert(ctx context.Context, name, email, password string) error
+	Provision(ctx context.Context, name, email string) (int, error)
+	Authenticate(ctx context.Context, email, password string) (int, error)
+	Exists(ctx context.Context, id int) (bool, error)
+	RecordFailedLogin(ctx context.Context, id int) error
+	ResetFailedLogins(ctx context.Context, id int) error
+	Unlock(ctx context.Context, id int) error
+	VerifyEmail(ctx context.Context, id int) error
+	List(ctx context.Context) ([]*User, error)
+	Count(ctx context.Context) (total int, disabled int, err error)
+	Emails(ctx context.Context, ids []int) (map[int]string, error)
+	Signups(ctx context.Context, since time.Time) ([]time.Time, error)
+	SetRole(ctx context.Context, id int, role Role) error
+	SetDisabled(ctx context.Context, id int, disabled bool) error
+}
+
+// Role is what a user is allowed to do. The moderators look after the
+// snippets, the admins after the users too.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Roles are the roles from the least to the most privileged.
+var Roles = []Role{RoleUser, RoleModerator, RoleAdmin}
+
+func (r Role) rank() int {
+	for i, role := range Roles {
+		if role == r {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+func (r Role) Valid() bool {
+	return r.rank() > 0
+}
+
+// Includes reports whether r is allowed to do everything other is.
+func (r Role) Includes(other Role) bool {
+	return r.Valid() && r.rank() >= other.rank()
 }
 
 type User struct {
@@ -24,37 +72,246 @@ type User struct {
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	FailedLogins   int
+	// LockedUntil is when the account can be logged in to again after too
+	// many failed logins. It's in the past or zero when it isn't locked.
+	LockedUntil time.Time
+	// EmailVerifiedAt is when the user opened the link sent to their email
+	// address, zero until then.
+	EmailVerifiedAt time.Time
+	Role            Role
+	// DisabledAt is when an admin disabled the account, zero when it isn't.
+	DisabledAt time.Time
+}
+
+func (u *User) Locked() bool {
+	return u.LockedUntil.After(time.Now())
 }
 
+func (u *User) EmailVerified() bool {
+	return !u.EmailVerifiedAt.IsZero()
+}
+
+func (u *User) Disabled() bool {
+	return !u.DisabledAt.IsZero()
+}
+
+// DefaultBcryptCost is the cost of the password hashes when a model doesn't
+// set one.
+const DefaultBcryptCost = 12
+
 type UserModel struct {
-	DB *sql.DB
+	DB         *sql.DB
+	BcryptCost int
+	Lockout    LockoutPolicy
+	QueryOptions
+}
+
+func (um *UserModel) Get(ctx context.Context, id int) (*User, error) {
+	ctx, span := trace.Start(ctx, "UserModel.Get")
+	defer span.End()
+
+	return um.get(ctx, "id = ?", id)
 }
 
-func (um *UserModel) Get(id int) (*User, error) {
+func (um *UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, span := trace.Start(ctx, "UserModel.GetByEmail")
+	defer span.End()
+
+	return um.get(ctx, "email = ?", email)
+}
+
+// userColumns are the columns scanned by scanUser.
+const userColumns = "id, name, email, created, failed_logins, locked_until, email_verified_at, role, disabled_at"
+
+// get returns the user matching where, a condition with one placeholder for
+// arg.
+func (um *UserModel) get(ctx context.Context, where string, arg any) (*User, error) {
+	query := "SELECT " + userColumns + " FROM users WHERE " + where
+
+	queryCtx, done := um.startQuery(ctx, query)
+	user, err := scanUser(um.DB.QueryRowContext(queryCtx, query, arg))
+	done()
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// scanUser scans the userColumns of row, a *sql.Row or *sql.Rows.
+func scanUser(row interface{ Scan(...any) error }) (*User, error) {
 	var user User
+	var lockedUntil, emailVerifiedAt, disabledAt sql.NullTime
 
-	query := `
-		SELECT id, name, email, created
-		FROM users WHERE id = ?
-	`
-	err := um.DB.QueryRow(query, id).Scan(
+	err := row.Scan(
 		&user.ID,
 		&user.Name,
 		&user.Email,
 		&user.Created,
+		&user.FailedLogins,
+		&lockedUntil,
+		&emailVerifiedAt,
+		&user.Role,
+		&disabledAt,
 	)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrNoRecord
-		} else {
+		return nil, err
+	}
+
+	user.LockedUntil = lockedUntil.Time
+	user.EmailVerifiedAt = emailVerifiedAt.Time
+	user.DisabledAt = disabledAt.Time
+
+	return &user, nil
+}
+
+// List returns all the users, the oldest first.
+func (um *UserModel) List(ctx context.Context) ([]*User, error) {
+	ctx, span := trace.Start(ctx, "UserModel.List")
+	defer span.End()
+
+	query := "SELECT " + userColumns + " FROM users ORDER BY id"
+
+	queryCtx, done := um.startQuery(ctx, query)
+	defer done()
+
+	rows, err := um.DB.QueryContext(queryCtx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
+
+		users = append(users, user)
 	}
 
-	return &user, nil
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
 }
 
-func (um *UserModel) UpdatePassword(id int, currentPassword, newPassword string) error {
+// Count returns how many users there are, and how many of them are disabled.
+func (um *UserModel) Count(ctx context.Context) (int, int, error) {
+	ctx, span := trace.Start(ctx, "UserModel.Count")
+	defer span.End()
+
+	query := "SELECT COUNT(*), COUNT(disabled_at) FROM users"
+
+	var total, disabled int
+
+	queryCtx, done := um.startQuery(ctx, query)
+	err := um.DB.QueryRowContext(queryCtx, query).Scan(&total, &disabled)
+	done()
+
+	return total, disabled, err
+}
+
+// emailsBatch is how many users Emails looks up per query, well under the
+// limits of the databases on the number of placeholders.
+const emailsBatch = 500
+
+// Emails returns the emails of the users ids by their IDs, leaving out the
+// ones that don't exist.
+func (um *UserModel) Emails(ctx context.Context, ids []int) (map[int]string, error) {
+	ctx, span := trace.Start(ctx, "UserModel.Emails")
+	defer span.End()
+
+	emails := make(map[int]string, len(ids))
+	for len(ids) > 0 {
+		batch := ids[:min(len(ids), emailsBatch)]
+		ids = ids[len(batch):]
+
+		args := make([]any, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		query := "SELECT id, email FROM users WHERE id IN (?" + strings.Repeat(", ?", len(batch)-1) + ")"
+
+		err := um.queryEmails(ctx, query, args, emails)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return emails, nil
+}
+
+func (um *UserModel) queryEmails(ctx context.Context, query string, args []any, emails map[int]string) error {
+	queryCtx, done := um.startQuery(ctx, query)
+	defer done()
+
+	rows, err := um.DB.QueryContext(queryCtx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var email string
+		err := rows.Scan(&id, &email)
+		if err != nil {
+			return err
+		}
+
+		emails[id] = email
+	}
+
+	return rows.Err()
+}
+
+// Signups returns when the users who signed up since then did, the oldest
+// first.
+func (um *UserModel) Signups(ctx context.Context, since time.Time) ([]time.Time, error) {
+	ctx, span := trace.Start(ctx, "UserModel.Signups")
+	defer span.End()
+
+	query := "SELECT created FROM users WHERE created >= ? ORDER BY created"
+
+	queryCtx, done := um.startQuery(ctx, query)
+	defer done()
+
+	rows, err := um.DB.QueryContext(queryCtx, query, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	signups := []time.Time{}
+	for rows.Next() {
+		var created time.Time
+		err := rows.Scan(&created)
+		if err != nil {
+			return nil, err
+		}
+
+		signups = append(signups, created)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return signups, nil
+}
+
+func (um *UserModel) UpdatePassword(ctx context.Context, id int, currentPassword, newPassword string) error {
+	ctx, span := trace.Start(ctx, "UserModel.UpdatePassword")
+	defer span.End()
+
 	var currentHashedPassword []byte
 
 	query := `
@@ -63,7 +320,9 @@ func (um *UserModel) UpdatePassword(id int, currentPassword, newPassword string)
 		WHERE id = ?
 	`
 
-	err := um.DB.QueryRow(query, id).Scan(&currentHashedPassword)
+	queryCtx, done := um.startQuery(ctx, query)
+	err := um.DB.QueryRowContext(queryCtx, query, id).Scan(&currentHashedPassword)
+	done()
 	if err != nil {
 		return err
 	}
@@ -77,34 +336,62 @@ func (um *UserModel) UpdatePassword(id int, currentPassword, newPassword string)
 		}
 	}
 
-	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), um.bcryptCost())
 	if err != nil {
 		return err
 	}
 
 	query = "UPDATE users SET hashed_password = ? WHERE id = ?"
-	_, err = um.DB.Exec(query, string(newHashedPassword), id)
+	queryCtx, done = um.startQuery(ctx, query)
+	_, err = um.DB.ExecContext(queryCtx, query, string(newHashedPassword), id)
+	done()
 
 	return err
 }
 
-func (um *UserModel) Insert(name, email, password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+// SetPassword replaces the password without checking the current one, for
+// the users who proved they own the email address. It unlocks the account
+// too.
+func (um *UserModel) SetPassword(ctx context.Context, id int, newPassword string) error {
+	ctx, span := trace.Start(ctx, "UserModel.SetPassword")
+	defer span.End()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), um.bcryptCost())
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE users SET hashed_password = ?, failed_logins = 0, locked_until = NULL WHERE id = ?"
+
+	queryCtx, done := um.startQuery(ctx, query)
+	result, err := um.DB.ExecContext(queryCtx, query, string(hashedPassword), id)
+	done()
+	if err != nil {
+		return err
+	}
+
+	return um.checkUpdated(ctx, id, result)
+}
+
+func (um *UserModel) Insert(ctx context.Context, name, email, password string) error {
+	ctx, span := trace.Start(ctx, "UserModel.Insert")
+	defer span.End()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), um.bcryptCost())
 	if err != nil {
 		return err
 	}
 	query := `
 		INSERT INTO users (name, email, hashed_password, created)
-		VALUES(?, ?, ?, UTC_TIMESTAMP())
+		VALUES(?, ?, ?, ?)
 	`
 
-	_, err = um.DB.Exec(query, name, email, string(hashedPassword))
+	queryCtx, done := um.startQuery(ctx, query)
+	_, err = um.DB.ExecContext(queryCtx, query, name, email, string(hashedPassword), time.Now().UTC())
+	done()
 	if err != nil {
-		var mySQLError *mysql.MySQLError
-		if errors.As(err, &mySQLError) {
-			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
-			}
+		if isUniqueViolation(err, "users_uc_email", "users.email") {
+			return ErrDuplicateEmail
 		}
 
 		return err
@@ -113,42 +400,310 @@ func (um *UserModel) Insert(name, email, password string) error {
 	return nil
 }
 
-func (um *UserModel) Authenticate(email, password string) (int, error) {
-	var id int
+// Provision creates the account of a user logging in with single sign-on
+// and returns its ID. The provider verified the email address already, and
+// the password is random, the user can set one with a password reset.
+func (um *UserModel) Provision(ctx context.Context, name, email string) (int, error) {
+	ctx, span := trace.Start(ctx, "UserModel.Provision")
+	defer span.End()
+
+	hashedPassword, err := randomPasswordHash(um.bcryptCost())
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	query := `
+		INSERT INTO users (name, email, hashed_password, created, email_verified_at)
+		VALUES(?, ?, ?, ?, ?)
+	`
+
+	queryCtx, done := um.startQuery(ctx, query)
+	result, err := um.DB.ExecContext(queryCtx, query, name, email, string(hashedPassword), now, now)
+	done()
+	if err != nil {
+		if isUniqueViolation(err, "users_uc_email", "users.email") {
+			return 0, ErrDuplicateEmail
+		}
+
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// randomPasswordHash hashes a random password nobody knows, for the
+// accounts created without one.
+func randomPasswordHash(cost int) ([]byte, error) {
+	password := make([]byte, 32)
+	_, err := rand.Read(password)
+	if err != nil {
+		return nil, err
+	}
+
+	return bcrypt.GenerateFromPassword([]byte(base64.RawURLEncoding.EncodeToString(password)), cost)
+}
+
+func (um *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	ctx, span := trace.Start(ctx, "UserModel.Authenticate")
+	defer span.End()
+
+	var id, failedLogins int
 	var hashedPassword []byte
+	var lockedUntil, disabledAt sql.NullTime
 
 	query := `
-		SELECT id, hashed_password
+		SELECT id, hashed_password, failed_logins, locked_until, disabled_at
 		FROM users
 		WHERE email = ?
 	`
 
-	err := um.DB.QueryRow(query, email).Scan(&id, &hashedPassword)
+	queryCtx, done := um.startQuery(ctx, query)
+	err := um.DB.QueryRowContext(queryCtx, query, email).Scan(&id, &hashedPassword, &failedLogins, &lockedUntil, &disabledAt)
+	done()
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			CompareDummyHash(password, um.bcryptCost())
 			return 0, ErrInvalidCredentials
 		} else {
 			return 0, err
 		}
 	}
 
+	now := time.Now().UTC()
+	if lockedUntil.Valid && lockedUntil.Time.After(now) {
+		CompareDummyHash(password, um.bcryptCost())
+		return 0, &LockoutError{UserID: id, Until: lockedUntil.Time}
+	}
+
 	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
 	if err != nil {
 		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			return 0, ErrInvalidCredentials
+			return 0, um.recordFailure(ctx, id, now)
 		} else {
 			return 0, err
 		}
 	}
 
+	// only the owner of the account learns it's disabled.
+	if disabledAt.Valid {
+		return 0, ErrAccountDisabled
+	}
+
+	// the failed logins are only forgotten by ResetFailedLogins once the user
+	// is logged in, the right password alone doesn't get past a second factor.
 	return id, nil
 }
 
-func (um *UserModel) Exists(id int) (bool, error) {
+// RecordFailedLogin counts a failed login of the user past their password,
+// like a wrong two-factor code, against the lockout policy. It returns
+// ErrInvalidCredentials, or a LockoutError when it locked the account.
+func (um *UserModel) RecordFailedLogin(ctx context.Context, id int) error {
+	ctx, span := trace.Start(ctx, "UserModel.RecordFailedLogin")
+	defer span.End()
+
+	return um.recordFailure(ctx, id, time.Now().UTC())
+}
+
+// ResetFailedLogins forgets the failed logins of the user once they logged
+// in. It doesn't lift a lock that hasn't expired yet, that's Unlock.
+func (um *UserModel) ResetFailedLogins(ctx context.Context, id int) error {
+	ctx, span := trace.Start(ctx, "UserModel.ResetFailedLogins")
+	defer span.End()
+
+	query := `
+		UPDATE users SET failed_logins = 0, locked_until = NULL
+		WHERE id = ? AND failed_logins > 0 AND (locked_until IS NULL OR locked_until <= ?)
+	`
+
+	queryCtx, done := um.startQuery(ctx, query)
+	_, err := um.DB.ExecContext(queryCtx, query, id, time.Now().UTC())
+	done()
+
+	return err
+}
+
+// recordFailure counts a failed login of the user and locks the account
+// when the lockout policy says so. It returns the error Authenticate
+// returns. The failures of concurrent logins are all counted, and a lock is
+// only ever extended, so a failure counted before the lock doesn't lift it.
+func (um *UserModel) recordFailure(ctx context.Context, id int, now time.Time) error {
+	query := "UPDATE users SET failed_logins = failed_logins + 1 WHERE id = ?"
+
+	queryCtx, done := um.startQuery(ctx, query)
+	_, err := um.DB.ExecContext(queryCtx, query, id)
+	done()
+	if err != nil {
+		return err
+	}
+
+	var failedLogins int
+	var locked sql.NullTime
+
+	// the count includes this failure, and maybe some counted since.
+	query = "SELECT failed_logins, locked_until FROM users WHERE id = ?"
+
+	queryCtx, done = um.startQuery(ctx, query)
+	err = um.DB.QueryRowContext(queryCtx, query, id).Scan(&failedLogins, &locked)
+	done()
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	lockDuration := um.Lockout.LockDuration(failedLogins)
+	if lockDuration <= 0 {
+		return ErrInvalidCredentials
+	}
+	lockedUntil := now.Add(lockDuration)
+
+	query = `
+		UPDATE users SET locked_until = CASE
+			WHEN locked_until IS NULL OR locked_until < ? THEN ?
+			ELSE locked_until
+		END
+		WHERE id = ?
+	`
+
+	queryCtx, done = um.startQuery(ctx, query)
+	_, err = um.DB.ExecContext(queryCtx, query, lockedUntil, lockedUntil, id)
+	done()
+	if err != nil {
+		return err
+	}
+
+	if locked.Valid && locked.Time.After(lockedUntil) {
+		lockedUntil = locked.Time
+	}
+
+	return &LockoutError{UserID: id, Until: lockedUntil, Started: true}
+}
+
+// Unlock lets the user log in again right away.
+func (um *UserModel) Unlock(ctx context.Context, id int) error {
+	ctx, span := trace.Start(ctx, "UserModel.Unlock")
+	defer span.End()
+
+	query := "UPDATE users SET failed_logins = 0, locked_until = NULL WHERE id = ?"
+
+	queryCtx, done := um.startQuery(ctx, query)
+	result, err := um.DB.ExecContext(queryCtx, query, id)
+	done()
+	if err != nil {
+		return err
+	}
+
+	return um.checkUpdated(ctx, id, result)
+}
+
+// checkUpdated returns ErrNoRecord when the UPDATE that gave result didn't
+// find the user id. MySQL only counts the rows it changed, so when none were
+// it looks the user up to tell a missing user from one left as they were.
+func (um *UserModel) checkUpdated(ctx context.Context, id int, result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	exists, err := um.Exists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// VerifyEmail records that the user owns their email address. Verifying it
+// again keeps the first time.
+func (um *UserModel) VerifyEmail(ctx context.Context, id int) error {
+	ctx, span := trace.Start(ctx, "UserModel.VerifyEmail")
+	defer span.End()
+
+	query := "UPDATE users SET email_verified_at = COALESCE(email_verified_at, ?) WHERE id = ?"
+
+	queryCtx, done := um.startQuery(ctx, query)
+	result, err := um.DB.ExecContext(queryCtx, query, time.Now().UTC(), id)
+	done()
+	if err != nil {
+		return err
+	}
+
+	return um.checkUpdated(ctx, id, result)
+}
+
+// SetRole changes what the user is allowed to do.
+func (um *UserModel) SetRole(ctx context.Context, id int, role Role) error {
+	ctx, span := trace.Start(ctx, "UserModel.SetRole")
+	defer span.End()
+
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+
+	query := "UPDATE users SET role = ? WHERE id = ?"
+
+	return um.updateUser(ctx, id, query, role)
+}
+
+// SetDisabled disables the account, so the user can't log in anymore, or
+// enables it again.
+func (um *UserModel) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	ctx, span := trace.Start(ctx, "UserModel.SetDisabled")
+	defer span.End()
+
+	if !disabled {
+		return um.updateUser(ctx, id, "UPDATE users SET disabled_at = NULL WHERE id = ?")
+	}
+
+	// disabling it again keeps the first time.
+	query := "UPDATE users SET disabled_at = COALESCE(disabled_at, ?) WHERE id = ?"
+
+	return um.updateUser(ctx, id, query, time.Now().UTC())
+}
+
+// updateUser runs query, an UPDATE of the user id taking args then id.
+func (um *UserModel) updateUser(ctx context.Context, id int, query string, args ...any) error {
+	queryCtx, done := um.startQuery(ctx, query)
+	result, err := um.DB.ExecContext(queryCtx, query, append(args, id)...)
+	done()
+	if err != nil {
+		return err
+	}
+
+	return um.checkUpdated(ctx, id, result)
+}
+
+func (um *UserModel) Exists(ctx context.Context, id int) (bool, error) {
+	ctx, span := trace.Start(ctx, "UserModel.Exists")
+	defer span.End()
+
 	var exists bool
 
 	query := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
-	err := um.DB.QueryRow(query, id).Scan(&exists)
+	queryCtx, done := um.startQuery(ctx, query)
+	err := um.DB.QueryRowContext(queryCtx, query, id).Scan(&exists)
+	done()
 
 	return exists, err
 }
+
+func (um *UserModel) bcryptCost() int {
+	if um.BcryptCost == 0 {
+		return DefaultBcryptCost
+	}
+
+	return um.BcryptCost
+}
@@ -1,7 +1,11 @@
 package models
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ahmadyogi543/snippetbox/internal/assert"
 )
@@ -43,9 +47,429 @@ func TestUserModelExists(t *testing.T) {
 			db := newTestDB(t)
 			um := UserModel{DB: db}
 
-			exists, err := um.Exists(test.userID)
+			exists, err := um.Exists(context.Background(), test.userID)
 			assert.Equal(t, exists, test.expected)
 			assert.NilError(t, err)
 		})
 	}
 }
+
+func TestUserModelInsertAndAuthenticate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestUserModelInsertAndAuthenticate test")
+	}
+
+	db := newTestDB(t)
+	um := UserModel{DB: db}
+
+	err := um.Insert(context.Background(), "Jane Doe", "jane@snippetbox.sh", "pa55word")
+	assert.NilError(t, err)
+
+	err = um.Insert(context.Background(), "Jane Doe", "jane@snippetbox.sh", "pa55word")
+	assert.Equal(t, err, ErrDuplicateEmail)
+
+	// the emails don't differ by case.
+	err = um.Insert(context.Background(), "Jane Doe", "Jane@Snippetbox.sh", "pa55word")
+	assert.Equal(t, err, ErrDuplicateEmail)
+
+	id, err := um.Authenticate(context.Background(), "jane@snippetbox.sh", "pa55word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 2)
+
+	user, err := um.GetByEmail(context.Background(), "JANE@snippetbox.sh")
+	assert.NilError(t, err)
+	assert.Equal(t, user.ID, 2)
+
+	_, err = um.Authenticate(context.Background(), "jane@snippetbox.sh", "wrong password")
+	assert.Equal(t, err, ErrInvalidCredentials)
+
+	user, err = um.Get(context.Background(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Email, "ahmady@snippetbox.sh")
+}
+
+func TestUserModelLockout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestUserModelLockout test")
+	}
+
+	db := newTestDB(t)
+	um := UserModel{DB: db, BcryptCost: 4, Lockout: LockoutPolicy{Threshold: 2, Duration: time.Minute, MaxDuration: time.Hour}}
+	ctx := context.Background()
+
+	err := um.Insert(ctx, "Jane Doe", "jane@snippetbox.sh", "pa55word")
+	assert.NilError(t, err)
+
+	_, err = um.Authenticate(ctx, "jane@snippetbox.sh", "wrong password")
+	assert.Equal(t, err, ErrInvalidCredentials)
+
+	_, err = um.Authenticate(ctx, "jane@snippetbox.sh", "wrong password")
+	var lockout *LockoutError
+	if !errors.As(err, &lockout) {
+		t.Fatalf("got: %v; want: a lockout", err)
+	}
+	assert.Equal(t, lockout.Started, true)
+
+	// the right password doesn't help while it's locked.
+	_, err = um.Authenticate(ctx, "jane@snippetbox.sh", "pa55word")
+	assert.Equal(t, errors.Is(err, ErrAccountLocked), true)
+
+	user, err := um.GetByEmail(ctx, "jane@snippetbox.sh")
+	assert.NilError(t, err)
+	assert.Equal(t, user.FailedLogins, 2)
+	assert.Equal(t, user.Locked(), true)
+
+	err = um.Unlock(ctx, user.ID)
+	assert.NilError(t, err)
+
+	// unlocking it again changes nothing, the user is still found.
+	err = um.Unlock(ctx, user.ID)
+	assert.NilError(t, err)
+
+	id, err := um.Authenticate(ctx, "jane@snippetbox.sh", "pa55word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, user.ID)
+
+	err = um.Unlock(ctx, 100)
+	assert.Equal(t, err, ErrNoRecord)
+
+	// a wrong code after the right password counts, and the right password
+	// doesn't forget it.
+	err = um.RecordFailedLogin(ctx, user.ID)
+	assert.Equal(t, err, ErrInvalidCredentials)
+
+	_, err = um.Authenticate(ctx, "jane@snippetbox.sh", "pa55word")
+	assert.NilError(t, err)
+
+	err = um.RecordFailedLogin(ctx, user.ID)
+	if !errors.As(err, &lockout) {
+		t.Fatalf("got: %v; want: a lockout", err)
+	}
+	assert.Equal(t, lockout.Started, true)
+
+	// logging in doesn't lift the lock.
+	assert.NilError(t, um.ResetFailedLogins(ctx, user.ID))
+	_, err = um.Authenticate(ctx, "jane@snippetbox.sh", "pa55word")
+	assert.Equal(t, errors.Is(err, ErrAccountLocked), true)
+
+	assert.NilError(t, um.Unlock(ctx, user.ID))
+	err = um.RecordFailedLogin(ctx, user.ID)
+	assert.Equal(t, err, ErrInvalidCredentials)
+	assert.NilError(t, um.ResetFailedLogins(ctx, user.ID))
+
+	user, err = um.Get(ctx, user.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, user.FailedLogins, 0)
+
+	err = um.RecordFailedLogin(ctx, 100)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestUserModelLockoutConcurrentFailures(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestUserModelLockoutConcurrentFailures test")
+	}
+
+	db := newTestDB(t)
+	// one connection, so SQLite doesn't report the database as busy, the
+	// logins still interleave between their queries.
+	db.SetMaxOpenConns(1)
+	um := UserModel{DB: db, BcryptCost: 8, Lockout: LockoutPolicy{Threshold: 3, Duration: time.Minute, MaxDuration: time.Hour}}
+	ctx := context.Background()
+
+	err := um.Insert(ctx, "Jane Doe", "jane@snippetbox.sh", "pa55word")
+	assert.NilError(t, err)
+
+	const logins = 20
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, logins)
+	for i := 0; i < logins; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := um.Authenticate(ctx, "jane@snippetbox.sh", "wrong password")
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	// every failure that got past the lock check is counted.
+	counted := 0
+	for err := range errs {
+		var lockout *LockoutError
+		switch {
+		case errors.As(err, &lockout):
+			if lockout.Started {
+				counted++
+			}
+		case errors.Is(err, ErrInvalidCredentials):
+			counted++
+		default:
+			t.Fatalf("got: %v; want: a failed login", err)
+		}
+	}
+
+	user, err := um.GetByEmail(ctx, "jane@snippetbox.sh")
+	assert.NilError(t, err)
+	assert.Equal(t, user.FailedLogins, counted)
+	assert.Equal(t, user.Locked(), true)
+
+	// a failure that was let in before the lock, and counted after it was
+	// reset, doesn't lift the lock.
+	until := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	_, err = db.Exec("UPDATE users SET failed_logins = 0, locked_until = ? WHERE id = ?", until, user.ID)
+	assert.NilError(t, err)
+
+	err = um.recordFailure(ctx, user.ID, time.Now().UTC())
+	assert.Equal(t, err, ErrInvalidCredentials)
+
+	// nor does one that locks it for less time.
+	_, err = db.Exec("UPDATE users SET failed_logins = 5 WHERE id = ?", user.ID)
+	assert.NilError(t, err)
+
+	var lockout *LockoutError
+	err = um.recordFailure(ctx, user.ID, time.Now().UTC())
+	if !errors.As(err, &lockout) {
+		t.Fatalf("got: %v; want: a lockout", err)
+	}
+
+	assert.Equal(t, lockout.Until.Equal(until), true)
+
+	user, err = um.Get(ctx, user.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, user.LockedUntil.Equal(until), true)
+}
+
+func TestLoginFailureModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestLoginFailureModel test")
+	}
+
+	db := newTestDB(t)
+	lm := LoginFailureModel{DB: db}
+	ctx := context.Background()
+	start := time.Now().Add(-time.Minute)
+
+	for i := 0; i < 3; i++ {
+		assert.NilError(t, lm.Insert(ctx, "192.0.2.1"))
+	}
+	assert.NilError(t, lm.Insert(ctx, "192.0.2.2"))
+
+	count, err := lm.Count(ctx, "192.0.2.1", start)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 3)
+
+	assert.NilError(t, lm.DeleteBefore(ctx, time.Now().Add(time.Minute)))
+
+	count, err = lm.Count(ctx, "192.0.2.1", start)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 0)
+}
+
+func TestUserModelVerifyEmail(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestUserModelVerifyEmail test")
+	}
+
+	db := newTestDB(t)
+	um := UserModel{DB: db}
+	ctx := context.Background()
+
+	user, err := um.Get(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, user.EmailVerified(), false)
+
+	assert.NilError(t, um.VerifyEmail(ctx, 1))
+
+	user, err = um.Get(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, user.EmailVerified(), true)
+
+	// verifying it again, like following the link twice, keeps the first time.
+	assert.NilError(t, um.VerifyEmail(ctx, 1))
+
+	again, err := um.Get(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, again.EmailVerifiedAt.Equal(user.EmailVerifiedAt), true)
+
+	err = um.VerifyEmail(ctx, 100)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestUserModelSetPassword(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestUserModelSetPassword test")
+	}
+
+	db := newTestDB(t)
+	um := UserModel{DB: db, BcryptCost: 4, Lockout: LockoutPolicy{Threshold: 1, Duration: time.Minute}}
+	ctx := context.Background()
+
+	err := um.Insert(ctx, "Jane Doe", "jane@snippetbox.sh", "pa55word")
+	assert.NilError(t, err)
+
+	_, err = um.Authenticate(ctx, "jane@snippetbox.sh", "wrong password")
+	assert.Equal(t, errors.Is(err, ErrAccountLocked), true)
+
+	user, err := um.GetByEmail(ctx, "jane@snippetbox.sh")
+	assert.NilError(t, err)
+
+	// setting the password unlocks the account too.
+	assert.NilError(t, um.SetPassword(ctx, user.ID, "n3wpa55word"))
+
+	id, err := um.Authenticate(ctx, "jane@snippetbox.sh", "n3wpa55word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, user.ID)
+
+	_, err = um.Authenticate(ctx, "jane@snippetbox.sh", "pa55word")
+	assert.Equal(t, errors.Is(err, ErrAccountLocked), true)
+
+	err = um.SetPassword(ctx, 100, "n3wpa55word")
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestUserModelProvision(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestUserModelProvision test")
+	}
+
+	db := newTestDB(t)
+	um := UserModel{DB: db, BcryptCost: 4}
+	ctx := context.Background()
+
+	id, err := um.Provision(ctx, "Jane Doe", "jane@snippetbox.sh")
+	assert.NilError(t, err)
+
+	user, err := um.Get(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Email, "jane@snippetbox.sh")
+	assert.Equal(t, user.EmailVerified(), true)
+
+	_, err = um.Provision(ctx, "Ahmad Yogi", "ahmady@snippetbox.sh")
+	assert.Equal(t, err, ErrDuplicateEmail)
+}
+
+func TestUserModelRoles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestUserModelRoles test")
+	}
+
+	db := newTestDB(t)
+	um := UserModel{DB: db}
+	ctx := context.Background()
+
+	user, err := um.Get(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Role, RoleUser)
+
+	assert.NilError(t, um.SetRole(ctx, 1, RoleAdmin))
+
+	user, err = um.Get(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Role, RoleAdmin)
+
+	// giving the role they already have changes nothing.
+	assert.NilError(t, um.SetRole(ctx, 1, RoleAdmin))
+
+	err = um.SetRole(ctx, 1, Role("root"))
+	assert.Equal(t, err, ErrInvalidRole)
+
+	err = um.SetRole(ctx, 100, RoleModerator)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestUserModelSetDisabled(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestUserModelSetDisabled test")
+	}
+
+	db := newTestDB(t)
+	um := UserModel{DB: db, BcryptCost: 4}
+	ctx := context.Background()
+
+	err := um.Insert(ctx, "Jane Doe", "jane@snippetbox.sh", "pa55word")
+	assert.NilError(t, err)
+	user, err := um.GetByEmail(ctx, "jane@snippetbox.sh")
+	assert.NilError(t, err)
+
+	assert.NilError(t, um.SetDisabled(ctx, user.ID, true))
+
+	user, err = um.Get(ctx, user.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Disabled(), true)
+
+	// disabling it again keeps the first time.
+	assert.NilError(t, um.SetDisabled(ctx, user.ID, true))
+
+	again, err := um.Get(ctx, user.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, again.DisabledAt.Equal(user.DisabledAt), true)
+
+	_, err = um.Authenticate(ctx, "jane@snippetbox.sh", "pa55word")
+	assert.Equal(t, err, ErrAccountDisabled)
+
+	// the wrong password doesn't tell it's disabled.
+	_, err = um.Authenticate(ctx, "jane@snippetbox.sh", "wrong password")
+	assert.Equal(t, err, ErrInvalidCredentials)
+
+	assert.NilError(t, um.SetDisabled(ctx, user.ID, false))
+	assert.NilError(t, um.SetDisabled(ctx, user.ID, false))
+
+	id, err := um.Authenticate(ctx, "jane@snippetbox.sh", "pa55word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, user.ID)
+
+	err = um.SetDisabled(ctx, 100, true)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestUserModelListAndSignups(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping TestUserModelListAndSignups test")
+	}
+
+	db := newTestDB(t)
+	um := UserModel{DB: db, BcryptCost: 4}
+	ctx := context.Background()
+
+	start := time.Now().Add(-time.Minute)
+	err := um.Insert(ctx, "Jane Doe", "jane@snippetbox.sh", "pa55word")
+	assert.NilError(t, err)
+
+	users, err := um.List(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(users), 2)
+	assert.Equal(t, users[0].Email, "ahmady@snippetbox.sh")
+	assert.Equal(t, users[1].Email, "jane@snippetbox.sh")
+
+	assert.NilError(t, um.SetDisabled(ctx, users[1].ID, true))
+	total, disabled, err := um.Count(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 2)
+	assert.Equal(t, disabled, 1)
+
+	emails, err := um.Emails(ctx, []int{users[1].ID, 100})
+	assert.NilError(t, err)
+	assert.Equal(t, len(emails), 1)
+	assert.Equal(t, emails[users[1].ID], "jane@snippetbox.sh")
+
+	// more users than a query looks up at once.
+	ids := make([]int, emailsBatch+1)
+	for i := range ids {
+		ids[i] = i + 1
+	}
+	emails, err = um.Emails(ctx, ids)
+	assert.NilError(t, err)
+	assert.Equal(t, len(emails), 2)
+
+	// the seed user signed up in 2023.
+	signups, err := um.Signups(ctx, start)
+	assert.NilError(t, err)
+	assert.Equal(t, len(signups), 1)
+	assert.Equal(t, signups[0].Equal(users[1].Created), true)
+}
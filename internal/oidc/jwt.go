@@ -0,0 +1,176 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var errToken = errors.New("oidc: malformed token")
+
+// jws is a token in the JWS compact serialization, split but not verified.
+type jws struct {
+	alg       string
+	kid       string
+	signed    []byte
+	payload   []byte
+	signature []byte
+}
+
+func parseJWS(raw string) (*jws, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errToken
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	err = json.Unmarshal(headerJSON, &header)
+	if err != nil {
+		return nil, errToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errToken
+	}
+
+	return &jws{
+		alg:       header.Alg,
+		kid:       header.Kid,
+		signed:    []byte(parts[0] + "." + parts[1]),
+		payload:   payload,
+		signature: signature,
+	}, nil
+}
+
+// verify checks the signature of the token by the key. The algorithm has to
+// match the type of the key, so a token can't pick a weaker one, and "none"
+// is never accepted.
+func (t *jws) verify(key crypto.PublicKey) bool {
+	digest := sha256.Sum256(t.signed)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return t.alg == "RS256" && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], t.signature) == nil
+	case *ecdsa.PublicKey:
+		if t.alg != "ES256" || len(t.signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(t.signature[:32])
+		s := new(big.Int).SetBytes(t.signature[32:])
+		return ecdsa.Verify(key, digest[:], r, s)
+	}
+
+	return false
+}
+
+// jsonWebKey is a key of a JWK set, only the fields of the RSA and P-256
+// signing keys.
+// The sizes of the RSA moduli accepted. The verification time grows with the
+// size, so the keys larger than any provider uses are refused.
+const (
+	minRSABits = 2048
+	maxRSABits = 8192
+)
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS returns the signing keys of a JWK set by key ID. The keys of
+// other types are skipped, the providers list keys for other uses too.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err := json.Unmarshal(data, &set)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed JWK set: %w", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (jwk *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, errToken
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errToken
+		}
+		if n.BitLen() < minRSABits || n.BitLen() > maxRSABits {
+			return nil, errToken
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, errToken
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		if !key.Curve.IsOnCurve(x, y) {
+			return nil, errToken
+		}
+		return key, nil
+	}
+
+	return nil, errToken
+}
@@ -0,0 +1,32 @@
+package oidc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestJSONWebKeyRSASize(t *testing.T) {
+	tests := []struct {
+		name  string
+		bits  int
+		valid bool
+	}{
+		{name: "2048 bits", bits: 2048, valid: true},
+		{name: "8192 bits", bits: 8192, valid: true},
+		{name: "Too small", bits: 1024},
+		{name: "Too large", bits: 8200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := append([]byte{0x80}, bytes.Repeat([]byte{0x01}, tt.bits/8-1)...)
+			jwk := &jsonWebKey{Kty: "RSA", N: base64.RawURLEncoding.EncodeToString(n), E: "AQAB"}
+
+			_, err := jwk.publicKey()
+			assert.Equal(t, err == nil, tt.valid)
+		})
+	}
+}
@@ -0,0 +1,370 @@
+// Package oidc implements the relying party side of an OpenID Connect
+// login: the discovery of the provider, the authorization code flow with
+// PKCE, and the validation of the ID tokens against the keys the provider
+// publishes.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// leeway is how far off the clocks of the provider and the server can
+	// be when checking the times in the ID tokens.
+	leeway = time.Minute
+	// keysRefreshInterval limits how often the keys are fetched again for
+	// an unknown key ID, so forged tokens can't make the server hammer the
+	// provider.
+	keysRefreshInterval = time.Minute
+	// maxResponseBytes limits the responses read from the provider.
+	maxResponseBytes = 1 << 20
+)
+
+// ErrInvalidToken is wrapped by the errors of the ID tokens that don't
+// validate, saying what's wrong with them.
+var ErrInvalidToken = errors.New("oidc: invalid ID token")
+
+// Provider is an OpenID Connect provider the users log in with. Its metadata
+// and keys are fetched when they're first needed.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends the users back to, it has to
+	// be registered with the provider.
+	RedirectURL string
+	// Client makes the requests to the provider, http.DefaultClient when
+	// nil.
+	Client *http.Client
+
+	mu          sync.Mutex
+	metadata    *metadata
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the claims of a validated ID token the login needs.
+type Claims struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// RandomString returns a random string for a state, nonce or PKCE verifier.
+func RandomString() (string, error) {
+	randomBytes := make([]byte, 32)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
+
+// AuthCodeURL returns the URL of the provider to send the users to. The
+// state, nonce and PKCE verifier have to be kept until they come back.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", p.ClientID)
+	values.Set("redirect_uri", p.RedirectURL)
+	values.Set("scope", "openid email profile")
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	values.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	values.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(md.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return md.AuthorizationEndpoint + separator + values.Encode(), nil
+}
+
+// Exchange trades the code the users came back with for an ID token, and
+// returns its claims once it's validated.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.do(req, &token)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("oidc: token request failed with status %d: %s %s", status, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("oidc: no ID token in the token response")
+	}
+
+	return p.VerifyIDToken(ctx, token.IDToken, nonce)
+}
+
+// VerifyIDToken validates the ID token, issued to this client for the login
+// with the nonce, and returns its claims.
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (*Claims, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := parseJWS(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	key, err := p.key(ctx, md, token.kid)
+	if err != nil {
+		return nil, err
+	}
+	if !token.verify(key) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var claims struct {
+		Issuer        string   `json:"iss"`
+		Subject       string   `json:"sub"`
+		Audience      audience `json:"aud"`
+		AuthorizedBy  string   `json:"azp"`
+		Expiry        int64    `json:"exp"`
+		IssuedAt      int64    `json:"iat"`
+		Nonce         string   `json:"nonce"`
+		Email         string   `json:"email"`
+		EmailVerified boolish  `json:"email_verified"`
+		Name          string   `json:"name"`
+	}
+	err = json.Unmarshal(token.payload, &claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+
+	now := time.Now()
+	switch {
+	case claims.Issuer != md.Issuer:
+		return nil, fmt.Errorf("%w: issued by %q", ErrInvalidToken, claims.Issuer)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	case !claims.Audience.contains(p.ClientID):
+		return nil, fmt.Errorf("%w: issued to another client", ErrInvalidToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedBy != p.ClientID:
+		return nil, fmt.Errorf("%w: authorized for another client", ErrInvalidToken)
+	case now.After(time.Unix(claims.Expiry, 0).Add(leeway)):
+		return nil, fmt.Errorf("%w: expired", ErrInvalidToken)
+	case time.Unix(claims.IssuedAt, 0).After(now.Add(leeway)):
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	case subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1:
+		return nil, fmt.Errorf("%w: wrong nonce", ErrInvalidToken)
+	}
+
+	return &Claims{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+// discover returns the metadata of the provider, fetching it the first
+// time. The errors aren't cached, the next login tries again.
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var md metadata
+	status, err := p.do(req, &md)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discovery: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: discovery failed with status %d", status)
+	}
+
+	// the provider can't claim to be another one.
+	if md.Issuer != p.Issuer {
+		return nil, fmt.Errorf("oidc: discovery returned the issuer %q, not %q", md.Issuer, p.Issuer)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery returned incomplete metadata")
+	}
+
+	p.metadata = &md
+	return p.metadata, nil
+}
+
+// key returns the key with the ID, fetching the keys again when it's
+// unknown, as the providers rotate them.
+func (p *Provider) key(ctx context.Context, md *metadata, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	if time.Since(p.keysFetched) < keysRefreshInterval {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, md.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	status, err := p.do(req, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: fetching keys: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: fetching keys failed with status %d", status)
+	}
+
+	keys, err := parseJWKS(raw)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+	}
+
+	return key, nil
+}
+
+// do sends the request and decodes the JSON response into dst.
+func (p *Provider) do(req *http.Request, dst any) (int, error) {
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponseBytes))
+	if err != nil {
+		return 0, err
+	}
+
+	err = json.Unmarshal(body, dst)
+	if err != nil && res.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("malformed response: %w", err)
+	}
+
+	return res.StatusCode, nil
+}
+
+// audience is the aud claim, either one string or an array of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if json.Unmarshal(data, &one) == nil {
+		*a = audience{one}
+		return nil
+	}
+
+	var many []string
+	err := json.Unmarshal(data, &many)
+	if err != nil {
+		return err
+	}
+	*a = many
+
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// boolish is a boolean claim some providers send as a string.
+type boolish bool
+
+func (b *boolish) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", `"true"`:
+		*b = true
+	case "false", `"false"`, "null":
+		*b = false
+	default:
+		return fmt.Errorf("oidc: invalid boolean %s", data)
+	}
+
+	return nil
+}
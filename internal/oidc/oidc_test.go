@@ -0,0 +1,210 @@
+package oidc_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/oidc"
+	"github.com/ahmadyogi543/snippetbox/internal/oidc/oidctest"
+)
+
+const redirectURL = "https://snippetbox.sh/user/login/oidc/callback"
+
+func newProvider(t *testing.T) (*oidctest.Provider, *oidc.Provider) {
+	server := oidctest.NewProvider("snippetbox", "secret")
+	t.Cleanup(server.Close)
+
+	return server, &oidc.Provider{
+		Issuer:       server.Issuer(),
+		ClientID:     "snippetbox",
+		ClientSecret: "secret",
+		RedirectURL:  redirectURL,
+	}
+}
+
+// authorize goes through the authorization endpoint and returns the code
+// and state the user comes back with.
+func authorize(t *testing.T, provider *oidc.Provider, state, nonce, verifier string) (string, string) {
+	authURL, err := provider.AuthCodeURL(context.Background(), state, nonce, verifier)
+	assert.NilError(t, err)
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(authURL)
+	assert.NilError(t, err)
+	res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusFound)
+
+	location, err := url.Parse(res.Header.Get("Location"))
+	assert.NilError(t, err)
+	assert.Equal(t, location.Scheme+"://"+location.Host+location.Path, redirectURL)
+
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestLogin(t *testing.T) {
+	server, provider := newProvider(t)
+
+	code, state := authorize(t, provider, "the-state", "the-nonce", "the-verifier")
+	assert.Equal(t, state, "the-state")
+
+	claims, err := provider.Exchange(context.Background(), code, "the-verifier", "the-nonce")
+	assert.NilError(t, err)
+	assert.Equal(t, claims.Issuer, server.Issuer())
+	assert.Equal(t, claims.Subject, server.User.Subject)
+	assert.Equal(t, claims.Email, server.User.Email)
+	assert.Equal(t, claims.EmailVerified, true)
+	assert.Equal(t, claims.Name, server.User.Name)
+
+	// the codes can only be used once.
+	_, err = provider.Exchange(context.Background(), code, "the-verifier", "the-nonce")
+	assert.Equal(t, err != nil, true)
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	_, provider := newProvider(t)
+
+	code, _ := authorize(t, provider, "the-state", "the-nonce", "the-verifier")
+
+	_, err := provider.Exchange(context.Background(), code, "another-verifier", "the-nonce")
+	assert.Equal(t, err != nil, true)
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	server, provider := newProvider(t)
+
+	valid := func() map[string]any {
+		now := time.Now()
+		return map[string]any{
+			"iss":            server.Issuer(),
+			"sub":            "248289761001",
+			"aud":            "snippetbox",
+			"exp":            now.Add(5 * time.Minute).Unix(),
+			"iat":            now.Unix(),
+			"nonce":          "the-nonce",
+			"email":          "sso@snippetbox.sh",
+			"email_verified": "true",
+		}
+	}
+
+	tests := []struct {
+		name   string
+		claims func(claims map[string]any)
+		token  func(token string) string
+		valid  bool
+	}{
+		{
+			name:   "Valid",
+			claims: func(claims map[string]any) {},
+			valid:  true,
+		},
+		{
+			name: "Several audiences",
+			claims: func(claims map[string]any) {
+				claims["aud"] = []string{"another", "snippetbox"}
+				claims["azp"] = "snippetbox"
+			},
+			valid: true,
+		},
+		{
+			name: "Other issuer",
+			claims: func(claims map[string]any) {
+				claims["iss"] = "https://evil.example.com"
+			},
+		},
+		{
+			name: "Other audience",
+			claims: func(claims map[string]any) {
+				claims["aud"] = "another"
+			},
+		},
+		{
+			name: "Authorized for another client",
+			claims: func(claims map[string]any) {
+				claims["aud"] = []string{"another", "snippetbox"}
+				claims["azp"] = "another"
+			},
+		},
+		{
+			name: "Expired",
+			claims: func(claims map[string]any) {
+				claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			},
+		},
+		{
+			name: "Issued in the future",
+			claims: func(claims map[string]any) {
+				claims["iat"] = time.Now().Add(time.Hour).Unix()
+			},
+		},
+		{
+			name: "Wrong nonce",
+			claims: func(claims map[string]any) {
+				claims["nonce"] = "another-nonce"
+			},
+		},
+		{
+			name: "No subject",
+			claims: func(claims map[string]any) {
+				delete(claims, "sub")
+			},
+		},
+		{
+			name:   "Bad signature",
+			claims: func(claims map[string]any) {},
+			token: func(token string) string {
+				return token[:len(token)-4] + "AAAA"
+			},
+		},
+		{
+			name:   "Unsigned",
+			claims: func(claims map[string]any) {},
+			token: func(token string) string {
+				header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"test-key"}`))
+				return header + "." + strings.Split(token, ".")[1] + "."
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.claims(claims)
+
+			token := server.Sign(claims)
+			if tt.token != nil {
+				token = tt.token(token)
+			}
+
+			_, err := provider.VerifyIDToken(context.Background(), token, "the-nonce")
+			if tt.valid {
+				assert.NilError(t, err)
+			} else {
+				assert.Equal(t, errors.Is(err, oidc.ErrInvalidToken), true)
+			}
+		})
+	}
+}
+
+func TestDiscoveryOtherIssuer(t *testing.T) {
+	server, _ := newProvider(t)
+
+	// the discovery is at the same URL, but the provider says it's another
+	// issuer.
+	provider := &oidc.Provider{
+		Issuer:      server.Issuer() + "/",
+		ClientID:    "snippetbox",
+		RedirectURL: redirectURL,
+	}
+
+	_, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	assert.Equal(t, err != nil, true)
+}
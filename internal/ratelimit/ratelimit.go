@@ -0,0 +1,171 @@
+// Package ratelimit limits how often a key, like a client IP, can do
+// something, with token buckets.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit allows Burst events at once, refilled at Burst per Period. The zero
+// value doesn't limit anything.
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// ParseLimit parses a limit written as "<burst>/<period>", for example
+// "10/1m", or "off" for no limit.
+func ParseLimit(s string) (Limit, error) {
+	if s == "off" {
+		return Limit{}, nil
+	}
+
+	burst, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q, want <burst>/<period> or off", s)
+	}
+
+	n, err := strconv.Atoi(burst)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q, the burst must be a positive number", s)
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q, the period must be a positive duration", s)
+	}
+
+	return Limit{Burst: n, Period: d}, nil
+}
+
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+
+	return strconv.Itoa(l.Burst) + "/" + l.Period.String()
+}
+
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Limit) UnmarshalText(text []byte) error {
+	limit, err := ParseLimit(string(text))
+	if err != nil {
+		return err
+	}
+
+	*l = limit
+	return nil
+}
+
+func (l Limit) Enabled() bool {
+	return l.Burst > 0 && l.Period > 0
+}
+
+// interval is how long it takes to refill one token.
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Burst)
+}
+
+// Store keeps the buckets. Allow takes a token from the bucket of key and
+// reports whether there was one, or how long until there is one. MemoryStore
+// keeps them in the process, a store shared by several servers can be added
+// behind the same interface.
+type Store interface {
+	Allow(ctx context.Context, key string, limit Limit) (ok bool, retryAfter time.Duration, err error)
+}
+
+// MemoryStore is a Store for a single process. The zero value is ready to
+// use and safe for concurrent use.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	// now is replaced in the tests.
+	now func() time.Time
+}
+
+// bucket stores when the bucket is full again rather than its tokens, which
+// is all that's needed to refill it lazily.
+type bucket struct {
+	full  time.Time
+	limit Limit
+}
+
+func (s *MemoryStore) Allow(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	if !limit.Enabled() {
+		return true, 0, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock()
+	if s.buckets == nil {
+		s.buckets = map[string]*bucket{}
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{full: now, limit: limit}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	if b.full.Before(now) {
+		b.full = now
+	}
+
+	// taking a token pushes the time the bucket is full again by one
+	// interval. The bucket is empty when that's more than a whole period
+	// away.
+	full := b.full.Add(limit.interval())
+	if full.Sub(now) > limit.Period {
+		retryAfter := full.Sub(now) - limit.Period
+		return false, retryAfter, nil
+	}
+
+	b.full = full
+	return true, 0, nil
+}
+
+// Cleanup removes the buckets that are full, they are the same as no bucket.
+func (s *MemoryStore) Cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock()
+	for key, b := range s.buckets {
+		if !b.full.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// Len returns the number of buckets in the store.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.buckets)
+}
+
+func (s *MemoryStore) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+
+	return time.Now()
+}
+
+// RetryAfterSeconds rounds d up to whole seconds for the Retry-After header,
+// which doesn't take fractions.
+func RetryAfterSeconds(d time.Duration) int {
+	return int(math.Max(1, math.Ceil(d.Seconds())))
+}
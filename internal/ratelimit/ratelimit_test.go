@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{value: "10/1m", want: Limit{Burst: 10, Period: time.Minute}},
+		{value: "off", want: Limit{}},
+		{value: "10", wantErr: true},
+		{value: "0/1m", wantErr: true},
+		{value: "10/0s", wantErr: true},
+		{value: "ten/1m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			limit, err := ParseLimit(tt.value)
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, limit, tt.want)
+
+			if !tt.wantErr {
+				parsed, err := ParseLimit(limit.String())
+				assert.NilError(t, err)
+				assert.Equal(t, parsed, limit)
+			}
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &MemoryStore{now: func() time.Time { return now }}
+	limit := Limit{Burst: 3, Period: 3 * time.Second}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		ok, _, err := store.Allow(ctx, "ip:192.0.2.1", limit)
+		assert.NilError(t, err)
+		assert.Equal(t, ok, true)
+	}
+
+	ok, retryAfter, err := store.Allow(ctx, "ip:192.0.2.1", limit)
+	assert.NilError(t, err)
+	assert.Equal(t, ok, false)
+	assert.Equal(t, retryAfter, time.Second)
+
+	// the other keys have their own buckets.
+	ok, _, _ = store.Allow(ctx, "ip:192.0.2.2", limit)
+	assert.Equal(t, ok, true)
+
+	// one token is back after a second.
+	now = now.Add(time.Second)
+	ok, _, _ = store.Allow(ctx, "ip:192.0.2.1", limit)
+	assert.Equal(t, ok, true)
+	ok, _, _ = store.Allow(ctx, "ip:192.0.2.1", limit)
+	assert.Equal(t, ok, false)
+
+	t.Run("Disabled", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			ok, _, _ := store.Allow(ctx, "ip:192.0.2.3", Limit{})
+			assert.Equal(t, ok, true)
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		assert.Equal(t, store.Len(), 2)
+
+		now = now.Add(3 * time.Second)
+		store.Cleanup()
+		assert.Equal(t, store.Len(), 0)
+	})
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	assert.Equal(t, RetryAfterSeconds(100*time.Millisecond), 1)
+	assert.Equal(t, RetryAfterSeconds(2*time.Second), 2)
+	assert.Equal(t, RetryAfterSeconds(2100*time.Millisecond), 3)
+}
@@ -0,0 +1,67 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+// the SHA1 test vectors of RFC 6238, cut to six digits.
+func TestCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		time int64
+		want string
+	}{
+		{time: 59, want: "287082"},
+		{time: 1111111109, want: "081804"},
+		{time: 1111111111, want: "050471"},
+		{time: 1234567890, want: "005924"},
+		{time: 2000000000, want: "279037"},
+		{time: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		code, err := Code(secret, time.Unix(tt.time, 0))
+		assert.NilError(t, err)
+		assert.Equal(t, code, tt.want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := NewSecret()
+	assert.NilError(t, err)
+
+	now := time.Now()
+	code, err := Code(secret, now)
+	assert.NilError(t, err)
+
+	counter, ok := Validate(secret, code, now)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, counter, Counter(now))
+
+	// the previous code still works for one period.
+	_, ok = Validate(secret, code, now.Add(Period))
+	assert.Equal(t, ok, true)
+
+	_, ok = Validate(secret, code, now.Add(3*Period))
+	assert.Equal(t, ok, false)
+
+	_, ok = Validate(secret, "12345", now)
+	assert.Equal(t, ok, false)
+
+	_, ok = Validate("not base32!", code, now)
+	assert.Equal(t, ok, false)
+}
+
+func TestURL(t *testing.T) {
+	got := URL("Snippetbox", "jane@snippetbox.sh", "JBSWY3DPEHPK3PXP")
+
+	assert.Equal(t, strings.HasPrefix(got, "otpauth://totp/Snippetbox:jane@snippetbox.sh?"), true)
+	assert.StringContains(t, got, "secret=JBSWY3DPEHPK3PXP")
+	assert.StringContains(t, got, "issuer=Snippetbox")
+}
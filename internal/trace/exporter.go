@@ -0,0 +1,75 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// WriterExporter writes the spans to a writer as JSON, one per line, which
+// is enough to look at traces locally.
+type WriterExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	c   io.Closer
+}
+
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{enc: json.NewEncoder(w)}
+}
+
+// NewFileExporter appends the spans to the file at path.
+func NewFileExporter(path string) (*WriterExporter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	e := NewWriterExporter(f)
+	e.c = f
+	return e, nil
+}
+
+type jsonSpan struct {
+	TraceID    string         `json:"trace_id"`
+	SpanID     string         `json:"span_id"`
+	ParentID   string         `json:"parent_id,omitempty"`
+	Name       string         `json:"name"`
+	Start      time.Time      `json:"start"`
+	DurationMS float64        `json:"duration_ms"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+func (e *WriterExporter) ExportSpan(data SpanData) error {
+	span := jsonSpan{
+		TraceID:    data.TraceID.String(),
+		SpanID:     data.SpanID.String(),
+		Name:       data.Name,
+		Start:      data.Start.UTC(),
+		DurationMS: float64(data.End.Sub(data.Start).Microseconds()) / 1000,
+		Attributes: data.Attributes,
+	}
+	if data.ParentID.IsValid() {
+		span.ParentID = data.ParentID.String()
+	}
+	if data.Err != nil {
+		span.Error = data.Err.Error()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.enc.Encode(span)
+}
+
+// Close closes the file of an exporter made by NewFileExporter.
+func (e *WriterExporter) Close() error {
+	if e.c == nil {
+		return nil
+	}
+
+	return e.c.Close()
+}
@@ -0,0 +1,76 @@
+package trace
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// TraceparentHeader is the header of the W3C Trace Context specification,
+// https://www.w3.org/TR/trace-context/.
+const TraceparentHeader = "traceparent"
+
+const sampledFlag = 0x01
+
+// ParseTraceparent parses a traceparent header value. It only accepts the
+// version 00 format, with a version above it the fields it knows about are
+// still read, as the specification asks.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return sc, false
+	}
+
+	version, ok := decodeHex(parts[0], 1)
+	if !ok || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return sc, false
+	}
+
+	traceID, ok := decodeHex(parts[1], len(sc.TraceID))
+	if !ok {
+		return sc, false
+	}
+	spanID, ok := decodeHex(parts[2], len(sc.SpanID))
+	if !ok {
+		return sc, false
+	}
+	flags, ok := decodeHex(parts[3], 1)
+	if !ok {
+		return sc, false
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Sampled = flags[0]&sampledFlag != 0
+
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+
+	return sc, true
+}
+
+// Traceparent returns the traceparent header value of sc.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// decodeHex decodes s, which must be n bytes of lowercase hex.
+func decodeHex(s string, n int) ([]byte, bool) {
+	if len(s) != n*2 || strings.ToLower(s) != s {
+		return nil, false
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, false
+	}
+
+	return b, true
+}
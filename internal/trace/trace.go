@@ -0,0 +1,241 @@
+// Package trace records spans of work, like HTTP requests and SQL queries,
+// and propagates them between services with the W3C traceparent header. It
+// follows the OpenTelemetry model in a much smaller API.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext identifies a span across services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Exporter receives the spans when they end. ExportSpan is called from the
+// goroutine that ended the span, so it must be safe for concurrent use.
+type Exporter interface {
+	ExportSpan(data SpanData) error
+}
+
+// SpanData is what an exporter receives of an ended span.
+type SpanData struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	ParentID   SpanID
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]any
+	Err        error
+}
+
+// Tracer starts the root spans of a service and sends the ended spans to its
+// exporter.
+type Tracer struct {
+	exporter Exporter
+	// OnError is called with the errors of the exporter. They are ignored
+	// when it's nil.
+	OnError func(error)
+}
+
+// NewTracer returns a tracer exporting to exporter. A nil tracer is valid and
+// doesn't record anything.
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// Start starts a span named name as a child of the span or the remote span
+// context in ctx, or as the root of a new trace.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	var parent SpanContext
+	if span := SpanFromContext(ctx); span != nil {
+		parent = span.context
+	} else if remote, ok := ctx.Value(remoteContextKey{}).(SpanContext); ok {
+		parent = remote
+	}
+
+	span := &Span{tracer: t, name: name, start: time.Now(), parent: parent.SpanID}
+	span.context.SpanID = newSpanID()
+	span.context.Sampled = true
+
+	if parent.IsValid() {
+		span.context.TraceID = parent.TraceID
+		span.context.Sampled = parent.Sampled
+	} else {
+		span.context.TraceID = newTraceID()
+	}
+
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// Start starts a span as a child of the span in ctx, with the same tracer. It
+// doesn't record anything when ctx has no span, so code called outside of a
+// traced request, like the tests, isn't traced.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+
+	return parent.tracer.Start(ctx, name)
+}
+
+// Span is a timed piece of work. A nil span is valid and records nothing.
+type Span struct {
+	tracer  *Tracer
+	name    string
+	context SpanContext
+	parent  SpanID
+	start   time.Time
+
+	mu    sync.Mutex
+	attrs map[string]any
+	err   error
+	ended bool
+}
+
+// SpanContext returns the identifiers of the span, to propagate it.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+
+	return s.context
+}
+
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.attrs == nil {
+		s.attrs = map[string]any{}
+	}
+	s.attrs[key] = value
+}
+
+// SetError marks the span as failed with err. A nil err is ignored, so it can
+// be deferred with the error returned by the function.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// End ends the span and exports it if it's sampled. Only the first call has
+// any effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	end := time.Now()
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+
+	data := SpanData{
+		TraceID:    s.context.TraceID,
+		SpanID:     s.context.SpanID,
+		ParentID:   s.parent,
+		Name:       s.name,
+		Start:      s.start,
+		End:        end,
+		Attributes: s.attrs,
+		Err:        s.err,
+	}
+	s.mu.Unlock()
+
+	if !s.context.Sampled || s.tracer.exporter == nil {
+		return
+	}
+
+	err := s.tracer.exporter.ExportSpan(data)
+	if err != nil && s.tracer.OnError != nil {
+		s.tracer.OnError(fmt.Errorf("exporting span %s: %w", data.Name, err))
+	}
+}
+
+type spanContextKey struct{}
+
+type remoteContextKey struct{}
+
+// SpanFromContext returns the span in ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteSpanContext returns a copy of ctx where the next span
+// started is a child of sc, a span of another service.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteContextKey{}, sc)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	randomID(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	randomID(id[:])
+	return id
+}
+
+func randomID(b []byte) {
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+}
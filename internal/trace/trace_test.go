@@ -0,0 +1,136 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (e *recordingExporter) ExportSpan(data SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, data)
+	return nil
+}
+
+func TestTracer(t *testing.T) {
+	exporter := &recordingExporter{}
+	tracer := NewTracer(exporter)
+
+	ctx, root := tracer.Start(context.Background(), "GET /")
+	_, child := Start(ctx, "SnippetModel.Latest")
+	child.SetAttribute("rows", 3)
+	child.SetError(errors.New("boom"))
+	child.End()
+	child.End()
+	root.End()
+
+	assert.Equal(t, len(exporter.spans), 2)
+
+	c, r := exporter.spans[0], exporter.spans[1]
+	assert.Equal(t, c.Name, "SnippetModel.Latest")
+	assert.Equal(t, c.TraceID, r.TraceID)
+	assert.Equal(t, c.ParentID, r.SpanID)
+	assert.Equal(t, c.Attributes["rows"], any(3))
+	assert.Equal(t, c.Err.Error(), "boom")
+	assert.Equal(t, r.ParentID.IsValid(), false)
+
+	t.Run("Without a span", func(t *testing.T) {
+		ctx, span := Start(context.Background(), "SnippetModel.Get")
+		assert.Equal(t, span == nil, true)
+		assert.Equal(t, SpanFromContext(ctx) == nil, true)
+
+		span.SetAttribute("id", 1)
+		span.End()
+	})
+
+	t.Run("Nil tracer", func(t *testing.T) {
+		var tracer *Tracer
+		_, span := tracer.Start(context.Background(), "GET /")
+		assert.Equal(t, span == nil, true)
+	})
+
+	t.Run("Remote parent", func(t *testing.T) {
+		remote, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+		assert.Equal(t, ok, true)
+
+		exporter.spans = nil
+		ctx := ContextWithRemoteSpanContext(context.Background(), remote)
+		_, span := tracer.Start(ctx, "GET /")
+		span.End()
+
+		assert.Equal(t, span.SpanContext().TraceID, remote.TraceID)
+		// the caller didn't sample the trace, so it isn't exported.
+		assert.Equal(t, len(exporter.spans), 0)
+	})
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		wantOK bool
+	}{
+		{name: "Valid", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantOK: true},
+		{name: "Future version", header: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", wantOK: true},
+		{name: "Version 00 with extra field", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{name: "Invalid version", header: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "Zero trace ID", header: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "Zero span ID", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{name: "Uppercase", header: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{name: "Short trace ID", header: "00-4bf92f3577b34da6-00f067aa0ba902b7-01"},
+		{name: "Empty", header: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tt.header)
+			assert.Equal(t, ok, tt.wantOK)
+
+			if ok {
+				assert.Equal(t, sc.TraceID.String(), "4bf92f3577b34da6a3ce929d0e0e4736")
+				assert.Equal(t, sc.SpanID.String(), "00f067aa0ba902b7")
+				assert.Equal(t, sc.Sampled, true)
+				assert.Equal(t, sc.Traceparent(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+			}
+		})
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewTracer(NewWriterExporter(&buf))
+
+	ctx, root := tracer.Start(context.Background(), "GET /")
+	_, child := Start(ctx, "template home.go.html")
+	child.End()
+	root.SetAttribute("http.status_code", 200)
+	root.End()
+
+	dec := json.NewDecoder(&buf)
+
+	var spans []map[string]any
+	for dec.More() {
+		var span map[string]any
+		err := dec.Decode(&span)
+		assert.NilError(t, err)
+		spans = append(spans, span)
+	}
+
+	assert.Equal(t, len(spans), 2)
+	assert.Equal(t, spans[0]["name"], any("template home.go.html"))
+	assert.Equal(t, spans[0]["parent_id"], any(root.SpanContext().SpanID.String()))
+	assert.Equal(t, spans[1]["trace_id"], any(root.SpanContext().TraceID.String()))
+	assert.Equal(t, spans[1]["attributes"].(map[string]any)["http.status_code"], any(200.0))
+}
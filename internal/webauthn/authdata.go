@@ -0,0 +1,76 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// The flags of the authenticator data.
+const (
+	flagUserPresent       = 0x01
+	flagUserVerified      = 0x04
+	flagAttestedData      = 0x40
+	flagExtensionIncluded = 0x80
+)
+
+var errAuthenticatorData = errors.New("webauthn: malformed authenticator data")
+
+// authenticatorData is the data the authenticators sign, with the key of a
+// new credential when registering one.
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	// publicKey is the COSE_Key of the new credential.
+	publicKey []byte
+}
+
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errAuthenticatorData
+	}
+
+	ad := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if ad.flags&flagAttestedData != 0 {
+		// the AAGUID of the authenticator model, then the credential ID.
+		if len(rest) < 18 {
+			return nil, errAuthenticatorData
+		}
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength > 1023 || len(rest) < idLength {
+			return nil, errAuthenticatorData
+		}
+		ad.credentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		// the key is CBOR too, its length is only known by decoding it.
+		_, afterKey, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, errAuthenticatorData
+		}
+		ad.publicKey = rest[:len(rest)-len(afterKey)]
+		rest = afterKey
+	}
+
+	if ad.flags&flagExtensionIncluded != 0 {
+		_, afterExtensions, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, errAuthenticatorData
+		}
+		rest = afterExtensions
+	}
+
+	if len(rest) != 0 {
+		return nil, errAuthenticatorData
+	}
+
+	return ad, nil
+}
@@ -0,0 +1,71 @@
+package webauthn
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+// the examples of RFC 8949 appendix A the authenticators can send.
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want any
+	}{
+		{hex: "00", want: int64(0)},
+		{hex: "17", want: int64(23)},
+		{hex: "1818", want: int64(24)},
+		{hex: "1903e8", want: int64(1000)},
+		{hex: "1a000f4240", want: int64(1000000)},
+		{hex: "20", want: int64(-1)},
+		{hex: "3903e7", want: int64(-1000)},
+		{hex: "f93c00", want: float64(1)},
+		{hex: "f4", want: false},
+		{hex: "f5", want: true},
+		{hex: "f6", want: nil},
+		{hex: "4401020304", want: []byte{1, 2, 3, 4}},
+		{hex: "6449455446", want: "IETF"},
+		{hex: "83010203", want: []any{int64(1), int64(2), int64(3)}},
+		{hex: "a201020304", want: map[any]any{int64(1): int64(2), int64(3): int64(4)}},
+		{hex: "a26161016162820203", want: map[any]any{"a": int64(1), "b": []any{int64(2), int64(3)}}},
+		{hex: "c11a514b67b0", want: int64(1363896240)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hex, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.hex)
+			assert.NilError(t, err)
+
+			got, rest, err := decodeCBOR(append(data, 0xff))
+			assert.NilError(t, err)
+			assert.Equal(t, reflect.DeepEqual(got, tt.want), true)
+			assert.Equal(t, len(rest), 1)
+		})
+	}
+}
+
+func TestDecodeCBORErrors(t *testing.T) {
+	tests := []string{
+		"",
+		// truncated
+		"19",
+		"44010203",
+		"830102",
+		// indefinite length
+		"5f42010243030405ff",
+		// a map key that's neither an integer nor a text string
+		"a14101f6",
+		// a length longer than the data
+		"9bffffffffffffffff",
+	}
+
+	for _, tt := range tests {
+		data, err := hex.DecodeString(tt)
+		assert.NilError(t, err)
+
+		_, _, err = decodeCBOR(data)
+		assert.Equal(t, err, errCBOR)
+	}
+}
@@ -0,0 +1,97 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// The COSE algorithms of the credential keys that are supported, the ones
+// the authenticators use in practice.
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// The sizes of the RSA moduli accepted. The verification time grows with the
+// size, so the keys larger than any authenticator uses are refused.
+const (
+	minRSABits = 2048
+	maxRSABits = 4096
+)
+
+var errPublicKey = errors.New("webauthn: unsupported or malformed public key")
+
+// publicKey is a credential public key decoded from its COSE_Key encoding.
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+func parsePublicKey(cose []byte) (*publicKey, error) {
+	decoded, rest, err := decodeCBOR(cose)
+	if err != nil {
+		return nil, err
+	}
+	params, ok := decoded.(map[any]any)
+	if !ok || len(rest) != 0 {
+		return nil, errPublicKey
+	}
+
+	kty, _ := params[int64(1)].(int64)
+	alg, _ := params[int64(3)].(int64)
+	crv, _ := params[int64(-1)].(int64)
+
+	switch {
+	case kty == 2 && alg == AlgES256 && crv == 1:
+		x, _ := params[int64(-2)].([]byte)
+		y, _ := params[int64(-3)].([]byte)
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errPublicKey
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errPublicKey
+		}
+		return &publicKey{alg: alg, key: key}, nil
+	case kty == 1 && alg == AlgEdDSA && crv == 6:
+		x, _ := params[int64(-2)].([]byte)
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errPublicKey
+		}
+		return &publicKey{alg: alg, key: ed25519.PublicKey(x)}, nil
+	case kty == 3 && alg == AlgRS256:
+		n, _ := params[int64(-1)].([]byte)
+		e, _ := params[int64(-2)].([]byte)
+		modulus := new(big.Int).SetBytes(n)
+		if modulus.BitLen() < minRSABits || modulus.BitLen() > maxRSABits || len(e) == 0 || len(e) > 4 {
+			return nil, errPublicKey
+		}
+		exponent := new(big.Int).SetBytes(e)
+		return &publicKey{alg: alg, key: &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}}, nil
+	}
+
+	return nil, errPublicKey
+}
+
+// verify checks the signature of data by the key.
+func (pk *publicKey) verify(data, signature []byte) bool {
+	switch key := pk.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, data, signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	}
+
+	return false
+}
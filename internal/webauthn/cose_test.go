@@ -0,0 +1,52 @@
+package webauthn
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+)
+
+// rsaCOSEKey returns the COSE_Key of an RS256 key with a modulus of the bits
+// and the exponent 65537.
+func rsaCOSEKey(bits int) []byte {
+	n := append([]byte{0x80}, bytes.Repeat([]byte{0x01}, (bits+7)/8-1)...)
+	n[0] >>= (8 - bits%8) % 8
+
+	key := []byte{
+		0xa4,
+		0x01, 0x03, // kty: RSA
+		0x03, 0x39, 0x01, 0x00, // alg: RS256
+		0x20, 0x59, byte(len(n) >> 8), byte(len(n)), // n
+	}
+	key = append(key, n...)
+
+	return append(key, 0x21, 0x43, 0x01, 0x00, 0x01) // e
+}
+
+func TestParsePublicKeyRSA(t *testing.T) {
+	tests := []struct {
+		name    string
+		bits    int
+		wantErr error
+	}{
+		{name: "2048 bits", bits: 2048},
+		{name: "4096 bits", bits: 4096},
+		{name: "Too small", bits: 2047, wantErr: errPublicKey},
+		{name: "Too large", bits: 4097, wantErr: errPublicKey},
+		{name: "Much too large", bits: 16384, wantErr: errPublicKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := parsePublicKey(rsaCOSEKey(tt.bits))
+			if tt.wantErr != nil {
+				assert.Equal(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, key.alg, int64(AlgRS256))
+		})
+	}
+}
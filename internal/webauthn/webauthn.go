@@ -0,0 +1,314 @@
+// Package webauthn implements the relying party side of the WebAuthn
+// ceremonies registering passkeys and logging in with them.
+//
+// It asks the authenticators for no attestation, so it doesn't check which
+// make of authenticator a credential comes from, and it always asks for user
+// verification, so a passkey is a second factor on its own.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timeout is how long the browsers give the users to touch their
+// authenticator.
+const Timeout = 5 * time.Minute
+
+var (
+	// ErrVerification is wrapped by the errors of the responses that don't
+	// verify, saying what's wrong with them.
+	ErrVerification = errors.New("webauthn: verification failed")
+	// ErrSignCount is returned for an assertion whose signature counter
+	// didn't go up, which happens when the authenticator was cloned.
+	ErrSignCount = fmt.Errorf("%w: signature counter didn't increase", ErrVerification)
+)
+
+// Base64URL is binary data encoded in JSON the way the browsers' toJSON
+// methods of the credentials encode it.
+type Base64URL []byte
+
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+
+	return nil
+}
+
+// NewChallenge returns a random challenge for a ceremony. It has to be kept
+// on the server until the response comes back, and only used once.
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, 32)
+	_, err := rand.Read(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// RelyingParty is the website the credentials are for.
+type RelyingParty struct {
+	// ID is the domain of the website, like snippetbox.sh.
+	ID   string
+	Name string
+	// Origin is where the pages asking for the credentials are served from,
+	// like https://snippetbox.sh.
+	Origin string
+}
+
+// User is the account a credential is registered for. ID is sent back when
+// logging in with the credential, so it can't be personal data like the
+// email address.
+type User struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+type relyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type userEntity struct {
+	ID          Base64URL `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type credentialDescriptor struct {
+	Type string    `json:"type"`
+	ID   Base64URL `json:"id"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions are the options of navigator.credentials.create, in the
+// JSON of PublicKeyCredential.parseCreationOptionsFromJSON.
+type CreationOptions struct {
+	Challenge              Base64URL              `json:"challenge"`
+	RP                     relyingPartyEntity     `json:"rp"`
+	User                   userEntity             `json:"user"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []credentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// CreationOptions returns the options to register a passkey for the user.
+// The credentials in exclude are the ones they registered already, so the
+// same authenticator isn't registered twice.
+func (rp *RelyingParty) CreationOptions(challenge []byte, user User, exclude [][]byte) *CreationOptions {
+	options := &CreationOptions{
+		Challenge: challenge,
+		RP:        relyingPartyEntity{ID: rp.ID, Name: rp.Name},
+		User:      userEntity{ID: user.ID, Name: user.Name, DisplayName: user.DisplayName},
+		PubKeyCredParams: []credentialParameter{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:                Timeout.Milliseconds(),
+		ExcludeCredentials:     []credentialDescriptor{},
+		AuthenticatorSelection: authenticatorSelection{ResidentKey: "required", UserVerification: "required"},
+		Attestation:            "none",
+	}
+
+	for _, id := range exclude {
+		options.ExcludeCredentials = append(options.ExcludeCredentials, credentialDescriptor{Type: "public-key", ID: id})
+	}
+
+	return options
+}
+
+// RequestOptions are the options of navigator.credentials.get, in the JSON
+// of PublicKeyCredential.parseRequestOptionsFromJSON.
+type RequestOptions struct {
+	Challenge        Base64URL `json:"challenge"`
+	RPID             string    `json:"rpId"`
+	Timeout          int64     `json:"timeout"`
+	UserVerification string    `json:"userVerification"`
+}
+
+// RequestOptions returns the options to log in with a passkey. They don't
+// say which credentials are allowed, the users pick one of theirs.
+func (rp *RelyingParty) RequestOptions(challenge []byte) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        challenge,
+		RPID:             rp.ID,
+		Timeout:          Timeout.Milliseconds(),
+		UserVerification: "required",
+	}
+}
+
+// AttestationResponse is the response of navigator.credentials.create.
+type AttestationResponse struct {
+	ClientDataJSON    Base64URL `json:"clientDataJSON"`
+	AttestationObject Base64URL `json:"attestationObject"`
+}
+
+// AssertionResponse is the response of navigator.credentials.get.
+type AssertionResponse struct {
+	ClientDataJSON    Base64URL `json:"clientDataJSON"`
+	AuthenticatorData Base64URL `json:"authenticatorData"`
+	Signature         Base64URL `json:"signature"`
+	UserHandle        Base64URL `json:"userHandle"`
+}
+
+// Credential is what's kept of a registered credential to check the
+// assertions made with it.
+type Credential struct {
+	ID []byte
+	// PublicKey is the COSE_Key of the credential.
+	PublicKey []byte
+	SignCount uint32
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// VerifyRegistration checks the response registering a credential for the
+// challenge and returns the credential.
+func (rp *RelyingParty) VerifyRegistration(challenge []byte, response *AttestationResponse) (*Credential, error) {
+	err := rp.verifyClientData(response.ClientDataJSON, "webauthn.create", challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, rest, err := decodeCBOR(response.AttestationObject)
+	if err != nil || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrVerification)
+	}
+	attestation, _ := decoded.(map[any]any)
+	rawAuthData, _ := attestation["authData"].([]byte)
+
+	authData, err := rp.verifyAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.credentialID == nil {
+		return nil, fmt.Errorf("%w: no credential in the authenticator data", ErrVerification)
+	}
+
+	_, err = parsePublicKey(authData.publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrVerification, err)
+	}
+
+	return &Credential{
+		ID:        append([]byte(nil), authData.credentialID...),
+		PublicKey: append([]byte(nil), authData.publicKey...),
+		SignCount: authData.signCount,
+	}, nil
+}
+
+// VerifyAssertion checks the response logging in with the credential for
+// the challenge, and returns the signature counter to store for the
+// credential. The caller checks the user handle belongs to the user of the
+// credential.
+func (rp *RelyingParty) VerifyAssertion(challenge []byte, credential *Credential, response *AssertionResponse) (uint32, error) {
+	err := rp.verifyClientData(response.ClientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+
+	authData, err := rp.verifyAuthenticatorData(response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := parsePublicKey(credential.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	clientDataHash := sha256.Sum256(response.ClientDataJSON)
+	signed := append(append([]byte(nil), response.AuthenticatorData...), clientDataHash[:]...)
+	if !key.verify(signed, response.Signature) {
+		return 0, fmt.Errorf("%w: bad signature", ErrVerification)
+	}
+
+	// the authenticators that don't count, like most synced passkeys, always
+	// send zero.
+	if (authData.signCount != 0 || credential.SignCount != 0) && authData.signCount <= credential.SignCount {
+		return 0, ErrSignCount
+	}
+
+	return authData.signCount, nil
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var data clientData
+	err := json.Unmarshal(raw, &data)
+	if err != nil {
+		return fmt.Errorf("%w: malformed client data", ErrVerification)
+	}
+
+	if data.Type != ceremony {
+		return fmt.Errorf("%w: client data for %q", ErrVerification, data.Type)
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(data.Challenge, "="))
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return fmt.Errorf("%w: wrong challenge", ErrVerification)
+	}
+
+	if data.Origin != rp.Origin || data.CrossOrigin {
+		return fmt.Errorf("%w: wrong origin %q", ErrVerification, data.Origin)
+	}
+
+	return nil
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	authData, err := parseAuthenticatorData(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrVerification, err)
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return nil, fmt.Errorf("%w: credential for another website", ErrVerification)
+	}
+
+	if authData.flags&flagUserPresent == 0 || authData.flags&flagUserVerified == 0 {
+		return nil, fmt.Errorf("%w: user not verified", ErrVerification)
+	}
+
+	return authData, nil
+}
@@ -0,0 +1,138 @@
+package webauthn_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ahmadyogi543/snippetbox/internal/assert"
+	"github.com/ahmadyogi543/snippetbox/internal/webauthn"
+	"github.com/ahmadyogi543/snippetbox/internal/webauthn/webauthntest"
+)
+
+var rp = &webauthn.RelyingParty{ID: "snippetbox.sh", Name: "Snippetbox", Origin: "https://snippetbox.sh"}
+
+// register registers a new credential of the authenticator.
+func register(t *testing.T, authenticator *webauthntest.Authenticator) *webauthn.Credential {
+	challenge, err := webauthn.NewChallenge()
+	assert.NilError(t, err)
+
+	options := rp.CreationOptions(challenge, webauthn.User{ID: []byte("1"), Name: "jane@snippetbox.sh", DisplayName: "Jane"}, nil)
+	response, err := authenticator.Create(options)
+	assert.NilError(t, err)
+
+	credential, err := rp.VerifyRegistration(challenge, response)
+	assert.NilError(t, err)
+
+	return credential
+}
+
+func TestRegistration(t *testing.T) {
+	authenticator, err := webauthntest.New(rp.ID, rp.Origin)
+	assert.NilError(t, err)
+
+	credential := register(t, authenticator)
+	assert.Equal(t, string(credential.ID), string(authenticator.CredentialID))
+	assert.Equal(t, credential.SignCount, uint32(0))
+
+	tests := []struct {
+		name          string
+		authenticator func(a *webauthntest.Authenticator)
+		challenge     []byte
+	}{
+		{
+			name:          "Wrong origin",
+			authenticator: func(a *webauthntest.Authenticator) { a.Origin = "https://evil.example" },
+		},
+		{
+			name:          "Wrong website",
+			authenticator: func(a *webauthntest.Authenticator) { a.RPID = "evil.example" },
+		},
+		{
+			name:          "User not verified",
+			authenticator: func(a *webauthntest.Authenticator) { a.SkipUserVerification = true },
+		},
+		{
+			name:      "Wrong challenge",
+			challenge: []byte("another challenge"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator, err := webauthntest.New(rp.ID, rp.Origin)
+			assert.NilError(t, err)
+			if tt.authenticator != nil {
+				tt.authenticator(authenticator)
+			}
+
+			challenge, err := webauthn.NewChallenge()
+			assert.NilError(t, err)
+			response, err := authenticator.Create(rp.CreationOptions(challenge, webauthn.User{ID: []byte("1")}, nil))
+			assert.NilError(t, err)
+
+			if tt.challenge != nil {
+				challenge = tt.challenge
+			}
+			_, err = rp.VerifyRegistration(challenge, response)
+			assert.Equal(t, errors.Is(err, webauthn.ErrVerification), true)
+		})
+	}
+}
+
+func TestAssertion(t *testing.T) {
+	authenticator, err := webauthntest.New(rp.ID, rp.Origin)
+	assert.NilError(t, err)
+	credential := register(t, authenticator)
+
+	login := func() (*webauthn.AssertionResponse, []byte) {
+		challenge, err := webauthn.NewChallenge()
+		assert.NilError(t, err)
+		response, err := authenticator.Get(rp.RequestOptions(challenge))
+		assert.NilError(t, err)
+		return response, challenge
+	}
+
+	response, challenge := login()
+	assert.Equal(t, string(response.UserHandle), "1")
+
+	signCount, err := rp.VerifyAssertion(challenge, credential, response)
+	assert.NilError(t, err)
+	assert.Equal(t, signCount, uint32(1))
+	credential.SignCount = signCount
+
+	// the same response again, like someone replaying it.
+	_, err = rp.VerifyAssertion(challenge, credential, response)
+	assert.Equal(t, err, webauthn.ErrSignCount)
+
+	response, challenge = login()
+	_, err = rp.VerifyAssertion([]byte("another challenge"), credential, response)
+	assert.Equal(t, errors.Is(err, webauthn.ErrVerification), true)
+
+	response.Signature[len(response.Signature)-1] ^= 0xff
+	_, err = rp.VerifyAssertion(challenge, credential, response)
+	assert.Equal(t, errors.Is(err, webauthn.ErrVerification), true)
+
+	// a clone of the authenticator is behind the original.
+	authenticator.SignCount = 0
+	response, challenge = login()
+	_, err = rp.VerifyAssertion(challenge, credential, response)
+	assert.Equal(t, err, webauthn.ErrSignCount)
+}
+
+func TestAssertionWithoutCounter(t *testing.T) {
+	authenticator, err := webauthntest.New(rp.ID, rp.Origin)
+	assert.NilError(t, err)
+	authenticator.FixedSignCount = true
+	credential := register(t, authenticator)
+
+	for i := 0; i < 2; i++ {
+		challenge, err := webauthn.NewChallenge()
+		assert.NilError(t, err)
+		response, err := authenticator.Get(rp.RequestOptions(challenge))
+		assert.NilError(t, err)
+
+		signCount, err := rp.VerifyAssertion(challenge, credential, response)
+		assert.NilError(t, err)
+		assert.Equal(t, signCount, uint32(0))
+	}
+}
@@ -0,0 +1,137 @@
+// Package webauthntest provides a software authenticator, to test the
+// WebAuthn ceremonies without a security key or a phone.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/ahmadyogi543/snippetbox/internal/webauthn"
+)
+
+// Authenticator holds one ES256 passkey for a website. It verifies the user
+// and counts the signatures, unless told not to.
+type Authenticator struct {
+	// RPID and Origin are the website the browser is on.
+	RPID   string
+	Origin string
+
+	CredentialID []byte
+	UserHandle   []byte
+	SignCount    uint32
+	// SkipUserVerification leaves out the user verified flag, like an
+	// authenticator without a PIN or biometrics.
+	SkipUserVerification bool
+	// FixedSignCount stops the counter, like the synced passkeys that
+	// always send zero.
+	FixedSignCount bool
+
+	key *ecdsa.PrivateKey
+}
+
+// New returns an authenticator with a new credential for the website.
+func New(rpID, origin string) (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	_, err = rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Authenticator{RPID: rpID, Origin: origin, CredentialID: id, key: key}, nil
+}
+
+// Create registers the credential for the user of the options, like
+// navigator.credentials.create.
+func (a *Authenticator) Create(options *webauthn.CreationOptions) (*webauthn.AttestationResponse, error) {
+	a.UserHandle = options.User.ID
+
+	clientDataJSON, err := a.clientData("webauthn.create", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+	publicKey := encodeCBOR(cborMap{
+		{int64(1), int64(2)},
+		{int64(3), int64(webauthn.AlgES256)},
+		{int64(-1), int64(1)},
+		{int64(-2), x},
+		{int64(-3), y},
+	})
+
+	authData := a.authenticatorData(0x40)
+	// no AAGUID, like the authenticators asked for no attestation.
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.CredentialID)))
+	authData = append(authData, a.CredentialID...)
+	authData = append(authData, publicKey...)
+
+	attestationObject := encodeCBOR(cborMap{
+		{"fmt", "none"},
+		{"attStmt", cborMap{}},
+		{"authData", authData},
+	})
+
+	return &webauthn.AttestationResponse{ClientDataJSON: clientDataJSON, AttestationObject: attestationObject}, nil
+}
+
+// Get logs in with the credential, like navigator.credentials.get.
+func (a *Authenticator) Get(options *webauthn.RequestOptions) (*webauthn.AssertionResponse, error) {
+	clientDataJSON, err := a.clientData("webauthn.get", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	if !a.FixedSignCount {
+		a.SignCount++
+	}
+	authData := a.authenticatorData(0)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &webauthn.AssertionResponse{
+		ClientDataJSON:    clientDataJSON,
+		AuthenticatorData: authData,
+		Signature:         signature,
+		UserHandle:        a.UserHandle,
+	}, nil
+}
+
+func (a *Authenticator) clientData(ceremony string, challenge []byte) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+func (a *Authenticator) authenticatorData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+
+	flags |= 0x01
+	if !a.SkipUserVerification {
+		flags |= 0x04
+	}
+
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.SignCount)
+}
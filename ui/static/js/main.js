@@ -8,3 +8,91 @@ for (let i = 0; i < navLinks.length; i++) {
     break;
   }
 }
+
+// Passkeys, only offered when the browser supports them.
+const passkey = document.querySelector(".passkey");
+
+if (passkey && window.PublicKeyCredential) {
+  passkey.hidden = false;
+
+  const base64URL = {
+    encode(buffer) {
+      const bytes = String.fromCharCode(...new Uint8Array(buffer));
+      return btoa(bytes).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+    },
+    decode(value) {
+      const base64 = value.replace(/-/g, "+").replace(/_/g, "/");
+      return Uint8Array.from(atob(base64), (c) => c.charCodeAt(0));
+    },
+  };
+
+  const showError = (message) => {
+    const error = document.getElementById("passkey-error");
+    error.textContent = message;
+    error.hidden = false;
+  };
+
+  const postJSON = async (url, body) => {
+    const response = await fetch(url, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+        "X-CSRF-Token": document.querySelector('input[name="csrf_token"]').value,
+      },
+      body: JSON.stringify(body || {}),
+    });
+    const data = await response.json();
+    if (!response.ok) {
+      throw new Error(data.error);
+    }
+    return data;
+  };
+
+  const register = document.getElementById("passkey-register");
+  if (register) {
+    register.addEventListener("click", async () => {
+      try {
+        const options = await postJSON("/account/passkeys/register/begin");
+        options.challenge = base64URL.decode(options.challenge);
+        options.user.id = base64URL.decode(options.user.id);
+        options.excludeCredentials = options.excludeCredentials.map((c) => ({ ...c, id: base64URL.decode(c.id) }));
+
+        const credential = await navigator.credentials.create({ publicKey: options });
+        const result = await postJSON("/account/passkeys/register/finish", {
+          name: document.getElementById("passkey-name").value,
+          response: {
+            clientDataJSON: base64URL.encode(credential.response.clientDataJSON),
+            attestationObject: base64URL.encode(credential.response.attestationObject),
+          },
+        });
+        window.location = result.redirect;
+      } catch (err) {
+        showError(err.message);
+      }
+    });
+  }
+
+  const login = document.getElementById("passkey-login");
+  if (login) {
+    login.addEventListener("click", async () => {
+      try {
+        const options = await postJSON("/user/login/passkey/begin");
+        options.challenge = base64URL.decode(options.challenge);
+
+        const credential = await navigator.credentials.get({ publicKey: options });
+        const result = await postJSON("/user/login/passkey/finish", {
+          id: credential.id,
+          response: {
+            clientDataJSON: base64URL.encode(credential.response.clientDataJSON),
+            authenticatorData: base64URL.encode(credential.response.authenticatorData),
+            signature: base64URL.encode(credential.response.signature),
+            userHandle: base64URL.encode(credential.response.userHandle),
+          },
+        });
+        window.location = result.redirect;
+      } catch (err) {
+        showError(err.message);
+      }
+    });
+  }
+}